@@ -0,0 +1,54 @@
+package jsondiff
+
+// CompareAny compares a against every candidate and returns the index of the
+// candidate matching best, with the result of comparing a to it like Compare.
+// It stops at the first candidate Compare reports as FullMatch. Otherwise the
+// best candidate is the one with the highest Similarity to a, the lowest
+// index among equally similar ones. Candidates that are not valid JSON are
+// only chosen if all of them are, the first one then with
+// SecondArgIsInvalidJson. If a is not valid JSON or there are no candidates,
+// the index is -1 and the Difference FirstArgIsInvalidJson or NoMatch.
+func CompareAny(a []byte, candidates [][]byte, opts *Options) (int, Difference, string) {
+	return differOf(opts).CompareAny(a, candidates)
+}
+
+// CompareAny is like the package-level CompareAny, using the options of d.
+func (d *Differ) CompareAny(a []byte, candidates [][]byte) (int, Difference, string) {
+	ctx := d.newContext(nil)
+	av, err := ctx.decode(a, 0)
+	if err != nil {
+		diff, msg, _ := invalidJSON(err, nil)
+		return -1, diff, msg
+	}
+	if len(candidates) == 0 {
+		return -1, NoMatch, ""
+	}
+	best, bestScore := -1, -1.0
+	var bestValue interface{}
+	var invalid error
+	for i, c := range candidates {
+		cv, err := ctx.decode(c, 1)
+		if err != nil {
+			invalid = err
+			continue
+		}
+		if ctx.equal(av, cv, "") {
+			return i, FullMatch, ""
+		}
+		score := ctx.score(av, cv)
+		// A candidate nested deeper than MaxDepth is scored on the values
+		// above the limit, without failing those that follow.
+		ctx.err = nil
+		if score > bestScore {
+			best, bestScore, bestValue = i, score, cv
+		}
+	}
+	if best < 0 {
+		diff, msg, _ := invalidJSON(nil, invalid)
+		return 0, diff, msg
+	}
+	cctx := d.newContext(nil)
+	cctx.order = ctx.order
+	diff, text := cctx.compareValues(av, bestValue)
+	return best, diff, text
+}
@@ -0,0 +1,34 @@
+package jsondiff
+
+import "testing"
+
+func TestCompareAny(t *testing.T) {
+	opts := Options{Indent: "  "}
+	a := []byte(`{"region": "eu", "price": 10, "currency": "EUR"}`)
+	cases := []struct {
+		candidates []string
+		index      int
+		diff       Difference
+		text       string
+	}{
+		{[]string{`{"region": "us"}`, `{"currency": "EUR", "price": 10, "region": "eu"}`, `{"region": "eu", "price": 10, "currency": "EUR"}`}, 1, FullMatch, ""},
+		{[]string{`{"region": "us", "price": 10, "currency": "USD"}`, `{"region": "eu", "price": 12, "currency": "EUR"}`, `[]`},
+			1, NoMatch, "{\n  \"price\": 10 => 12\n}"},
+		{[]string{`{"region": "eu", "price": 1}`, `{"region": "eu", "price": 2}`}, 0, NoMatch, ""},
+		{[]string{`{`, `nope`}, 0, SecondArgIsInvalidJson, "second argument is invalid json"},
+		{nil, -1, NoMatch, ""},
+	}
+	for _, c := range cases {
+		var candidates [][]byte
+		for _, s := range c.candidates {
+			candidates = append(candidates, []byte(s))
+		}
+		index, diff, text := CompareAny(a, candidates, &opts)
+		if index != c.index || diff != c.diff || (c.text != "" && text != c.text) {
+			t.Errorf("%q: got %d %s %q, expected %d %s %q", c.candidates, index, diff, text, c.index, c.diff, c.text)
+		}
+	}
+	if index, diff, _ := CompareAny([]byte(`{`), [][]byte{a}, &opts); index != -1 || diff != FirstArgIsInvalidJson {
+		t.Errorf("got %d %s for invalid input", index, diff)
+	}
+}
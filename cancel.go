@@ -0,0 +1,54 @@
+package jsondiff
+
+import gocontext "context"
+
+// cancelCheckInterval is the number of values compared or written between
+// two checks of whether the comparison has been canceled.
+const cancelCheckInterval = 1024
+
+// CompareContext is like Compare, but stops early once c is done. It then
+// returns NoMatch, no text and the error of c; the output of a stopped
+// comparison is never returned.
+func CompareContext(c gocontext.Context, a, b []byte, opts *Options) (Difference, string, error) {
+	return differOf(opts).CompareContext(c, a, b)
+}
+
+// CompareContext is like the package-level CompareContext, using the options
+// of d.
+func (d *Differ) CompareContext(c gocontext.Context, a, b []byte) (Difference, string, error) {
+	ctx := d.newContext(nil)
+	if c.Done() != nil {
+		ctx.cancelErr = c.Err
+	}
+	diff, text := ctx.compare(a, b)
+	if ctx.err != nil {
+		return NoMatch, "", ctx.err
+	}
+	return diff, text, nil
+}
+
+// canceled counts a visited value and reports whether the comparison has
+// been canceled, checking every cancelCheckInterval values. A value nested
+// deeper than the limit stops the comparison with a *DepthError.
+func (ctx *context) canceled() bool {
+	if ctx.err != nil {
+		return true
+	}
+	if len(ctx.path) > ctx.differ.maxDepth {
+		ctx.err = &DepthError{Path: ctx.pointer(), MaxDepth: ctx.differ.maxDepth}
+		return true
+	}
+	if ctx.cancelErr == nil {
+		return false
+	}
+	ctx.nodes++
+	return ctx.nodes%cancelCheckInterval == 0 && ctx.check()
+}
+
+// check reports whether the comparison has been canceled.
+func (ctx *context) check() bool {
+	if ctx.err == nil && ctx.cancelErr != nil {
+		ctx.err = ctx.cancelErr()
+	}
+	return ctx.err != nil
+}
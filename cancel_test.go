@@ -0,0 +1,136 @@
+package jsondiff
+
+import (
+	"bytes"
+	gocontext "context"
+	"errors"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// cancelAfter is a context that reports being canceled once Err has been
+// called n times.
+type cancelAfter struct {
+	gocontext.Context
+	n, calls int
+}
+
+func (c *cancelAfter) Err() error {
+	c.calls++
+	if c.calls > c.n {
+		return gocontext.Canceled
+	}
+	return nil
+}
+
+func bigDocument(n, changed int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			buf.WriteString(",")
+		}
+		v := i
+		if i%changed == 0 {
+			v = -i
+		}
+		buf.WriteString(`{"id": ` + strconv.Itoa(i) + `, "v": ` + strconv.Itoa(v) + `}`)
+	}
+	buf.WriteString("]")
+	return buf.Bytes()
+}
+
+func TestCompareContextCanceled(t *testing.T) {
+	a, b := bigDocument(10000, 1), bigDocument(10000, 7)
+	parent, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	c := &cancelAfter{Context: parent, n: 3}
+	diff, text, err := CompareContext(c, a, b, &Options{})
+	if err != gocontext.Canceled || diff != NoMatch || text != "" {
+		t.Errorf("got %s, %d bytes of text and %v", diff, len(text), err)
+	}
+	if c.calls != c.n+1 {
+		t.Errorf("comparison went on after cancellation, %d checks", c.calls)
+	}
+
+	cancel()
+	if _, _, err := CompareContext(parent, a, b, &Options{}); err != gocontext.Canceled {
+		t.Errorf("got %v for a canceled context", err)
+	}
+}
+
+func TestCompareContextNotCanceled(t *testing.T) {
+	a, b := bigDocument(3000, 1), bigDocument(3000, 7)
+	opts := Options{Indent: "  ", StringAsMapFields: []string{"doc"}}
+	c, cancel := gocontext.WithCancel(gocontext.Background())
+	defer cancel()
+	diff, text, err := CompareContext(c, a, b, &opts)
+	expDiff, expected := Compare(a, b, &opts)
+	if err != nil || diff != expDiff || text != expected {
+		t.Errorf("got %s and %v, expected %s", diff, err, expDiff)
+	}
+}
+
+// nestedArrays returns depth arrays nested in each other around leaf.
+func nestedArrays(depth int, leaf string) []byte {
+	return []byte(strings.Repeat("[", depth) + leaf + strings.Repeat("]", depth))
+}
+
+// nestedValues is nestedArrays for decoded values.
+func nestedValues(depth int, leaf interface{}) interface{} {
+	v := leaf
+	for i := 0; i < depth; i++ {
+		v = []interface{}{v}
+	}
+	return v
+}
+
+func TestMaxDepth(t *testing.T) {
+	opts := Options{MaxDepth: 100}
+	// The leaf of 100 arrays is at depth 100.
+	diff, text, err := CompareErr(nestedArrays(100, "1"), nestedArrays(100, "2"), &opts)
+	if diff != NoMatch || text == "" || err != nil {
+		t.Errorf("at the limit: got %s, %q, %v", diff, text, err)
+	}
+	for _, c := range []struct{ a, b []byte }{
+		{nestedArrays(101, "1"), nestedArrays(101, "2")},
+		{nestedArrays(101, "1"), nestedArrays(101, "1")},
+		// Added values are written as deep as they are nested.
+		{[]byte(`{}`), []byte(`{"x": ` + string(nestedArrays(100, "1")) + `}`)},
+	} {
+		diff, text, err := CompareErr(c.a, c.b, &opts)
+		var depthErr *DepthError
+		if diff != NoMatch || text != "" || !errors.As(err, &depthErr) || depthErr.MaxDepth != 100 {
+			t.Errorf("%s, %s: got %s, %q, %v", c.a, c.b, diff, text, err)
+		} else if depth := strings.Count(depthErr.Path, "/"); depth != 101 {
+			t.Errorf("%s, %s: error at depth %d: %s", c.a, c.b, depth, depthErr.Path)
+		}
+	}
+	if diff, text := Compare(nestedArrays(101, "1"), nestedArrays(101, "1"), &opts); diff != NoMatch || text != "" {
+		t.Errorf("Compare: got %s, %q", diff, text)
+	}
+	if _, _, err := CompareYAML(nestedArrays(101, "1"), nestedArrays(100, "1"), &opts); err == nil {
+		t.Error("CompareYAML accepted a document nested too deeply")
+	}
+	opts.MaxDepth = -1
+	if err := opts.Validate(); err == nil {
+		t.Error("negative MaxDepth accepted")
+	}
+}
+
+func TestDefaultMaxDepth(t *testing.T) {
+	// Decoded values may be nested deeper than the JSON decoder allows.
+	deep := nestedValues(DefaultMaxDepth+1, true)
+	opts := Options{NoOutput: true}
+	if diff, _ := CompareValues(deep, deep, &opts); diff != NoMatch {
+		t.Errorf("above the default limit: got %s", diff)
+	}
+	opts.MaxDepth = DefaultMaxDepth + 1
+	if diff, _ := CompareValues(deep, deep, &opts); diff != FullMatch {
+		t.Errorf("below a raised limit: got %s", diff)
+	}
+	if _, _, err := CompareYAML(nestedArrays(DefaultMaxDepth+1, "1"), []byte("1"), &Options{}); err == nil {
+		t.Error("CompareYAML accepted a document nested too deeply")
+	}
+}
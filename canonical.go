@@ -0,0 +1,20 @@
+package jsondiff
+
+import "bytes"
+
+// Canonical returns the JSON document data pretty-printed the way Compare
+// renders values: object keys sorted, every member and element on its own
+// line indented with indent, and numbers written as they appear in data. The
+// result ends with a line break. If data is not valid JSON, the error is an
+// *InvalidJSONError.
+func Canonical(data []byte, indent string) ([]byte, error) {
+	ctx := newDiffer(Options{Indent: indent}).newContext(nil)
+	v, err := ctx.decode(data, 0)
+	if err != nil {
+		return nil, newInvalidJSONError(1, data, err)
+	}
+	var buf bytes.Buffer
+	ctx.writeValue(&buf, v, true)
+	buf.WriteByte('\n')
+	return buf.Bytes(), nil
+}
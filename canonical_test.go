@@ -0,0 +1,29 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestCanonical(t *testing.T) {
+	cases := []struct{ in, expected string }{
+		{`{"b": [1, 2.50, {}], "a": {"y": null, "x": "é<"}, "c": []}`,
+			"{\n  \"a\": {\n    \"x\": \"é<\",\n    \"y\": null\n  },\n  \"b\": [\n    1,\n    2.50,\n    {}\n  ],\n  \"c\": []\n}\n"},
+		{` "s" `, "\"s\"\n"},
+		{`true`, "true\n"},
+	}
+	for _, c := range cases {
+		got, err := Canonical([]byte(c.in), "  ")
+		if err != nil || string(got) != c.expected {
+			t.Errorf("%s: got %q and %v, expected %q", c.in, got, err, c.expected)
+		}
+		if !json.Valid(got) {
+			t.Errorf("%s: output is not valid JSON", c.in)
+		}
+	}
+	var ije *InvalidJSONError
+	if _, err := Canonical([]byte(`{"a": `), "  "); !errors.As(err, &ije) {
+		t.Errorf("got %v, expected an *InvalidJSONError", err)
+	}
+}
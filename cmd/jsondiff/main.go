@@ -0,0 +1,243 @@
+// Command jsondiff compares two JSON documents and prints their differences.
+//
+// Usage:
+//
+//	jsondiff [flags] FILE1 FILE2
+//
+// Either file may be "-" to read it from the standard input. The exit code is
+// 0 if the documents match, 1 if they differ and 2 if either of them is not
+// valid JSON or cannot be read, or the flags are wrong. Which differences
+// make the command fail is selected with --fail-on: nomatch fails only if
+// the documents don't match at all, superset also if the first is a
+// superset of the second and any, the default, on every difference,
+// including a first document that is a subset of the second.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/nsf/jsondiff"
+)
+
+// Exit codes of the command.
+const (
+	exitMatch   = 0
+	exitDiffer  = 1
+	exitInvalid = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// listFlag is a flag holding a list of field names. It can be repeated and
+// every value may hold several names separated by commas.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *listFlag) Set(s string) error {
+	for _, f := range strings.Split(s, ",") {
+		if f != "" {
+			*l = append(*l, f)
+		}
+	}
+	return nil
+}
+
+// formats are the supported values of --format.
+var formats = []string{"console", "html", "entries"}
+
+// plannedFormats are values of --format refused with a dedicated error
+// because the library has no renderer for them yet.
+var plannedFormats = []string{"unified", "patch"}
+
+// failOnPolicies are the supported values of --fail-on, from the most to
+// the least lenient.
+var failOnPolicies = []string{"nomatch", "superset", "any"}
+
+// config is the result of parsing the command line.
+type config struct {
+	opts   jsondiff.Options
+	files  []string
+	format string
+	failOn string
+	output string
+}
+
+// parseArgs parses the command line arguments into a config. stdout is where
+// the report goes, for --color=auto.
+func parseArgs(args []string, stdout, stderr io.Writer) (*config, error) {
+	fs := flag.NewFlagSet("jsondiff", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jsondiff [flags] FILE1 FILE2")
+		fmt.Fprintln(stderr, `Either file may be "-" to read the standard input.`)
+		fs.PrintDefaults()
+	}
+	var ignore, fuzzy, stringAsMap listFlag
+	fs.Var(&ignore, "ignore", "comma-separated `fields` to ignore, can be repeated")
+	fs.Var(&fuzzy, "fuzzy", "comma-separated `fields` whose values are not compared, can be repeated")
+	fs.Var(&stringAsMap, "string-as-map", "comma-separated `fields` holding JSON documents as strings, can be repeated")
+	nullAsEmpty := fs.Bool("null-as-empty", false, "treat null like a missing value")
+	printTypes := fs.Bool("print-types", false, "annotate values with their JSON type")
+	indent := fs.String("indent", "    ", "indentation `string` of nested values")
+	color := fs.String("color", "auto", "colorize the output: always, never or auto")
+	format := fs.String("format", "console", "output `format`: "+strings.Join(formats, ", "))
+	failOn := fs.String("fail-on", "any", "differences making the exit code 1: nomatch for NoMatch only,\nsuperset for NoMatch and SupersetMatch, or any for every difference")
+	output := fs.String("output", "", "write the report to `file` instead of the standard output")
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return nil, errors.New("expected two files")
+	}
+	if fs.Arg(0) == "-" && fs.Arg(1) == "-" {
+		return nil, errors.New("only one file can be read from the standard input")
+	}
+	c := &config{files: fs.Args(), format: *format, failOn: *failOn, output: *output}
+	if !contains(formats, c.format) {
+		if contains(plannedFormats, c.format) {
+			return nil, fmt.Errorf("format %q is not supported yet, supported formats are %s", c.format, strings.Join(formats, ", "))
+		}
+		return nil, fmt.Errorf("unknown format %q, supported formats are %s", c.format, strings.Join(formats, ", "))
+	}
+	if !contains(failOnPolicies, c.failOn) {
+		return nil, fmt.Errorf("invalid --fail-on %q: must be %s", c.failOn, strings.Join(failOnPolicies, ", "))
+	}
+	switch *color {
+	case "always":
+		c.opts = jsondiff.DefaultConsoleOptions()
+	case "never":
+		c.opts = jsondiff.DefaultASCIISymbolOptions()
+	case "auto":
+		// Files are not terminals, and without colors differences are
+		// still marked with symbols.
+		out := stdout
+		if c.output != "" {
+			out = ioutil.Discard
+		}
+		c.opts = jsondiff.ConsoleOptionsFor(out)
+		if c.opts.Added == (jsondiff.Tag{}) {
+			c.opts = jsondiff.DefaultASCIISymbolOptions()
+		}
+	default:
+		return nil, fmt.Errorf("invalid --color %q: must be always, never or auto", *color)
+	}
+	if c.format == "html" {
+		c.opts = jsondiff.DefaultHTMLOptions()
+	}
+	c.opts.IgnoreFields = ignore
+	c.opts.FuzzyFields = fuzzy
+	c.opts.StringAsMapFields = stringAsMap
+	c.opts.NullAsEmpty = *nullAsEmpty
+	c.opts.PrintTypes = *printTypes
+	c.opts.Indent = *indent
+	return c, nil
+}
+
+// run runs the command with the given arguments and returns its exit code.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	c, err := parseArgs(args, stdout, stderr)
+	if err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintln(stderr, "jsondiff:", err)
+		}
+		return exitInvalid
+	}
+	d, err := jsondiff.NewDiffer(c.opts)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+	var docs [2][]byte
+	for i, name := range c.files {
+		if docs[i], err = readInput(name, stdin); err != nil {
+			fmt.Fprintln(stderr, "jsondiff:", err)
+			return exitInvalid
+		}
+	}
+	out := stdout
+	var file *os.File
+	if c.output != "" {
+		if file, err = os.Create(c.output); err != nil {
+			fmt.Fprintln(stderr, "jsondiff:", err)
+			return exitInvalid
+		}
+		out = file
+	}
+	diff, err := report(out, d, c.format, docs[0], docs[1])
+	if file != nil {
+		if cerr := file.Close(); err == nil && cerr != nil {
+			err = fmt.Errorf("jsondiff: %w", cerr)
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitInvalid
+	}
+	return exitCode(diff, c.failOn)
+}
+
+// report compares a and b and writes the report in the given format to w.
+// The error describes invalid documents or the failure to write the report.
+func report(w io.Writer, d *jsondiff.Differ, format string, a, b []byte) (jsondiff.Difference, error) {
+	if format == "entries" {
+		diff, err := d.WriteNDJSON(w, a, b)
+		if err == nil && diff.IsInvalidJson() {
+			_, _, err = d.CompareErr(a, b)
+		}
+		return diff, err
+	}
+	diff, text, err := d.CompareErr(a, b)
+	if err != nil || text == "" {
+		return diff, err
+	}
+	if format == "html" {
+		text = "<pre>" + text + "</pre>"
+	}
+	_, err = fmt.Fprintln(w, text)
+	return diff, err
+}
+
+// exitCode returns the exit code for the result of a comparison under the
+// given --fail-on policy.
+func exitCode(diff jsondiff.Difference, failOn string) int {
+	switch {
+	case diff.IsInvalidJson():
+		return exitInvalid
+	case diff == jsondiff.FullMatch:
+		return exitMatch
+	case diff == jsondiff.NoMatch, failOn == "any":
+		return exitDiffer
+	case diff == jsondiff.SupersetMatch && failOn == "superset":
+		return exitDiffer
+	}
+	return exitMatch
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// readInput reads the whole file name, or stdin if name is "-".
+func readInput(name string, stdin io.Reader) ([]byte, error) {
+	if name == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(name)
+}
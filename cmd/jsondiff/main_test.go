@@ -0,0 +1,250 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/nsf/jsondiff"
+)
+
+func TestParseArgs(t *testing.T) {
+	c, err := parseArgs([]string{
+		"--ignore", "a,b", "--ignore=c", "--fuzzy", "f", "--string-as-map", "s",
+		"--null-as-empty", "--print-types", "--indent", "\t", "x.json", "-",
+	}, ioutil.Discard, ioutil.Discard)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := jsondiff.DefaultASCIISymbolOptions()
+	expected.IgnoreFields = []string{"a", "b", "c"}
+	expected.FuzzyFields = []string{"f"}
+	expected.StringAsMapFields = []string{"s"}
+	expected.NullAsEmpty = true
+	expected.PrintTypes = true
+	expected.Indent = "\t"
+	if !reflect.DeepEqual(c.opts, expected) {
+		t.Errorf("got options %+v, expected %+v", c.opts, expected)
+	}
+	if c.format != "console" || c.failOn != "any" || c.output != "" {
+		t.Errorf("got format %q, fail-on %q, output %q", c.format, c.failOn, c.output)
+	}
+	if !reflect.DeepEqual(c.files, []string{"x.json", "-"}) {
+		t.Errorf("got files %q", c.files)
+	}
+}
+
+func TestParseArgsColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	cases := map[string]jsondiff.Options{
+		"always": jsondiff.DefaultConsoleOptions(),
+		"never":  jsondiff.DefaultASCIISymbolOptions(),
+		// The output is not a terminal.
+		"auto": jsondiff.DefaultASCIISymbolOptions(),
+	}
+	for color, expected := range cases {
+		c, err := parseArgs([]string{"--color=" + color, "a", "b"}, &bytes.Buffer{}, ioutil.Discard)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.opts.Added != expected.Added {
+			t.Errorf("--color=%s: got added tag %q, expected %q", color, c.opts.Added, expected.Added)
+		}
+	}
+}
+
+func TestParseArgsErrors(t *testing.T) {
+	cases := [][]string{
+		{"a"},
+		{"a", "b", "c"},
+		{"-", "-"},
+		{"--color=sometimes", "a", "b"},
+		{"--unknown", "a", "b"},
+		{"--fail-on=never", "a", "b"},
+	}
+	for _, args := range cases {
+		if _, err := parseArgs(args, ioutil.Discard, ioutil.Discard); err == nil {
+			t.Errorf("%q: expected an error", args)
+		}
+	}
+}
+
+func writeFile(t *testing.T, dir, name, data string) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.json", `{"a": 1, "b": 2}`)
+	same := writeFile(t, dir, "same.json", `{"b": 2, "a": 1}`)
+	subset := writeFile(t, dir, "subset.json", `{"a": 1}`)
+	invalid := writeFile(t, dir, "invalid.json", `{"a": `)
+	cases := []struct {
+		args   []string
+		stdin  string
+		code   int
+		stdout string
+	}{
+		{[]string{a, same}, "", exitMatch, ""},
+		{[]string{a, "-"}, `{"a": 1, "b": 2}`, exitMatch, ""},
+		{[]string{a, subset}, "", exitDiffer, "{\n    - \"b\": 2\n}\n"},
+		{[]string{"--ignore=b", a, subset}, "", exitMatch, ""},
+		{[]string{"-", subset}, `{"a": 2}`, exitDiffer, "{\n    ~ \"a\": 2 => 1\n}\n"},
+		{[]string{a, invalid}, "", exitInvalid, ""},
+		{[]string{a, filepath.Join(dir, "missing.json")}, "", exitInvalid, ""},
+		{[]string{a}, "", exitInvalid, ""},
+	}
+	for _, c := range cases {
+		var stdout, stderr bytes.Buffer
+		code := run(c.args, strings.NewReader(c.stdin), &stdout, &stderr)
+		if code != c.code {
+			t.Errorf("%q: got exit code %d, expected %d (stderr %q)", c.args, code, c.code, stderr.String())
+		}
+		if stdout.String() != c.stdout {
+			t.Errorf("%q: got output %q, expected %q", c.args, stdout.String(), c.stdout)
+		}
+		if code == exitInvalid && stderr.Len() == 0 {
+			t.Errorf("%q: no error message", c.args)
+		}
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	cases := []struct {
+		diff   jsondiff.Difference
+		failOn string
+		code   int
+	}{
+		{jsondiff.FullMatch, "any", exitMatch},
+		{jsondiff.SupersetMatch, "any", exitDiffer},
+		{jsondiff.SubsetMatch, "any", exitDiffer},
+		{jsondiff.NoMatch, "any", exitDiffer},
+		{jsondiff.FullMatch, "superset", exitMatch},
+		{jsondiff.SupersetMatch, "superset", exitDiffer},
+		{jsondiff.SubsetMatch, "superset", exitMatch},
+		{jsondiff.NoMatch, "superset", exitDiffer},
+		{jsondiff.FullMatch, "nomatch", exitMatch},
+		{jsondiff.SupersetMatch, "nomatch", exitMatch},
+		{jsondiff.SubsetMatch, "nomatch", exitMatch},
+		{jsondiff.NoMatch, "nomatch", exitDiffer},
+		{jsondiff.FirstArgIsInvalidJson, "nomatch", exitInvalid},
+		{jsondiff.SecondArgIsInvalidJson, "any", exitInvalid},
+		{jsondiff.BothArgsAreInvalidJson, "superset", exitInvalid},
+	}
+	for _, c := range cases {
+		if code := exitCode(c.diff, c.failOn); code != c.code {
+			t.Errorf("%s with --fail-on=%s: got %d, expected %d", c.diff, c.failOn, code, c.code)
+		}
+	}
+}
+
+func TestRunFailOn(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.json", `{"a": 1, "b": 2}`)
+	subset := writeFile(t, dir, "subset.json", `{"a": 1}`)
+	other := writeFile(t, dir, "other.json", `{"a": 2}`)
+	cases := []struct {
+		failOn string
+		files  [2]string
+		code   int
+	}{
+		{"nomatch", [2]string{a, subset}, exitMatch},
+		{"nomatch", [2]string{subset, a}, exitMatch},
+		{"nomatch", [2]string{a, other}, exitDiffer},
+		{"superset", [2]string{a, subset}, exitDiffer},
+		{"superset", [2]string{subset, a}, exitMatch},
+		{"superset", [2]string{a, other}, exitDiffer},
+		{"any", [2]string{a, subset}, exitDiffer},
+		{"any", [2]string{subset, a}, exitDiffer},
+		{"any", [2]string{a, other}, exitDiffer},
+	}
+	for _, c := range cases {
+		var stdout bytes.Buffer
+		if code := run([]string{"--fail-on=" + c.failOn, c.files[0], c.files[1]}, nil, &stdout, ioutil.Discard); code != c.code {
+			t.Errorf("--fail-on=%s %s %s: got exit code %d, expected %d", c.failOn, c.files[0], c.files[1], code, c.code)
+		}
+		if stdout.Len() == 0 {
+			t.Errorf("--fail-on=%s %s %s: no report", c.failOn, c.files[0], c.files[1])
+		}
+	}
+}
+
+func TestRunFormats(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.json", `{"a": 1, "b": "<x>"}`)
+	b := writeFile(t, dir, "b.json", `{"a": 2, "b": "<y>"}`)
+	cases := map[string]string{
+		"console": "{\n    ~ \"a\": 1 => 2,\n    ~ \"b\": \"<x>\" => \"<y>\"\n}\n",
+		"html": "<pre>{\n    \"a\": <span style=\"background-color: #fcff7f\">1 => 2</span>,\n" +
+			"    \"b\": <span style=\"background-color: #fcff7f\">\"&lt;x&gt;\" => \"&lt;y&gt;\"</span>\n}</pre>\n",
+		"entries": `{"path":"/a","kind":"changed","old":1,"new":2}` + "\n" +
+			`{"path":"/b","kind":"changed","old":"\u003cx\u003e","new":"\u003cy\u003e"}` + "\n" +
+			`{"path":"","kind":"summary","difference":"NoMatch"}` + "\n",
+	}
+	for format, expected := range cases {
+		var stdout, stderr bytes.Buffer
+		if code := run([]string{"--format=" + format, a, b}, nil, &stdout, &stderr); code != exitDiffer {
+			t.Errorf("--format=%s: got exit code %d (stderr %q)", format, code, stderr.String())
+		}
+		if stdout.String() != expected {
+			t.Errorf("--format=%s: got %q, expected %q", format, stdout.String(), expected)
+		}
+	}
+}
+
+func TestRunFormatErrors(t *testing.T) {
+	for _, format := range []string{"unified", "patch", "yaml"} {
+		var stderr bytes.Buffer
+		if code := run([]string{"--format=" + format, "a", "b"}, nil, ioutil.Discard, &stderr); code != exitInvalid {
+			t.Errorf("--format=%s: got exit code %d", format, code)
+		}
+		if !strings.Contains(stderr.String(), "console, html, entries") {
+			t.Errorf("--format=%s: error does not list the supported formats: %q", format, stderr.String())
+		}
+	}
+}
+
+func TestRunEntriesInvalid(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.json", `{"a": 1}`)
+	invalid := writeFile(t, dir, "invalid.json", `{"a": `)
+	var stderr bytes.Buffer
+	if code := run([]string{"--format=entries", a, invalid}, nil, ioutil.Discard, &stderr); code != exitInvalid {
+		t.Errorf("got exit code %d", code)
+	}
+	if !strings.Contains(stderr.String(), "second document is invalid JSON") {
+		t.Errorf("got error %q", stderr.String())
+	}
+}
+
+func TestRunOutput(t *testing.T) {
+	dir := t.TempDir()
+	a := writeFile(t, dir, "a.json", `{"a": 1}`)
+	b := writeFile(t, dir, "b.json", `{"a": 2}`)
+	report := filepath.Join(dir, "report.txt")
+	var stdout bytes.Buffer
+	if code := run([]string{"--output", report, a, b}, nil, &stdout, ioutil.Discard); code != exitDiffer {
+		t.Errorf("got exit code %d, expected %d", code, exitDiffer)
+	}
+	if stdout.Len() != 0 {
+		t.Errorf("got output on stdout: %q", stdout.String())
+	}
+	data, err := ioutil.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := "{\n    ~ \"a\": 1 => 2\n}\n"; string(data) != expected {
+		t.Errorf("got report %q, expected %q", data, expected)
+	}
+	missing := filepath.Join(dir, "missing", "report.txt")
+	if code := run([]string{"--output", missing, a, b}, nil, &stdout, ioutil.Discard); code != exitInvalid {
+		t.Errorf("unwritable output: got exit code %d, expected %d", code, exitInvalid)
+	}
+}
@@ -0,0 +1,58 @@
+package jsondiff
+
+import (
+	"io"
+	"os"
+)
+
+// Provides a set of options for console output on terminals supporting 256
+// colors. Changes are highlighted with faint backgrounds which stay readable
+// on both dark and light terminals.
+func DefaultConsole256Options() Options {
+	return Options{
+		Added:   Tag{Begin: "\033[38;5;16;48;5;194m", End: "\033[0m"},
+		Removed: Tag{Begin: "\033[38;5;16;48;5;224m", End: "\033[0m"},
+		Changed: Tag{Begin: "\033[38;5;16;48;5;230m", End: "\033[0m"},
+		Indent:  "    ",
+	}
+}
+
+// Provides a set of options for console output on terminals supporting 24-bit
+// colors. Uses the same faint backgrounds as DefaultConsole256Options.
+func DefaultConsoleTrueColorOptions() Options {
+	return Options{
+		Added:   Tag{Begin: "\033[38;2;0;0;0;48;2;215;255;215m", End: "\033[0m"},
+		Removed: Tag{Begin: "\033[38;2;0;0;0;48;2;255;215;215m", End: "\033[0m"},
+		Changed: Tag{Begin: "\033[38;2;0;0;0;48;2;255;255;215m", End: "\033[0m"},
+		Indent:  "    ",
+	}
+}
+
+// ConsoleOptionsFor returns options suitable for writing the output to w. If
+// the NO_COLOR environment variable is set (see https://no-color.org) or w is
+// not a terminal, the returned options contain no escape sequences at all,
+// otherwise DefaultConsoleOptions are returned.
+func ConsoleOptionsFor(w io.Writer) Options {
+	return consoleOptions(isTerminal(w))
+}
+
+func consoleOptions(terminal bool) Options {
+	if os.Getenv("NO_COLOR") != "" || !terminal {
+		return Options{Indent: "    "}
+	}
+	return DefaultConsoleOptions()
+}
+
+// isTerminal reports whether w is a character device, which is how
+// terminals are presented on all supported platforms.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return fi.Mode()&os.ModeCharDevice != 0
+}
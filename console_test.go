@@ -0,0 +1,64 @@
+package jsondiff
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestConsolePresets(t *testing.T) {
+	for name, opts := range map[string]Options{
+		"256":       DefaultConsole256Options(),
+		"truecolor": DefaultConsoleTrueColorOptions(),
+	} {
+		for _, tag := range []Tag{opts.Added, opts.Removed, opts.Changed} {
+			if !strings.HasPrefix(tag.Begin, "\033[") || !strings.HasSuffix(tag.Begin, "m") {
+				t.Errorf("%s: tag does not begin with an SGR sequence: %q", name, tag.Begin)
+			}
+			if tag.End != "\033[0m" {
+				t.Errorf("%s: tag does not reset attributes: %q", name, tag.End)
+			}
+		}
+		if opts.Added == opts.Removed || opts.Added == opts.Changed || opts.Removed == opts.Changed {
+			t.Errorf("%s: tags are not distinct", name)
+		}
+	}
+	if !strings.Contains(DefaultConsole256Options().Added.Begin, "48;5;") {
+		t.Errorf("256 color preset must use 256 color backgrounds")
+	}
+	if !strings.Contains(DefaultConsoleTrueColorOptions().Added.Begin, "48;2;") {
+		t.Errorf("true color preset must use 24-bit backgrounds")
+	}
+}
+
+func TestConsoleOptionsFor(t *testing.T) {
+	plain := Options{Indent: "    "}
+
+	if opts := ConsoleOptionsFor(&bytes.Buffer{}); !reflect.DeepEqual(opts, plain) {
+		t.Errorf("non-terminal writer must get plain options, got %#v", opts)
+	}
+
+	f, err := ioutil.TempFile("", "jsondiff")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	if opts := ConsoleOptionsFor(f); !reflect.DeepEqual(opts, plain) {
+		t.Errorf("regular file must get plain options, got %#v", opts)
+	}
+}
+
+func TestConsoleOptionsNoColor(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	if opts := consoleOptions(true); !reflect.DeepEqual(opts, DefaultConsoleOptions()) {
+		t.Errorf("terminal must get console options, got %#v", opts)
+	}
+	t.Setenv("NO_COLOR", "1")
+	if opts := consoleOptions(true); !reflect.DeepEqual(opts, Options{Indent: "    "}) {
+		t.Errorf("NO_COLOR must disable colors, got %#v", opts)
+	}
+}
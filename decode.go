@@ -0,0 +1,373 @@
+package jsondiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// keyOrder records the order in which object keys appeared in the input
+// documents. Objects are identified by the address of their map, which stays
+// stable for as long as the decoded tree is alive.
+type keyOrder map[uintptr][]string
+
+func (o keyOrder) set(m map[string]interface{}, keys []string) {
+	o[reflect.ValueOf(m).Pointer()] = keys
+}
+
+// keys returns the keys of m in input order, or nil if the order of m is
+// unknown.
+func (o keyOrder) keys(m map[string]interface{}) []string {
+	return o[reflect.ValueOf(m).Pointer()]
+}
+
+// merge returns the keys of ma in input order followed by the keys found only
+// in mb, each slotted in after its nearest preceding neighbor from mb.
+func (o keyOrder) merge(ma, mb map[string]interface{}) []string {
+	ka, kb := o.keys(ma), o.keys(mb)
+	if ka == nil || kb == nil {
+		return sortedKeys(ma, mb)
+	}
+	var leading []string
+	after := make(map[string][]string)
+	prev := ""
+	havePrev := false
+	for _, k := range kb {
+		if _, ok := ma[k]; ok {
+			prev, havePrev = k, true
+		} else if havePrev {
+			after[prev] = append(after[prev], k)
+		} else {
+			leading = append(leading, k)
+		}
+	}
+	keys := make([]string, 0, len(ka)+len(kb))
+	keys = append(keys, leading...)
+	for _, k := range ka {
+		keys = append(keys, k)
+		keys = append(keys, after[k]...)
+	}
+	return keys
+}
+
+// Position is the location of a value in an input document. Offset is the
+// zero-based byte offset of the first byte of the value; Line and Column are
+// one-based, and Column counts bytes.
+type Position struct {
+	Offset int
+	Line   int
+	Column int
+}
+
+func (p Position) String() string {
+	return strconv.Itoa(p.Line) + ":" + strconv.Itoa(p.Column)
+}
+
+// positions maps the JSON Pointer of every value in a document to where the
+// value starts.
+type positions map[string]Position
+
+// DuplicateKey is a key appearing more than once in the same object of an
+// input document, found with DetectDuplicateKeys. Only the last value of such
+// a key is compared.
+type DuplicateKey struct {
+	// Document is 1 for the first and 2 for the second document.
+	Document int
+	// Path is the JSON Pointer of the member, in the format of
+	// DiffEntry.Path.
+	Path string
+	// Position is where the repeated occurrence of the key starts.
+	Position Position
+}
+
+// decoder parses a single JSON value. Numbers are decoded as json.Number. If
+// order is not nil, the key order of every decoded object is recorded in it;
+// if positions is not nil, the start of every decoded value is. If
+// noTrailing is set, anything but whitespace after the value is an error. If
+// detectDuplicates is set, repeated keys are collected in duplicates.
+// invalidUTF8 selects how strings holding invalid UTF-8 are decoded. If
+// emptyAsNull is set, data holding only whitespace decodes as null.
+type decoder struct {
+	order            keyOrder
+	positions        positions
+	noTrailing       bool
+	detectDuplicates bool
+	duplicates       []DuplicateKey
+	invalidUTF8      InvalidUTF8Mode
+	emptyAsNull      bool
+
+	// rawStrings is set when strings holding invalid UTF-8 are decoded
+	// again from data, keeping their invalid bytes.
+	rawStrings bool
+	// end is the offset following the decoded value.
+	end int
+
+	data []byte
+	d    *json.Decoder
+	path []string
+
+	// line and column of off, advanced as the decoder moves forward.
+	off, line, col int
+}
+
+func decode(data []byte, order keyOrder) (interface{}, error) {
+	dec := decoder{order: order}
+	return dec.decode(data)
+}
+
+func (dec *decoder) decode(data []byte) (interface{}, error) {
+	data, err := checkBOM(data)
+	if err != nil {
+		return nil, err
+	}
+	dec.data = data
+	if dec.emptyAsNull && onlySpace(data) {
+		return nil, nil
+	}
+	bad := -1
+	if dec.invalidUTF8 != InvalidUTF8Replace {
+		bad = invalidUTF8Offset(data)
+	}
+	dec.rawStrings = dec.invalidUTF8 == InvalidUTF8Pass && bad >= 0
+	v, err := dec.decodeFrom(bytes.NewReader(data))
+	// Syntax errors come first, and trailing data that is not looked at
+	// does not count.
+	if err == nil && dec.invalidUTF8 == InvalidUTF8Reject && bad >= 0 && bad < dec.end {
+		return nil, &InvalidUTF8Error{Offset: bad}
+	}
+	return v, err
+}
+
+// decodeFrom parses a single JSON value read from r. If positions are
+// recorded, r must read dec.data.
+func (dec *decoder) decodeFrom(r io.Reader) (interface{}, error) {
+	var v interface{}
+	var err error
+	d := json.NewDecoder(r)
+	d.UseNumber()
+	if dec.order == nil && dec.positions == nil && !dec.detectDuplicates && !dec.rawStrings {
+		err = d.Decode(&v)
+	} else {
+		dec.d = d
+		dec.off, dec.line, dec.col = 0, 1, 1
+		v, err = dec.decodeValue()
+	}
+	dec.end = int(d.InputOffset())
+	if err == nil && dec.noTrailing {
+		err = trailingData(d, r)
+	}
+	return v, err
+}
+
+// onlySpace reports whether data holds nothing but JSON whitespace.
+func onlySpace(data []byte) bool {
+	for _, c := range data {
+		if !isJSONSpace(c) {
+			return false
+		}
+	}
+	return true
+}
+
+// TrailingDataError is the error of a document holding more than whitespace
+// after its JSON value.
+type TrailingDataError struct {
+	// Offset is the byte offset of the first byte after the value that is
+	// not whitespace.
+	Offset int
+}
+
+func (e *TrailingDataError) Error() string {
+	return "trailing data after the JSON value at offset " + strconv.Itoa(e.Offset)
+}
+
+// trailingData returns a *TrailingDataError if anything but whitespace
+// follows the value just decoded by d from r.
+func trailingData(d *json.Decoder, r io.Reader) error {
+	off := int(d.InputOffset())
+	rest := bufio.NewReader(io.MultiReader(d.Buffered(), r))
+	for {
+		c, err := rest.ReadByte()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch c {
+		case ' ', '\t', '\r', '\n':
+			off++
+			continue
+		}
+		return &TrailingDataError{Offset: off}
+	}
+}
+
+// nextOffset returns the offset of the token about to be read by the next
+// call to Token.
+func (dec *decoder) nextOffset() int {
+	off := int(dec.d.InputOffset())
+	for off < len(dec.data) {
+		switch dec.data[off] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			off++
+			continue
+		}
+		break
+	}
+	return off
+}
+
+// record stores the position of the value about to be read by the next call
+// to Token.
+func (dec *decoder) record() {
+	off := dec.nextOffset()
+	for ; dec.off < off; dec.off++ {
+		if dec.data[dec.off] == '\n' {
+			dec.line, dec.col = dec.line+1, 1
+		} else {
+			dec.col++
+		}
+	}
+	dec.positions[dec.pointer()] = Position{Offset: off, Line: dec.line, Column: dec.col}
+}
+
+// pointer returns the JSON Pointer of the value being decoded.
+func (dec *decoder) pointer() string {
+	return pointerOf(dec.path)
+}
+
+// decode decodes document i (0 for the first, 1 for the second) for ctx,
+// recording key order and positions as configured.
+func (ctx *context) decode(data []byte, i int) (interface{}, error) {
+	dec := decoder{order: ctx.order, noTrailing: ctx.opts.DisallowTrailingData, detectDuplicates: ctx.opts.DetectDuplicateKeys,
+		invalidUTF8: ctx.opts.InvalidUTF8, emptyAsNull: ctx.opts.EmptyInputAsNull}
+	if ctx.opts.LenientParsing {
+		data = stripLenient(data)
+	}
+	if ctx.tracking {
+		ctx.positions[i] = make(positions)
+		dec.positions = ctx.positions[i]
+	}
+	v, err := dec.decode(data)
+	for _, dup := range dec.duplicates {
+		dup.Document = i + 1
+		dup.Path = ctx.basePath + dup.Path
+		ctx.duplicates = append(ctx.duplicates, dup)
+	}
+	return v, err
+}
+
+// position returns where the current value starts in document i, or nil if
+// it is not known.
+func (ctx *context) position(i int) *Position {
+	if ctx.positions[i] == nil {
+		return ctx.outer[i]
+	}
+	if p, ok := ctx.positions[i][ctx.localPointer()]; ok {
+		return &p
+	}
+	return nil
+}
+
+// positionsOf returns the positions of the current value in both documents
+// for a difference of the given kind.
+func (ctx *context) positionsOf(kind DiffKind) (oldPos, newPos *Position) {
+	if kind != KindAdded {
+		oldPos = ctx.position(0)
+	}
+	if kind != KindRemoved {
+		newPos = ctx.position(1)
+	}
+	return oldPos, newPos
+}
+
+var errUnexpectedDelim = errors.New("unexpected delimiter")
+
+func (dec *decoder) decodeValue() (interface{}, error) {
+	if dec.positions != nil {
+		dec.record()
+	}
+	d := dec.d
+	off := 0
+	if dec.rawStrings {
+		off = dec.nextOffset()
+	}
+	tok, err := d.Token()
+	if err != nil {
+		return nil, err
+	}
+	tok = dec.raw(tok, off)
+	switch tok {
+	case json.Delim('{'):
+		m := make(map[string]interface{})
+		var keys []string
+		for d.More() {
+			keyOff := 0
+			if dec.detectDuplicates || dec.rawStrings {
+				keyOff = dec.nextOffset()
+			}
+			tok, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			k, ok := dec.raw(tok, keyOff).(string)
+			if !ok {
+				return nil, errUnexpectedDelim
+			}
+			dec.path = append(dec.path, k)
+			if _, dup := m[k]; dup && dec.detectDuplicates {
+				dec.duplicates = append(dec.duplicates, DuplicateKey{Path: dec.pointer(), Position: positionAt(dec.data, keyOff)})
+			}
+			v, err := dec.decodeValue()
+			dec.path = dec.path[:len(dec.path)-1]
+			if err != nil {
+				return nil, err
+			}
+			if _, dup := m[k]; !dup {
+				keys = append(keys, k)
+			}
+			m[k] = v
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		if dec.order != nil {
+			dec.order.set(m, keys)
+		}
+		return m, nil
+	case json.Delim('['):
+		s := make([]interface{}, 0)
+		for d.More() {
+			dec.path = append(dec.path, strconv.Itoa(len(s)))
+			v, err := dec.decodeValue()
+			dec.path = dec.path[:len(dec.path)-1]
+			if err != nil {
+				return nil, err
+			}
+			s = append(s, v)
+		}
+		if _, err := d.Token(); err != nil {
+			return nil, err
+		}
+		return s, nil
+	case json.Delim('}'), json.Delim(']'):
+		return nil, errUnexpectedDelim
+	}
+	return tok, nil
+}
+
+// raw returns tok, the token read at offset off, with the invalid UTF-8 the
+// decoder replaced in it put back if rawStrings is set.
+func (dec *decoder) raw(tok json.Token, off int) json.Token {
+	if s, ok := tok.(string); ok && dec.rawStrings && strings.ContainsRune(s, utf8.RuneError) {
+		return unquoteRaw(dec.data, off)
+	}
+	return tok
+}
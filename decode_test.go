@@ -0,0 +1,172 @@
+package jsondiff
+
+import (
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDecodeKeyOrder(t *testing.T) {
+	order := make(keyOrder)
+	v, err := decode([]byte(`{"id": 1, "name": "x", "details": {"z": [], "a": [{"y": 1, "b": 2}]}}`), order)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := v.(map[string]interface{})
+	if keys := order.keys(m); !reflect.DeepEqual(keys, []string{"id", "name", "details"}) {
+		t.Errorf("unexpected key order %q", keys)
+	}
+	details := m["details"].(map[string]interface{})
+	if keys := order.keys(details); !reflect.DeepEqual(keys, []string{"z", "a"}) {
+		t.Errorf("unexpected key order %q", keys)
+	}
+	inner := details["a"].([]interface{})[0].(map[string]interface{})
+	if keys := order.keys(inner); !reflect.DeepEqual(keys, []string{"y", "b"}) {
+		t.Errorf("unexpected key order %q", keys)
+	}
+}
+
+func TestDecodeMatchesDecoder(t *testing.T) {
+	inputs := []string{
+		`{"a": 1, "b": [true, false, null, "s", 1.5e3], "c": {}}`,
+		`[]`,
+		`"str"`,
+		`{"a": 1, "a": 2}`,
+		``,
+		`{"a" 1}`,
+		`{"a": 1,}`,
+		`[1, 2`,
+		`]`,
+	}
+	for _, in := range inputs {
+		v1, err1 := decode([]byte(in), nil)
+		v2, err2 := decode([]byte(in), make(keyOrder))
+		if (err1 != nil) != (err2 != nil) {
+			t.Errorf("%q: error mismatch: %v vs %v", in, err1, err2)
+			continue
+		}
+		if err1 == nil && !reflect.DeepEqual(v1, v2) {
+			t.Errorf("%q: value mismatch: %#v vs %#v", in, v1, v2)
+		}
+	}
+}
+
+func TestKeyOrderMerge(t *testing.T) {
+	order := make(keyOrder)
+	a, _ := decode([]byte(`{"id": 1, "name": "x", "details": 3}`), order)
+	b, _ := decode([]byte(`{"first": 0, "id": 1, "extra": 2, "more": 3, "details": 3, "last": 4}`), order)
+	keys := order.merge(a.(map[string]interface{}), b.(map[string]interface{}))
+	expected := []string{"first", "id", "extra", "more", "name", "details", "last"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Errorf("got %q, expected %q", keys, expected)
+	}
+}
+
+func TestDecodePositions(t *testing.T) {
+	cases := []struct {
+		in       string
+		expected positions
+	}{
+		{
+			`{"a": 1, "b": [true, "x"], "c/d": {}}`,
+			positions{
+				"":      {0, 1, 1},
+				"/a":    {6, 1, 7},
+				"/b":    {14, 1, 15},
+				"/b/0":  {15, 1, 16},
+				"/b/1":  {21, 1, 22},
+				"/c~1d": {34, 1, 35},
+			},
+		},
+		{
+			"\n{\n  \"a\": {\n    \"b\": [\n      1,\n      2\n    ]\n  },\n  \"c\" :\"x\"\n}",
+			positions{
+				"":       {1, 2, 1},
+				"/a":     {10, 3, 8},
+				"/a/b":   {21, 4, 10},
+				"/a/b/0": {29, 5, 7},
+				"/a/b/1": {38, 6, 7},
+				"/c":     {58, 9, 8},
+			},
+		},
+	}
+	for _, c := range cases {
+		dec := decoder{positions: make(positions)}
+		if _, err := dec.decode([]byte(c.in)); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(dec.positions, c.expected) {
+			t.Errorf("%q: got %#v, expected %#v", c.in, dec.positions, c.expected)
+		}
+	}
+}
+
+func TestDisallowTrailingData(t *testing.T) {
+	opts := Options{DisallowTrailingData: true}
+	cases := []struct {
+		a        string
+		diff     Difference
+		position Position
+	}{
+		{"{\"a\": 1}  \n\t ", FullMatch, Position{}},
+		{`{"a": 1} trailing garbage`, FirstArgIsInvalidJson, Position{9, 1, 10}},
+		{"{\"a\": 1}\n{\"b\": 2}", FirstArgIsInvalidJson, Position{9, 2, 1}},
+		{`{"a": 1}]`, FirstArgIsInvalidJson, Position{8, 1, 9}},
+	}
+	for _, c := range cases {
+		for _, o := range []Options{opts, {DisallowTrailingData: true, PreserveKeyOrder: true}} {
+			diff, _, err := CompareErr([]byte(c.a), []byte(`{"a": 1}`), &o)
+			if diff != c.diff {
+				t.Errorf("%q: got %s, expected %s", c.a, diff, c.diff)
+			}
+			var invalid *InvalidJSONError
+			if c.diff != FullMatch && (!errors.As(err, &invalid) || invalid.Position != c.position) {
+				t.Errorf("%q: got %v, expected trailing data at %v", c.a, err, c.position)
+			}
+			diff, _, _ = CompareReaders(strings.NewReader(c.a), strings.NewReader(`{"a": 1}`), &o)
+			if diff != c.diff {
+				t.Errorf("%q: CompareReaders got %s, expected %s", c.a, diff, c.diff)
+			}
+		}
+		if diff, _ := Compare([]byte(c.a), []byte(`{"a": 1}`), &Options{}); diff != FullMatch {
+			t.Errorf("%q: got %s by default", c.a, diff)
+		}
+	}
+}
+
+func TestEmptyInputAsNull(t *testing.T) {
+	cases := []struct {
+		a, b string
+		// The verdicts without EmptyInputAsNull, with it, and with it and
+		// NullAsEmpty.
+		diffs [3]Difference
+	}{
+		{``, ``, [3]Difference{BothArgsAreInvalidJson, FullMatch, FullMatch}},
+		{" \n\t", ``, [3]Difference{BothArgsAreInvalidJson, FullMatch, FullMatch}},
+		{``, `null`, [3]Difference{FirstArgIsInvalidJson, FullMatch, FullMatch}},
+		{``, `{}`, [3]Difference{FirstArgIsInvalidJson, NoMatch, FullMatch}},
+		{"\r\n", `[]`, [3]Difference{FirstArgIsInvalidJson, NoMatch, FullMatch}},
+		{``, `{"a": 1}`, [3]Difference{FirstArgIsInvalidJson, NoMatch, NoMatch}},
+		{`{}`, ` `, [3]Difference{SecondArgIsInvalidJson, NoMatch, FullMatch}},
+	}
+	variants := []Options{{}, {EmptyInputAsNull: true}, {EmptyInputAsNull: true, NullAsEmpty: true}}
+	for _, c := range cases {
+		for i, opts := range variants {
+			diff, _, err := CompareErr([]byte(c.a), []byte(c.b), &opts)
+			if diff != c.diffs[i] {
+				t.Errorf("%q, %q, variant %d: got %s, expected %s", c.a, c.b, i, diff, c.diffs[i])
+			}
+			if diff == FirstArgIsInvalidJson || diff == SecondArgIsInvalidJson || diff == BothArgsAreInvalidJson {
+				if !errors.Is(err, io.EOF) {
+					t.Errorf("%q, %q, variant %d: got %v, expected io.EOF", c.a, c.b, i, err)
+				}
+			}
+			diff, _, _ = CompareReaders(strings.NewReader(c.a), strings.NewReader(c.b), &opts)
+			if diff != c.diffs[i] {
+				t.Errorf("%q, %q, variant %d: CompareReaders got %s, expected %s", c.a, c.b, i, diff, c.diffs[i])
+			}
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package jsondiff
+
+import "time"
+
+// CompareDecoded is like CompareDetail, but also returns the decoded
+// documents in Result.A and Result.B, with numbers as json.Number. They are
+// the values the comparison ran on, normalized if NormalizeDecoded is set,
+// and are not retained by the library, so the caller may modify them.
+func CompareDecoded(a, b []byte, opts *Options) Result {
+	return differOf(opts).CompareDecoded(a, b)
+}
+
+// CompareDecoded is like the package-level CompareDecoded, using the options
+// of d.
+func (d *Differ) CompareDecoded(a, b []byte) Result {
+	start := time.Now()
+	ctx := d.newContext(nil)
+	ctx.collect = true
+	ctx.keepDecoded = true
+	diff, text := ctx.compare(a, b)
+	r := ctx.detail(diff, text, start)
+	r.A, r.B = ctx.decoded[0], ctx.decoded[1]
+	if ctx.opts.NormalizeDecoded {
+		r.A, r.B = ctx.normalizeDecoded(r.A, 0), ctx.normalizeDecoded(r.B, 1)
+	}
+	return r
+}
+
+// normalizeDecoded returns a copy of v, decoded from document i, without
+// ignored fields and with the strings of StringAsMapFields decoded. The
+// copy shares no map or slice with v, which the entries of the comparison
+// may refer to.
+func (ctx *context) normalizeDecoded(v interface{}, i int) interface{} {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		for k, e := range vv {
+			if _, ignored := ctx.ignoreFields[k]; ignored {
+				continue
+			}
+			if s, ok := e.(string); ok {
+				if _, isStringAsMap := ctx.stringAsMapFields[k]; isStringAsMap {
+					nctx := ctx.differ.nested.newContext(nil)
+					if nv, err := nctx.decode([]byte(s), i); err == nil {
+						m[k] = nctx.normalizeDecoded(nv, i)
+						continue
+					}
+				}
+			}
+			m[k] = ctx.normalizeDecoded(e, i)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(vv))
+		for j, e := range vv {
+			s[j] = ctx.normalizeDecoded(e, i)
+		}
+		return s
+	}
+	return v
+}
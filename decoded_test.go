@@ -0,0 +1,67 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompareDecoded(t *testing.T) {
+	opts := Options{IgnoreFields: []string{"id"}, StringAsMapFields: []string{"doc"}}
+	a := []byte(`{"id": 1, "n": 12345678901234567890, "f": 1.50, "doc": "{\"id\": 2, \"x\": [1]}", "list": [{"id": 3, "v": null}]}`)
+	b := []byte(`{"id": 2, "n": 12345678901234567890, "f": 1.5}`)
+	r := CompareDecoded(a, b, &opts)
+	expectedA := map[string]interface{}{
+		"id": json.Number("1"), "n": json.Number("12345678901234567890"), "f": json.Number("1.50"),
+		"doc":  `{"id": 2, "x": [1]}`,
+		"list": []interface{}{map[string]interface{}{"id": json.Number("3"), "v": nil}},
+	}
+	expectedB := map[string]interface{}{"id": json.Number("2"), "n": json.Number("12345678901234567890"), "f": json.Number("1.5")}
+	if !reflect.DeepEqual(r.A, expectedA) || !reflect.DeepEqual(r.B, expectedB) {
+		t.Errorf("got %#v and %#v", r.A, r.B)
+	}
+	if detail := CompareDetail(a, b, &opts); r.Difference != detail.Difference || r.Text != detail.Text || !reflect.DeepEqual(r.Entries, detail.Entries) {
+		t.Errorf("got %s %q, expected %s %q", r.Difference, r.Text, detail.Difference, detail.Text)
+	}
+
+	opts.NormalizeDecoded = true
+	r = CompareDecoded(a, b, &opts)
+	expectedA = map[string]interface{}{
+		"n": json.Number("12345678901234567890"), "f": json.Number("1.50"),
+		"doc":  map[string]interface{}{"x": []interface{}{json.Number("1")}},
+		"list": []interface{}{map[string]interface{}{"v": nil}},
+	}
+	expectedB = map[string]interface{}{"n": json.Number("12345678901234567890"), "f": json.Number("1.5")}
+	if !reflect.DeepEqual(r.A, expectedA) || !reflect.DeepEqual(r.B, expectedB) {
+		t.Errorf("normalized: got %#v and %#v", r.A, r.B)
+	}
+}
+
+func TestCompareDecodedInvalid(t *testing.T) {
+	r := CompareDecoded([]byte(`[1]`), []byte(`[1`), &Options{})
+	if r.Difference != SecondArgIsInvalidJson || !reflect.DeepEqual(r.A, []interface{}{json.Number("1")}) || r.B != nil {
+		t.Errorf("got %s %#v %#v", r.Difference, r.A, r.B)
+	}
+}
+
+func TestCompareDecodedMutation(t *testing.T) {
+	a, b := []byte(`{"a": {"b": [1, 2]}, "c": 1}`), []byte(`{"a": {"b": [1]}}`)
+	for _, normalize := range []bool{false, true} {
+		opts := Options{NormalizeDecoded: normalize}
+		d, err := NewDiffer(opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r := d.CompareDetail(a, b)
+		decoded := d.CompareDecoded(a, b)
+		decoded.A.(map[string]interface{})["a"].(map[string]interface{})["b"].([]interface{})[1] = "changed"
+		decoded.B.(map[string]interface{})["c"] = 1
+		again := d.CompareDetail(a, b)
+		if again.Difference != r.Difference || again.Text != r.Text || !reflect.DeepEqual(again.Entries, r.Entries) {
+			t.Errorf("normalize %v: comparing again gave %s %q, expected %s %q", normalize, again.Difference, again.Text, r.Difference, r.Text)
+		}
+		if normalize && decoded.Entries[0].Old != json.Number("2") {
+			t.Errorf("the entries share values with the normalized documents: %v", decoded.Entries[0].Old)
+		}
+	}
+}
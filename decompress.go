@@ -0,0 +1,52 @@
+package jsondiff
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+)
+
+// DecompressError is the error of an input with AutoDecompress set that
+// starts like a gzip stream but cannot be decompressed.
+type DecompressError struct {
+	Err error
+}
+
+func (e *DecompressError) Error() string {
+	return "decompressing gzip: " + e.Err.Error()
+}
+
+func (e *DecompressError) Unwrap() error {
+	return e.Err
+}
+
+// decompress returns a reader decompressing r if it starts with the gzip
+// magic bytes, or a reader of r as it is otherwise. Errors of the
+// decompression are returned as *DecompressError, errors reading r as they
+// are.
+func decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(2)
+	if err != nil || magic[0] != 0x1f || magic[1] != 0x8b {
+		// Short inputs and read errors are left to the decoder.
+		return br, nil
+	}
+	zr, err := gzip.NewReader(br)
+	if err != nil {
+		return nil, &DecompressError{Err: err}
+	}
+	return &gzipReader{zr}, nil
+}
+
+// gzipReader wraps the errors of a gzip.Reader in *DecompressError.
+type gzipReader struct {
+	zr *gzip.Reader
+}
+
+func (gr *gzipReader) Read(p []byte) (int, error) {
+	n, err := gr.zr.Read(p)
+	if err != nil && err != io.EOF {
+		err = &DecompressError{Err: err}
+	}
+	return n, err
+}
@@ -0,0 +1,76 @@
+package jsondiff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func gzipped(s string) []byte {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	zw.Write([]byte(s))
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestAutoDecompress(t *testing.T) {
+	opts := Options{Indent: "  ", AutoDecompress: true}
+	cases := []struct {
+		a    []byte
+		b    string
+		diff Difference
+	}{
+		{gzipped(`{"a": 1, "b": [1, 2]}`), `{"a": 2, "b": [1, 3]}`, NoMatch},
+		{gzipped(`{"a": 1}`), `{"a": 1}`, FullMatch},
+		{gzipped(`{"a": `), `{"a": 1}`, FirstArgIsInvalidJson},
+		{[]byte(`{"a": 1}`), `{"a": 1}`, FullMatch},
+		{[]byte(`1`), `1`, FullMatch},
+		{nil, `{}`, FirstArgIsInvalidJson},
+	}
+	for _, c := range cases {
+		plain := c.a
+		if zr, err := gzip.NewReader(bytes.NewReader(c.a)); err == nil {
+			plain, _ = io.ReadAll(zr)
+		}
+		expDiff, expected := Compare(plain, []byte(c.b), &opts)
+		diff, text, err := CompareReaders(iotest.OneByteReader(bytes.NewReader(c.a)), strings.NewReader(c.b), &opts)
+		if err != nil || diff != c.diff || diff != expDiff || text != expected {
+			t.Errorf("%q: got %s %q %v, expected %s %q", plain, diff, text, err, expDiff, expected)
+		}
+	}
+	// Without AutoDecompress a gzip stream is not JSON.
+	opts.AutoDecompress = false
+	if diff, _, err := CompareReaders(bytes.NewReader(gzipped(`{}`)), strings.NewReader(`{}`), &opts); err != nil || diff != FirstArgIsInvalidJson {
+		t.Errorf("got %s and %v, expected %s", diff, err, FirstArgIsInvalidJson)
+	}
+}
+
+func TestAutoDecompressCorrupted(t *testing.T) {
+	opts := Options{AutoDecompress: true, TrackPositions: true}
+	valid := gzipped(`{"a": [1, 2, 3]}`)
+	truncated := valid[:len(valid)-6]
+	corrupted := append([]byte(nil), valid...)
+	corrupted[len(corrupted)-1] ^= 0xff
+	header := []byte{0x1f, 0x8b, 0, 0}
+	for _, data := range [][]byte{truncated, corrupted, header} {
+		for _, o := range []Options{opts, {AutoDecompress: true}} {
+			_, _, err := CompareReaders(strings.NewReader(`{}`), bytes.NewReader(data), &o)
+			var de *DecompressError
+			if !errors.As(err, &de) || !strings.Contains(err.Error(), "second document") {
+				t.Errorf("%x: got %v, expected a decompression error", data, err)
+			}
+		}
+	}
+	broken := errors.New("connection reset")
+	r := io.MultiReader(bytes.NewReader(valid[:20]), iotest.ErrReader(broken))
+	_, _, err := CompareReaders(r, strings.NewReader(`{}`), &opts)
+	var de *DecompressError
+	if !errors.Is(err, broken) || errors.As(err, &de) {
+		t.Errorf("got %v, expected a read error", err)
+	}
+}
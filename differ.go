@@ -0,0 +1,292 @@
+package jsondiff
+
+import (
+	gocontext "context"
+	"strings"
+)
+
+// Differ compares JSON documents using a fixed set of options. The options
+// are prepared once by NewDiffer, so comparing many documents with a Differ
+// does less work per call than the package-level functions. A Differ is safe
+// for concurrent use by multiple goroutines.
+type Differ struct {
+	opts              Options
+	fuzzyFields       map[string]struct{}
+	ignoreFields      map[string]struct{}
+	stringAsMapFields map[string]struct{}
+	// maskFields and maskPaths are the names and the JSON Pointers of
+	// MaskFields.
+	maskFields map[string]struct{}
+	maskPaths  map[string]struct{}
+	// severityFields and severityPaths are the names and the JSON Pointers
+	// of Severities.
+	severityFields map[string]Severity
+	severityPaths  map[string]Severity
+	// weightFields and weightPaths are the names and the JSON Pointers of
+	// Weights.
+	weightFields map[string]float64
+	weightPaths  map[string]float64
+	templates    bool
+	// tags are the tags of opts parsed for expansion, if templates is set.
+	tags [5]compiledTag
+	// lineStarts is a line break followed by the prefix and maxCachedIndent
+	// indents, sliced by lineStart.
+	lineStarts string
+	// maxDepth is opts.MaxDepth or its default.
+	maxDepth int
+	// nested compares the documents embedded in StringAsMapFields.
+	nested *Differ
+}
+
+// NewDiffer returns a Differ comparing documents with opts, or the error
+// returned by opts.Validate. The fields of opts are read by NewDiffer and
+// must not be modified while the Differ is in use.
+func NewDiffer(opts Options) (*Differ, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return newDiffer(opts), nil
+}
+
+// differOf returns the Differ a package-level function compares with. A nil
+// opts stands for the zero Options.
+func differOf(opts *Options) *Differ {
+	if opts == nil {
+		return newDiffer(Options{})
+	}
+	return newDiffer(*opts)
+}
+
+// newDiffer is NewDiffer without validating opts, which the package-level
+// functions use to keep comparing with whatever options they are given.
+func newDiffer(opts Options) *Differ {
+	opts.Normal = closedTag(opts.Normal)
+	opts.Added = closedTag(opts.Added)
+	opts.Removed = closedTag(opts.Removed)
+	opts.Changed = closedTag(opts.Changed)
+	opts.Warning = closedTag(opts.Warning)
+	d := &Differ{
+		opts:              opts,
+		fuzzyFields:       fieldSet(opts.FuzzyFields),
+		ignoreFields:      fieldSet(opts.IgnoreFields),
+		stringAsMapFields: fieldSet(opts.StringAsMapFields),
+		templates:         opts.hasTemplates(),
+		lineStarts:        lineStarts(opts.Prefix, opts.Indent),
+		maxDepth:          opts.MaxDepth,
+	}
+	if d.maxDepth == 0 {
+		d.maxDepth = DefaultMaxDepth
+	}
+	for _, f := range opts.MaskFields {
+		set := &d.maskFields
+		if strings.HasPrefix(f, "/") {
+			set = &d.maskPaths
+		}
+		if *set == nil {
+			*set = make(map[string]struct{})
+		}
+		(*set)[f] = struct{}{}
+	}
+	for f, s := range opts.Severities {
+		set := &d.severityFields
+		if strings.HasPrefix(f, "/") {
+			set = &d.severityPaths
+		}
+		if *set == nil {
+			*set = make(map[string]Severity)
+		}
+		(*set)[f] = s
+	}
+	for f, w := range opts.Weights {
+		set := &d.weightFields
+		if strings.HasPrefix(f, "/") {
+			set = &d.weightPaths
+		}
+		if *set == nil {
+			*set = make(map[string]float64)
+		}
+		(*set)[f] = w
+	}
+	if d.templates {
+		d.tags = compileTags(&d.opts)
+	}
+	if len(opts.StringAsMapFields) > 0 {
+		nested := *d
+		nested.opts.MaxOutputBytes = 0
+		nested.opts.ShowSummary = false
+		nested.opts.ShowLegend = false
+		nested.opts.EmptyInputAsNull = false
+		nested.opts.AlwaysRender = false
+		d.nested = &nested
+		nested.nested = &nested
+	}
+	return d
+}
+
+// fieldSet returns the set of fields, or nil, which looks up like an empty
+// set, if there are none.
+func fieldSet(fields []string) map[string]struct{} {
+	if len(fields) == 0 {
+		return nil
+	}
+	return sliceToSet(fields)
+}
+
+// closedTag returns tag with the End that closes its Begin if End is empty
+// and Begin only opens things that must be closed: HTML elements, closed in
+// reverse order, or ANSI SGR sequences, closed by a reset. Other tags, such
+// as the symbols of DefaultSymbolOptions, are returned as they are.
+func closedTag(tag Tag) Tag {
+	if tag.End != "" || tag.Begin == "" {
+		return tag
+	}
+	if isSGR(tag.Begin) {
+		tag.End = "\033[0m"
+		return tag
+	}
+	var names []string
+	for s := tag.Begin; s != ""; {
+		name, rest, ok := cutOpeningTag(s)
+		if !ok {
+			return tag
+		}
+		names = append(names, name)
+		s = rest
+	}
+	var end strings.Builder
+	for i := len(names) - 1; i >= 0; i-- {
+		end.WriteString("</" + names[i] + ">")
+	}
+	tag.End = end.String()
+	return tag
+}
+
+// isSGR reports whether s is made of ANSI SGR sequences only, such as
+// "\033[1;31m".
+func isSGR(s string) bool {
+	for s != "" {
+		if !strings.HasPrefix(s, "\033[") {
+			return false
+		}
+		i := strings.IndexByte(s, 'm')
+		if i < 0 || strings.Trim(s[2:i], "0123456789;") != "" {
+			return false
+		}
+		s = s[i+1:]
+	}
+	return true
+}
+
+// cutOpeningTag returns the name of the HTML opening tag s starts with, such
+// as "span" for `<span class="x">`, and the rest of s. Self-closing tags do
+// not count, as they need no closing.
+func cutOpeningTag(s string) (name, rest string, ok bool) {
+	if len(s) < 3 || s[0] != '<' {
+		return "", "", false
+	}
+	i := 1
+	for i < len(s) && (s[i] >= 'a' && s[i] <= 'z' || s[i] >= 'A' && s[i] <= 'Z' || i > 1 && s[i] >= '0' && s[i] <= '9') {
+		i++
+	}
+	name = s[1:i]
+	if i < len(s) && strings.IndexByte(" \t\n/>", s[i]) < 0 {
+		return "", "", false
+	}
+	var quote byte
+	for ; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '>':
+			if name == "" || s[i-1] == '/' {
+				return "", "", false
+			}
+			return name, s[i+1:], true
+		}
+	}
+	return "", "", false
+}
+
+// missingMatches reports whether a member with key k present in one of the
+// documents only matches, which IntersectionOnly makes every member do and
+// FuzzyAllowMissing makes FuzzyFields do.
+func (d *Differ) missingMatches(k string) bool {
+	if d.opts.IntersectionOnly {
+		return true
+	}
+	if !d.opts.FuzzyAllowMissing {
+		return false
+	}
+	_, fuzzy := d.fuzzyFields[k]
+	return fuzzy
+}
+
+// lineStarts returns the string lineStart slices: a line break followed by
+// prefix and maxCachedIndent indents.
+func lineStarts(prefix, indent string) string {
+	if prefix == "" && indent == "" {
+		return "\n"
+	}
+	var b strings.Builder
+	b.Grow(1 + len(prefix) + maxCachedIndent*len(indent))
+	b.WriteString("\n")
+	b.WriteString(prefix)
+	for i := 0; i < maxCachedIndent; i++ {
+		b.WriteString(indent)
+	}
+	return b.String()
+}
+
+// maxCachedIndent is the number of indentation levels lineStart returns at
+// most; deeper lines write the remaining indents one by one.
+const maxCachedIndent = 32
+
+// lineStart returns the line break, the prefix and the indentation of a line
+// at level, up to maxCachedIndent levels, for writing them in one go.
+func (d *Differ) lineStart(level int) string {
+	if level > maxCachedIndent {
+		level = maxCachedIndent
+	}
+	return d.lineStarts[:1+len(d.opts.Prefix)+level*len(d.opts.Indent)]
+}
+
+// Compare is like the package-level Compare, using the options of d.
+func (d *Differ) Compare(a, b []byte) (Difference, string) {
+	diff, text, _ := d.CompareContext(gocontext.Background(), a, b)
+	return diff, text
+}
+
+// With returns a Differ using the options of d changed by extra, or the
+// error returned by Validate. The options are applied to a deep copy made
+// with Options.Clone, so an Option appending to a slice of d's options
+// cannot write into it, and d can still be used concurrently.
+func (d *Differ) With(extra ...Option) (*Differ, error) {
+	opts := d.optionsWith(extra)
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return newDiffer(opts), nil
+}
+
+// CompareWith is like Compare with the options of d changed by extra for
+// this call only, like by With. Like the package-level functions, it does
+// not validate the resulting options.
+func (d *Differ) CompareWith(a, b []byte, extra ...Option) (Difference, string) {
+	if len(extra) == 0 {
+		return d.Compare(a, b)
+	}
+	return newDiffer(d.optionsWith(extra)).Compare(a, b)
+}
+
+// optionsWith returns a copy of the options of d changed by extra.
+func (d *Differ) optionsWith(extra []Option) Options {
+	opts := d.opts.Clone()
+	for _, o := range extra {
+		o(&opts)
+	}
+	return opts
+}
@@ -0,0 +1,277 @@
+package jsondiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestDifferConcurrent(t *testing.T) {
+	opts := DefaultConsoleOptions()
+	opts.IgnoreFields = []string{"ignored"}
+	opts.StringAsMapFields = []string{"doc"}
+	d, err := NewDiffer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var wg sync.WaitGroup
+	errs := make(chan error, 64)
+	for i := 0; i < 64; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			a := fmt.Sprintf(`{"n": %d, "ignored": %d, "doc": "{\"x\": %d}"}`, i, i, i)
+			b := fmt.Sprintf(`{"n": %d, "ignored": 0, "doc": "{\"x\": %d}"}`, i+i%2, i)
+			diff, got := d.Compare([]byte(a), []byte(b))
+			expDiff, expected := Compare([]byte(a), []byte(b), &opts)
+			if diff != expDiff || got != expected {
+				errs <- fmt.Errorf("%d: got %s %q, expected %s %q", i, diff, got, expDiff, expected)
+			}
+			if (i%2 == 0) != (diff == FullMatch) {
+				errs <- fmt.Errorf("%d: unexpected %s", i, diff)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+}
+
+func TestCompareWith(t *testing.T) {
+	ignored := make([]string, 1, 8)
+	ignored[0] = "id"
+	opts := Options{IgnoreFields: ignored}
+	d, err := NewDiffer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// appendIgnored appends to the slice in place, like the code this
+	// protects against.
+	appendIgnored := func(field string) Option {
+		return func(opts *Options) {
+			opts.IgnoreFields = append(opts.IgnoreFields, field)
+		}
+	}
+	fields := []string{"a", "b", "c", "d", "e", "f", "g", "h"}
+	var wg sync.WaitGroup
+	errs := make(chan error, 8*len(fields))
+	for i := 0; i < 8; i++ {
+		for j, field := range fields {
+			wg.Add(1)
+			go func(j int, field string) {
+				defer wg.Done()
+				a := `{"id": 1, "a": 1, "b": 1, "c": 1, "d": 1, "e": 1, "f": 1, "g": 1, "h": 1}`
+				b := `{"id": 2, "a": 1, "b": 1, "c": 1, "d": 1, "e": 1, "f": 1, "g": 1, "h": 1}`
+				b = strings.Replace(b, `"`+field+`": 1`, `"`+field+`": 2`, 1)
+				other := fields[(j+1)%len(fields)]
+				if diff, text := d.CompareWith([]byte(a), []byte(b), appendIgnored(field)); diff != FullMatch {
+					errs <- fmt.Errorf("ignoring %s: got %s %q", field, diff, text)
+				}
+				if diff, _ := d.CompareWith([]byte(a), []byte(b), appendIgnored(other)); diff != NoMatch {
+					errs <- fmt.Errorf("ignoring %s instead of %s: got %s", other, field, diff)
+				}
+			}(j, field)
+		}
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(err)
+	}
+	if len(d.opts.IgnoreFields) != 1 || ignored[:2][1] != "" {
+		t.Errorf("the options of the Differ were modified: %q", ignored[:cap(ignored)])
+	}
+	if diff, _ := d.Compare([]byte(`{"id": 1, "a": 1}`), []byte(`{"id": 2, "a": 2}`)); diff != NoMatch {
+		t.Errorf("got %s", diff)
+	}
+}
+
+func TestWith(t *testing.T) {
+	d, err := New(WithIgnoreFields("id"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := d.With(WithFuzzyFields("ts"), WithIndent("  "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, b := []byte(`{"id": 1, "ts": 1, "x": [1]}`), []byte(`{"id": 2, "ts": 2, "x": [2]}`)
+	if diff, text := d2.Compare(a, b); diff != NoMatch || text != "{\n  \"x\": [\n    1 => 2\n  ]\n}" {
+		t.Errorf("got %s %q", diff, text)
+	}
+	if d.opts.Indent != "" || d.opts.FuzzyFields != nil {
+		t.Errorf("the options of the Differ were modified: %+v", d.opts)
+	}
+	if _, err := d.With(WithMaxOutputBytes(-1)); err == nil {
+		t.Error("With accepted invalid options")
+	}
+}
+
+func TestNewDifferSetup(t *testing.T) {
+	// Options without fields or indentation need nothing but the Differ.
+	if allocs := testing.AllocsPerRun(100, func() { newDiffer(Options{}) }); allocs > 1 {
+		t.Errorf("newDiffer allocates %v times for zero Options", allocs)
+	}
+	d := newDiffer(Options{IgnoreFields: []string{"a"}, Prefix: "> ", Indent: "\t"})
+	if d.fuzzyFields != nil || d.stringAsMapFields != nil || len(d.ignoreFields) != 1 {
+		t.Errorf("got sets %v, %v, %v", d.fuzzyFields, d.ignoreFields, d.stringAsMapFields)
+	}
+	if got := d.lineStart(2); got != "\n> \t\t" {
+		t.Errorf("lineStart(2) = %q", got)
+	}
+}
+
+func TestNilOptions(t *testing.T) {
+	a, b := []byte(`{"a": [1, 2], "b": "x"}`), []byte(`{"a": [1], "b": "y", "c": null}`)
+	zero := &Options{}
+	diff, text := Compare(a, b, nil)
+	if wantDiff, want := Compare(a, b, zero); diff != wantDiff || text != want {
+		t.Errorf("got %s:\n%s\nexpected %s:\n%s", diff, text, wantDiff, want)
+	}
+	if diff, text, err := CompareErr(a, []byte(`{`), nil); diff != SecondArgIsInvalidJson || text == "" || err == nil {
+		t.Errorf("CompareErr got %s, %q, %v", diff, text, err)
+	}
+	if _, entries := CompareEntries(a, b, nil); len(entries) != 3 {
+		t.Errorf("CompareEntries got %d entries", len(entries))
+	}
+	if Equal(a, b, nil) || !Equal(a, a, nil) {
+		t.Error("Equal is wrong")
+	}
+	if diff, _, err := CompareReaders(bytes.NewReader(a), bytes.NewReader(b), nil); diff != NoMatch || err != nil {
+		t.Errorf("CompareReaders got %s, %v", diff, err)
+	}
+	if diff, err := CompareStream(bytes.NewReader(a), bytes.NewReader(a), nil); diff != FullMatch || err != nil {
+		t.Errorf("CompareStream got %s, %v", diff, err)
+	}
+	if r := CompareDetail(a, b, nil); r.Difference != NoMatch {
+		t.Errorf("CompareDetail got %s", r.Difference)
+	}
+	if diff, _ := CompareValues([]interface{}{true}, []interface{}{false}, nil); diff != NoMatch {
+		t.Errorf("CompareValues got %s", diff)
+	}
+	if s, err := Similarity(a, a, nil); s != 1 || err != nil {
+		t.Errorf("Similarity got %v, %v", s, err)
+	}
+	it := NewIterator(a, b, nil)
+	n := 0
+	for it.Next() {
+		n++
+	}
+	if n != 3 {
+		t.Errorf("the iterator got %d entries", n)
+	}
+	var buf bytes.Buffer
+	if diff, err := WriteNDJSON(&buf, a, b, nil); diff != NoMatch || err != nil || buf.Len() == 0 {
+		t.Errorf("WriteNDJSON got %s, %v", diff, err)
+	}
+}
+
+func TestClosedTag(t *testing.T) {
+	cases := []struct {
+		begin, end string
+	}{
+		{"", ""},
+		{"+ ", ""},
+		{"<", ""},
+		{"<br/>", ""},
+		{"<b>x", ""},
+		{"<b", ""},
+		{"\033[31mx", ""},
+		{"\033[1;31m", "\033[0m"},
+		{"\033[1m\033[31m", "\033[0m"},
+		{"<span>", "</span>"},
+		{`<span class="a>b" title='{path}'>`, "</span>"},
+		{"<b><i>", "</i></b>"},
+		{"<h1 >", "</h1>"},
+	}
+	for _, c := range cases {
+		if got := closedTag(Tag{Begin: c.begin}); got != (Tag{c.begin, c.end}) {
+			t.Errorf("%q: got End %q, expected %q", c.begin, got.End, c.end)
+		}
+	}
+	if got := closedTag(Tag{Begin: "<b>", End: "!"}); got.End != "!" {
+		t.Errorf("a set End was replaced with %q", got.End)
+	}
+
+	// Half-filled tags are balanced in the output.
+	opts := Options{Normal: Tag{Begin: "<n>"}, Changed: Tag{Begin: "\033[33m"}, Added: Tag{Begin: "+"}, Indent: " "}
+	_, text := Compare([]byte(`{"a": 1, "b": [1]}`), []byte(`{"a": 2, "b": [1, 2]}`), &opts)
+	expected := "<n>{</n>\n <n>\"a\": </n>\033[33m1 => 2\033[0m<n>,</n>\n <n>\"b\": [</n>\n  +2\n <n>]</n>\n<n>}</n>"
+	if text != expected {
+		t.Errorf("got:\n%q\nexpected:\n%q", text, expected)
+	}
+	if opts.Normal.End != "" {
+		t.Error("the options passed were changed")
+	}
+}
+
+var benchA = []byte(`{"id": 1, "name": "x", "tags": ["a", "b"], "meta": {"updated": "now", "n": 1}}`)
+var benchB = []byte(`{"id": 1, "name": "y", "tags": ["a", "c"], "meta": {"updated": "later", "n": 1}}`)
+
+func benchOptions() Options {
+	opts := DefaultConsoleOptions()
+	opts.FuzzyFields = []string{"updated", "created", "version"}
+	opts.IgnoreFields = []string{"etag", "trace", "request_id"}
+	opts.StringAsMapFields = []string{"payload"}
+	return opts
+}
+
+func BenchmarkCompare(b *testing.B) {
+	opts := benchOptions()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Compare(benchA, benchB, &opts)
+	}
+}
+
+func BenchmarkDifferCompare(b *testing.B) {
+	d, err := NewDiffer(benchOptions())
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.Compare(benchA, benchB)
+	}
+}
+
+// BenchmarkCompareEmptyOptions compares small documents with zero Options,
+// where setting up the comparison is a large part of the work.
+func BenchmarkCompareEmptyOptions(b *testing.B) {
+	opts := Options{}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Compare(benchA, benchB, &opts)
+	}
+}
+
+// BenchmarkCompareStringAsMap compares an array of objects whose "payload"
+// holds an embedded document, every tenth of which differs.
+func BenchmarkCompareStringAsMap(b *testing.B) {
+	var docA, docB bytes.Buffer
+	docA.WriteString("[")
+	docB.WriteString("[")
+	for i := 0; i < 1000; i++ {
+		if i > 0 {
+			docA.WriteString(",")
+			docB.WriteString(",")
+		}
+		fmt.Fprintf(&docA, `{"id": %d, "payload": "{\"n\": %d, \"s\": \"x\"}"}`, i, i)
+		n := i
+		if i%10 == 0 {
+			n = -i
+		}
+		fmt.Fprintf(&docB, `{"id": %d, "payload": "{\"s\": \"x\", \"n\": %d}"}`, i, n)
+	}
+	docA.WriteString("]")
+	docB.WriteString("]")
+	opts := benchOptions()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Compare(docA.Bytes(), docB.Bytes(), &opts)
+	}
+}
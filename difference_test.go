@@ -0,0 +1,135 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestDifferenceHelpers(t *testing.T) {
+	for d := FullMatch; d <= SubsetMatch; d++ {
+		invalid := d == FirstArgIsInvalidJson || d == SecondArgIsInvalidJson || d == BothArgsAreInvalidJson
+		if d.IsMatch() != (d == FullMatch) || d.IsSuperset() != (d == SupersetMatch) || d.IsSubset() != (d == SubsetMatch) || d.IsInvalidJson() != invalid {
+			t.Errorf("wrong helper result for %s", d)
+		}
+	}
+}
+
+func TestDifferenceMarshaling(t *testing.T) {
+	for d := FullMatch; d <= SubsetMatch; d++ {
+		data, err := json.Marshal(map[string]Difference{"d": d})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expected := `{"d":"` + d.String() + `"}`; string(data) != expected {
+			t.Errorf("got %s, expected %s", data, expected)
+		}
+		var m map[string]Difference
+		if err := json.Unmarshal(data, &m); err != nil || m["d"] != d {
+			t.Errorf("%s: got %s and %v", d, m["d"], err)
+		}
+		var text Difference
+		if b, _ := d.MarshalText(); text.UnmarshalText(b) != nil || text != d {
+			t.Errorf("%s: text round trip gave %s", d, text)
+		}
+	}
+	var d Difference
+	for _, garbage := range []string{`"Match"`, `"fullmatch"`, `2`, `""`, `{}`} {
+		if err := json.Unmarshal([]byte(garbage), &d); err == nil {
+			t.Errorf("%s: no error", garbage)
+		}
+	}
+	if _, err := json.Marshal(Difference(42)); err == nil {
+		t.Error("no error marshaling an invalid Difference")
+	}
+}
+
+func TestSubsetMatch(t *testing.T) {
+	opts := Options{StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b string
+		diff Difference
+	}{
+		{`{"a": 1}`, `{"a": 1, "b": 2}`, SubsetMatch},
+		{`[1]`, `[1, 2]`, SubsetMatch},
+		{`{"a": {"b": [1]}}`, `{"a": {"b": [1, {"c": 1}], "d": 1}}`, SubsetMatch},
+		{`{"x": [{"y": {}}]}`, `{"x": [{"y": {"z": null}}]}`, SubsetMatch},
+		{`{"doc": "{\"p\": 1}"}`, `{"doc": "{\"p\": 1, \"q\": 2}"}`, SubsetMatch},
+		{`{"a": 1, "b": 2}`, `{"a": 1}`, SupersetMatch},
+		{`{"a": {"b": [1, 2], "c": 1}}`, `{"a": {"b": [1]}}`, SupersetMatch},
+		{`{"a": 1}`, `{"b": 1}`, NoMatch},
+		{`{"a": {"b": 1}, "c": [1]}`, `{"a": {}, "c": [1, 2]}`, NoMatch},
+		{`{"a": {"b": [1, 2]}}`, `{"a": {"b": [1], "c": 1}}`, NoMatch},
+		{`{"a": {"b": 1}}`, `{"a": {"b": 2, "c": 1}}`, NoMatch},
+		{`[1, {"a": 1}]`, `[1, {"a": 2}, 3]`, NoMatch},
+		{`[1, 2]`, `[1, 2, 3, [4]]`, SubsetMatch},
+		{`[[1, 2], [1]]`, `[[1], [1, 2]]`, NoMatch},
+		{`{"a": [1, 2], "b": [1]}`, `{"a": [1], "b": [1, 2]}`, NoMatch},
+	}
+	for _, c := range cases {
+		if diff, _ := Compare([]byte(c.a), []byte(c.b), &opts); diff != c.diff {
+			t.Errorf("%s, %s: got %s, expected %s", c.a, c.b, diff, c.diff)
+		}
+		swapped := c.diff
+		switch c.diff {
+		case SubsetMatch:
+			swapped = SupersetMatch
+		case SupersetMatch:
+			swapped = SubsetMatch
+		}
+		if diff, _ := Compare([]byte(c.b), []byte(c.a), &opts); diff != swapped {
+			t.Errorf("%s, %s: got %s, expected %s", c.b, c.a, diff, swapped)
+		}
+	}
+
+	// Extra elements of the second array are rendered as added.
+	opts.Added = Tag{Begin: "<a>", End: "</a>"}
+	if _, msg := Compare([]byte(`[1]`), []byte(`[1, 2]`), &opts); msg != "[\n<a>2</a>\n]" {
+		t.Errorf("extra element not rendered as added:\n%s", msg)
+	}
+}
+
+func TestCombine(t *testing.T) {
+	cases := []struct {
+		a, b, expected Difference
+	}{
+		{FullMatch, FullMatch, FullMatch},
+		{FullMatch, SupersetMatch, SupersetMatch},
+		{SubsetMatch, FullMatch, SubsetMatch},
+		{SupersetMatch, SupersetMatch, SupersetMatch},
+		{SupersetMatch, SubsetMatch, NoMatch},
+		{NoMatch, SupersetMatch, NoMatch},
+		{SubsetMatch, NoMatch, NoMatch},
+	}
+	for _, c := range cases {
+		if got := combine(c.a, c.b); got != c.expected {
+			t.Errorf("combine(%s, %s) = %s, expected %s", c.a, c.b, got, c.expected)
+		}
+	}
+}
+
+// TestPrintStringDiffWorst checks that the Difference of a document embedded
+// in a string is the worst of its members, not the last one that differs.
+func TestPrintStringDiffWorst(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		expected Difference
+	}{
+		{`{"a": 1, "b": 2, "c": 3}`, `{"a": 9}`, NoMatch},
+		{`{"a": [1, 2, 3], "b": 2}`, `{"a": [9]}`, NoMatch},
+		{`[1, {"b": 2}, 3]`, `[9, {}]`, NoMatch},
+		{`{"a": 1, "b": 2, "c": 3}`, `{"a": 1}`, SupersetMatch},
+		{`{"a": [{}], "z": 1}`, `{"a": [{"x": 1}], "z": 1, "zz": 2}`, SubsetMatch},
+	}
+	for _, c := range cases {
+		ctx := newDiffer(Options{StringAsMapFields: []string{"doc"}}).newContext(nil)
+		ctx.curKey = "doc"
+		var buf bytes.Buffer
+		if diff := ctx.printStringDiff(&buf, c.a, c.b); diff != c.expected || ctx.diff != c.expected {
+			t.Errorf("%s, %s: got %s, recorded %s, expected %s", c.a, c.b, diff, ctx.diff, c.expected)
+		}
+		if diff, _ := Compare([]byte(c.a), []byte(c.b), &Options{}); diff != c.expected {
+			t.Errorf("%s, %s: Compare got %s, expected %s", c.a, c.b, diff, c.expected)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package jsondiff
+
+// DiffKind is the kind of a single difference between two documents.
+type DiffKind int
+
+const (
+	// KindAdded means the value is present only in the second document.
+	KindAdded DiffKind = iota
+	// KindRemoved means the value is present only in the first document.
+	KindRemoved
+	// KindChanged means the value is present in both documents but differs.
+	KindChanged
+)
+
+func (k DiffKind) String() string {
+	switch k {
+	case KindAdded:
+		return "added"
+	case KindRemoved:
+		return "removed"
+	case KindChanged:
+		return "changed"
+	}
+	return "invalid"
+}
+
+// DiffEntry describes a single difference between two documents.
+type DiffEntry struct {
+	// Path is the JSON Pointer of the value. Values inside StringAsMapFields
+	// documents have the pointer of the field, a '#' and the pointer inside
+	// the document as their path.
+	Path string
+	Kind DiffKind
+	// Old is the value in the first document, nil for added values.
+	Old interface{}
+	// New is the value in the second document, nil for removed values.
+	New interface{}
+	// OldPos and NewPos are where the value starts in the first and second
+	// document. They are only set with Options.TrackPositions, and nil for
+	// added and removed values respectively.
+	OldPos *Position
+	NewPos *Position
+	// Severity is the severity of the value set by Options.Severities.
+	Severity Severity
+}
+
+// record notes a difference at the value being compared, reports it to
+// OnDifference and adds an entry for it if entries are collected or passes
+// it to emit. An error of emit stops the comparison. Differences past
+// MaxDifferences are not reported.
+func (ctx *context) record(kind DiffKind, a, b interface{}) {
+	if ctx.stopped {
+		return
+	}
+	ctx.differences++
+	if ctx.differences == ctx.opts.MaxDifferences {
+		ctx.stopped = true
+	}
+	if ctx.differ.masking() {
+		if kind != KindAdded {
+			a = ctx.mask(a)
+		}
+		if kind != KindRemoved {
+			b = ctx.mask(b)
+		}
+	}
+	if ctx.warn {
+		ctx.warnings++
+	} else if !ctx.mismatched {
+		ctx.mismatched = true
+		ctx.firstPath = ctx.pointer()
+	}
+	if ctx.opts.OnDifference != nil {
+		ctx.opts.OnDifference(ctx.pointer(), kind, a, b)
+	}
+	if ctx.collect || ctx.emit != nil {
+		oldPos, newPos := ctx.positionsOf(kind)
+		e := DiffEntry{Path: ctx.pointer(), Kind: kind, Old: a, New: b, OldPos: oldPos, NewPos: newPos,
+			Severity: ctx.severity()}
+		if ctx.collect {
+			ctx.entries = append(ctx.entries, e)
+		}
+		if ctx.emit != nil {
+			if err := ctx.emit(e); err != nil {
+				ctx.err = err
+			}
+		}
+	}
+}
+
+// CompareEntries compares two JSON documents like Compare, but instead of the
+// rendered text returns the list of differences in document order. Values
+// in the entries are decoded like by encoding/json with UseNumber. Ignored
+// and fuzzy fields never produce entries. For invalid JSON documents the
+// list is empty.
+func CompareEntries(a, b []byte, opts *Options) (Difference, []DiffEntry) {
+	return differOf(opts).CompareEntries(a, b)
+}
+
+// CompareEntries is like the package-level CompareEntries, using the options
+// of d.
+func (d *Differ) CompareEntries(a, b []byte) (Difference, []DiffEntry) {
+	ctx := d.newContext(nil)
+	ctx.collect = true
+	diff, _ := ctx.compare(a, b)
+	return diff, ctx.entries
+}
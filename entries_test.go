@@ -0,0 +1,108 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestCompareEntries(t *testing.T) {
+	opts := Options{
+		IgnoreFields:      []string{"ignored"},
+		FuzzyFields:       []string{"fuzzy"},
+		StringAsMapFields: []string{"doc"},
+	}
+	a := `{"ignored": 1, "fuzzy": 1, "doc": "{\"x\": 1}", "arr": [1, 2], "same": true}`
+	b := `{"ignored": 2, "fuzzy": 2, "doc": "{\"x\": 2}", "arr": [1], "same": true}`
+	diff, entries := CompareEntries([]byte(a), []byte(b), &opts)
+	if diff != NoMatch {
+		t.Errorf("got %s, expected %s", diff, NoMatch)
+	}
+	expected := []DiffEntry{
+		{Path: "/arr/1", Kind: KindRemoved, Old: json.Number("2")},
+		{Path: "/doc#/x", Kind: KindChanged, Old: json.Number("1"), New: json.Number("2")},
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Errorf("got %#v, expected %#v", entries, expected)
+	}
+
+	if diff, entries := CompareEntries([]byte(a), []byte(a), &opts); diff != FullMatch || len(entries) != 0 {
+		t.Errorf("got %s with %d entries for equal documents", diff, len(entries))
+	}
+}
+
+func TestDiffKindString(t *testing.T) {
+	for kind, s := range map[DiffKind]string{KindAdded: "added", KindRemoved: "removed", KindChanged: "changed", 42: "invalid"} {
+		if kind.String() != s {
+			t.Errorf("got %q, expected %q", kind.String(), s)
+		}
+	}
+}
+
+func TestCompareEntriesPositions(t *testing.T) {
+	opts := Options{TrackPositions: true, StringAsMapFields: []string{"doc"}}
+	a := "{\n  \"arr\": [1, 2],\n  \"doc\": \"{\\\"x\\\": 1}\",\n  \"n\": 1\n}"
+	b := `{"n": 2, "arr": [1], "doc": "{\"x\": 2}", "new": null}`
+	_, entries := CompareEntries([]byte(a), []byte(b), &opts)
+	expected := []struct {
+		path           string
+		oldPos, newPos *Position
+	}{
+		{"/arr/1", &Position{15, 2, 14}, nil},
+		{"/doc#/x", &Position{28, 3, 10}, &Position{28, 1, 29}},
+		{"/n", &Position{49, 4, 8}, &Position{6, 1, 7}},
+		{"/new", nil, &Position{49, 1, 50}},
+	}
+	if len(entries) != len(expected) {
+		t.Fatalf("got %d entries, expected %d", len(entries), len(expected))
+	}
+	for i, e := range expected {
+		got := entries[i]
+		if got.Path != e.path || !reflect.DeepEqual(got.OldPos, e.oldPos) || !reflect.DeepEqual(got.NewPos, e.newPos) {
+			t.Errorf("got %s at %v and %v, expected %s at %v and %v",
+				got.Path, got.OldPos, got.NewPos, e.path, e.oldPos, e.newPos)
+		}
+	}
+
+	if _, entries := CompareEntries([]byte(a), []byte(b), &Options{}); entries[0].OldPos != nil {
+		t.Errorf("positions without TrackPositions")
+	}
+}
+
+func TestOnDifference(t *testing.T) {
+	type call struct {
+		path     string
+		kind     DiffKind
+		old, new interface{}
+	}
+	var calls []call
+	opts := Options{
+		IgnoreFields:      []string{"ignored"},
+		FuzzyFields:       []string{"fuzzy"},
+		StringAsMapFields: []string{"doc"},
+		OnDifference: func(path string, kind DiffKind, oldValue, newValue interface{}) {
+			calls = append(calls, call{path, kind, oldValue, newValue})
+		},
+	}
+	a := `{"ignored": 1, "fuzzy": 1, "doc": "{\"x\": 1}", "arr": [1, 2], "z": {"k": true}}`
+	b := `{"ignored": 2, "fuzzy": 2, "doc": "{\"x\": 2}", "arr": [1], "new": "v", "z": {"k": false}}`
+	Compare([]byte(a), []byte(b), &opts)
+	expected := []call{
+		{"/arr/1", KindRemoved, json.Number("2"), nil},
+		{"/doc#/x", KindChanged, json.Number("1"), json.Number("2")},
+		{"/new", KindAdded, nil, "v"},
+		{"/z/k", KindChanged, true, false},
+	}
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("got %v, expected %v", calls, expected)
+	}
+
+	defer func() {
+		if r := recover(); r != "boom" {
+			t.Errorf("got panic %v, expected boom", r)
+		}
+	}()
+	opts.OnDifference = func(string, DiffKind, interface{}, interface{}) { panic("boom") }
+	Compare([]byte(a), []byte(b), &opts)
+	t.Error("the panic was swallowed")
+}
@@ -0,0 +1,129 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// Equal reports whether Compare would return FullMatch for a and b. It
+// renders no output and stops at the first difference, so it is much cheaper
+// than Compare when only the verdict is needed. Invalid JSON documents are
+// never equal.
+func Equal(a, b []byte, opts *Options) bool {
+	return differOf(opts).Equal(a, b)
+}
+
+// Equal is like the package-level Equal, using the options of d.
+func (d *Differ) Equal(a, b []byte) bool {
+	if d.grading() {
+		// Severities are set by path, which equal does not track.
+		diff, _ := d.Compare(a, b)
+		return diff == FullMatch
+	}
+	return d.equalContext().equalJSON(a, b)
+}
+
+// equalContext returns a context for equal with the options of d.
+func (d *Differ) equalContext() *context {
+	return &context{opts: &d.opts, differ: d,
+		fuzzyFields: d.fuzzyFields, ignoreFields: d.ignoreFields, stringAsMapFields: d.stringAsMapFields}
+}
+
+func (ctx *context) equalJSON(a, b []byte) bool {
+	if ctx.identical(a, b) {
+		return true
+	}
+	av, err := ctx.decode(a, 0)
+	if err != nil {
+		return false
+	}
+	bv, err := ctx.decode(b, 1)
+	if err != nil {
+		return false
+	}
+	return ctx.equal(av, bv, "")
+}
+
+// equal reports whether printDiff would find no difference between a and b,
+// the values of the field key at the current path.
+func (ctx *context) equal(a, b interface{}, key string) bool {
+	return ctx.equalAt(a, b, key, len(ctx.path))
+}
+
+// equalAt is equal for values nested depth levels deep. Values deeper than
+// MaxDepth are never equal, as printDiff stops at them with a *DepthError.
+func (ctx *context) equalAt(a, b interface{}, key string, depth int) bool {
+	if depth > ctx.differ.maxDepth {
+		return false
+	}
+	_, isFuzzy := ctx.fuzzyFields[key]
+	if a == nil || b == nil {
+		return isFuzzy || (a == nil && b == nil) || (ctx.opts.NullAsEmpty && ctx.isZeroLen(a, b))
+	}
+	if !sameKind(a, b) {
+		return false
+	}
+	if isFuzzy {
+		return true
+	}
+	if _, same := ctx.sameSubtree(a, b); same {
+		return true
+	}
+	switch aa := a.(type) {
+	case bool:
+		return aa == b.(bool)
+	case json.Number:
+		bb, ok := b.(json.Number)
+		return ok && aa == bb
+	case string:
+		bb, ok := b.(string)
+		if !ok {
+			return false
+		}
+		if aa == bb {
+			return true
+		}
+		if _, isStringAsMap := ctx.stringAsMapFields[key]; isStringAsMap {
+			// Embedded documents are compared with the options of the
+			// nested Differ, like printStringDiff does.
+			return ctx.differ.nested.equalContext().equalJSON([]byte(aa), []byte(bb))
+		}
+		return false
+	case []interface{}:
+		bb := b.([]interface{})
+		if len(aa) != len(bb) {
+			return false
+		}
+		for i := range aa {
+			if !ctx.equalAt(aa[i], bb[i], key, depth+1) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bb := b.(map[string]interface{})
+		for k, va := range aa {
+			if _, ignored := ctx.ignoreFields[k]; ignored {
+				continue
+			}
+			vb, ok := bb[k]
+			if !ok && ctx.differ.missingMatches(k) {
+				continue
+			}
+			if !ok || !ctx.equalAt(va, vb, k, depth+1) {
+				return false
+			}
+		}
+		for k := range bb {
+			if _, ignored := ctx.ignoreFields[k]; ignored {
+				continue
+			}
+			if _, ok := aa[k]; !ok && !ctx.differ.missingMatches(k) {
+				return false
+			}
+		}
+		return true
+	}
+	// Values of types the decoder does not produce, like printDiff.
+	return reflect.DeepEqual(a, b)
+}
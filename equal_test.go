@@ -0,0 +1,67 @@
+package jsondiff
+
+import "testing"
+
+func TestEqualAgreesWithCompare(t *testing.T) {
+	opts := DefaultConsoleOptions()
+	opts.IgnoreFields = []string{"fuzz1"}
+	opts.FuzzyFields = []string{"fuzz2"}
+	opts.StringAsMapFields = []string{"stringAsMap"}
+	opts.NullAsEmpty = true
+	extra := []struct{ a, b string }{
+		{`[1, 2]`, `[1, 2]`},
+		{`[1, 2]`, `[1]`},
+		{`[]`, `null`},
+		{`{"fuzz2": [1]}`, `{"fuzz2": [2, 3]}`},
+		{`{"fuzz2": [1]}`, `{"fuzz2": {}}`},
+		{`{"fuzz2": 1}`, `{"fuzz2": "1"}`},
+		{`{"fuzz2": null}`, `{"fuzz2": "1"}`},
+		{`{"stringAsMap": "not json"}`, `{"stringAsMap": "not json"}`},
+		{`{"stringAsMap": "[1]"}`, `{"stringAsMap": "[1]"}`},
+		{`{"a": {"fuzz1": 1}}`, `{"a": {}}`},
+		{`{"a": {}}`, `{"a": {"fuzz1": 1}}`},
+		{`{"a": 1}`, `invalid`},
+		{`1`, `1.0`},
+		{`"s"`, `"s"`},
+		{`true`, `false`},
+	}
+	pairs := make([]struct{ a, b string }, 0, len(cases)+len(extra))
+	for _, c := range cases {
+		pairs = append(pairs, struct{ a, b string }{c.a, c.b})
+	}
+	pairs = append(pairs, extra...)
+	deep := struct{ a, b string }{`[[[[1]]]]`, `[[[[1 ]]]]`}
+	pairs = append(pairs, deep, struct{ a, b string }{`{"a": [[[1]]]}`, `{"a": [[[1]]]}`})
+	pairs = append(pairs, struct{ a, b string }{`{"stringAsMap": ""}`, `{"stringAsMap": "null"}`})
+	emptyAsNull := opts
+	emptyAsNull.EmptyInputAsNull = true
+	for _, o := range []Options{opts, {}, {MaxDepth: 2}, emptyAsNull} {
+		for _, p := range pairs {
+			diff, _ := Compare([]byte(p.a), []byte(p.b), &o)
+			if got := Equal([]byte(p.a), []byte(p.b), &o); got != (diff == FullMatch) {
+				t.Errorf("%s, %s: got %v, Compare returned %s", p.a, p.b, got, diff)
+			}
+		}
+	}
+	if Equal([]byte(deep.a), []byte(deep.b), &Options{MaxDepth: 2}) {
+		t.Errorf("%s, %s: equal past MaxDepth", deep.a, deep.b)
+	}
+}
+
+func BenchmarkEqualUnequal(b *testing.B) {
+	x, y := bigDocument(5000, 1), bigDocument(5000, 97)
+	opts := Options{Indent: "  "}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Equal(x, y, &opts)
+	}
+}
+
+func BenchmarkCompareUnequal(b *testing.B) {
+	x, y := bigDocument(5000, 1), bigDocument(5000, 97)
+	opts := Options{Indent: "  "}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		Compare(x, y, &opts)
+	}
+}
@@ -0,0 +1,132 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+)
+
+// InvalidJSONError reports why a document is not valid JSON.
+type InvalidJSONError struct {
+	// Document is 1 for the first and 2 for the second document, or 3 for
+	// the third one of Compare3.
+	Document int
+	// Position is where decoding failed: the offending byte for syntax
+	// errors and the end of the document if it ends too early.
+	Position Position
+	// Err is the error returned by encoding/json.
+	Err error
+}
+
+func (e *InvalidJSONError) Error() string {
+	doc := "first"
+	switch e.Document {
+	case 2:
+		doc = "second"
+	case 3:
+		doc = "third"
+	}
+	return "jsondiff: " + doc + " document is invalid JSON at line " + strconv.Itoa(e.Position.Line) +
+		", column " + strconv.Itoa(e.Position.Column) + " (offset " + strconv.Itoa(e.Position.Offset) + "): " + e.Err.Error()
+}
+
+func (e *InvalidJSONError) Unwrap() error {
+	return e.Err
+}
+
+// DepthError is the error of a comparison stopped at a value nested deeper
+// than Options.MaxDepth.
+type DepthError struct {
+	// Path is the JSON Pointer of the value, in the format of
+	// DiffEntry.Path.
+	Path     string
+	MaxDepth int
+}
+
+func (e *DepthError) Error() string {
+	return "jsondiff: documents are nested deeper than " + strconv.Itoa(e.MaxDepth) + " levels"
+}
+
+// limitError returns the error of ctx if it is a *DepthError or a
+// *ComparisonTooLargeError, which the functions reporting invalid documents
+// return too, or nil.
+func (ctx *context) limitError() error {
+	switch ctx.err.(type) {
+	case *DepthError, *ComparisonTooLargeError:
+		return ctx.err
+	}
+	return nil
+}
+
+// newInvalidJSONError returns the error for document doc holding data, which
+// failed to decode with err.
+func newInvalidJSONError(doc int, data []byte, err error) *InvalidJSONError {
+	return &InvalidJSONError{Document: doc, Position: positionAt(data, errorOffset(err, len(data))), Err: err}
+}
+
+// errorOffset returns the offset err occurred at in a document of size
+// bytes.
+func errorOffset(err error, size int) int {
+	switch e := err.(type) {
+	case *json.SyntaxError:
+		// Offset counts the offending byte.
+		return int(e.Offset) - 1
+	case *json.UnmarshalTypeError:
+		return int(e.Offset)
+	case *TrailingDataError:
+		return e.Offset
+	case *InvalidUTF8Error:
+		return e.Offset
+	}
+	if err == io.ErrUnexpectedEOF {
+		return size
+	}
+	return 0
+}
+
+// positionAt returns the position of the byte at off in data.
+func positionAt(data []byte, off int) Position {
+	if off > len(data) {
+		off = len(data)
+	}
+	if off < 0 {
+		off = 0
+	}
+	p := Position{Offset: off, Line: 1, Column: 1}
+	for _, c := range data[:off] {
+		if c == '\n' {
+			p.Line, p.Column = p.Line+1, 1
+		} else {
+			p.Column++
+		}
+	}
+	return p
+}
+
+// decodeErrors returns the InvalidJSONError of each document that failed to
+// decode, joined if both did, or nil.
+func decodeErrors(a, b []byte, errA, errB error) error {
+	var errs []error
+	if errA != nil {
+		errs = append(errs, newInvalidJSONError(1, a, errA))
+	}
+	if errB != nil {
+		errs = append(errs, newInvalidJSONError(2, b, errB))
+	}
+	return errors.Join(errs...)
+}
+
+// CompareErr is like Compare, but if either document is not valid JSON it
+// also returns an error describing why. The error is an *InvalidJSONError,
+// or both of them joined with errors.Join for BothArgsAreInvalidJson. For
+// documents nested deeper than Options.MaxDepth it is a *DepthError, and
+// for a comparison over Options.MaxMemoryBytes a *ComparisonTooLargeError.
+func CompareErr(a, b []byte, opts *Options) (Difference, string, error) {
+	return differOf(opts).CompareErr(a, b)
+}
+
+// CompareErr is like the package-level CompareErr, using the options of d.
+func (d *Differ) CompareErr(a, b []byte) (Difference, string, error) {
+	return d.newContext(nil).compareErr(a, b)
+}
@@ -0,0 +1,58 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestCompareErr(t *testing.T) {
+	valid := []byte(`{"a": 1}`)
+	cases := []struct {
+		a, b     string
+		diff     Difference
+		expected []string
+	}{
+		{`{"a": 1,}`, `{}`, FirstArgIsInvalidJson,
+			[]string{"first document is invalid JSON at line 1, column 9 (offset 8): invalid character '}'"}},
+		{`{}`, "{\n  \"a\": [1,\n  2 3]\n}", SecondArgIsInvalidJson,
+			[]string{"second document is invalid JSON at line 3, column 5 (offset 17): invalid character '3'"}},
+		{`{"a": `, `[`, BothArgsAreInvalidJson,
+			[]string{"first document is invalid JSON at line 1, column 7 (offset 6): unexpected EOF",
+				"second document is invalid JSON at line 1, column 2 (offset 1): unexpected EOF"}},
+		{``, `{}`, FirstArgIsInvalidJson,
+			[]string{"first document is invalid JSON at line 1, column 1 (offset 0): EOF"}},
+	}
+	for _, c := range cases {
+		diff, _, err := CompareErr([]byte(c.a), []byte(c.b), &Options{})
+		if diff != c.diff || err == nil {
+			t.Errorf("%q, %q: got %s and %v", c.a, c.b, diff, err)
+			continue
+		}
+		lines := strings.Split(err.Error(), "\n")
+		if len(lines) != len(c.expected) {
+			t.Errorf("%q, %q: got %q", c.a, c.b, err)
+			continue
+		}
+		for i, line := range lines {
+			if !strings.HasPrefix(line, "jsondiff: "+c.expected[i]) {
+				t.Errorf("%q, %q: got %q, expected %q", c.a, c.b, line, c.expected[i])
+			}
+		}
+	}
+
+	_, _, err := CompareErr([]byte(`{"a": 1,}`), []byte(`[`), &Options{})
+	var syntax *json.SyntaxError
+	if !errors.As(err, &syntax) || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Errorf("got %#v, expected the decoder errors", err)
+	}
+	var invalid *InvalidJSONError
+	if !errors.As(err, &invalid) || invalid.Document != 1 || invalid.Position != (Position{8, 1, 9}) {
+		t.Errorf("got %#v", invalid)
+	}
+	if diff, _, err := CompareErr(valid, valid, &Options{}); diff != FullMatch || err != nil {
+		t.Errorf("got %s and %v for valid documents", diff, err)
+	}
+}
@@ -0,0 +1,109 @@
+package jsondiff
+
+import (
+	"bytes"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+const hexDigits = "0123456789abcdef"
+
+// escapeMode selects the optional escaping applied by writeQuoted.
+type escapeMode int
+
+const (
+	// escapeASCII escapes every non-ASCII character.
+	escapeASCII escapeMode = 1 << iota
+	// escapeHTML replaces '<', '>' and '&' with HTML character references.
+	escapeHTML
+)
+
+func (opts *Options) escapeMode() escapeMode {
+	var mode escapeMode
+	if opts.ASCIIOnly {
+		mode |= escapeASCII
+	}
+	if opts.EscapeHTML {
+		mode |= escapeHTML
+	}
+	return mode
+}
+
+// writeQuoted writes s to buf as a double-quoted string. Quotes and
+// backslashes are escaped as in JSON, control and other non-printable
+// characters are written as \uXXXX and bytes that are not valid UTF-8 are
+// written as \xXX, so the output never contains raw terminal sequences.
+func writeQuoted(buf *bytes.Buffer, s string, mode escapeMode) {
+	buf.WriteByte('"')
+	writeEscaped(buf, s, mode)
+	buf.WriteByte('"')
+}
+
+// writeEscaped writes s to buf escaped like writeQuoted, without the
+// surrounding quotes.
+func writeEscaped(buf *bytes.Buffer, s string, mode escapeMode) {
+	asciiOnly := mode&escapeASCII != 0
+	html := mode&escapeHTML != 0
+	start := 0
+	for i := 0; i < len(s); {
+		c := s[i]
+		if c >= 0x20 && c < utf8.RuneSelf && c != '"' && c != '\\' && c != 0x7f &&
+			!(html && (c == '<' || c == '>' || c == '&')) {
+			i++
+			continue
+		}
+		r, size := rune(c), 1
+		if c >= utf8.RuneSelf {
+			r, size = utf8.DecodeRuneInString(s[i:])
+			if r != utf8.RuneError || size != 1 {
+				if !asciiOnly && unicode.IsPrint(r) {
+					i += size
+					continue
+				}
+			}
+		}
+		buf.WriteString(s[start:i])
+		switch {
+		case c == '"' || c == '\\':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+		case c == '<':
+			buf.WriteString("&lt;")
+		case c == '>':
+			buf.WriteString("&gt;")
+		case c == '&':
+			buf.WriteString("&amp;")
+		case c == '\n':
+			buf.WriteString(`\n`)
+		case c == '\r':
+			buf.WriteString(`\r`)
+		case c == '\t':
+			buf.WriteString(`\t`)
+		case c == '\b':
+			buf.WriteString(`\b`)
+		case c == '\f':
+			buf.WriteString(`\f`)
+		case r == utf8.RuneError && size == 1:
+			buf.WriteString(`\x`)
+			buf.WriteByte(hexDigits[c>>4])
+			buf.WriteByte(hexDigits[c&0xf])
+		case r > 0xffff:
+			r1, r2 := utf16.EncodeRune(r)
+			writeUnicodeEscape(buf, r1)
+			writeUnicodeEscape(buf, r2)
+		default:
+			writeUnicodeEscape(buf, r)
+		}
+		i += size
+		start = i
+	}
+	buf.WriteString(s[start:])
+}
+
+func writeUnicodeEscape(buf *bytes.Buffer, r rune) {
+	buf.WriteString(`\u`)
+	for shift := 12; shift >= 0; shift -= 4 {
+		buf.WriteByte(hexDigits[(r>>uint(shift))&0xf])
+	}
+}
@@ -0,0 +1,103 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteQuoted(t *testing.T) {
+	cases := []struct {
+		in       string
+		mode     escapeMode
+		expected string
+	}{
+		{`plain`, 0, `"plain"`},
+		{`say "hi" \ bye`, 0, `"say \"hi\" \\ bye"`},
+		{"\x1b[31mred\x1b[0m", 0, `"\u001b[31mred\u001b[0m"`},
+		{"nul\x00bell\x07del\x7f", 0, `"nul\u0000bell\u0007del\u007f"`},
+		{"tab\tnl\ncr\r", 0, `"tab\tnl\ncr\r"`},
+		{"bad\xff\xfeutf8", 0, `"bad\xff\xfeutf8"`},
+		{"zero\u200bwidth", 0, `"zero\u200bwidth"`},
+		{"привет", 0, `"привет"`},
+		{"привет", escapeASCII, `"\u043f\u0440\u0438\u0432\u0435\u0442"`},
+		{"emoji 😀", escapeASCII, `"emoji \ud83d\ude00"`},
+		{"emoji 😀", 0, `"emoji 😀"`},
+		{"<b>&amp;", 0, `"<b>&amp;"`},
+		{"<b>&amp;", escapeHTML, `"&lt;b&gt;&amp;amp;"`},
+		{"<ü>", escapeHTML | escapeASCII, `"&lt;\u00fc&gt;"`},
+	}
+	for _, c := range cases {
+		var buf bytes.Buffer
+		writeQuoted(&buf, c.in, c.mode)
+		if buf.String() != c.expected {
+			t.Errorf("writeQuoted(%q, %v) = %s, expected %s", c.in, c.mode, buf.String(), c.expected)
+		}
+	}
+}
+
+func TestKeyEscaping(t *testing.T) {
+	cases := []struct {
+		key string
+		// quoted is the key in rendered output, pointer its path and
+		// shown the path as written by ShowPaths.
+		quoted, pointer, shown string
+	}{
+		{`he said "hi"`, `"he said \"hi\""`, `/he said "hi"`, `/he said \"hi\"`},
+		{`back\slash`, `"back\\slash"`, `/back\slash`, `/back\\slash`},
+		{"line\nbreak", `"line\nbreak"`, "/line\nbreak", `/line\nbreak`},
+		{"\u202eright to left", `"\u202eright to left"`, "/\u202eright to left", `/\u202eright to left`},
+		{"zero\u200bwidth", `"zero\u200bwidth"`, "/zero\u200bwidth", `/zero\u200bwidth`},
+		{"", `""`, `/`, `/`},
+		{"a/b~c", `"a/b~c"`, `/a~1b~0c`, `/a~1b~0c`},
+		{"é", `"é"`, `/é`, `/é`},
+	}
+	for _, c := range cases {
+		key, err := json.Marshal(c.key)
+		if err != nil {
+			t.Fatal(err)
+		}
+		a := []byte(`{"x": 0, ` + string(key) + `: 1}`)
+		b := []byte(`{"x": 0, ` + string(key) + `: 2}`)
+
+		opts := Options{Changed: Tag{Begin: "~"}, Indent: " ", ShowPaths: true, PathComment: " # "}
+		_, text := Compare(a, b, &opts)
+		if expected := "{\n " + c.quoted + ": ~1 => 2 # " + c.shown + "\n}"; text != expected {
+			t.Errorf("%q: got:\n%s\nexpected:\n%s", c.key, text, expected)
+		}
+
+		_, entries := CompareEntries(a, b, &Options{})
+		if len(entries) != 1 || entries[0].Path != c.pointer {
+			t.Errorf("%q: got entries %+v, expected path %q", c.key, entries, c.pointer)
+		}
+
+		dup := []byte(`{` + string(key) + `: 1, ` + string(key) + `: 1}`)
+		res := CompareDetail(dup, dup, &Options{DetectDuplicateKeys: true})
+		if len(res.DuplicateKeys) != 2 || res.DuplicateKeys[0].Path != c.pointer {
+			t.Errorf("%q: got duplicates %+v, expected path %q", c.key, res.DuplicateKeys, c.pointer)
+		}
+
+		patch := patchFromEntries(t, a, b)
+		if diff, _, err := VerifyPatch(a, b, patch, &Options{}); diff != FullMatch || err != nil {
+			t.Errorf("%q: patch %s gives %s, %v", c.key, patch, diff, err)
+		}
+
+		var sarif bytes.Buffer
+		if _, err := WriteSARIF(&sarif, a, b, &Options{}); err != nil {
+			t.Fatal(err)
+		}
+		var log struct {
+			Runs []struct {
+				Results []struct {
+					Message struct{ Text string }
+				}
+			}
+		}
+		if err := json.Unmarshal(sarif.Bytes(), &log); err != nil {
+			t.Fatal(err)
+		}
+		if msg := log.Runs[0].Results[0].Message.Text; msg != c.shown+" changed: 1 => 2" {
+			t.Errorf("%q: got SARIF message %q", c.key, msg)
+		}
+	}
+}
@@ -0,0 +1,203 @@
+package jsondiff
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"hash/maphash"
+	"reflect"
+)
+
+// hashSeeds seed the two independent 64-bit hashes making up a treeHash.
+var hashSeeds = [2]maphash.Seed{maphash.MakeSeed(), maphash.MakeSeed()}
+
+// treeHash is the 128-bit structural hash of a value. Members of objects
+// are hashed in any order, elements of arrays in theirs. Ignored fields are
+// left out, since they never make values differ.
+type treeHash [2]uint64
+
+// subtree is what hashing found out about an object or array: its hash and
+// its number of leaves, as counted by leaves.
+type subtree struct {
+	hash   treeHash
+	leaves int
+}
+
+// containerKey identifies a non-empty object or array by the address of its
+// map or of its first element, and its length.
+type containerKey struct {
+	p uintptr
+	n int
+}
+
+// minHashedLeaves is the number of leaves from which the subtree of an
+// object or array is recorded. Smaller ones are walked as fast as they are
+// looked up.
+const minHashedLeaves = 8
+
+// subtrees maps the objects and arrays of hashed values to their subtree.
+// Keys are addresses, so the values must stay alive while it is used.
+type subtrees map[containerKey]subtree
+
+// containerOf returns the key of v if it is a non-empty object or array.
+func containerOf(v interface{}) (containerKey, bool) {
+	switch vv := v.(type) {
+	case []interface{}:
+		if len(vv) > 0 {
+			return containerKey{reflect.ValueOf(vv).Pointer(), len(vv)}, true
+		}
+	case map[string]interface{}:
+		if len(vv) > 0 {
+			return containerKey{reflect.ValueOf(vv).Pointer(), len(vv)}, true
+		}
+	}
+	return containerKey{}, false
+}
+
+// hashes computes the two halves of a treeHash.
+type hashes [2]maphash.Hash
+
+// start seeds m and writes tag to it.
+func (m *hashes) start(tag byte) {
+	for i, seed := range hashSeeds {
+		m[i].SetSeed(seed)
+		m[i].WriteByte(tag)
+	}
+}
+
+// write writes h to m.
+func (m *hashes) write(h treeHash) {
+	var buf [8]byte
+	for i := range m {
+		binary.LittleEndian.PutUint64(buf[:], h[i])
+		m[i].Write(buf[:])
+	}
+}
+
+// sum returns the treeHash m has computed.
+func (m *hashes) sum() treeHash {
+	return treeHash{m[0].Sum64(), m[1].Sum64()}
+}
+
+// hashTag returns the hash of tag.
+func hashTag(tag byte) treeHash {
+	var m hashes
+	m.start(tag)
+	return m.sum()
+}
+
+// hashString returns the hash of tag followed by s.
+func hashString(tag byte, s string) treeHash {
+	var m hashes
+	m.start(tag)
+	m[0].WriteString(s)
+	m[1].WriteString(s)
+	return m.sum()
+}
+
+// hashTree hashes v and records the subtree of every object and array in
+// it with at least minHashedLeaves leaves in into. It returns the hash and
+// the number of leaves of v, and false if v holds a value of a type the
+// decoder does not produce.
+func (ctx *context) hashTree(v interface{}, into subtrees) (treeHash, int, bool) {
+	var h treeHash
+	n := 0
+	switch vv := v.(type) {
+	case nil:
+		h = hashTag('n')
+	case bool:
+		if vv {
+			h = hashTag('t')
+		} else {
+			h = hashTag('f')
+		}
+	case json.Number:
+		h = hashString('#', string(vv))
+	case string:
+		h = hashString('"', vv)
+	case []interface{}:
+		var m hashes
+		m.start('[')
+		for _, e := range vv {
+			eh, en, ok := ctx.hashTree(e, into)
+			if !ok {
+				return h, 0, false
+			}
+			m.write(eh)
+			n += en
+		}
+		h = m.sum()
+	case map[string]interface{}:
+		// Members are combined by adding their hashes, which does not
+		// depend on their order.
+		var sum treeHash
+		for k, e := range vv {
+			if _, ignored := ctx.ignoreFields[k]; ignored {
+				continue
+			}
+			eh, en, ok := ctx.hashTree(e, into)
+			if !ok {
+				return h, 0, false
+			}
+			var m hashes
+			m.start(':')
+			m.write(hashString(':', k))
+			m.write(eh)
+			mh := m.sum()
+			sum[0] += mh[0]
+			sum[1] += mh[1]
+			n += en
+		}
+		var m hashes
+		m.start('{')
+		m.write(sum)
+		h = m.sum()
+	default:
+		return h, 0, false
+	}
+	if n == 0 {
+		n = 1
+	}
+	if n >= minHashedLeaves {
+		if key, ok := containerOf(v); ok {
+			into[key] = subtree{h, n}
+		}
+	}
+	return h, n, true
+}
+
+// hashTrees hashes v into a new subtrees and adds it to those ctx looks
+// values up in.
+func (ctx *context) hashTrees(v interface{}) {
+	into := make(subtrees)
+	ctx.hashTree(v, into)
+	ctx.subtrees = append(ctx.subtrees, into)
+}
+
+// sameSubtree reports whether a and b are hashed objects or arrays with the
+// same hash, which makes them equal however they are compared, and returns
+// their number of leaves.
+func (ctx *context) sameSubtree(a, b interface{}) (int, bool) {
+	if ctx.subtrees == nil {
+		return 0, false
+	}
+	ka, okA := containerOf(a)
+	kb, okB := containerOf(b)
+	if !okA || !okB {
+		return 0, false
+	}
+	sa, okA := ctx.lookupSubtree(ka)
+	sb, okB := ctx.lookupSubtree(kb)
+	if !okA || !okB || sa.hash != sb.hash {
+		return 0, false
+	}
+	return sa.leaves, true
+}
+
+func (ctx *context) lookupSubtree(key containerKey) (subtree, bool) {
+	for _, m := range ctx.subtrees {
+		if s, ok := m[key]; ok {
+			return s, true
+		}
+	}
+	return subtree{}, false
+}
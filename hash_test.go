@@ -0,0 +1,106 @@
+package jsondiff
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestHashTree(t *testing.T) {
+	ctx := newDiffer(Options{IgnoreFields: []string{"skip"}}).newContext(nil)
+	hash := func(doc string) treeHash {
+		v, err := ctx.decode([]byte(doc), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		h, _, _ := ctx.hashTree(v, make(subtrees))
+		return h
+	}
+	for _, c := range []struct {
+		a, b string
+		same bool
+	}{
+		{`{"a": 1, "b": [true, null]}`, `{"b": [true, null], "a": 1}`, true},
+		{`{"a": 1, "skip": 2}`, `{"a": 1}`, true},
+		{`[1, 2]`, `[2, 1]`, false},
+		{`{"a": 1, "b": 2}`, `{"a": 2, "b": 1}`, false},
+		{`[1]`, `["1"]`, false},
+		{`1`, `1.0`, false},
+		{`[]`, `{}`, false},
+		{`[[]]`, `[{}]`, false},
+		{`{"a": {}}`, `{"a": []}`, false},
+		{`[null]`, `[false]`, false},
+	} {
+		if same := hash(c.a) == hash(c.b); same != c.same {
+			t.Errorf("%s, %s: same hash %v", c.a, c.b, same)
+		}
+	}
+
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		v := randomValue(rnd, 4)
+		into := make(subtrees)
+		if _, n, ok := ctx.hashTree(v, into); !ok || n != ctx.leaves(v) {
+			t.Fatalf("%v: got %d leaves, %v, expected %d", v, n, ok, ctx.leaves(v))
+		}
+	}
+	if _, _, ok := ctx.hashTree([]interface{}{1}, make(subtrees)); ok {
+		t.Error("a value of an unknown type was hashed")
+	}
+}
+
+func TestHashSubtrees(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, base := range []Options{
+		{},
+		{IgnoreFields: []string{"fuzz1"}, NullAsEmpty: true},
+		{FuzzyFields: []string{"fuzz2"}, StringAsMapFields: []string{"a"}},
+	} {
+		hashed := base
+		hashed.HashSubtrees = true
+		for i := 0; i < 1000; i++ {
+			v := randomValue(rnd, 5)
+			var docs [3]bytes.Buffer
+			shuffledJSON(rnd, &docs[0], v)
+			shuffledJSON(rnd, &docs[1], mutate(rnd, v))
+			shuffledJSON(rnd, &docs[2], mutate(rnd, v))
+			m1, c1, err1 := Compare3(docs[0].Bytes(), docs[1].Bytes(), docs[2].Bytes(), &base)
+			m2, c2, err2 := Compare3(docs[0].Bytes(), docs[1].Bytes(), docs[2].Bytes(), &hashed)
+			if !bytes.Equal(m1, m2) || !reflect.DeepEqual(c1, c2) || err1 != err2 {
+				t.Fatalf("%+v: %s, %s, %s: got %s %v, expected %s %v", base, docs[0].Bytes(), docs[1].Bytes(), docs[2].Bytes(), m2, c2, m1, c1)
+			}
+		}
+	}
+}
+
+// chainDocuments returns a document nesting depth objects, each with a
+// block of metadata, and two copies of it changed at the innermost object.
+func chainDocuments(depth int) (base, ours, theirs []byte) {
+	meta := `{"source": "shadow", "region": "eu", "tags": ["a", "b", "c"], "limits": {"cpu": 4, "mem": 8, "disk": 100}}`
+	doc := func(leaf string) []byte {
+		var buf bytes.Buffer
+		for i := 0; i < depth; i++ {
+			fmt.Fprintf(&buf, `{"meta": %s, "history": [%s, %s], "next": `, meta, meta, meta)
+		}
+		buf.WriteString(leaf)
+		buf.WriteString(strings.Repeat("}", depth))
+		return buf.Bytes()
+	}
+	return doc(`{"a": 1, "b": 1}`), doc(`{"a": 2, "b": 1}`), doc(`{"a": 1, "b": 2}`)
+}
+
+func BenchmarkCompare3HashSubtrees(b *testing.B) {
+	base, ours, theirs := chainDocuments(50)
+	for _, hash := range []bool{false, true} {
+		b.Run(fmt.Sprintf("HashSubtrees=%v", hash), func(b *testing.B) {
+			d := newDiffer(Options{HashSubtrees: hash})
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				d.Compare3(base, ours, theirs)
+			}
+		})
+	}
+}
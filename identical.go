@@ -0,0 +1,73 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"unicode/utf8"
+)
+
+// identical reports whether a and b can be reported as FullMatch without
+// decoding them: both are valid JSON and the same but for whitespace between
+// tokens, and nothing is asked of the comparison but its verdict, which
+// rules out AlwaysRender. Options never make a document differ from itself,
+// except for the duplicate keys DetectDuplicateKeys reports and a MaxDepth
+// too low for the documents, which both disable the shortcut, and the
+// invalid UTF-8 InvalidUTF8Reject rejects.
+func (ctx *context) identical(a, b []byte) bool {
+	if ctx.collect || ctx.countLeaves || ctx.emit != nil || ctx.opts.DetectDuplicateKeys ||
+		ctx.opts.AlwaysRender || ctx.differ.maxDepth < DefaultMaxDepth {
+		return false
+	}
+	if ctx.opts.InvalidUTF8 == InvalidUTF8Reject && !utf8.Valid(a) {
+		return false
+	}
+	if !equalIgnoringSpace(a, b) || !json.Valid(a) {
+		return false
+	}
+	// Removing whitespace can join tokens, as in [1 2] and [12].
+	return bytes.Equal(a, b) || json.Valid(b)
+}
+
+// equalIgnoringSpace reports whether a and b hold the same bytes, leaving
+// out JSON whitespace outside of strings. It stops at the first difference.
+func equalIgnoringSpace(a, b []byte) bool {
+	i, j := 0, 0
+	inString := false
+	for {
+		if !inString {
+			for i < len(a) && isJSONSpace(a[i]) {
+				i++
+			}
+			for j < len(b) && isJSONSpace(b[j]) {
+				j++
+			}
+		}
+		if i == len(a) || j == len(b) {
+			return i == len(a) && j == len(b)
+		}
+		c := a[i]
+		if c != b[j] {
+			return false
+		}
+		switch {
+		case c == '"':
+			inString = !inString
+		case c == '\\' && inString:
+			// The escaped byte is compared without being interpreted.
+			i++
+			j++
+			if i == len(a) || j == len(b) {
+				return i == len(a) && j == len(b)
+			}
+			if a[i] != b[j] {
+				return false
+			}
+		}
+		i++
+		j++
+	}
+}
+
+func isJSONSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
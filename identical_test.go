@@ -0,0 +1,104 @@
+package jsondiff
+
+import (
+	"bytes"
+	gocontext "context"
+	"encoding/json"
+	"errors"
+	"testing"
+)
+
+func TestEqualIgnoringSpace(t *testing.T) {
+	for _, c := range []struct {
+		a, b  string
+		equal bool
+	}{
+		{``, ``, true},
+		{`{"a": [1, 2]}`, "{\"a\":\n\t[1,2]\r\n}", true},
+		{`[1 2]`, `[12]`, true},
+		{`["a b"]`, `["ab"]`, false},
+		{`["a\" b"]`, `["a\"b"]`, false},
+		{`["a\\" ,1]`, `["a\\",1]`, true},
+		{`[1]`, `[1] `, true},
+		{`[1]`, `[1]]`, false},
+		{`[1]`, `[2]`, false},
+		{`"\`, `"\`, true},
+		{`"\`, `"\"`, false},
+	} {
+		if got := equalIgnoringSpace([]byte(c.a), []byte(c.b)); got != c.equal {
+			t.Errorf("%q, %q: got %v", c.a, c.b, got)
+		}
+		if got := equalIgnoringSpace([]byte(c.b), []byte(c.a)); got != c.equal {
+			t.Errorf("%q, %q: got %v", c.b, c.a, got)
+		}
+	}
+}
+
+func TestIdenticalShortcut(t *testing.T) {
+	opts := Options{}
+	doc := []byte(`{"a": [1, {"b": null}], "c": "x y"}`)
+	for _, c := range []struct {
+		a, b   string
+		result Difference
+	}{
+		{string(doc), string(doc), FullMatch},
+		{string(doc), "{\"a\":[1,{\"b\":null}],\n\"c\":\"x y\"}", FullMatch},
+		{`[1 2]`, `[12]`, FirstArgIsInvalidJson},
+		{`[12]`, `[1 2]`, SecondArgIsInvalidJson},
+		{`[1`, `[1`, BothArgsAreInvalidJson},
+		{`[1] x`, `[1] x`, FullMatch},
+	} {
+		if diff, _ := Compare([]byte(c.a), []byte(c.b), &opts); diff != c.result {
+			t.Errorf("%s, %s: got %s, expected %s", c.a, c.b, diff, c.result)
+		}
+		if equal := Equal([]byte(c.a), []byte(c.b), &opts); equal != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Equal returned %v", c.a, c.b, equal)
+		}
+	}
+	d := newDiffer(opts)
+	if allocs := testing.AllocsPerRun(10, func() { d.Compare(doc, doc) }); allocs > 1 {
+		t.Errorf("comparing identical documents allocates %v times", allocs)
+	}
+
+	// What the comparison reports besides its verdict is still found.
+	if r := CompareDetail(doc, doc, &opts); r.Stats.Nodes == 0 || r.Stats.Unchanged != 3 {
+		t.Errorf("CompareDetail: got %+v", r.Stats)
+	}
+	if _, counts, _ := Relationship(doc, doc, &opts); counts.Common != 3 {
+		t.Errorf("Relationship: got %+v", counts)
+	}
+	dup := []byte(`{"a": 1, "a": 1}`)
+	if r := CompareDetail(dup, dup, &Options{DetectDuplicateKeys: true}); len(r.DuplicateKeys) != 2 {
+		t.Errorf("duplicate keys: got %+v", r.DuplicateKeys)
+	}
+	deep := nestedArrays(20, "1")
+	var depthErr *DepthError
+	if _, _, err := CompareErr(deep, deep, &Options{MaxDepth: 10}); !errors.As(err, &depthErr) {
+		t.Errorf("MaxDepth: got %v", err)
+	}
+	c, cancel := gocontext.WithCancel(gocontext.Background())
+	cancel()
+	if diff, _, err := CompareContext(c, doc, doc, &opts); diff != NoMatch || err != gocontext.Canceled {
+		t.Errorf("canceled: got %s, %v", diff, err)
+	}
+}
+
+func BenchmarkCompareIdentical(b *testing.B) {
+	doc, _ := largeObjects(50000)
+	var reindented bytes.Buffer
+	json.Indent(&reindented, doc, "", "  ")
+	b.Run("Same", func(b *testing.B) {
+		benchmarkCompareDocuments(b, DefaultConsoleOptions(), doc, doc)
+	})
+	b.Run("Reindented", func(b *testing.B) {
+		benchmarkCompareDocuments(b, DefaultConsoleOptions(), doc, reindented.Bytes())
+	})
+	b.Run("Detail", func(b *testing.B) {
+		d := newDiffer(DefaultConsoleOptions())
+		b.ReportAllocs()
+		b.SetBytes(int64(2 * len(doc)))
+		for i := 0; i < b.N; i++ {
+			d.CompareDetail(doc, doc)
+		}
+	})
+}
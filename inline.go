@@ -0,0 +1,78 @@
+package jsondiff
+
+import (
+	"bytes"
+	"unicode/utf8"
+)
+
+// commonAffixes returns the length in bytes of the longest common prefix and
+// suffix of a and b. The lengths never split a UTF-8 sequence and the prefix
+// and suffix never overlap in either string.
+func commonAffixes(a, b string) (prefix, suffix int) {
+	n := len(a)
+	if len(b) < n {
+		n = len(b)
+	}
+	for prefix < n && a[prefix] == b[prefix] {
+		prefix++
+	}
+	for prefix > 0 && (prefix < len(a) && !utf8.RuneStart(a[prefix]) ||
+		prefix < len(b) && !utf8.RuneStart(b[prefix])) {
+		prefix--
+	}
+	for suffix < n-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	for suffix > 0 && !utf8.RuneStart(a[len(a)-suffix]) {
+		suffix--
+	}
+	return prefix, suffix
+}
+
+// writeInlineMismatch renders two different strings highlighting only the
+// part in the middle that differs, using the Normal tag for the common prefix
+// and suffix and the Changed tag, or the Warning tag, for the rest. It
+// returns false without writing anything if Options.InlineStringDiff is not
+// set, NoOutput or TagChangedKeys is, one of the values is not a string or
+// the strings have nothing in common. With TagChangedKeys the Changed tag
+// covers the whole entry, and a marker in it must not split the strings.
+func (ctx *context) writeInlineMismatch(buf *bytes.Buffer, a, b interface{}) bool {
+	if !ctx.opts.InlineStringDiff || ctx.opts.TagChangedKeys || ctx.silent() || ctx.masked {
+		return false
+	}
+	if _, isStringAsMap := ctx.stringAsMapFields[ctx.curKey]; isStringAsMap && ctx.differ.masking() {
+		return false
+	}
+	sa, aok := a.(string)
+	sb, bok := b.(string)
+	if !aok || !bok {
+		return false
+	}
+	if max := ctx.opts.InlineStringDiffMaxLen; max > 0 && (len(sa) > max || len(sb) > max) {
+		return false
+	}
+	prefix, suffix := commonAffixes(sa, sb)
+	if prefix == 0 && suffix == 0 {
+		return false
+	}
+	ctx.writeHighlighted(buf, sa, prefix, suffix)
+	ctx.writeTypeMaybe(buf, a)
+	buf.WriteString(" => ")
+	ctx.writeHighlighted(buf, sb, prefix, suffix)
+	ctx.writeTypeMaybe(buf, b)
+	return true
+}
+
+func (ctx *context) writeHighlighted(buf *bytes.Buffer, s string, prefix, suffix int) {
+	mode := ctx.opts.escapeMode()
+	ctx.tag(buf, &ctx.opts.Normal)
+	buf.WriteByte('"')
+	writeEscaped(buf, s[:prefix], mode)
+	if middle := s[prefix : len(s)-suffix]; middle != "" {
+		ctx.tag(buf, ctx.entryTag(&ctx.opts.Changed))
+		writeEscaped(buf, middle, mode)
+		ctx.tag(buf, &ctx.opts.Normal)
+	}
+	writeEscaped(buf, s[len(s)-suffix:], mode)
+	buf.WriteByte('"')
+}
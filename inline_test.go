@@ -0,0 +1,56 @@
+package jsondiff
+
+import (
+	"testing"
+)
+
+func TestCommonAffixes(t *testing.T) {
+	cases := []struct {
+		a, b           string
+		prefix, suffix int
+	}{
+		{"user-1234-east", "user-1234-west", 10, 2},
+		{"abc", "abc", 3, 0},
+		{"abc", "xyz", 0, 0},
+		{"ab", "aXb", 1, 1},
+		{"aaa", "aa", 2, 0},
+		{"née", "nüe", 1, 1},
+		{"ä", "ö", 0, 0},
+	}
+	for _, c := range cases {
+		prefix, suffix := commonAffixes(c.a, c.b)
+		if prefix != c.prefix || suffix != c.suffix {
+			t.Errorf("commonAffixes(%q, %q) = %d, %d, expected %d, %d", c.a, c.b, prefix, suffix, c.prefix, c.suffix)
+		}
+	}
+}
+
+func TestInlineStringDiff(t *testing.T) {
+	opts := Options{
+		Changed:          Tag{Begin: "[", End: "]"},
+		InlineStringDiff: true,
+		EscapeHTML:       true,
+	}
+	cases := []struct {
+		a, b     string
+		expected string
+	}{
+		{`"user-1234-east"`, `"user-1234-west"`, `"user-1234-[ea]st" => "user-1234-[we]st"`},
+		{`"xbc"`, `"ybc"`, `"[x]bc" => "[y]bc"`},
+		{`"abx"`, `"aby"`, `"ab[x]" => "ab[y]"`},
+		{`"ab"`, `"a<b"`, `"ab" => "a[&lt;]b"`},
+		{`"abc"`, `"xyz"`, `["abc" => "xyz"]`},
+		{`"a\u001bc"`, `"a\u0007c"`, `"a[\u001b]c" => "a[\u0007]c"`},
+	}
+	for i, c := range cases {
+		_, msg := Compare([]byte(c.a), []byte(c.b), &opts)
+		if msg != c.expected {
+			t.Errorf("case %d: got %s, expected %s", i, msg, c.expected)
+		}
+	}
+
+	opts.InlineStringDiffMaxLen = 5
+	if _, msg := Compare([]byte(`"user-1234-east"`), []byte(`"user-1234-west"`), &opts); msg != `["user-1234-east" => "user-1234-west"]` {
+		t.Errorf("long strings must not be diffed inline, got %s", msg)
+	}
+}
@@ -0,0 +1,111 @@
+package jsondiff
+
+import "errors"
+
+// errIteratorClosed stops the comparison of a closed Iterator.
+var errIteratorClosed = errors.New("jsondiff: iterator closed")
+
+// Iterator yields the differences between two JSON documents one at a time,
+// in document order, as they are found. The comparison runs only as far as
+// the entries are consumed:
+//
+//	it := jsondiff.NewIterator(a, b, &opts)
+//	defer it.Close()
+//	for it.Next() {
+//		e := it.Entry()
+//		...
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+//
+// An Iterator must not be used from several goroutines at once.
+type Iterator struct {
+	entries chan DiffEntry
+	stop    chan struct{}
+	entry   DiffEntry
+	// closed is set once the comparison finished, stopped if it was
+	// stopped by Close.
+	closed, stopped bool
+	// diff and err are set by the comparison before it closes entries.
+	diff Difference
+	err  error
+}
+
+// NewIterator returns an Iterator over the differences between a and b,
+// compared with opts. The entries are those CompareEntries would return.
+func NewIterator(a, b []byte, opts *Options) *Iterator {
+	return differOf(opts).NewIterator(a, b)
+}
+
+// NewIterator is like the package-level NewIterator, using the options of d.
+func (d *Differ) NewIterator(a, b []byte) *Iterator {
+	it := &Iterator{entries: make(chan DiffEntry), stop: make(chan struct{})}
+	go func() {
+		defer close(it.entries)
+		ctx := d.newContext(nil)
+		ctx.emit = func(e DiffEntry) error {
+			select {
+			case it.entries <- e:
+				return nil
+			case <-it.stop:
+				return errIteratorClosed
+			}
+		}
+		it.diff, _, it.err = ctx.compareErr(a, b)
+	}()
+	return it
+}
+
+// Next advances to the next difference and reports whether there is one.
+// It returns false once all differences were yielded or the Iterator was
+// closed.
+func (it *Iterator) Next() bool {
+	if it.closed {
+		return false
+	}
+	e, ok := <-it.entries
+	if !ok {
+		it.closed = true
+		return false
+	}
+	it.entry = e
+	return true
+}
+
+// Entry returns the difference Next advanced to.
+func (it *Iterator) Entry() DiffEntry {
+	return it.entry
+}
+
+// Err returns the error describing why a document is not valid JSON, like
+// CompareErr, once Next returned false. There are no entries then.
+func (it *Iterator) Err() error {
+	if !it.closed {
+		return nil
+	}
+	return it.err
+}
+
+// Difference returns the Difference of the documents once Next returned
+// false after yielding all differences. It is NoMatch for an Iterator
+// closed early.
+func (it *Iterator) Difference() Difference {
+	if !it.closed || it.stopped {
+		return NoMatch
+	}
+	return it.diff
+}
+
+// Close stops the comparison and releases its resources. It must be called
+// unless Next returned false, and may be called more than once.
+func (it *Iterator) Close() {
+	if it.closed {
+		return
+	}
+	close(it.stop)
+	// Wait for the comparison to stop.
+	for range it.entries {
+	}
+	it.closed, it.stopped = true, true
+}
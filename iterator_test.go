@@ -0,0 +1,106 @@
+package jsondiff
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"runtime"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestIterator(t *testing.T) {
+	opts := Options{StringAsMapFields: []string{"doc"}, TrackPositions: true}
+	a := []byte(`{"a": [1, 2, 3], "doc": "{\"x\": 1, \"y\": [true]}", "o": {"k": "v", "gone": null}, "same": 1}`)
+	b := []byte(`{"a": [1, 3], "doc": "{\"x\": 2, \"y\": []}", "o": {"k": "w", "new": {}}, "same": 1}`)
+	diff, expected := CompareEntries(a, b, &opts)
+	it := NewIterator(a, b, &opts)
+	var got []DiffEntry
+	for it.Next() {
+		got = append(got, it.Entry())
+	}
+	if err := it.Err(); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, expected) || it.Difference() != diff {
+		t.Errorf("got %s %+v, expected %s %+v", it.Difference(), got, diff, expected)
+	}
+	it.Close()
+	if it.Next() {
+		t.Error("Next returned true after the end")
+	}
+}
+
+func TestIteratorInvalid(t *testing.T) {
+	it := NewIterator([]byte(`[1]`), []byte(`[1,`), &Options{})
+	defer it.Close()
+	if it.Next() {
+		t.Error("got an entry for invalid JSON")
+	}
+	var ije *InvalidJSONError
+	if err := it.Err(); err == nil || !errors.As(err, &ije) || ije.Document != 2 || it.Difference() != SecondArgIsInvalidJson {
+		t.Errorf("got %s %v", it.Difference(), err)
+	}
+}
+
+// changedArrays returns two arrays of n numbers differing in every element.
+func changedArrays(n int) ([]byte, []byte) {
+	var a, b bytes.Buffer
+	a.WriteString("[")
+	b.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			a.WriteString(",")
+			b.WriteString(",")
+		}
+		a.WriteString(strconv.Itoa(i))
+		b.WriteString(strconv.Itoa(i + 1))
+	}
+	a.WriteString("]")
+	b.WriteString("]")
+	return a.Bytes(), b.Bytes()
+}
+
+// allocated returns the bytes allocated by fn and how long it took.
+func allocated(fn func()) (uint64, time.Duration) {
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+	fn()
+	elapsed := time.Since(start)
+	runtime.ReadMemStats(&after)
+	return after.TotalAlloc - before.TotalAlloc, elapsed
+}
+
+func TestIteratorStopEarly(t *testing.T) {
+	a, b := changedArrays(200000)
+	opts := Options{}
+	goroutines := runtime.NumGoroutine()
+	var first DiffEntry
+	iterBytes, iterTime := allocated(func() {
+		it := NewIterator(a, b, &opts)
+		if !it.Next() {
+			t.Fatal("no entry")
+		}
+		first = it.Entry()
+		it.Close()
+	})
+	if first.Path != "/0" || first.Kind != KindChanged {
+		t.Errorf("got first entry %+v", first)
+	}
+	batchBytes, batchTime := allocated(func() {
+		CompareEntries(a, b, &opts)
+	})
+	// Both decode the documents, but only the batch compares all of them.
+	if iterBytes > batchBytes/2 || iterTime > batchTime {
+		t.Errorf("iterator allocated %d bytes in %v, batch %d bytes in %v", iterBytes, iterTime, batchBytes, batchTime)
+	}
+	for i := 0; i < 100 && runtime.NumGoroutine() > goroutines; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if n := runtime.NumGoroutine(); n > goroutines {
+		t.Errorf("%d goroutines running after Close, %d before", n, goroutines)
+	}
+}
@@ -3,9 +3,12 @@ package jsondiff
 import (
 	"bytes"
 	"encoding/json"
+	"fmt"
 	"reflect"
 	"sort"
 	"strconv"
+	"strings"
+	"sync"
 )
 
 type Difference int
@@ -17,6 +20,7 @@ const (
 	FirstArgIsInvalidJson
 	SecondArgIsInvalidJson
 	BothArgsAreInvalidJson
+	SubsetMatch
 )
 
 func (d Difference) String() string {
@@ -33,15 +37,107 @@ func (d Difference) String() string {
 		return "SecondArgIsInvalidJson"
 	case BothArgsAreInvalidJson:
 		return "BothArgsAreInvalidJson"
+	case SubsetMatch:
+		return "SubsetMatch"
 	}
 	return "Invalid"
 }
 
+// IsMatch reports whether d is FullMatch.
+func (d Difference) IsMatch() bool {
+	return d == FullMatch
+}
+
+// IsSuperset reports whether d is SupersetMatch.
+func (d Difference) IsSuperset() bool {
+	return d == SupersetMatch
+}
+
+// IsSubset reports whether d is SubsetMatch.
+func (d Difference) IsSubset() bool {
+	return d == SubsetMatch
+}
+
+// IsInvalidJson reports whether d means that either document is not valid
+// JSON.
+func (d Difference) IsInvalidJson() bool {
+	return d == FirstArgIsInvalidJson || d == SecondArgIsInvalidJson || d == BothArgsAreInvalidJson
+}
+
+// MarshalText returns the name of d, as returned by String.
+func (d Difference) MarshalText() ([]byte, error) {
+	if d < FullMatch || d > SubsetMatch {
+		return nil, fmt.Errorf("jsondiff: invalid Difference %d", int(d))
+	}
+	return []byte(d.String()), nil
+}
+
+// UnmarshalText sets d to the Difference named text.
+func (d *Difference) UnmarshalText(text []byte) error {
+	for v := FullMatch; v <= SubsetMatch; v++ {
+		if v.String() == string(text) {
+			*d = v
+			return nil
+		}
+	}
+	return fmt.Errorf("jsondiff: unknown Difference %q", text)
+}
+
+// MarshalJSON returns the name of d as a JSON string.
+func (d Difference) MarshalJSON() ([]byte, error) {
+	text, err := d.MarshalText()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(string(text))
+}
+
+// UnmarshalJSON sets d to the Difference named by the JSON string data. A
+// JSON null leaves d unchanged.
+func (d *Difference) UnmarshalJSON(data []byte) error {
+	if string(data) == "null" {
+		return nil
+	}
+	var name string
+	if err := json.Unmarshal(data, &name); err != nil {
+		return fmt.Errorf("jsondiff: Difference must be a JSON string: %w", err)
+	}
+	return d.UnmarshalText([]byte(name))
+}
+
+// Tag is written around rendered values. Begin and End may contain the
+// placeholders {path}, {key}, {type} and {kind}, which are replaced with the
+// JSON Pointer, the object key or array index, the JSON type ("object",
+// "number", ...) and the kind of the entry ("added", "removed", "changed" or
+// "unchanged") the tag is opened for. {type} is empty for the Normal tag.
+// Substituted values are escaped, for HTML if Options.EscapeHTML is set.
+// A tag whose End is empty and whose Begin only opens HTML elements or only
+// holds ANSI SGR sequences is closed as if End closed the elements, in
+// reverse order, or reset the attributes.
 type Tag struct {
 	Begin string
 	End   string
 }
 
+// PrintTypesMode selects which values are annotated with their JSON type.
+type PrintTypesMode int
+
+const (
+	// PrintTypesAuto annotates all values if Options.PrintTypes is set and
+	// none otherwise.
+	PrintTypesAuto PrintTypesMode = iota
+	// PrintTypesAlways annotates all values.
+	PrintTypesAlways
+	// PrintTypesOnMismatch annotates only added, removed and changed values,
+	// including all values nested in them.
+	PrintTypesOnMismatch
+	// PrintTypesNever annotates no values.
+	PrintTypesNever
+)
+
+// Options configure a comparison. The functions taking an *Options treat a
+// nil one as the zero Options: no tags, no indentation and no special
+// fields.
 type Options struct {
 	Normal            Tag
 	Added             Tag
@@ -54,8 +150,298 @@ type Options struct {
 	IgnoreFields      []string
 	StringAsMapFields []string
 	NullAsEmpty       bool
+
+	// MaxOutputBytes limits the size of the returned diff string. Once the
+	// rendered output exceeds the limit nothing more is rendered, though the
+	// comparison goes on, and the output is cut at the last line break
+	// before the limit and followed by a truncation marker counting the
+	// differences left out. Zero means unlimited. The Difference result is
+	// not affected.
+	MaxOutputBytes int
+
+	// MaxDifferences stops the comparison once that many differences have
+	// been found, to bound the work spent on documents that differ
+	// throughout. No more entries are produced or rendered, and the output
+	// ends with a "... stopped after N differences" marker. Zero means
+	// unlimited. The Difference is the one found so far, which may
+	// understate it: documents that would turn out to be NoMatch can be
+	// reported as SupersetMatch or SubsetMatch.
+	MaxDifferences int
+	// MaxDifferencesExact makes a comparison reaching MaxDifferences go on
+	// to compute the exact Difference, still without producing or rendering
+	// the differences past the limit.
+	MaxDifferencesExact bool
+
+	// PreserveKeyOrder renders object keys in the order they appear in the
+	// input documents instead of sorting them. Keys present only in the
+	// second document are placed after their nearest preceding neighbor.
+	PreserveKeyOrder bool
+
+	// ShowPaths annotates every added, removed and changed entry with its
+	// JSON Pointer. Paths inside StringAsMapFields documents are written as
+	// the pointer of the field, a '#' and the pointer inside the document.
+	ShowPaths bool
+
+	// PathComment is written between an entry and its path when ShowPaths is
+	// set. Defaults to "    # ".
+	PathComment string
+
+	// ShowSummary appends a line with the number of added, removed, changed
+	// and unchanged entries to the output. A wholly added or removed subtree
+	// counts as a single entry, unchanged entries are counted as leaf values.
+	// Ignored and fuzzy fields are not counted.
+	ShowSummary bool
+
+	// MaxDisplayDepth limits how deep whole values are expanded in the
+	// output. Objects and arrays nested MaxDisplayDepth or more levels below
+	// the document root are rendered as a placeholder with the number of
+	// their children, e.g. "{… 14 keys}". Zero means unlimited. The
+	// Difference result is not affected.
+	MaxDisplayDepth int
+
+	// ASCIIOnly escapes every non-ASCII character in rendered strings and
+	// keys as \uXXXX, for log systems that mangle UTF-8. Control characters
+	// and invalid UTF-8 are always escaped.
+	ASCIIOnly bool
+
+	// DetailedTypes makes type annotations distinguish integers from
+	// floating-point numbers: "(integer)" and "(float)" are printed instead
+	// of "(number)". Changed numbers of different kinds are annotated even
+	// if PrintTypes is not set, unless PrintTypesMode is PrintTypesNever.
+	DetailedTypes bool
+
+	// EscapeHTML replaces '<', '>' and '&' in rendered strings, keys and
+	// paths with HTML character references. It is set by the HTML presets.
+	EscapeHTML bool
+
+	// InlineStringDiff highlights only the differing middle part of changed
+	// string values, rendering their common prefix and suffix with the
+	// Normal tag. Strings with nothing in common are rendered as usual.
+	InlineStringDiff bool
+
+	// InlineStringDiffMaxLen disables InlineStringDiff for strings longer
+	// than the given number of bytes. Zero means no limit.
+	InlineStringDiffMaxLen int
+
+	// SARIFLevels maps kinds of differences to the SARIF result level
+	// ("error", "warning", "note" or "none") used by WriteSARIF. Kinds not in
+	// the map are reported as "error".
+	SARIFLevels map[DiffKind]string
+
+	// PrintTypesMode selects which values are annotated with their type. If
+	// it is not PrintTypesAuto, it takes precedence over PrintTypes. The
+	// strings of StringAsMapFields holding JSON, and the documents rendered
+	// in their place, are annotated as "(string as json)"; the values inside
+	// those documents are annotated like any other.
+	PrintTypesMode PrintTypesMode
+
+	// ExpandChangedValues renders both sides of a changed value in full.
+	// Otherwise objects and arrays are shown as "{}" and "[]" when a value
+	// changes its type.
+	ExpandChangedValues bool
+
+	// CollapseAddedRemoved renders added and removed objects and arrays as a
+	// placeholder with the number of their children, e.g. "{… 14 keys}",
+	// instead of printing the whole subtree.
+	CollapseAddedRemoved bool
+
+	// ShowLegend prepends a line naming the added, removed and changed
+	// markers, each wrapped in its own tag, and a separator line to the
+	// output. Nothing is added on FullMatch.
+	ShowLegend bool
+
+	// TrackPositions records where every value starts in the input
+	// documents and fills in OldPos and NewPos of the entries returned by
+	// CompareEntries. Values inside StringAsMapFields documents get the
+	// position of the field.
+	TrackPositions bool
+
+	// ShowPositions appends the line and column of every difference in the
+	// first (a) and second (b) document to its line, e.g. "    # a:3:7
+	// b:4:7", after the path if ShowPaths is also set. It implies
+	// TrackPositions.
+	ShowPositions bool
+
+	// TreeGuides indents with box-drawing guides ("│  ", "├─ " and "└─ ")
+	// connecting every child to its container instead of repeating Indent.
+	TreeGuides bool
+
+	// DisallowTrailingData makes a document holding anything but
+	// whitespace after its JSON value invalid. By default only the first
+	// value is compared and anything after it is ignored.
+	DisallowTrailingData bool
+
+	// OnDifference, if not nil, is called for every added, removed and
+	// changed value as soon as it is found, in document order, with the
+	// arguments of the matching DiffEntry. It is not called for ignored or
+	// fuzzy fields. Panics in OnDifference are not recovered.
+	OnDifference func(path string, kind DiffKind, oldValue, newValue interface{})
+
+	// AutoDecompress makes CompareReaders and CompareFiles decompress
+	// inputs starting with the gzip magic bytes while reading them. Other
+	// inputs are read as they are.
+	AutoDecompress bool
+
+	// NilRawMessageAsNull makes CompareRaw and CompareRawBatch treat a nil
+	// json.RawMessage as null, the way json.Marshal encodes it. Otherwise
+	// it is invalid JSON, like any empty document.
+	NilRawMessageAsNull bool
+
+	// LenientParsing accepts the comments and trailing commas of JSONC and
+	// JSON5 configuration files: // line comments, /* block comments */ and
+	// a comma before the closing bracket of an object or array. They are
+	// ignored, and positions in errors and in TrackPositions still refer to
+	// the original input. Other JSON5 extensions, such as single-quoted
+	// strings or unquoted keys, are still invalid JSON.
+	LenientParsing bool
+
+	// DetectDuplicateKeys finds keys appearing more than once in the same
+	// object of either document, and of the documents in StringAsMapFields
+	// whose strings differ, which are the only ones decoded. encoding/json
+	// keeps only the last value of such a key, so the comparison itself is
+	// not affected; the duplicates are listed in Result.DuplicateKeys by
+	// CompareDetail.
+	DetectDuplicateKeys bool
+
+	// NormalizeDecoded makes CompareDecoded return the decoded documents
+	// the way they were compared: without IgnoreFields members and with the
+	// strings of StringAsMapFields replaced by the documents they hold, if
+	// valid. NullAsEmpty and FuzzyFields depend on the other document and
+	// are not applied.
+	NormalizeDecoded bool
+
+	// NoOutput makes the comparison only classify the documents: nothing is
+	// rendered and the returned text is empty. The Difference, the entries
+	// and the statistics are the same as without it.
+	NoOutput bool
+
+	// Parallelism is the number of goroutines comparing the members of the
+	// root object. The members are split between them in order and the
+	// results are put together as if compared one after another, so the
+	// output is the same. Zero and 1 compare on the calling goroutine, as do
+	// comparisons with OnDifference, MaxDifferences, MaxOutputBytes or
+	// placeholders in tags, and those streaming their entries.
+	Parallelism int
+
+	// MaxDepth limits how deeply the compared values may be nested, the
+	// root being at depth 0, to bound the stack used by the recursion over
+	// them. A comparison reaching a deeper value stops: Compare returns
+	// NoMatch and no text, and the functions returning an error return a
+	// *DepthError. Zero uses DefaultMaxDepth. The decoder rejects JSON
+	// documents nested deeper than 10000 levels as invalid regardless, so
+	// higher limits only apply to YAML documents and decoded values. With
+	// NoOutput, the inside of an added or removed value is not walked, and
+	// so not limited.
+	MaxDepth int
+
+	// HashSubtrees makes Compare3, which compares the values of a branch
+	// again at every level it merges above them, hash the objects and
+	// arrays of the documents first. Those with the same hash are then
+	// equal without being walked. The hashes are 128 bits, seeded at random
+	// when the program starts.
+	HashSubtrees bool
+
+	// LazyDecoding makes comparisons of two objects decode only the
+	// members whose JSON text differs between them, whitespace aside, and
+	// so on in members that are objects in both documents. Members with
+	// the same text are equal without being decoded, and ignored fields
+	// are never decoded. The output is the same, but Stats leave out what
+	// is inside the members skipped. It has no effect with options that
+	// need every value decoded: PreserveKeyOrder, TrackPositions,
+	// ShowPositions, ShowSummary, DetectDuplicateKeys and a MaxDepth below
+	// DefaultMaxDepth, nor on CompareDecoded and Relationship.
+	LazyDecoding bool
+
+	// MaxMemoryBytes bounds, approximately, the memory a comparison may
+	// use for the decoded documents and the rendered output. Documents
+	// passed as bytes are estimated before they are decoded, as their
+	// length plus 64 bytes per value, and the output is counted as it is
+	// rendered. A comparison going over the budget stops: Compare returns
+	// NoMatch and no text, and the functions returning an error return a
+	// *ComparisonTooLargeError. Documents read from readers or decoded
+	// from YAML only have their output counted. Zero means no limit.
+	MaxMemoryBytes int64
+
+	// FuzzyAllowMissing makes a member of FuzzyFields match when it is
+	// present in one of the documents only, as if it held any value in the
+	// other. Such a member is neither rendered nor reported as added or
+	// removed.
+	FuzzyAllowMissing bool
+
+	// IntersectionOnly compares only the members present in both objects
+	// being compared, at every level: a member present in one of them only
+	// is neither rendered nor reported as added or removed, and does not
+	// affect the Difference, as if FuzzyAllowMissing applied to every key.
+	// Documents sharing no key are therefore a FullMatch, as are an empty
+	// object and any other object. Array elements are still compared by
+	// index, extra elements being added or removed.
+	IntersectionOnly bool
+
+	// InvalidUTF8 selects how strings holding bytes that are not valid
+	// UTF-8 are decoded: replaced before they are compared, the default,
+	// rejected as invalid JSON, or passed through and compared as they are.
+	// See InvalidUTF8Mode.
+	InvalidUTF8 InvalidUTF8Mode
+
+	// EmptyInputAsNull makes a document that is empty or holds only
+	// whitespace decode as null instead of being invalid JSON, so that
+	// NullAsEmpty applies to it. Without it, the error of such a document
+	// matches io.EOF. It does not apply to the strings of StringAsMapFields.
+	EmptyInputAsNull bool
+
+	// AlwaysRender makes the functions returning the rendered output return
+	// the first document, rendered in full with the Normal tag, when the
+	// documents match, instead of an empty string. The output for documents
+	// that differ is unchanged.
+	AlwaysRender bool
+
+	// RootLabels name the first and second documents on the lines showing
+	// their roots when these are of different types, which are rendered in
+	// full and annotated with their types unless PrintTypesMode is
+	// PrintTypesNever. Empty labels are "first" and "second".
+	RootLabels [2]string
+
+	// MaskFields lists members whose values must not be shown: keys, or
+	// JSON Pointers if they start with '/', in the format of DiffEntry.Path.
+	// They are compared as usual, but every scalar inside them is rendered
+	// as *** and is "***" in DiffEntry values, in the values passed to
+	// OnDifference and in the Conflicts of Compare3, so that a change shows
+	// without the values changed. Keys inside masked members are shown.
+	// The documents returned by CompareDecoded and Compare3 are not masked.
+	MaskFields []string
+
+	// Severities sets the severity of members, named by keys, or by JSON
+	// Pointers if they start with '/', in the format of DiffEntry.Path. A
+	// member not listed has the severity of its parent, SeverityFail for
+	// the root. Differences inside members with SeverityWarn are warnings:
+	// they are rendered and have entries, with their Severity set, but the
+	// Difference is computed as if the values matched, so documents that
+	// differ only by warnings are a FullMatch, rendered like any other
+	// difference. IgnoreFields members are not compared either way.
+	Severities map[string]Severity
+	// Warning is the tag of warnings, used in place of the tags of Added,
+	// Removed and Changed. Warnings are rendered with the tag of their
+	// kind if it is zero.
+	Warning Tag
+
+	// Weights sets how much the leaves of members count in Similarity and
+	// in the scores derived from it, such as the choice of CompareAny.
+	// Members are named by keys, or by JSON Pointers if they start with
+	// '/', in the format of DiffEntry.Path. A member not listed weighs as
+	// much as its parent, 1 for the root, and weight 0 leaves a member out
+	// of the score. Weights must be finite and not negative.
+	Weights map[string]float64
+
+	// TagChangedKeys opens the Changed tag of a changed member before its
+	// key, as the Added and Removed tags are, rather than before its value,
+	// so that a marker in the tag starts the line of every entry. The tag
+	// then covers the whole entry, and InlineStringDiff has no effect.
+	TagChangedKeys bool
 }
 
+// DefaultMaxDepth is the nesting limit used by Options without MaxDepth.
+const DefaultMaxDepth = 10000
+
 // Provides a set of options that are well suited for console output. Options
 // use ANSI foreground color escape sequences to highlight changes.
 func DefaultConsoleOptions() Options {
@@ -71,53 +457,319 @@ func DefaultConsoleOptions() Options {
 // inside <pre> tag.
 func DefaultHTMLOptions() Options {
 	return Options{
-		Added:   Tag{Begin: `<span style="background-color: #8bff7f">`, End: `</span>`},
-		Removed: Tag{Begin: `<span style="background-color: #fd7f7f">`, End: `</span>`},
-		Changed: Tag{Begin: `<span style="background-color: #fcff7f">`, End: `</span>`},
-		Indent:  "    ",
+		Added:      Tag{Begin: `<span style="background-color: #8bff7f">`, End: `</span>`},
+		Removed:    Tag{Begin: `<span style="background-color: #fd7f7f">`, End: `</span>`},
+		Changed:    Tag{Begin: `<span style="background-color: #fcff7f">`, End: `</span>`},
+		Indent:     "    ",
+		EscapeHTML: true,
+	}
+}
+
+// Provides a set of options for HTML output that mark changes with CSS
+// classes instead of inline styles. The classes are jsondiff-added,
+// jsondiff-removed and jsondiff-changed, HTMLClassStylesheet is a minimal
+// stylesheet for them. Works best inside <pre> tag.
+func DefaultHTMLClassOptions() Options {
+	return Options{
+		Added:      Tag{Begin: `<span class="jsondiff-added">`, End: `</span>`},
+		Removed:    Tag{Begin: `<span class="jsondiff-removed">`, End: `</span>`},
+		Changed:    Tag{Begin: `<span class="jsondiff-changed">`, End: `</span>`},
+		Indent:     "    ",
+		EscapeHTML: true,
+	}
+}
+
+// Provides a set of options for plain-text environments without color
+// support, such as chat messages or email. Entries are marked with visible
+// symbols: "✚ " for added, "✖ " for removed and "✎ " for changed values. The
+// marker starts the line of every entry, before the key of a member, and
+// is repeated on every line of a multi-line entry.
+func DefaultSymbolOptions() Options {
+	return Options{
+		Added:          Tag{Begin: "✚ "},
+		Removed:        Tag{Begin: "✖ "},
+		Changed:        Tag{Begin: "✎ "},
+		Indent:         "    ",
+		TagChangedKeys: true,
 	}
 }
 
+// Provides the same options as DefaultSymbolOptions, but with ASCII markers:
+// "+ " for added, "- " for removed and "~ " for changed values.
+func DefaultASCIISymbolOptions() Options {
+	return Options{
+		Added:          Tag{Begin: "+ "},
+		Removed:        Tag{Begin: "- "},
+		Changed:        Tag{Begin: "~ "},
+		Indent:         "    ",
+		TagChangedKeys: true,
+	}
+}
+
+// HTMLClassStylesheet is a minimal stylesheet for the classes used by
+// DefaultHTMLClassOptions.
+const HTMLClassStylesheet = `.jsondiff-added { background-color: #8bff7f; }
+.jsondiff-removed { background-color: #fd7f7f; }
+.jsondiff-changed { background-color: #fcff7f; }
+`
+
 type context struct {
-	opts              *Options
-	level             int
-	lastTag           *Tag
-	diff              Difference
+	lineState
+	opts   *Options
+	differ *Differ
+	level  int
+	diff   Difference
+	// curKey is the key of the member whose value is being compared, which
+	// the elements of arrays inherit.
 	curKey            string
 	fuzzyFields       map[string]struct{}
 	ignoreFields      map[string]struct{}
 	stringAsMapFields map[string]struct{}
+	marks             []outputMark
+	guides            []guideMark
+	order             keyOrder
+	basePath          string
+	path              []string
+	summary           summary
+	collect           bool
+	entries           []DiffEntry
+	emit              func(DiffEntry) error
+	inEntry           bool
+	entryValue        interface{}
+	templates         bool
+	tracking          bool
+	masked            bool
+	warn              bool
+	warnings          int
+	weight            float64
+	differences       int
+	stopped           bool
+	limit             int
+	full              bool
+	dropped           int
+	positions         [2]positions
+	outer             [2]*Position
+	stats             Stats
+	duplicates        []DuplicateKey
+	countLeaves       bool
+	leafCounts        LeafCounts
+	mismatched        bool
+	firstPath         string
+	decoded           [2]interface{}
+	keepDecoded       bool
+	memory            int64
+	outBuf            *bytes.Buffer
+	memberKey         memberKey
+	outLen            int
+	subtrees          []subtrees
+	cancelErr         func() error
+	nodes             int
+	err               error
+}
+
+// lineState describes the line being written: the open tag and the path
+// comment to be written at its end.
+type lineState struct {
+	// lastTag is the tag as configured, openTag is the tag as written, with
+	// placeholders expanded. Without placeholders both are the same.
+	lastTag *Tag
+	openTag *Tag
+	comment string
+	// opened is the buffer the Begin of openTag was written to and
+	// openedAt the offset following it.
+	opened   *bytes.Buffer
+	openedAt int
+}
+
+// summary counts the entries reported by a comparison.
+type summary struct {
+	added     int
+	removed   int
+	changed   int
+	unchanged int
+}
+
+func (s *summary) add(other summary) {
+	s.added += other.added
+	s.removed += other.removed
+	s.changed += other.changed
+	s.unchanged += other.unchanged
+}
+
+func (s summary) String() string {
+	return strconv.Itoa(s.added) + " added, " +
+		strconv.Itoa(s.removed) + " removed, " +
+		strconv.Itoa(s.changed) + " changed, " +
+		strconv.Itoa(s.unchanged) + " unchanged"
+}
+
+// outputMark remembers where a difference entry starts. Marks are only
+// recorded when Options.MaxOutputBytes is set and are used to count the
+// differences dropped by truncation.
+type outputMark struct {
+	buf *bytes.Buffer
+	off int
+}
+
+// silent reports whether nothing is rendered: with NoOutput, or once the
+// output has exceeded MaxOutputBytes.
+func (ctx *context) silent() bool {
+	return ctx.opts.NoOutput || ctx.full
+}
+
+// bound sets ctx.full if buf has grown past MaxOutputBytes, so that the
+// rest of the comparison renders nothing that truncate would cut anyway.
+func (ctx *context) bound(buf *bytes.Buffer) {
+	if ctx.limit > 0 && buf.Len() > ctx.limit {
+		ctx.full = true
+	}
+}
+
+// write writes s to buf, unless nothing is rendered.
+func (ctx *context) write(buf *bytes.Buffer, s string) {
+	if !ctx.silent() {
+		buf.WriteString(s)
+		ctx.bound(buf)
+	}
 }
 
 func (ctx *context) newline(buf *bytes.Buffer, s string) {
+	if ctx.silent() {
+		if ctx.full && ctx.opts.TreeGuides {
+			ctx.dropGuide(buf, s != "")
+		}
+		return
+	}
 	buf.WriteString(s)
-	if ctx.lastTag != nil {
-		buf.WriteString(ctx.lastTag.End)
+	ctx.closeTag(buf)
+	ctx.flushComment(buf)
+	if ctx.opts.TreeGuides {
+		buf.WriteString(ctx.differ.lineStart(0))
+		// Lines following an opening bracket or a separator start a child.
+		ctx.guides = append(ctx.guides, guideMark{buf: buf, off: buf.Len(), level: ctx.level, start: s != ""})
+	} else {
+		buf.WriteString(ctx.differ.lineStart(ctx.level))
+		for i := maxCachedIndent; i < ctx.level; i++ {
+			buf.WriteString(ctx.opts.Indent)
+		}
 	}
-	buf.WriteString("\n")
-	buf.WriteString(ctx.opts.Prefix)
-	for i := 0; i < ctx.level; i++ {
-		buf.WriteString(ctx.opts.Indent)
+	ctx.reopenTag(buf)
+	ctx.bound(buf)
+}
+
+func (ctx *context) push(token string) {
+	ctx.path = append(ctx.path, token)
+}
+
+func (ctx *context) pop() {
+	ctx.path = ctx.path[:len(ctx.path)-1]
+}
+
+// pointer returns the JSON Pointer of the value being compared.
+func (ctx *context) pointer() string {
+	return ctx.basePath + ctx.localPointer()
+}
+
+// localPointer returns the JSON Pointer of the current value within the
+// document being compared, without basePath.
+func (ctx *context) localPointer() string {
+	return pointerOf(ctx.path)
+}
+
+// annotate schedules the path of the current entry to be written at the end
+// of its line.
+func (ctx *context) annotate(kind DiffKind) {
+	if ctx.silent() || (!ctx.opts.ShowPaths && !ctx.opts.ShowPositions) {
+		return
 	}
-	if ctx.lastTag != nil {
-		buf.WriteString(ctx.lastTag.Begin)
+	comment := ctx.opts.PathComment
+	if comment == "" {
+		comment = "    # "
+	}
+	var buf bytes.Buffer
+	buf.WriteString(comment)
+	if ctx.opts.ShowPaths {
+		path := ctx.pointer()
+		if path == "" {
+			path = "(root)"
+		}
+		writeEscaped(&buf, path, ctx.opts.escapeMode())
+	}
+	if ctx.opts.ShowPositions {
+		oldPos, newPos := ctx.positionsOf(kind)
+		for i, p := range []*Position{oldPos, newPos} {
+			if p == nil {
+				continue
+			}
+			if buf.Len() > len(comment) {
+				buf.WriteByte(' ')
+			}
+			buf.WriteString([]string{"a:", "b:"}[i])
+			buf.WriteString(p.String())
+		}
 	}
+	ctx.comment = buf.String()
+}
+
+func (ctx *context) flushComment(buf *bytes.Buffer) {
+	buf.WriteString(ctx.comment)
+	ctx.comment = ""
 }
 
 func (ctx *context) key(buf *bytes.Buffer, k string) {
 	ctx.curKey = k
-	buf.WriteString(strconv.Quote(k))
+	if ctx.silent() {
+		return
+	}
+	writeQuoted(buf, k, ctx.opts.escapeMode())
 	buf.WriteString(": ")
 }
 
+// memberKey records where the key of the member being compared was written,
+// for Options.TagChangedKeys.
+type memberKey struct {
+	buf      *bytes.Buffer
+	off, end int
+	line     lineState
+}
+
+// tagKey writes the key of the changed member being compared again after
+// opening tag, taking back the key written before it, if nothing else was
+// written since.
+func (ctx *context) tagKey(buf *bytes.Buffer, tag *Tag) {
+	k := ctx.memberKey
+	ctx.memberKey = memberKey{}
+	if k.buf != buf || buf.Len() != k.end {
+		return
+	}
+	buf.Truncate(k.off)
+	ctx.lineState = k.line
+	ctx.tag(buf, tag)
+	ctx.key(buf, ctx.curKey)
+}
+
 func (ctx *context) writeValue(buf *bytes.Buffer, v interface{}, full bool) {
+	if ctx.canceled() {
+		return
+	}
+	if ctx.silent() {
+		return
+	}
+	if full && ctx.isCollapsed() && ctx.writePlaceholder(buf, v) {
+		return
+	}
+	if ctx.masked && isScalar(v) {
+		buf.WriteString(maskedValue)
+		ctx.writeTypeMaybe(buf, v)
+		return
+	}
 	switch vv := v.(type) {
 	case bool:
 		buf.WriteString(strconv.FormatBool(vv))
 	case json.Number:
 		buf.WriteString(string(vv))
 	case string:
-		buf.WriteString(strconv.Quote(vv))
+		writeQuoted(buf, ctx.maskEmbedded(vv), ctx.opts.escapeMode())
 	case []interface{}:
 		if full {
 			if len(vv) == 0 {
@@ -127,7 +779,9 @@ func (ctx *context) writeValue(buf *bytes.Buffer, v interface{}, full bool) {
 				ctx.newline(buf, "[")
 			}
 			for i, v := range vv {
+				ctx.push(strconv.Itoa(i))
 				ctx.writeValue(buf, v, true)
+				ctx.pop()
 				if i != len(vv)-1 {
 					ctx.newline(buf, ",")
 				} else {
@@ -147,17 +801,22 @@ func (ctx *context) writeValue(buf *bytes.Buffer, v interface{}, full bool) {
 				ctx.level++
 				ctx.newline(buf, "{")
 			}
-			i := 0
-			for k, v := range vv {
+			keys := ctx.objectKeys(vv)
+			outer := ctx.curKey
+			for i, k := range keys {
 				ctx.key(buf, k)
-				ctx.writeValue(buf, v, true)
-				if i != len(vv)-1 {
+				ctx.push(k)
+				masked := ctx.maskMember(k)
+				ctx.writeValue(buf, vv[k], true)
+				ctx.masked = masked
+				ctx.pop()
+				ctx.curKey = outer
+				if i != len(keys)-1 {
 					ctx.newline(buf, ",")
 				} else {
 					ctx.level--
 					ctx.newline(buf, "")
 				}
-				i++
 			}
 			buf.WriteString("}")
 		} else {
@@ -170,19 +829,127 @@ func (ctx *context) writeValue(buf *bytes.Buffer, v interface{}, full bool) {
 	ctx.writeTypeMaybe(buf, v)
 }
 
+// objectKeys returns the keys of m in the order they should be rendered.
+func (ctx *context) objectKeys(m map[string]interface{}) []string {
+	if ctx.order != nil {
+		if keys := ctx.order.keys(m); keys != nil {
+			return keys
+		}
+	}
+	return sortedKeys(m)
+}
+
+// mergedKeys returns the union of the keys of ma and mb in the order they
+// should be rendered.
+func (ctx *context) mergedKeys(ma, mb map[string]interface{}) []string {
+	if ctx.order != nil {
+		return ctx.order.merge(ma, mb)
+	}
+	return sortedKeys(ma, mb)
+}
+
+// writePlaceholder renders a non-empty object or array as a placeholder with
+// the number of its children, e.g. "{… 14 keys}". It returns false without
+// writing anything for other values.
+func (ctx *context) writePlaceholder(buf *bytes.Buffer, v interface{}) bool {
+	if !hasPlaceholder(v) {
+		return false
+	}
+	if ctx.silent() {
+		return true
+	}
+	switch vv := v.(type) {
+	case []interface{}:
+		buf.WriteString("[… ")
+		buf.WriteString(plural(len(vv), "item", "items"))
+		buf.WriteString("]")
+	case map[string]interface{}:
+		buf.WriteString("{… ")
+		buf.WriteString(plural(len(vv), "key", "keys"))
+		buf.WriteString("}")
+	}
+	ctx.writeTypeMaybe(buf, v)
+	return true
+}
+
+// hasPlaceholder reports whether v is rendered as a placeholder by
+// writePlaceholder: a non-empty object or array.
+func hasPlaceholder(v interface{}) bool {
+	switch vv := v.(type) {
+	case []interface{}:
+		return len(vv) > 0
+	case map[string]interface{}:
+		return len(vv) > 0
+	}
+	return false
+}
+
+// isCollapsed reports whether composite values at the current depth are
+// rendered as placeholders because of Options.MaxDisplayDepth.
+func (ctx *context) isCollapsed() bool {
+	return ctx.opts.MaxDisplayDepth > 0 && len(ctx.path) >= ctx.opts.MaxDisplayDepth
+}
+
+// printTypes reports whether the value being rendered is annotated with its
+// type.
+func (ctx *context) printTypes() bool {
+	switch ctx.opts.PrintTypesMode {
+	case PrintTypesAlways:
+		return true
+	case PrintTypesOnMismatch:
+		return ctx.inEntry
+	case PrintTypesNever:
+		return false
+	}
+	return ctx.opts.PrintTypes
+}
+
+// writeTypeMaybe annotates v with its type if printTypes says so. The root
+// of a document embedded in a string and the strings of StringAsMapFields
+// that hold valid JSON are annotated as "(string as json)": they are
+// strings in the document rendered, which are read as the documents they
+// hold. Values inside an embedded document are annotated as usual.
 func (ctx *context) writeTypeMaybe(buf *bytes.Buffer, v interface{}) {
-	if ctx.opts.PrintTypes {
-		buf.WriteString(" ")
-		ctx.writeType(buf, v)
+	if ctx.silent() || !ctx.printTypes() {
+		return
+	}
+	buf.WriteString(" ")
+	if ctx.holdsDocument(v) {
+		buf.WriteString("(string as json)")
+		return
+	}
+	ctx.writeType(buf, v)
+}
+
+// holdsDocument reports whether v, the value being rendered, is the root of
+// a document embedded in a string or a string holding such a document.
+func (ctx *context) holdsDocument(v interface{}) bool {
+	if ctx.basePath != "" && len(ctx.path) == 0 {
+		return true
 	}
+	s, ok := v.(string)
+	if !ok {
+		return false
+	}
+	if _, isStringAsMap := ctx.stringAsMapFields[ctx.curKey]; !isStringAsMap {
+		return false
+	}
+	_, err := ctx.differ.nested.newContext(nil).decode([]byte(s), 0)
+	return err == nil
 }
 
 func (ctx *context) writeType(buf *bytes.Buffer, v interface{}) {
-	switch v.(type) {
+	switch vv := v.(type) {
 	case bool:
 		buf.WriteString("(boolean)")
 	case json.Number:
-		buf.WriteString("(number)")
+		if !ctx.opts.DetailedTypes {
+			buf.WriteString("(number)")
+		} else if isInteger(vv) {
+			buf.WriteString("(integer)")
+		} else {
+			buf.WriteString("(float)")
+		}
 	case string:
 		buf.WriteString("(string)")
 	case []interface{}:
@@ -195,34 +962,321 @@ func (ctx *context) writeType(buf *bytes.Buffer, v interface{}) {
 }
 
 func (ctx *context) writeMismatch(buf *bytes.Buffer, a, b interface{}) {
-	ctx.writeValue(buf, a, false)
+	if ctx.silent() {
+		return
+	}
+	// With DetailedTypes, numbers that differ in their kind are always
+	// annotated, so that 1 => 1.0 doesn't look like a plain value change.
+	na, aok := a.(json.Number)
+	nb, bok := b.(json.Number)
+	force := ctx.opts.DetailedTypes && ctx.canForceTypes() && aok && bok && isInteger(na) != isInteger(nb)
+	ctx.writeValue(buf, a, ctx.opts.ExpandChangedValues)
+	if force {
+		buf.WriteString(" ")
+		ctx.writeType(buf, a)
+	}
 	buf.WriteString(" => ")
-	ctx.writeValue(buf, b, false)
+	ctx.writeValue(buf, b, ctx.opts.ExpandChangedValues)
+	if force {
+		buf.WriteString(" ")
+		ctx.writeType(buf, b)
+	}
+}
+
+// canForceTypes reports whether values that printTypes leaves alone may be
+// annotated with their type anyway, to tell apart values that would read
+// alike.
+func (ctx *context) canForceTypes() bool {
+	return !ctx.printTypes() && ctx.opts.PrintTypesMode != PrintTypesNever
+}
+
+// writeRootMismatch renders a and b, the roots of the documents, which are
+// of different types, on lines of their own starting with the labels of
+// RootLabels. Objects and arrays are rendered in full, and both values are
+// annotated with their type.
+func (ctx *context) writeRootMismatch(buf *bytes.Buffer, a, b interface{}) {
+	if ctx.silent() {
+		return
+	}
+	labels := ctx.opts.rootLabels()
+	for i, v := range [2]interface{}{a, b} {
+		if i > 0 {
+			ctx.newline(buf, "")
+		}
+		ctx.tag(buf, &ctx.opts.Changed)
+		writeEscaped(buf, labels[i], ctx.opts.escapeMode())
+		buf.WriteString(": ")
+		ctx.writeValue(buf, v, true)
+		if ctx.canForceTypes() {
+			buf.WriteString(" ")
+			ctx.writeType(buf, v)
+		}
+	}
+}
+
+// rootLabels returns RootLabels with empty labels replaced by the defaults.
+func (opts *Options) rootLabels() [2]string {
+	labels := opts.RootLabels
+	for i, def := range [2]string{"first", "second"} {
+		if labels[i] == "" {
+			labels[i] = def
+		}
+	}
+	return labels
+}
+
+// mark records the start of a difference entry at the current end of buf,
+// or counts it as dropped once the output has exceeded MaxOutputBytes.
+func (ctx *context) mark(buf *bytes.Buffer) {
+	if ctx.opts.MaxOutputBytes <= 0 || ctx.opts.NoOutput {
+		return
+	}
+	if ctx.full {
+		ctx.dropped++
+		return
+	}
+	ctx.marks = append(ctx.marks, outputMark{buf: buf, off: buf.Len()})
+}
+
+// commit appends the contents of a child buffer to buf, moving the marks
+// recorded in the child so they point into buf.
+func (ctx *context) commit(buf, child *bytes.Buffer) {
+	if ctx.opts.NoOutput {
+		return
+	}
+	off := buf.Len()
+	for i := len(ctx.marks) - 1; i >= 0 && ctx.marks[i].buf == child; i-- {
+		ctx.marks[i].buf = buf
+		ctx.marks[i].off += off
+	}
+	// The separator before child is written to buf after child is rendered,
+	// so its guide follows those of child and is moved in front of them.
+	end := len(ctx.guides)
+	for end > 0 && ctx.guides[end-1].buf == buf {
+		end--
+	}
+	start := end
+	for start > 0 && ctx.guides[start-1].buf == child {
+		start--
+		ctx.guides[start].buf = buf
+		ctx.guides[start].off += off
+	}
+	if start < end && end < len(ctx.guides) {
+		moved := append([]guideMark(nil), ctx.guides[start:end]...)
+		n := copy(ctx.guides[start:], ctx.guides[end:])
+		copy(ctx.guides[start+n:], moved)
+	}
+	buf.Write(child.Bytes())
+}
+
+// itemBufs holds the buffers the members of objects compared in parallel
+// are rendered into. A buffer is reused once commit has copied it, since
+// that leaves no reference to its contents.
+var itemBufs = sync.Pool{New: func() interface{} { return new(bytes.Buffer) }}
+
+// maxPooledItemBuf is the capacity above which an item buffer is not returned
+// to the pool, so that one huge item does not pin its memory.
+const maxPooledItemBuf = 64 << 10
+
+func getItemBuf() *bytes.Buffer {
+	return itemBufs.Get().(*bytes.Buffer)
+}
+
+func putItemBuf(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledItemBuf {
+		buf.Reset()
+		itemBufs.Put(buf)
+	}
+}
+
+// truncate cuts the final output to fit into opts.MaxOutputBytes. The cut is
+// made at a line break, where every tag is already closed, so the remaining
+// markup stays balanced. The differences left out are those marked past the
+// cut and those dropped without being rendered.
+func (ctx *context) truncate(buf *bytes.Buffer) {
+	limit := ctx.opts.MaxOutputBytes
+	if limit <= 0 || buf.Len() <= limit {
+		return
+	}
+	cut := bytes.LastIndexByte(buf.Bytes()[:limit], '\n')
+	if cut < 0 {
+		cut = 0
+	}
+	more := ctx.dropped
+	for _, m := range ctx.marks {
+		if m.off >= cut {
+			more++
+		}
+	}
+	buf.Truncate(cut)
+	if cut > 0 {
+		buf.WriteString("\n")
+		buf.WriteString(ctx.opts.Prefix)
+	}
+	buf.WriteString("... output truncated (")
+	buf.WriteString(formatCount(more))
+	if more == 1 {
+		buf.WriteString(" more difference)")
+	} else {
+		buf.WriteString(" more differences)")
+	}
+}
+
+// stopping reports whether the comparison stops descending, having found
+// MaxDifferences differences without MaxDifferencesExact.
+func (ctx *context) stopping() bool {
+	return ctx.stopped && !ctx.opts.MaxDifferencesExact
+}
+
+// writeStopped appends the marker of a comparison that reached
+// MaxDifferences to the final output.
+func (ctx *context) writeStopped(buf *bytes.Buffer) {
+	if !ctx.stopped {
+		return
+	}
+	buf.WriteString("\n")
+	buf.WriteString(ctx.opts.Prefix)
+	buf.WriteString("... stopped after ")
+	buf.WriteString(formatCount(ctx.differences))
+	if ctx.differences == 1 {
+		buf.WriteString(" difference")
+	} else {
+		buf.WriteString(" differences")
+	}
 }
 
+// tag makes tag the one that is open, closing the open one if it differs.
+// At most one tag is open at a time, and newline closes it before every
+// line break and opens it again after, so the markup of every line is
+// balanced and tags never nest.
 func (ctx *context) tag(buf *bytes.Buffer, tag *Tag) {
-	if ctx.lastTag == tag {
+	if ctx.silent() || ctx.lastTag == tag {
+		return
+	}
+	ctx.closeTag(buf)
+	open := tag
+	if ctx.templates {
+		open = ctx.expandTag(tag)
+	}
+	ctx.lastTag, ctx.openTag = tag, open
+	ctx.reopenTag(buf)
+}
+
+// reopenTag writes the Begin of the open tag, if any, to buf.
+func (ctx *context) reopenTag(buf *bytes.Buffer) {
+	if ctx.openTag != nil {
+		buf.WriteString(ctx.openTag.Begin)
+		ctx.opened, ctx.openedAt = buf, buf.Len()
+	}
+}
+
+// closeTag writes the End of the open tag, if any, to buf. A tag with
+// nothing written since its Begin is taken back instead, so that the output
+// holds no empty pairs of tags.
+func (ctx *context) closeTag(buf *bytes.Buffer) {
+	if ctx.openTag == nil {
 		return
-	} else if ctx.lastTag != nil {
-		buf.WriteString(ctx.lastTag.End)
 	}
-	buf.WriteString(tag.Begin)
-	ctx.lastTag = tag
+	if ctx.opened != buf || buf.Len() != ctx.openedAt {
+		buf.WriteString(ctx.openTag.End)
+		return
+	}
+	n := ctx.openedAt - len(ctx.openTag.Begin)
+	buf.Truncate(n)
+	for i := len(ctx.marks) - 1; i >= 0 && ctx.marks[i].buf == buf && ctx.marks[i].off > n; i-- {
+		ctx.marks[i].off = n
+	}
+	for i := len(ctx.guides) - 1; i >= 0 && ctx.guides[i].buf == buf && ctx.guides[i].off > n; i-- {
+		ctx.guides[i].off = n
+	}
+	ctx.opened = nil
 }
 
 func (ctx *context) result(d Difference) {
-	if d == NoMatch {
-		ctx.diff = NoMatch
-	} else if d == SupersetMatch && ctx.diff != NoMatch {
-		ctx.diff = SupersetMatch
-	} else if ctx.diff != NoMatch && ctx.diff != SupersetMatch {
-		ctx.diff = FullMatch
+	if ctx.warn {
+		return
 	}
+	ctx.diff = combine(ctx.diff, d)
+}
+
+// combine returns the Difference of a value whose parts differ by a and b.
+// Removals and additions together make NoMatch.
+func combine(a, b Difference) Difference {
+	switch {
+	case a == b || b == FullMatch:
+		return a
+	case a == FullMatch:
+		return b
+	}
+	return NoMatch
 }
 
 func (ctx *context) printMismatch(buf *bytes.Buffer, a, b interface{}) {
-	ctx.tag(buf, &ctx.opts.Changed)
-	ctx.writeMismatch(buf, a, b)
+	ctx.mark(buf)
+	ctx.entryValue = b
+	ctx.inEntry = true
+	if ctx.basePath == "" && len(ctx.path) == 0 && typeName(a) != typeName(b) {
+		ctx.writeRootMismatch(buf, a, b)
+	} else {
+		tag := ctx.entryTag(&ctx.opts.Changed)
+		if ctx.opts.TagChangedKeys {
+			ctx.tagKey(buf, tag)
+		}
+		if !ctx.writeInlineMismatch(buf, a, b) {
+			ctx.tag(buf, tag)
+			ctx.writeMismatch(buf, a, b)
+		}
+	}
+	ctx.inEntry = false
+	ctx.annotate(KindChanged)
+	ctx.record(KindChanged, a, b)
+	ctx.summary.changed++
+	ctx.countOnly(0, a)
+	ctx.countOnly(1, b)
+}
+
+// printRemoved renders v, which is present only in the first document. If key
+// is not nil, it is written before the value.
+func (ctx *context) printRemoved(buf *bytes.Buffer, key *string, v interface{}) Difference {
+	ctx.mark(buf)
+	ctx.entryValue = v
+	ctx.tag(buf, ctx.entryTag(&ctx.opts.Removed))
+	if key != nil {
+		ctx.key(buf, *key)
+	}
+	ctx.inEntry = true
+	if !ctx.opts.CollapseAddedRemoved || !ctx.writePlaceholder(buf, v) {
+		ctx.writeValue(buf, v, true)
+	}
+	ctx.inEntry = false
+	ctx.annotate(KindRemoved)
+	ctx.record(KindRemoved, v, nil)
+	ctx.summary.removed++
+	ctx.countOnly(0, v)
+	ctx.result(SupersetMatch)
+	return SupersetMatch
+}
+
+// printAdded renders v, which is present only in the second document. If key
+// is not nil, it is written before the value.
+func (ctx *context) printAdded(buf *bytes.Buffer, key *string, v interface{}) Difference {
+	ctx.mark(buf)
+	ctx.entryValue = v
+	ctx.tag(buf, ctx.entryTag(&ctx.opts.Added))
+	if key != nil {
+		ctx.key(buf, *key)
+	}
+	ctx.inEntry = true
+	if !ctx.opts.CollapseAddedRemoved || !ctx.writePlaceholder(buf, v) {
+		ctx.writeValue(buf, v, true)
+	}
+	ctx.inEntry = false
+	ctx.annotate(KindAdded)
+	ctx.record(KindAdded, nil, v)
+	ctx.summary.added++
+	ctx.countOnly(1, v)
+	ctx.result(SubsetMatch)
+	return SubsetMatch
 }
 
 func (ctx *context) printStringDiff(buf *bytes.Buffer, aa string, b interface{}) Difference {
@@ -242,30 +1296,38 @@ func (ctx *context) printStringDiff(buf *bytes.Buffer, aa string, b interface{})
 	if !isStringAsMap {
 		return failedFn()
 	}
-	diff, msg := Compare([]byte(aa), []byte(bb), ctx.opts)
-	if diff != FullMatch {
-		buf.WriteString(msg)
+	nctx := ctx.differ.nested.newContext(ctx)
+	diff, embedded := nctx.compareEmbedded(ctx, buf, []byte(aa), []byte(bb))
+	if nctx.err != nil {
+		ctx.err = nctx.err
+		return FullMatch
+	}
+	ctx.memory = nctx.memory
+	if !embedded {
+		// The strings are compared as strings if either is not a document.
+		return failedFn()
+	}
+	ctx.stats.merge(nctx.stats, len(ctx.path))
+	ctx.duplicates = append(ctx.duplicates, nctx.duplicates...)
+	ctx.warnings += nctx.warnings
+	ctx.differences, ctx.stopped = nctx.differences, nctx.stopped
+	if diff != FullMatch || nctx.warnings > 0 {
+		ctx.summary.add(nctx.summary)
+		ctx.leafCounts.add(nctx.leafCounts)
+		ctx.entries = append(ctx.entries, nctx.entries...)
+		if !ctx.mismatched && nctx.mismatched {
+			ctx.mismatched = true
+			ctx.firstPath = nctx.firstPath
+		}
 		ctx.result(diff)
+		if diff == FullMatch {
+			// The documents differ by warnings only, which are rendered.
+			return NoMatch
+		}
 		return diff
 	}
 	return FullMatch
 }
-func (ctx *context) isStringDiff(aa string, b interface{}) bool {
-	bb, ok := b.(string)
-	if !ok {
-		return true
-	}
-	if aa == bb {
-		return false
-	}
-	_, isStringAsMap := ctx.stringAsMapFields[ctx.curKey]
-	if !isStringAsMap {
-		return true
-	}
-	diff, _ := Compare([]byte(aa), []byte(bb), &Options{})
-	return diff != FullMatch
-}
-
 func (ctx *context) isZeroLen(a, b interface{}) bool {
 	data := a
 	if data == nil {
@@ -282,10 +1344,160 @@ func (ctx *context) isZeroLen(a, b interface{}) bool {
 	return false
 }
 
+// sameKind reports whether the non-nil values a and b are of the same kind of
+// JSON value, so that they are compared rather than reported as a mismatch.
+// Strings and numbers are of the same kind, which makes a fuzzy field match
+// either. Values of types the decoder does not produce are of the same kind
+// only if their types are identical.
+func sameKind(a, b interface{}) bool {
+	switch a.(type) {
+	case bool:
+		_, ok := b.(bool)
+		return ok
+	case string, json.Number:
+		switch b.(type) {
+		case string, json.Number:
+			return true
+		}
+		return false
+	case []interface{}:
+		_, ok := b.([]interface{})
+		return ok
+	case map[string]interface{}:
+		_, ok := b.(map[string]interface{})
+		return ok
+	}
+	return reflect.TypeOf(a) == reflect.TypeOf(b)
+}
+
+// printMembers renders the members of the objects ma and mb with the given
+// keys into buf and returns how they differ.
+func (ctx *context) printMembers(buf *bytes.Buffer, keys []string, ma, mb map[string]interface{}) Difference {
+	mDiff := FullMatch
+	isFirstKey := true
+	for _, k := range keys {
+		if ctx.err != nil || ctx.stopping() {
+			break
+		}
+		if _, found := ctx.ignoreFields[k]; found {
+			continue
+		}
+		start := ctx.beginItem(buf, isFirstKey)
+		itemDiff := ctx.printMember(buf, k, ma, mb)
+		if ctx.endItem(buf, start, itemDiff) {
+			isFirstKey = false
+			mDiff = combine(mDiff, itemDiff)
+		}
+	}
+	return mDiff
+}
+
+// printMember renders the member k of the objects ma and mb into buf and
+// returns how it differs.
+func (ctx *context) printMember(buf *bytes.Buffer, k string, ma, mb map[string]interface{}) Difference {
+	itemDiff := FullMatch
+	outer := ctx.curKey
+	ctx.push(k)
+	masked := ctx.maskMember(k)
+	warn := ctx.gradeMember(k)
+	va, aok := ma[k]
+	vb, bok := mb[k]
+	if aok != bok && ctx.differ.missingMatches(k) {
+		aok, bok = false, false
+	}
+	if aok && bok {
+		if ctx.opts.TagChangedKeys {
+			ctx.memberKey = memberKey{buf: buf, off: buf.Len(), line: ctx.lineState}
+		}
+		ctx.key(buf, k)
+		ctx.memberKey.end = buf.Len()
+		itemDiff = ctx.printDiff(buf, va, vb)
+		ctx.memberKey = memberKey{}
+	} else if aok {
+		itemDiff = ctx.printRemoved(buf, &k, va)
+	} else if bok {
+		itemDiff = ctx.printAdded(buf, &k, vb)
+	}
+	ctx.masked = masked
+	ctx.warn = warn
+	ctx.pop()
+	ctx.curKey = outer
+	return itemDiff
+}
+
+// itemStart is the state of the output before an item of an array or
+// object, which is written to the buffer of its parent directly and rolled
+// back to that state if it turns out to match.
+type itemStart struct {
+	off    int
+	line   lineState
+	marks  int
+	guides int
+	// dropped is the count of differences dropped past MaxOutputBytes.
+	dropped int
+	// stopped is set for items begun past MaxDifferences, which are
+	// compared for the Difference only.
+	stopped bool
+}
+
+// beginItem starts an item of an array or object at the end of buf,
+// preceded by a separator unless it is the first one written.
+func (ctx *context) beginItem(buf *bytes.Buffer, first bool) itemStart {
+	start := itemStart{off: buf.Len(), line: ctx.lineState, marks: len(ctx.marks), guides: len(ctx.guides),
+		dropped: ctx.dropped, stopped: ctx.stopped}
+	if !first {
+		ctx.newline(buf, ",")
+	}
+	ctx.comment = ""
+	return start
+}
+
+// endItem ends the item begun at start, which is kept if it differs and
+// rolled back otherwise, or if it was begun past MaxDifferences, and reports
+// whether it is kept. A kept item is continued on the line it ends on.
+func (ctx *context) endItem(buf *bytes.Buffer, start itemStart, diff Difference) bool {
+	ctx.chargeOutput(buf)
+	if diff == FullMatch || start.stopped {
+		ctx.rollback(buf, start)
+		return false
+	}
+	ctx.tag(buf, &ctx.opts.Normal)
+	ctx.bound(buf)
+	return true
+}
+
+// rollback removes what was written to buf since start and restores the
+// state of the line, the marks and the guides. Rendering resumes if the
+// output is back within MaxOutputBytes.
+func (ctx *context) rollback(buf *bytes.Buffer, start itemStart) {
+	buf.Truncate(start.off)
+	ctx.lineState = start.line
+	ctx.marks = ctx.marks[:start.marks]
+	ctx.guides = ctx.guides[:start.guides]
+	ctx.dropped = start.dropped
+	ctx.full = false
+	ctx.bound(buf)
+}
+
 func (ctx *context) printDiff(buf *bytes.Buffer, a, b interface{}) Difference {
+	if ctx.canceled() {
+		return FullMatch
+	}
+	ctx.stats.Nodes++
+	if len(ctx.path) > ctx.stats.MaxDepth {
+		ctx.stats.MaxDepth = len(ctx.path)
+	}
+	if a == skipped {
+		ctx.result(FullMatch)
+		return FullMatch
+	}
 	_, isFuzzy := ctx.fuzzyFields[ctx.curKey]
 	if a == nil || b == nil {
 		if isFuzzy || (a == nil && b == nil) || (ctx.opts.NullAsEmpty && ctx.isZeroLen(a, b)) {
+			if !isFuzzy {
+				ctx.summary.unchanged++
+			}
+			ctx.countCommon(a)
 			ctx.tag(buf, &ctx.opts.Normal)
 			ctx.writeValue(buf, a, false)
 			ctx.result(FullMatch)
@@ -297,42 +1509,39 @@ func (ctx *context) printDiff(buf *bytes.Buffer, a, b interface{}) Difference {
 		}
 	}
 
-	ka := reflect.TypeOf(a).Kind()
-	kb := reflect.TypeOf(b).Kind()
-	if ka != kb {
+	if !sameKind(a, b) {
 		ctx.printMismatch(buf, a, b)
 		ctx.result(NoMatch)
 		return NoMatch
 	}
 	if isFuzzy {
+		ctx.countCommon(a)
 		ctx.tag(buf, &ctx.opts.Normal)
 		ctx.writeValue(buf, a, false)
 		ctx.result(FullMatch)
 		return FullMatch
 	}
-	switch ka {
-	case reflect.Bool:
-		if a.(bool) != b.(bool) {
+	switch aa := a.(type) {
+	case bool:
+		if aa != b.(bool) {
 			ctx.printMismatch(buf, a, b)
 			ctx.result(NoMatch)
 			return NoMatch
 		}
-	case reflect.String:
-		switch aa := a.(type) {
-		case json.Number:
-			bb, ok := b.(json.Number)
-			if !ok || aa != bb {
-				ctx.printMismatch(buf, a, b)
-				ctx.result(NoMatch)
-				return NoMatch
-			}
-		case string:
-			if diff := ctx.printStringDiff(buf, aa, b); diff != FullMatch {
-				return diff
-			}
+	case json.Number:
+		bb, ok := b.(json.Number)
+		if !ok || aa != bb {
+			ctx.printMismatch(buf, a, b)
+			ctx.result(NoMatch)
+			return NoMatch
 		}
-	case reflect.Slice:
-		sa, sb := a.([]interface{}), b.([]interface{})
+	case string:
+		if diff := ctx.printStringDiff(buf, aa, b); diff != FullMatch {
+			return diff
+		}
+	case []interface{}:
+		sa, sb := aa, b.([]interface{})
+		ctx.stats.Arrays++
 		salen, sblen := len(sa), len(sb)
 		max := salen
 		if sblen > max {
@@ -340,111 +1549,74 @@ func (ctx *context) printDiff(buf *bytes.Buffer, a, b interface{}) Difference {
 		}
 		ctx.tag(buf, &ctx.opts.Normal)
 		if max == 0 {
-			buf.WriteString("[")
+			ctx.countCommon(a)
+			ctx.write(buf, "[")
 		} else {
 			ctx.level++
 			ctx.newline(buf, "[")
 		}
 		sDiff := FullMatch
 		isFirstKey := true
-		for i := 0; i < max; i++ {
+		for i := 0; i < max && ctx.err == nil && !ctx.stopping(); i++ {
 			itemDiff := FullMatch
-			itemBuf := &bytes.Buffer{}
+			start := ctx.beginItem(buf, isFirstKey)
+			ctx.push(strconv.Itoa(i))
 			if i < salen && i < sblen {
-				itemDiff = ctx.printDiff(itemBuf, sa[i], sb[i])
+				itemDiff = ctx.printDiff(buf, sa[i], sb[i])
 			} else if i < salen {
-				ctx.tag(itemBuf, &ctx.opts.Removed)
-				ctx.writeValue(itemBuf, sa[i], true)
-				ctx.result(SupersetMatch)
-				itemDiff = SupersetMatch
+				itemDiff = ctx.printRemoved(buf, nil, sa[i])
 			} else if i < sblen {
-				ctx.tag(itemBuf, &ctx.opts.Added)
-				ctx.writeValue(itemBuf, sb[i], true)
-				ctx.result(NoMatch)
-				itemDiff = NoMatch
+				itemDiff = ctx.printAdded(buf, nil, sb[i])
 			}
-			if itemDiff != FullMatch {
-				if isFirstKey {
-					isFirstKey = false
-				} else {
-					ctx.newline(buf, ",")
-				}
-				sDiff = itemDiff
-				buf.WriteString(itemBuf.String())
-				ctx.tag(buf, &ctx.opts.Normal)
+			ctx.pop()
+			if ctx.endItem(buf, start, itemDiff) {
+				isFirstKey = false
+				sDiff = combine(sDiff, itemDiff)
 			}
 		}
-		ctx.level--
+		if max > 0 {
+			ctx.level--
+		}
 		ctx.newline(buf, "")
-		buf.WriteString("]")
+		ctx.write(buf, "]")
 		ctx.writeTypeMaybe(buf, a)
 		return sDiff
-	case reflect.Map:
-		ma, mb := a.(map[string]interface{}), b.(map[string]interface{})
-		keysMap := make(map[string]bool)
-		for k := range ma {
-			keysMap[k] = true
-		}
-		for k := range mb {
-			keysMap[k] = true
-		}
-		keys := make([]string, 0, len(keysMap))
-		for k := range keysMap {
-			keys = append(keys, k)
-		}
-		sort.Strings(keys)
+	case map[string]interface{}:
+		ma, mb := aa, b.(map[string]interface{})
+		ctx.stats.Objects++
+		keys := ctx.mergedKeys(ma, mb)
 		ctx.tag(buf, &ctx.opts.Normal)
 		if len(keys) == 0 {
-			buf.WriteString("{")
+			ctx.countCommon(a)
+			ctx.write(buf, "{")
 		} else {
 			ctx.level++
 			ctx.newline(buf, "{")
 		}
-		mDiff := FullMatch
-		isfirstKey := true
-		for _, k := range keys {
-			if _, found := ctx.ignoreFields[k]; found {
-				continue
-			}
-			itemBuf := &bytes.Buffer{}
-			itemDiff := FullMatch
-			va, aok := ma[k]
-			vb, bok := mb[k]
-			if aok && bok {
-				ctx.key(itemBuf, k)
-				itemDiff = ctx.printDiff(itemBuf, va, vb)
-			} else if aok {
-				ctx.tag(itemBuf, &ctx.opts.Removed)
-				ctx.key(itemBuf, k)
-				ctx.writeValue(itemBuf, va, true)
-				ctx.result(SupersetMatch)
-				itemDiff = SupersetMatch
-			} else if bok {
-				ctx.tag(itemBuf, &ctx.opts.Added)
-				ctx.key(itemBuf, k)
-				ctx.writeValue(itemBuf, vb, true)
-				ctx.result(NoMatch)
-				itemDiff = NoMatch
-			}
-			if itemDiff != FullMatch {
-				if isfirstKey {
-					isfirstKey = false
-				} else {
-					ctx.newline(buf, ",")
-				}
-				mDiff = itemDiff
-				buf.WriteString(itemBuf.String())
-				ctx.tag(buf, &ctx.opts.Normal)
-			}
+		var mDiff Difference
+		if ctx.parallel(keys) {
+			mDiff = ctx.printMembersParallel(buf, keys, ma, mb)
+		} else {
+			mDiff = ctx.printMembers(buf, keys, ma, mb)
+		}
+		if len(keys) > 0 {
+			ctx.level--
 		}
-		ctx.level--
 		ctx.newline(buf, "")
-		buf.WriteString("}")
+		ctx.write(buf, "}")
 		ctx.writeTypeMaybe(buf, a)
 		return mDiff
+	default:
+		if !reflect.DeepEqual(a, b) {
+			ctx.printMismatch(buf, a, b)
+			ctx.result(NoMatch)
+			return NoMatch
+		}
 	}
 	ctx.tag(buf, &ctx.opts.Normal)
 	ctx.writeValue(buf, a, true)
+	ctx.summary.unchanged++
+	ctx.countCommon(a)
 	ctx.result(FullMatch)
 	return FullMatch
 }
@@ -459,52 +1631,301 @@ func (ctx *context) printDiff(buf *bytes.Buffer, a, b interface{}) Difference {
 // hierarchy which don't match exactly, it must be a superset of another one.
 // For example:
 //
-//     {"a": 123, "b": 456, "c": [7, 8, 9]}
+//	{"a": 123, "b": 456, "c": [7, 8, 9]}
 //
 // Is a superset of:
 //
-//     {"a": 123, "c": [7, 8]}
+//	{"a": 123, "c": [7, 8]}
+//
+// SubsetMatch is the opposite of SupersetMatch: the first argument is a
+// subset of the second one, every difference being a value present only in
+// the second argument.
 //
 // NoMatch means there is no match.
 //
+// Arrays are compared element by element at the same index, without
+// aligning inserted or deleted elements: an element inserted into an array
+// shows every element after it as changed. Comparing arrays takes time and
+// memory linear in their length.
+//
 // The rest of the difference types mean that one of or both JSON documents are
-// invalid JSON.
+// invalid JSON. Documents must be encoded in UTF-8: a leading UTF-8 byte
+// order mark is read as whitespace, keeping the offsets of the document,
+// and a UTF-16 or UTF-32 one makes the document invalid with an
+// *EncodingError.
 //
 // Returned string uses a format similar to pretty printed JSON to show the
 // human-readable difference between provided JSON documents. It is important
 // to understand that returned format is not a valid JSON and is not meant
 // to be machine readable.
 func Compare(a, b []byte, opts *Options) (Difference, string) {
-	var av, bv interface{}
-	da := json.NewDecoder(bytes.NewReader(a))
-	da.UseNumber()
-	db := json.NewDecoder(bytes.NewReader(b))
-	db.UseNumber()
-	errA := da.Decode(&av)
-	errB := db.Decode(&bv)
+	return differOf(opts).Compare(a, b)
+}
+
+// newContext creates the state of a single comparison. If parent is not nil,
+// the comparison is of documents embedded in a string value of the parent
+// comparison and paths are reported relative to the parent.
+func (d *Differ) newContext(parent *context) *context {
+	opts := &d.opts
+	ctx := &context{opts: opts, differ: d}
+	if opts.PreserveKeyOrder {
+		ctx.order = make(keyOrder)
+	}
+	if parent != nil {
+		ctx.basePath = parent.pointer() + "#"
+		ctx.collect = parent.collect
+		ctx.emit = parent.emit
+		ctx.countLeaves = parent.countLeaves
+		ctx.outer = [2]*Position{parent.position(0), parent.position(1)}
+		ctx.cancelErr = parent.cancelErr
+		ctx.memory = parent.memory
+		ctx.masked = parent.masked
+		ctx.warn = parent.warn
+		ctx.differences = parent.differences
+		ctx.stopped = parent.stopped
+		ctx.limit, ctx.full = parent.limit, parent.full
+	} else {
+		ctx.tracking = opts.TrackPositions || opts.ShowPositions
+		if !opts.NoOutput {
+			ctx.limit = opts.MaxOutputBytes
+		}
+	}
+	ctx.templates = d.templates
+	ctx.fuzzyFields = d.fuzzyFields
+	ctx.ignoreFields = d.ignoreFields
+	ctx.stringAsMapFields = d.stringAsMapFields
+	return ctx
+}
+
+// compare decodes and compares a and b and returns the rendered output.
+func (ctx *context) compare(a, b []byte) (Difference, string) {
+	diff, text, _ := ctx.compareErr(a, b)
+	return diff, text
+}
+
+// compareErr compares a and b and returns the error describing invalid
+// documents, if any.
+func (ctx *context) compareErr(a, b []byte) (Difference, string, error) {
+	if !ctx.check() && ctx.identical(a, b) {
+		return FullMatch, "", nil
+	}
+	if !ctx.chargeDocuments(a, b) {
+		return NoMatch, "", ctx.limitError()
+	}
+	av, bv, errA, errB, ok := ctx.decodeDocuments(a, b)
+	if !ok {
+		return NoMatch, "", nil
+	}
+	if diff, msg, invalid := invalidJSON(errA, errB); invalid {
+		return diff, msg, decodeErrors(a, b, errA, errB)
+	}
+	diff, text := ctx.compareValues(av, bv)
+	return diff, text, ctx.limitError()
+}
+
+// decodeDocuments decodes a and b, lazily if it can, and keeps them in
+// ctx.decoded. It returns false if the comparison was stopped meanwhile.
+func (ctx *context) decodeDocuments(a, b []byte) (av, bv interface{}, errA, errB error, ok bool) {
+	lazy := false
+	if ctx.lazy() {
+		av, bv, lazy = ctx.decodeLazily(a, b)
+	}
+	if !lazy {
+		av, errA = ctx.decode(a, 0)
+		if ctx.check() {
+			return nil, nil, nil, nil, false
+		}
+		bv, errB = ctx.decode(b, 1)
+	}
+	if ctx.check() {
+		return nil, nil, nil, nil, false
+	}
+	ctx.decoded = [2]interface{}{av, bv}
+	return av, bv, errA, errB, true
+}
+
+// compareEmbedded compares a and b, the documents embedded in a string
+// value of parent, and renders their differences into buf, the buffer
+// parent renders the value into. The documents are rendered like a value
+// of parent would be: at its level, continuing the line it is on, and with
+// its tree guides. Nothing is written if they match, or if either is not
+// valid JSON, which compareEmbedded reports by returning false.
+func (ctx *context) compareEmbedded(parent *context, buf *bytes.Buffer, a, b []byte) (Difference, bool) {
+	if !ctx.check() && ctx.identical(a, b) {
+		return FullMatch, true
+	}
+	if !ctx.chargeDocuments(a, b) {
+		return NoMatch, true
+	}
+	av, bv, errA, errB, ok := ctx.decodeDocuments(a, b)
+	if !ok {
+		return NoMatch, true
+	}
+	if errA != nil || errB != nil {
+		return NoMatch, false
+	}
+	start := itemStart{off: buf.Len(), line: parent.lineState, marks: len(parent.marks), guides: len(parent.guides),
+		dropped: parent.dropped}
+	parent.mark(buf)
+	ctx.level = parent.level
+	ctx.lineState = ctx.carryLine(parent, parent.lineState)
+	ctx.marks, ctx.guides = parent.marks, parent.guides
+	ctx.outBuf, ctx.outLen = parent.outBuf, parent.outLen
+	ctx.printDiff(buf, av, bv)
+	parent.lineState = parent.carryLine(ctx, ctx.lineState)
+	parent.marks, parent.guides = ctx.marks, ctx.guides
+	parent.outBuf, parent.outLen = ctx.outBuf, ctx.outLen
+	parent.full = ctx.full
+	if ctx.err != nil || (ctx.diff == FullMatch && ctx.warnings == 0) {
+		parent.rollback(buf, start)
+		return FullMatch, true
+	}
+	return ctx.diff, true
+}
+
+// invalidJSON classifies the decoding errors of both arguments. It returns
+// false if both were decoded successfully.
+func invalidJSON(errA, errB error) (Difference, string, bool) {
 	if errA != nil && errB != nil {
-		return BothArgsAreInvalidJson, "both arguments are invalid json"
+		return BothArgsAreInvalidJson, "both arguments are invalid json", true
 	}
 	if errA != nil {
-		return FirstArgIsInvalidJson, "first argument is invalid json"
+		return FirstArgIsInvalidJson, "first argument is invalid json", true
 	}
 	if errB != nil {
-		return SecondArgIsInvalidJson, "second argument is invalid json"
+		return SecondArgIsInvalidJson, "second argument is invalid json", true
+	}
+	return FullMatch, "", false
+}
+
+// writeLegend writes a line explaining the tags in use, followed by a
+// separator line. The Warning tag is explained if Severities is set.
+func (ctx *context) writeLegend(buf *bytes.Buffer) {
+	type legendEntry struct {
+		tag  *Tag
+		name string
+	}
+	entries := []legendEntry{
+		{&ctx.opts.Added, "added"},
+		{&ctx.opts.Removed, "removed"},
+		{&ctx.opts.Changed, "changed"},
+	}
+	if ctx.differ.grading() && ctx.opts.Warning != (Tag{}) {
+		entries = append(entries, legendEntry{&ctx.opts.Warning, "warning"})
+	}
+	width := -1
+	for i, e := range entries {
+		if i > 0 {
+			buf.WriteString(" ")
+		}
+		width += len(e.name) + 1
+		tag := e.tag
+		if ctx.templates {
+			tag = ctx.expandTag(tag)
+		}
+		buf.WriteString(tag.Begin)
+		buf.WriteString(e.name)
+		buf.WriteString(tag.End)
 	}
+	buf.WriteString("\n")
+	buf.WriteString(ctx.opts.Prefix)
+	buf.WriteString(strings.Repeat("-", width))
+	buf.WriteString("\n")
+	buf.WriteString(ctx.opts.Prefix)
+}
 
-	ctx := context{opts: opts}
-	ctx.fuzzyFields = sliceToSet(opts.FuzzyFields)
-	ctx.ignoreFields = sliceToSet(opts.IgnoreFields)
-	ctx.stringAsMapFields = sliceToSet(opts.StringAsMapFields)
+// compareValues compares two decoded documents and returns the rendered
+// output.
+func (ctx *context) compareValues(av, bv interface{}) (Difference, string) {
 	var buf bytes.Buffer
 	ctx.printDiff(&buf, av, bv)
-	if ctx.diff == FullMatch {
+	if ctx.err != nil {
+		return NoMatch, ""
+	}
+	if ctx.diff == FullMatch && ctx.warnings == 0 {
+		if ctx.opts.AlwaysRender {
+			return FullMatch, ctx.render(av)
+		}
 		return FullMatch, ""
 	}
-	if ctx.lastTag != nil {
-		buf.WriteString(ctx.lastTag.End)
+	return ctx.diff, ctx.finish(&buf)
+}
+
+// render returns v rendered in full, for AlwaysRender.
+func (ctx *context) render(v interface{}) string {
+	var buf bytes.Buffer
+	ctx.lineState = lineState{}
+	ctx.marks, ctx.guides = nil, nil
+	ctx.full, ctx.dropped = false, 0
+	ctx.tag(&buf, &ctx.opts.Normal)
+	ctx.writeValue(&buf, v, true)
+	return ctx.finish(&buf)
+}
+
+// finish completes the rendered output in buf and returns it.
+func (ctx *context) finish(buf *bytes.Buffer) string {
+	if ctx.opts.NoOutput {
+		return ""
+	}
+	ctx.closeTag(buf)
+	ctx.flushComment(buf)
+	ctx.writeGuides(buf)
+	ctx.truncate(buf)
+	ctx.writeStopped(buf)
+	if ctx.opts.ShowLegend {
+		var legend bytes.Buffer
+		ctx.writeLegend(&legend)
+		legend.Write(buf.Bytes())
+		*buf = legend
+	}
+	if ctx.opts.ShowSummary {
+		buf.WriteString("\n")
+		buf.WriteString(ctx.opts.Prefix)
+		buf.WriteString(ctx.opts.Normal.Begin)
+		buf.WriteString(ctx.summary.String())
+		buf.WriteString(ctx.opts.Normal.End)
+	}
+	return buf.String()
+}
+
+// sortedKeys returns the union of the keys of the given maps in sorted order,
+// so that rendered output is stable between runs.
+func sortedKeys(ms ...map[string]interface{}) []string {
+	var keys []string
+	seen := make(map[string]struct{})
+	for _, m := range ms {
+		for k := range m {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// pointerOf returns the JSON Pointer made of the unescaped reference tokens.
+// Every path reported, rendered or turned into a patch is built by it or by
+// escapePointerToken, so a key appears escaped the same way in all of them:
+// only '~' and '/' are escaped, and the empty key is the empty token, as in
+// "/" for the member "" of the root.
+func pointerOf(tokens []string) string {
+	var buf strings.Builder
+	for _, token := range tokens {
+		buf.WriteByte('/')
+		buf.WriteString(escapePointerToken(token))
 	}
-	return ctx.diff, buf.String()
+	return buf.String()
+}
+
+// escapePointerToken escapes a reference token of a JSON Pointer as described
+// in RFC 6901.
+func escapePointerToken(token string) string {
+	if strings.IndexAny(token, "~/") < 0 {
+		return token
+	}
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
 }
 
 func sliceToSet(src []string) map[string]struct{} {
@@ -514,3 +1935,26 @@ func sliceToSet(src []string) map[string]struct{} {
 	}
 	return m
 }
+
+// isInteger reports whether n is written as an integer, i.e. without a
+// fraction or an exponent.
+func isInteger(n json.Number) bool {
+	return !strings.ContainsAny(string(n), ".eE")
+}
+
+// plural formats n followed by the singular or plural form of a noun.
+func plural(n int, singular, plural string) string {
+	if n == 1 {
+		return "1 " + singular
+	}
+	return strconv.Itoa(n) + " " + plural
+}
+
+// formatCount formats n with thousands separators, e.g. 1,234.
+func formatCount(n int) string {
+	s := strconv.Itoa(n)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
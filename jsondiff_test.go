@@ -1,8 +1,20 @@
 package jsondiff
 
 import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"strings"
 	"testing"
 )
 
@@ -15,7 +27,7 @@ var cases = []struct {
 	{`{"a": 5}`, `{"a": 6}`, NoMatch},
 	{`{"a": 5}`, `{"a": true}`, NoMatch},
 	{`{"a": 5}`, `{"a": 5}`, FullMatch},
-	{`{"a": 5}`, `{"a": 5, "b": 6}`, NoMatch},
+	{`{"a": 5}`, `{"a": 5, "b": 6}`, SubsetMatch},
 	{`{"a": 5, "b": 6}`, `{"a": 5}`, SupersetMatch},
 	{`{"a": 5, "b": 6}`, `{"b": 6}`, SupersetMatch},
 	{`{"a": null}`, `{"a": 1}`, NoMatch},
@@ -51,12 +63,1771 @@ func TestCompare(t *testing.T) {
 }
 
 func TestCompareJson(t *testing.T) {
-	buf1, _ := ioutil.ReadFile("data1.json")
-	buf2, _ := ioutil.ReadFile("data2.json")
-	opts := DefaultConsoleOptions()
+	opts := Options{Indent: "    "}
 	opts.FuzzyFields = []string{"UrlList", "UserCount"}
 	opts.StringAsMapFields = []string{"LinkRawData", "LinkSendData", "log_extra"}
-	_, msg := Compare(buf1, buf2, &opts)
-	_ = msg
-	//	fmt.Println(msg)
+	diff, msg, err := CompareFiles("testdata/data1.json", "testdata/data2.json", &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != NoMatch {
+		t.Errorf("got %s, expected %s", diff, NoMatch)
+	}
+	for _, s := range []string{`"price": 10 => 12`, `"host": "web-1" => "web-2"`, `"Owner": "marketing"`} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("missing %q in:\n%s", s, msg)
+		}
+	}
+	if strings.Contains(msg, "UserCount") || strings.Contains(msg, "UrlList") {
+		t.Errorf("fuzzy fields reported:\n%s", msg)
+	}
+}
+
+func TestMaxOutputBytes(t *testing.T) {
+	a, b := shiftedArrays(1000)
+
+	opts := DefaultHTMLOptions()
+	_, full := Compare(a, b, &opts)
+	opts.MaxOutputBytes = 1024
+	result, msg := Compare(a, b, &opts)
+	if result != NoMatch {
+		t.Errorf("got: %s, expected: %s", result, NoMatch)
+	}
+	if len(msg) > 1024+100 || len(msg) < 512 {
+		t.Errorf("truncated output has unexpected length %d", len(msg))
+	}
+	kept := msg[:strings.LastIndex(msg, "\n")]
+	if !strings.HasPrefix(full, kept+"\n") {
+		t.Errorf("truncated output is not cut at a line break of the full output")
+	}
+	if strings.Count(msg, "<span") != strings.Count(msg, "</span>") {
+		t.Errorf("unbalanced tags in truncated output:\n%s", msg)
+	}
+	shown := strings.Count(msg, "=>")
+	marker := fmt.Sprintf("... output truncated (%s more differences)", formatCount(1000-shown))
+	if !strings.HasSuffix(msg, marker) {
+		t.Errorf("expected marker %q, got:\n%s", marker, msg)
+	}
+
+	opts.MaxOutputBytes = len(full)
+	if _, msg := Compare(a, b, &opts); msg != full {
+		t.Errorf("output within the limit must not be truncated")
+	}
+
+	// Nothing is rendered past the limit: the buffer only grows beyond it
+	// by the line being written when it is reached.
+	opts.MaxOutputBytes = 1024
+	ctx := differOf(&opts).newContext(nil)
+	av, bv, _, _, _ := ctx.decodeDocuments(a, b)
+	var buf bytes.Buffer
+	ctx.printDiff(&buf, av, bv)
+	if buf.Len() > 1024+100 {
+		t.Errorf("rendered %d bytes with a limit of 1024", buf.Len())
+	}
+	if ctx.diff != NoMatch || ctx.differences != 1000 {
+		t.Errorf("got %s with %d differences, expected %s with 1000", ctx.diff, ctx.differences, NoMatch)
+	}
+}
+
+func TestMaxDifferences(t *testing.T) {
+	a, b := shiftedArrays(1000)
+
+	opts := DefaultConsoleOptions()
+	_, full := Compare(a, b, &opts)
+	for _, limit := range []int{0, 1, 50, 1000} {
+		opts.MaxDifferences = limit
+		diff, msg := Compare(a, b, &opts)
+		if diff != NoMatch {
+			t.Errorf("limit %d: got %s, expected %s", limit, diff, NoMatch)
+		}
+		_, entries := CompareEntries(a, b, &opts)
+		if limit == 0 {
+			if msg != full || len(entries) != 1000 {
+				t.Errorf("limit 0: got %d entries and a different output", len(entries))
+			}
+			continue
+		}
+		marker := fmt.Sprintf("\n... stopped after %s difference", formatCount(limit))
+		if limit > 1 {
+			marker += "s"
+		}
+		if shown := strings.Count(msg, "=>"); shown != limit || len(entries) != limit || !strings.HasSuffix(msg, marker) {
+			t.Errorf("limit %d: got %d rendered and %d entries, expected marker %q in:\n%s",
+				limit, shown, len(entries), marker, msg)
+		}
+		if !strings.HasPrefix(full, strings.TrimSuffix(msg, "\n]"+marker)) {
+			t.Errorf("limit %d: output is not the start of the full output:\n%s", limit, msg)
+		}
+	}
+
+	opts = Options{
+		Indent:         " ",
+		Removed:        Tag{Begin: "<r>", End: "</r>"},
+		Changed:        Tag{Begin: "<c>", End: "</c>"},
+		MaxDifferences: 1,
+	}
+	var calls int
+	opts.OnDifference = func(string, DiffKind, interface{}, interface{}) { calls++ }
+	x, y := []byte(`{"a": 1, "b": {"c": 2, "d": [3]}}`), []byte(`{"b": {"c": 3, "d": [4, 5]}}`)
+	diff, text := Compare(x, y, &opts)
+	expected := "{\n <r>\"a\": 1</r>\n}\n... stopped after 1 difference"
+	if diff != SupersetMatch || text != expected || calls != 1 {
+		t.Errorf("got %s, %d calls and:\n%s\nexpected:\n%s", diff, calls, text, expected)
+	}
+	// MaxDifferencesExact finds the exact Difference, rendering the same.
+	opts.MaxDifferencesExact = true
+	calls = 0
+	diff, text = Compare(x, y, &opts)
+	if diff != NoMatch || text != expected || calls != 1 {
+		t.Errorf("got %s, %d calls and:\n%s\nexpected:\n%s", diff, calls, text, expected)
+	}
+	opts.MaxDifferences = 2
+	diff, text = Compare(x, y, &opts)
+	expected = "{\n <r>\"a\": 1</r>,\n \"b\": {\n  \"c\": <c>2 => 3</c>\n }\n}\n... stopped after 2 differences"
+	if diff != NoMatch || text != expected {
+		t.Errorf("got %s and:\n%s\nexpected:\n%s", diff, text, expected)
+	}
+
+	if err := (&Options{MaxDifferences: -1}).Validate(); err == nil {
+		t.Error("negative MaxDifferences accepted")
+	}
+}
+
+func TestFormatCount(t *testing.T) {
+	for n, s := range map[int]string{0: "0", 12: "12", 999: "999", 1000: "1,000", 1234567: "1,234,567"} {
+		if got := formatCount(n); got != s {
+			t.Errorf("formatCount(%d) = %q, expected %q", n, got, s)
+		}
+	}
+}
+
+func TestCompareOutputIsStable(t *testing.T) {
+	a := `{"a": 1}`
+	b := `{"a": 1, "added": {"z": 1, "y": {"c": [{"q": 1, "p": 2}], "b": 2, "a": 3}, "x": null, "w": "w"}}`
+	opts := DefaultConsoleOptions()
+	_, expected := Compare([]byte(a), []byte(b), &opts)
+	for i := 0; i < 100; i++ {
+		if _, msg := Compare([]byte(a), []byte(b), &opts); msg != expected {
+			t.Fatalf("run %d produced different output:\n%s\nexpected:\n%s", i, msg, expected)
+		}
+	}
+	if strings.Index(expected, `"w"`) > strings.Index(expected, `"z"`) {
+		t.Errorf("keys of an added object are not sorted:\n%s", expected)
+	}
+}
+
+func TestPreserveKeyOrder(t *testing.T) {
+	a := `{"id": 1, "name": "x", "details": {"z": 1, "a": 2}}`
+	b := `{"id": 2, "name": "y", "tags": {"z": 1, "a": 2}, "details": {"z": 2, "a": 3}}`
+	opts := Options{Indent: "  ", PreserveKeyOrder: true}
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	expected := `{
+  "id": 1 => 2,
+  "name": "x" => "y",
+  "tags": {
+    "z": 1,
+    "a": 2
+  },
+  "details": {
+    "z": 1 => 2,
+    "a": 2 => 3
+  }
+}`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+
+	opts.PreserveKeyOrder = false
+	_, msg = Compare([]byte(a), []byte(b), &opts)
+	if strings.Index(msg, `"details"`) > strings.Index(msg, `"id"`) {
+		t.Errorf("keys must be sorted without PreserveKeyOrder:\n%s", msg)
+	}
+}
+
+func TestShowPaths(t *testing.T) {
+	opts := Options{Indent: "  ", ShowPaths: true, StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b     string
+		expected string
+	}{
+		{`5`, `6`, `5 => 6    # (root)`},
+		{
+			`{"items": [{"price": 3}, {"price": 1}, {"price": 3}], "a/b": 1}`,
+			`{"items": [{"price": 3}, {"price": 1}, {"price": 4}, 7], "a/b": 2}`,
+			`{
+  "a/b": 1 => 2,    # /a~1b
+  "items": [
+    {
+      "price": 3 => 4    # /items/2/price
+    },
+    7    # /items/3
+  ]
+}`,
+		},
+		{
+			`{"x": {"doc": "{\"a\": 1, \"b\": [1]}"}}`,
+			`{"x": {"doc": "{\"a\": 2, \"b\": []}"}}`,
+			`{
+  "x": {
+    "doc": {
+      "a": 1 => 2,    # /x/doc#/a
+      "b": [
+        1    # /x/doc#/b/0
+      ]
+    }
+  }
+}`,
+		},
+	}
+	for i, c := range cases {
+		_, msg := Compare([]byte(c.a), []byte(c.b), &opts)
+		if msg != c.expected {
+			t.Errorf("case %d: got:\n%s\nexpected:\n%s", i, msg, c.expected)
+		}
+	}
+
+	opts.PathComment = " // "
+	if _, msg := Compare([]byte(`[1]`), []byte(`[2]`), &opts); msg != "[\n  1 => 2 // /0\n]" {
+		t.Errorf("custom comment prefix is not used:\n%s", msg)
+	}
+}
+
+func TestStringAsMapOptions(t *testing.T) {
+	opts := Options{
+		IgnoreFields:      []string{"etag"},
+		StringAsMapFields: []string{"doc", "inner"},
+		NullAsEmpty:       true,
+	}
+	cases := []struct {
+		a, b   string
+		result Difference
+	}{
+		{`{"doc": "{\"a\": 1, \"etag\": 1}"}`, `{"doc": "{\"a\": 1, \"etag\": 2}"}`, FullMatch},
+		{`{"doc": "{\"a\": 1, \"etag\": 1}"}`, `{"doc": "{\"a\": 1}"}`, FullMatch},
+		{`{"doc": "{\"a\": null}"}`, `{"doc": "{\"a\": []}"}`, FullMatch},
+		{`{"doc": "{\"inner\": \"{\\\"etag\\\": 1}\"}"}`, `{"doc": "{\"inner\": \"{\\\"etag\\\": 2}\"}"}`, FullMatch},
+		{`{"doc": "{\"a\": 1, \"etag\": 1}"}`, `{"doc": "{\"a\": 2, \"etag\": 1}"}`, NoMatch},
+	}
+	for _, c := range cases {
+		a, b := []byte(c.a), []byte(c.b)
+		if diff, msg := Compare(a, b, &opts); diff != c.result {
+			t.Errorf("%s, %s: got %s, expected %s:\n%s", c.a, c.b, diff, c.result, msg)
+		}
+		if equal := Equal(a, b, &opts); equal != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Equal returned %v", c.a, c.b, equal)
+		}
+		if diff, err := CompareStream(bytes.NewReader(a), bytes.NewReader(b), &opts); err != nil || diff != c.result {
+			t.Errorf("%s, %s: CompareStream returned %s, %v", c.a, c.b, diff, err)
+		}
+		if s, err := Similarity(a, b, &opts); err != nil || (s == 1) != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Similarity returned %v, %v", c.a, c.b, s, err)
+		}
+	}
+}
+
+func TestStringAsMapNotJSON(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		diff     Difference
+		expected string
+	}{
+		{`{"doc": "plain"}`, `{"doc": "plain"}`, FullMatch, ``},
+		{`{"doc": "plain"}`, `{"doc": "other"}`, NoMatch, `{
+  "doc": <c>"plain" => "other"</c>
+}`},
+		{`{"doc": "{\"a\": 1}"}`, `{"doc": "plain"}`, NoMatch, `{
+  "doc": <c>"{\"a\": 1}" => "plain"</c>
+}`},
+		{`{"doc": "plain", "x": [1]}`, `{"doc": "{\"a\": 1}", "x": [1]}`, NoMatch, `{
+  "doc": <c>"plain" => "{\"a\": 1}"</c>
+}`},
+	}
+	opts := Options{Indent: "  ", StringAsMapFields: []string{"doc"}, Changed: Tag{Begin: "<c>", End: "</c>"}}
+	for _, c := range cases {
+		diff, msg := Compare([]byte(c.a), []byte(c.b), &opts)
+		if diff != c.diff || msg != c.expected {
+			t.Errorf("%s, %s: got %s:\n%s\nexpected %s:\n%s", c.a, c.b, diff, msg, c.diff, c.expected)
+		}
+		if stream, err := CompareStream(strings.NewReader(c.a), strings.NewReader(c.b), &opts); stream != c.diff || err != nil {
+			t.Errorf("%s, %s: CompareStream got %s, %v", c.a, c.b, stream, err)
+		}
+		if equal := Equal([]byte(c.a), []byte(c.b), &opts); equal != (c.diff == FullMatch) {
+			t.Errorf("%s, %s: Equal got %v", c.a, c.b, equal)
+		}
+		_, entries := CompareEntries([]byte(c.a), []byte(c.b), &opts)
+		if c.diff != FullMatch && (len(entries) != 1 || entries[0].Path != "/doc" || entries[0].Kind != KindChanged) {
+			t.Errorf("%s, %s: got entries %+v", c.a, c.b, entries)
+		}
+	}
+}
+
+// TestStringAsMapPresets checks that the tags of the presets around the
+// members of a document embedded in a string are closed where they would be
+// around those of any object, so that no color or span runs over the
+// embedded document or past it.
+func TestStringAsMapPresets(t *testing.T) {
+	a := `{"doc": "{\"a\": 1, \"b\": {\"c\": [1, 2]}}", "list": [{"doc": "{}"}], "x": 1}`
+	b := `{"doc": "{\"a\": 2, \"b\": {\"c\": [1, 3]}, \"d\": true}", "list": [{"doc": "{}"}, {"doc": "{\"k\": [1]}"}], "x": 1, "y": 2}`
+	expected := `{
+  "doc": {
+    "a": <c>1 => 2</>,
+    "b": {
+      "c": [
+        <c>2 => 3</>
+      ]
+    },
+    <a>"d": true</>
+  },
+  "list": [
+    <a>{</>
+      <a>"doc": "{\"k\": [1]}"</>
+    <a>}</>
+  ],
+  <a>"y": 2</>
+}`
+	presets := []struct {
+		name string
+		opts Options
+		tags *strings.Replacer
+	}{
+		{"HTML", DefaultHTMLOptions(), strings.NewReplacer(
+			"<a>", `<span style="background-color: #8bff7f">`,
+			"<c>", `<span style="background-color: #fcff7f">`,
+			"</>", "</span>")},
+		{"console", DefaultConsoleOptions(), strings.NewReplacer(
+			"<a>", "\033[0;32m",
+			"<c>", "\033[0;33m",
+			"</>", "\033[0m")},
+	}
+	for _, p := range presets {
+		opts := p.opts
+		opts.Indent = "  "
+		opts.StringAsMapFields = []string{"doc"}
+		_, msg := Compare([]byte(a), []byte(b), &opts)
+		if expected := p.tags.Replace(expected); msg != expected {
+			t.Errorf("%s: got:\n%s\nexpected:\n%s", p.name, msg, expected)
+		}
+	}
+}
+
+func TestStringAsMapIndentation(t *testing.T) {
+	a := `{"x": [1, {"y": {"doc": "{\"a\": 1, \"b\": [1, 2]}", "z": 1}}]}`
+	b := `{"x": [1, {"y": {"doc": "{\"a\": 2, \"b\": [1]}", "z": 2}}]}`
+	opts := Options{
+		Indent:            "  ",
+		Prefix:            "> ",
+		StringAsMapFields: []string{"doc"},
+		Normal:            Tag{Begin: "<n>", End: "</n>"},
+		Removed:           Tag{Begin: "<r>", End: "</r>"},
+		Changed:           Tag{Begin: "<c>", End: "</c>"},
+	}
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	expected := `<n>{</n>
+>   <n>"x": [</n>
+>     <n>{</n>
+>       <n>"y": {</n>
+>         <n>"doc": {</n>
+>           <n>"a": </n><c>1 => 2</c><n>,</n>
+>           <n>"b": [</n>
+>             <r>2</r>
+>           <n>]</n>
+>         <n>},</n>
+>         <n>"z": </n><c>1 => 2</c>
+>       <n>}</n>
+>     <n>}</n>
+>   <n>]</n>
+> <n>}</n>`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+
+	opts = Options{Indent: "  ", StringAsMapFields: []string{"doc"}, TreeGuides: true}
+	_, msg = Compare([]byte(a), []byte(b), &opts)
+	expected = `{
+└─ "x": [
+   └─ {
+      └─ "y": {
+         ├─ "doc": {
+         │  ├─ "a": 1 => 2,
+         │  └─ "b": [
+         │     └─ 2
+         │     ]
+         │  },
+         └─ "z": 1 => 2
+         }
+      }
+   ]
+}`
+	if msg != expected {
+		t.Errorf("tree guides: got:\n%s\nexpected:\n%s", msg, expected)
+	}
+}
+
+func TestStringAsMapPrintTypes(t *testing.T) {
+	a := `{"doc": "{\"a\": 1, \"b\": [true]}", "n": 1}`
+	cases := []struct {
+		b        string
+		mode     PrintTypesMode
+		render   bool
+		expected string
+	}{
+		// The annotations inside the document follow PrintTypes, and the
+		// document stands for the string holding it.
+		{`{"doc": "{\"a\": 2, \"b\": [true]}", "n": 1}`, PrintTypesAuto, false, `{
+  "doc": {
+    "a": 1 (number) => 2 (number)
+  } (string as json)
+} (object)`},
+		{`{"doc": "{\"a\": 2, \"b\": [true]}", "n": 1}`, PrintTypesOnMismatch, false, `{
+  "doc": {
+    "a": 1 (number) => 2 (number)
+  }
+}`},
+		{`{"doc": "[1]", "n": 1}`, PrintTypesAuto, false, `{
+  "doc": {} (string as json) => [] (string as json)
+} (object)`},
+		// Strings of which one is not JSON are compared as strings.
+		{`{"doc": "nope", "n": 1}`, PrintTypesAuto, false, `{
+  "doc": "{\"a\": 1, \"b\": [true]}" (string as json) => "nope" (string)
+} (object)`},
+		// A matching field is rendered as the string it is.
+		{`{"doc": "{\"b\": [true], \"a\": 1}", "n": 2}`, PrintTypesAuto, false, `{
+  "n": 1 (number) => 2 (number)
+} (object)`},
+		{`{"doc": "{\"b\": [true], \"a\": 1}", "n": 1}`, PrintTypesAuto, true, `{
+  "doc": "{\"a\": 1, \"b\": [true]}" (string as json),
+  "n": 1 (number)
+} (object)`},
+	}
+	for _, c := range cases {
+		opts := Options{Indent: "  ", PrintTypes: true, PrintTypesMode: c.mode, StringAsMapFields: []string{"doc"}, AlwaysRender: c.render}
+		if _, msg := Compare([]byte(a), []byte(c.b), &opts); msg != c.expected {
+			t.Errorf("%s: got:\n%s\nexpected:\n%s", c.b, msg, c.expected)
+		}
+	}
+}
+
+func TestCurrentKey(t *testing.T) {
+	opts := Options{FuzzyFields: []string{"meta"}, StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b   string
+		result Difference
+	}{
+		{`{"list": [{"meta": 1}, 1]}`, `{"list": [{"meta": 2}, 2]}`, NoMatch},
+		{`{"list": [{"x": {"meta": 1}}, 1]}`, `{"list": [{}, 2]}`, NoMatch},
+		{`{"meta": [1, {"a": 1}]}`, `{"meta": [2, {"a": 2}]}`, FullMatch},
+		{`[{"doc": "{}"}, "{\"a\": 1}"]`, `[{"doc": "{}"}, "{\"a\":1}"]`, NoMatch},
+		{`{"doc": ["{\"a\": 1}"]}`, `{"doc": ["{\"a\":1}"]}`, FullMatch},
+	}
+	for _, c := range cases {
+		for _, noOutput := range []bool{false, true} {
+			opts.NoOutput = noOutput
+			if diff, msg := Compare([]byte(c.a), []byte(c.b), &opts); diff != c.result {
+				t.Errorf("%s, %s, NoOutput %v: got %s, expected %s:\n%s", c.a, c.b, noOutput, diff, c.result, msg)
+			}
+		}
+		if equal := Equal([]byte(c.a), []byte(c.b), &opts); equal != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Equal returned %v", c.a, c.b, equal)
+		}
+	}
+}
+
+func TestFuzzyAllowMissing(t *testing.T) {
+	opts := Options{
+		Indent:            "  ",
+		FuzzyFields:       []string{"request_id"},
+		FuzzyAllowMissing: true,
+	}
+	cases := []struct {
+		a, b     string
+		result   Difference
+		expected string
+	}{
+		{`{"a": 1}`, `{"a": 1, "request_id": "x"}`, FullMatch, ``},
+		{`{"a": 1, "request_id": "x"}`, `{"a": 1}`, FullMatch, ``},
+		{`{"a": [{"request_id": 1}]}`, `{"a": [{}]}`, FullMatch, ``},
+		{`{"a": 1, "request_id": "x"}`, `{"a": 2}`, NoMatch, "{\n  \"a\": 1 => 2\n}"},
+		{`{"a": 1, "b": 2}`, `{"a": 1, "request_id": "x"}`, SupersetMatch, "{\n  \"b\": 2\n}"},
+		{`{"a": 1}`, `{"a": 1, "b": 2, "request_id": "x"}`, SubsetMatch, "{\n  \"b\": 2\n}"},
+	}
+	for _, c := range cases {
+		a, b := []byte(c.a), []byte(c.b)
+		if diff, msg := Compare(a, b, &opts); diff != c.result || msg != c.expected {
+			t.Errorf("%s, %s: got %s:\n%s\nexpected %s:\n%s", c.a, c.b, diff, msg, c.result, c.expected)
+		}
+		if equal := Equal(a, b, &opts); equal != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Equal returned %v", c.a, c.b, equal)
+		}
+		if diff, err := CompareStream(bytes.NewReader(a), bytes.NewReader(b), &opts); err != nil || diff != c.result {
+			t.Errorf("%s, %s: CompareStream returned %s, %v", c.a, c.b, diff, err)
+		}
+		if s, err := Similarity(a, b, &opts); err != nil || (s == 1) != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Similarity returned %v, %v", c.a, c.b, s, err)
+		}
+	}
+
+	opts.FuzzyAllowMissing = false
+	if diff, _ := Compare([]byte(`{"a": 1}`), []byte(`{"a": 1, "request_id": "x"}`), &opts); diff != SubsetMatch {
+		t.Errorf("without FuzzyAllowMissing: got %s", diff)
+	}
+
+	opts.FuzzyAllowMissing = true
+	merged, conflicts, err := Compare3([]byte(`{"a": 1, "request_id": 1}`), []byte(`{"a": 2}`), []byte(`{"a": 1, "request_id": 2}`), &opts)
+	if err != nil || len(conflicts) != 0 || string(merged) != `{"a":2}` {
+		t.Errorf("Compare3 returned %s, %+v, %v", merged, conflicts, err)
+	}
+}
+
+func TestIntersectionOnly(t *testing.T) {
+	opts := Options{
+		Indent:           "  ",
+		Changed:          Tag{Begin: "<c>", End: "</c>"},
+		IntersectionOnly: true,
+	}
+	cases := []struct {
+		a, b     string
+		result   Difference
+		expected string
+	}{
+		{`{"id": 1, "a": 1}`, `{"id": 2, "b": 2}`, NoMatch, "{\n  \"id\": <c>1 => 2</c>\n}"},
+		{`{"id": 1, "a": 1}`, `{"id": 1, "b": 2}`, FullMatch, ``},
+		// Documents sharing no key match.
+		{`{"a": 1}`, `{"b": 2}`, FullMatch, ``},
+		{`{}`, `{"b": {"c": 2}}`, FullMatch, ``},
+		{`{"x": {"id": 1, "a": [1]}}`, `{"x": {"id": 2}, "y": null}`, NoMatch,
+			"{\n  \"x\": {\n    \"id\": <c>1 => 2</c>\n  }\n}"},
+		{`[{"a": 1}, {"b": 1}]`, `[{"b": 2}, {"b": 1}]`, FullMatch, ``},
+		// Arrays are still compared by index.
+		{`{"a": [1, 2]}`, `{"a": [1], "b": 1}`, SupersetMatch, "{\n  \"a\": [\n    2\n  ]\n}"},
+		{`{"a": 1}`, `[1]`, NoMatch, "<c>first: {</c>\n  <c>\"a\": 1</c>\n<c>} (object)</c>\n<c>second: [</c>\n  <c>1</c>\n<c>] (array)</c>"},
+	}
+	for _, c := range cases {
+		a, b := []byte(c.a), []byte(c.b)
+		if diff, msg := Compare(a, b, &opts); diff != c.result || msg != c.expected {
+			t.Errorf("%s, %s: got %s:\n%s\nexpected %s:\n%s", c.a, c.b, diff, msg, c.result, c.expected)
+		}
+		if _, entries := CompareEntries(a, b, &opts); (len(entries) == 0) != (c.result == FullMatch) {
+			t.Errorf("%s, %s: got entries %+v", c.a, c.b, entries)
+		}
+		if equal := Equal(a, b, &opts); equal != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Equal returned %v", c.a, c.b, equal)
+		}
+		if diff, err := CompareStream(bytes.NewReader(a), bytes.NewReader(b), &opts); err != nil || diff != c.result {
+			t.Errorf("%s, %s: CompareStream returned %s, %v", c.a, c.b, diff, err)
+		}
+		if s, err := Similarity(a, b, &opts); err != nil || (s == 1) != (c.result == FullMatch) {
+			t.Errorf("%s, %s: Similarity returned %v, %v", c.a, c.b, s, err)
+		}
+	}
+}
+
+func TestNullAsEmptyNested(t *testing.T) {
+	opts := Options{NullAsEmpty: true, StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b   string
+		result Difference
+	}{
+		{`{"a": {"b": null}}`, `{"a": {"b": []}}`, FullMatch},
+		{`[{"a": {"b": null}}]`, `[{"a": {"b": []}}]`, FullMatch},
+		{`[null, null]`, `[{}, []]`, FullMatch},
+		{`[null, null]`, `[{}, {}]`, FullMatch},
+		{`[[null], {"x": null}]`, `[[{}], {"x": {}}]`, FullMatch},
+		{`[null, 1]`, `[{}, 1, 2]`, SubsetMatch},
+		{`[null, 1]`, `[{}, 2]`, NoMatch},
+		{`[null]`, `[{"a": null}]`, NoMatch},
+		{`{"doc": "{\"a\": null}"}`, `{"doc": "{\"a\": {}}"}`, FullMatch},
+		{`{"doc": "[null, [null]]"}`, `{"doc": "[[], [{}]]"}`, FullMatch},
+		{`{"doc": "null"}`, `{"doc": "{}"}`, FullMatch},
+		// A missing member is not null.
+		{`{"a": null}`, `{}`, SupersetMatch},
+	}
+	for _, c := range cases {
+		for _, swap := range []bool{false, true} {
+			a, b, result := []byte(c.a), []byte(c.b), c.result
+			if swap {
+				a, b = b, a
+				switch result {
+				case SupersetMatch:
+					result = SubsetMatch
+				case SubsetMatch:
+					result = SupersetMatch
+				}
+			}
+			if diff, msg := Compare(a, b, &opts); diff != result {
+				t.Errorf("%s, %s: got %s, expected %s:\n%s", a, b, diff, result, msg)
+			}
+			if equal := Equal(a, b, &opts); equal != (result == FullMatch) {
+				t.Errorf("%s, %s: Equal returned %v", a, b, equal)
+			}
+			if diff, err := CompareStream(bytes.NewReader(a), bytes.NewReader(b), &opts); err != nil || diff != result {
+				t.Errorf("%s, %s: CompareStream returned %s, %v", a, b, diff, err)
+			}
+		}
+	}
+}
+
+func TestShowSummary(t *testing.T) {
+	a := `{"same": [1, 2, {"x": null}], "changed": 1, "type": "s", "removed": {"a": 1, "b": 2},
+		"fuzzy": 1, "ignored": 1, "doc": "{\"p\": 1, \"q\": 2}", "list": [1, 2, 3]}`
+	b := `{"same": [1, 2, {"x": null}], "changed": 2, "type": 5, "added": {"a": 1},
+		"fuzzy": 2, "ignored": 2, "doc": "{\"p\": 1, \"q\": 3, \"r\": 4}", "list": [1, 2]}`
+	opts := Options{
+		ShowSummary:       true,
+		FuzzyFields:       []string{"fuzzy"},
+		IgnoreFields:      []string{"ignored"},
+		StringAsMapFields: []string{"doc"},
+		Normal:            Tag{Begin: "<n>", End: "</n>"},
+	}
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	expected := "\n<n>2 added, 2 removed, 3 changed, 6 unchanged</n>"
+	if !strings.HasSuffix(msg, expected) {
+		t.Errorf("summary not found, got:\n%s", msg)
+	}
+
+	if _, msg := Compare([]byte(a), []byte(a), &opts); msg != "" {
+		t.Errorf("full match must produce no output, got:\n%s", msg)
+	}
+}
+
+func TestMaxDisplayDepth(t *testing.T) {
+	a := `{"same": 1}`
+	b := `{"same": 1, "added": {"obj": {"x": {"y": 1}}, "arr": [[1, 2], [3]], "one": [0], "empty": {}}}`
+	opts := Options{Indent: "  ", MaxDisplayDepth: 2}
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	expected := `{
+  "added": {
+    "arr": [… 2 items],
+    "empty": {},
+    "obj": {… 1 key},
+    "one": [… 1 item]
+  }
+}`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+
+	opts.MaxDisplayDepth = 3
+	_, msg = Compare([]byte(a), []byte(b), &opts)
+	expected = `{
+  "added": {
+    "arr": [
+      [… 2 items],
+      [… 1 item]
+    ],
+    "empty": {},
+    "obj": {
+      "x": {… 1 key}
+    },
+    "one": [
+      0
+    ]
+  }
+}`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+
+	opts.MaxDisplayDepth = 1
+	result, msg := Compare([]byte(a), []byte(b), &opts)
+	if result != SubsetMatch || msg != "{\n  \"added\": {… 4 keys}\n}" {
+		t.Errorf("got %s:\n%s", result, msg)
+	}
+}
+
+func TestCompareEscapesControlCharacters(t *testing.T) {
+	a := "{\"k\\u001b[2J\": \"v\\u0000\", \"bad\xff\": \"x\", \"s\": \"\xfe\"}"
+	b := "{\"k\\u001b[2J\": \"w\\u0007\", \"bad\xff\": \"y\", \"s\": \"\\u001b\"}"
+	opts := Options{ShowPaths: true}
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	for _, c := range msg {
+		if c < 0x20 && c != '\n' {
+			t.Fatalf("output contains control character %q:\n%q", c, msg)
+		}
+	}
+	if !strings.Contains(msg, `"k\u001b[2J": "v\u0000" => "w\u0007"`) {
+		t.Errorf("control characters are not escaped:\n%s", msg)
+	}
+
+	opts.ASCIIOnly = true
+	_, msg = Compare([]byte(a), []byte(b), &opts)
+	for _, c := range msg {
+		if c >= 0x80 {
+			t.Fatalf("output contains non-ASCII character %q:\n%s", c, msg)
+		}
+	}
+	if !strings.Contains(msg, `"bad\ufffd": "x" => "y"`) {
+		t.Errorf("invalid UTF-8 is not escaped:\n%s", msg)
+	}
+}
+
+func TestDetailedTypes(t *testing.T) {
+	opts := Options{PrintTypes: true, DetailedTypes: true}
+	cases := []struct {
+		a, b     string
+		expected string
+	}{
+		{`[1]`, `[]`, "[\n1 (integer)\n] (array)"},
+		{`[1.0]`, `[]`, "[\n1.0 (float)\n] (array)"},
+		{`[1e3]`, `[]`, "[\n1e3 (float)\n] (array)"},
+		{`1`, `1.0`, "1 (integer) => 1.0 (float)"},
+	}
+	for i, c := range cases {
+		_, msg := Compare([]byte(c.a), []byte(c.b), &opts)
+		if msg != c.expected {
+			t.Errorf("case %d: got:\n%s\nexpected:\n%s", i, msg, c.expected)
+		}
+	}
+
+	opts.PrintTypes = false
+	if _, msg := Compare([]byte(`1`), []byte(`1.0`), &opts); msg != "1 (integer) => 1.0 (float)" {
+		t.Errorf("numbers of different kinds must be annotated, got: %s", msg)
+	}
+	if _, msg := Compare([]byte(`1`), []byte(`2`), &opts); msg != "1 => 2" {
+		t.Errorf("numbers of the same kind must not be annotated, got: %s", msg)
+	}
+
+	opts = Options{PrintTypes: true}
+	if _, msg := Compare([]byte(`1`), []byte(`1.0`), &opts); msg != "1 (number) => 1.0 (number)" {
+		t.Errorf("got: %s", msg)
+	}
+}
+
+func TestRootMismatch(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		opts     Options
+		expected string
+	}{
+		{`5`, `"5"`, Options{}, `first: 5 (number)
+second: "5" (string)`},
+		{`null`, `{"a": 1}`, Options{Indent: "  "}, `first: null (null)
+second: {
+  "a": 1
+} (object)`},
+		{`{"a": [1, 2]}`, `[{"a": 1}]`, Options{Indent: "  ", Changed: Tag{Begin: "<c>", End: "</c>"}}, `<c>first: {</c>
+  <c>"a": [</c>
+    <c>1,</c>
+    <c>2</c>
+  <c>]</c>
+<c>} (object)</c>
+<c>second: [</c>
+  <c>{</c>
+    <c>"a": 1</c>
+  <c>}</c>
+<c>] (array)</c>`},
+		{`{}`, `[]`, Options{RootLabels: [2]string{"expected", "actual"}}, `expected: {} (object)
+actual: [] (array)`},
+		{`{}`, `[]`, Options{RootLabels: [2]string{"", "<actual>"}, EscapeHTML: true}, `first: {} (object)
+&lt;actual&gt;: [] (array)`},
+		// Types are annotated once.
+		{`[1]`, `true`, Options{PrintTypes: true}, `first: [
+1 (number)
+] (array)
+second: true (boolean)`},
+		{`[1]`, `true`, Options{PrintTypesMode: PrintTypesNever}, `first: [
+1
+]
+second: true`},
+		// Values of the same type, and values that are not roots, are
+		// rendered as before.
+		{`5`, `6`, Options{}, `5 => 6`},
+		{`[5]`, `["5"]`, Options{}, `[
+5 => "5"
+]`},
+	}
+	for _, c := range cases {
+		diff, msg := Compare([]byte(c.a), []byte(c.b), &c.opts)
+		if diff != NoMatch || msg != c.expected {
+			t.Errorf("%s, %s: got %s:\n%s\nexpected:\n%s", c.a, c.b, diff, msg, c.expected)
+		}
+	}
+}
+
+func TestDefaultHTMLClassOptions(t *testing.T) {
+	opts := DefaultHTMLClassOptions()
+	a := `{"changed": "<b>", "removed": 1, "same": 1}`
+	b := `{"changed": "a & b", "added": 2, "same": 1}`
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	for _, class := range []string{"jsondiff-added", "jsondiff-removed", "jsondiff-changed"} {
+		if !strings.Contains(msg, `<span class="`+class+`">`) {
+			t.Errorf("class %s not found in:\n%s", class, msg)
+		}
+		if !strings.Contains(HTMLClassStylesheet, "."+class+" ") {
+			t.Errorf("class %s is not styled by the sample stylesheet", class)
+		}
+	}
+	if strings.Contains(msg, "style=") {
+		t.Errorf("inline style emitted:\n%s", msg)
+	}
+	if !strings.Contains(msg, `"&lt;b&gt;" => "a &amp; b"`) {
+		t.Errorf("values are not HTML-escaped:\n%s", msg)
+	}
+}
+
+// spanError returns what is wrong with the markup of a line of HTML output,
+// if anything: it must be well-formed, and spans must not be nested.
+func spanError(line string) error {
+	dec := xml.NewDecoder(strings.NewReader("<line>" + line + "</line>"))
+	depth := 0
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		switch tok.(type) {
+		case xml.StartElement:
+			if depth++; depth > 2 {
+				return errors.New("nested span")
+			}
+		case xml.EndElement:
+			depth--
+		}
+	}
+}
+
+func TestTagsBalanced(t *testing.T) {
+	variants := []func(*Options){
+		func(*Options) {},
+		func(o *Options) { o.Normal = Tag{Begin: `<span class="jsondiff-normal">`, End: `</span>`} },
+		func(o *Options) { o.ShowPaths, o.ShowSummary, o.ShowLegend = true, true, true },
+		func(o *Options) { o.TreeGuides, o.PrintTypes = true, true },
+		func(o *Options) { o.MaxOutputBytes = 300 },
+		func(o *Options) { o.InlineStringDiff, o.CollapseAddedRemoved = true, true },
+		func(o *Options) { o.ExpandChangedValues, o.MaxDisplayDepth = true, 2 },
+		func(o *Options) {
+			o.Normal = Tag{Begin: `<span data-kind="{kind}">`, End: `</span>`}
+			o.Changed.Begin = `<span class="jsondiff-changed" data-path="{path}">`
+		},
+	}
+	docs := randomDocuments(rand.New(rand.NewSource(1)), 300)
+	for _, c := range cases {
+		docs = append(docs, [2][]byte{[]byte(c.a), []byte(c.b)})
+	}
+	docs = append(docs, [2][]byte{
+		[]byte(`{"list": [{"doc": "{\"a\": [1, 2]}"}, "hello world"]}`),
+		[]byte(`{"list": [{"doc": "{\"a\": [1, {\"b\": 3}]}"}, "hello there world"]}`),
+	})
+	for i, variant := range variants {
+		opts := DefaultHTMLClassOptions()
+		opts.IgnoreFields = []string{"fuzz1"}
+		opts.StringAsMapFields = []string{"doc"}
+		variant(&opts)
+		for _, doc := range docs {
+			_, msg := Compare(doc[0], doc[1], &opts)
+			for _, line := range strings.Split(msg, "\n") {
+				if err := spanError(line); err != nil {
+					t.Fatalf("variant %d: %s, %s: %v in line %q of:\n%s", i, doc[0], doc[1], err, line, msg)
+				}
+				// Markup in text is escaped, so only a Begin can end in '>'.
+				if strings.Contains(line, "></span>") {
+					t.Fatalf("variant %d: %s, %s: empty span in line %q of:\n%s", i, doc[0], doc[1], line, msg)
+				}
+			}
+		}
+	}
+}
+
+func TestNoEmptyTags(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		diff     Difference
+		expected string
+	}{
+		{`{"a": {"skip": 1}, "b": 1}`, `{"a": {"skip": 2}, "b": 1}`, FullMatch, ``},
+		{`{"a": {"skip": 1}, "b": 1}`, `{"a": {"skip": 2}, "b": 2}`, NoMatch, `<n>{</n>
+  <n>"b": </n><c>1 => 2</c>
+<n>}</n>`},
+		{`{"a": {"b": {"c": 1, "d": 1}, "e": 1}, "f": 1}`, `{"a": {"b": {"c": 2, "d": 1}, "e": 1}, "f": 1}`, NoMatch, `<n>{</n>
+  <n>"a": {</n>
+    <n>"b": {</n>
+      <n>"c": </n><c>1 => 2</c>
+    <n>}</n>
+  <n>}</n>
+<n>}</n>`},
+		{`[[1, 2, 3]]`, `[[1, 3]]`, NoMatch, `<n>[</n>
+  <n>[</n>
+    <c>2 => 3</c><n>,</n>
+    <r>3</r>
+  <n>]</n>
+<n>]</n>`},
+		{`{"a": [1]}`, `{"a": [1, {"b": 2}], "c": 3}`, SubsetMatch, `<n>{</n>
+  <n>"a": [</n>
+    <a>{</a>
+      <a>"b": 2</a>
+    <a>}</a>
+  <n>],</n>
+  <a>"c": 3</a>
+<n>}</n>`},
+	}
+	opts := Options{
+		Indent:       "  ",
+		IgnoreFields: []string{"skip"},
+		Normal:       Tag{Begin: "<n>", End: "</n>"},
+		Added:        Tag{Begin: "<a>", End: "</a>"},
+		Removed:      Tag{Begin: "<r>", End: "</r>"},
+		Changed:      Tag{Begin: "<c>", End: "</c>"},
+	}
+	for _, c := range cases {
+		diff, msg := Compare([]byte(c.a), []byte(c.b), &opts)
+		if diff != c.diff || msg != c.expected {
+			t.Errorf("%s, %s: got %s:\n%s\nexpected %s:\n%s", c.a, c.b, diff, msg, c.diff, c.expected)
+		}
+	}
+}
+
+func TestPrintTypesMode(t *testing.T) {
+	a := `{"same": 1, "changed": 1, "removed": [true]}`
+	b := `{"same": 1, "changed": "1", "added": {"k": null}}`
+	cases := []struct {
+		mode     PrintTypesMode
+		expected string
+	}{
+		{PrintTypesAlways, `{
+  "added": {
+    "k": null (null)
+  } (object),
+  "changed": 1 (number) => "1" (string),
+  "removed": [
+    true (boolean)
+  ] (array)
+} (object)`},
+		{PrintTypesOnMismatch, `{
+  "added": {
+    "k": null (null)
+  } (object),
+  "changed": 1 (number) => "1" (string),
+  "removed": [
+    true (boolean)
+  ] (array)
+}`},
+		{PrintTypesNever, `{
+  "added": {
+    "k": null
+  },
+  "changed": 1 => "1",
+  "removed": [
+    true
+  ]
+}`},
+	}
+	for _, c := range cases {
+		opts := Options{Indent: "  ", PrintTypes: true, PrintTypesMode: c.mode}
+		_, msg := Compare([]byte(a), []byte(b), &opts)
+		if msg != c.expected {
+			t.Errorf("mode %d: got:\n%s\nexpected:\n%s", c.mode, msg, c.expected)
+		}
+	}
+
+	opts := Options{PrintTypesMode: PrintTypesOnMismatch, InlineStringDiff: true}
+	if _, msg := Compare([]byte(`"ab"`), []byte(`"ac"`), &opts); msg != `"ab" (string) => "ac" (string)` {
+		t.Errorf("inline string diff is not annotated: %s", msg)
+	}
+}
+
+func TestExpandAndCollapse(t *testing.T) {
+	a := `{"changed": {"x": 1, "y": [1, 2]}, "removed": [1, 2, 3]}`
+	b := `{"changed": ["x"], "added": {"k": {"n": 1}}}`
+	cases := []struct {
+		expand, collapse bool
+		expected         string
+	}{
+		{false, false, `{
+  "added": {
+    "k": {
+      "n": 1
+    }
+  },
+  "changed": {} => [],
+  "removed": [
+    1,
+    2,
+    3
+  ]
+}`},
+		{true, false, `{
+  "added": {
+    "k": {
+      "n": 1
+    }
+  },
+  "changed": {
+    "x": 1,
+    "y": [
+      1,
+      2
+    ]
+  } => [
+    "x"
+  ],
+  "removed": [
+    1,
+    2,
+    3
+  ]
+}`},
+		{false, true, `{
+  "added": {… 1 key},
+  "changed": {} => [],
+  "removed": [… 3 items]
+}`},
+		{true, true, `{
+  "added": {… 1 key},
+  "changed": {
+    "x": 1,
+    "y": [
+      1,
+      2
+    ]
+  } => [
+    "x"
+  ],
+  "removed": [… 3 items]
+}`},
+	}
+	for _, c := range cases {
+		opts := Options{Indent: "  ", ExpandChangedValues: c.expand, CollapseAddedRemoved: c.collapse}
+		_, msg := Compare([]byte(a), []byte(b), &opts)
+		if msg != c.expected {
+			t.Errorf("expand=%v collapse=%v: got:\n%s\nexpected:\n%s", c.expand, c.collapse, msg, c.expected)
+		}
+	}
+}
+
+func TestExpandChangedComposites(t *testing.T) {
+	a := `{"o2a": {"k": 1}, "o2s": {"k": [1, {"x": null}]}, "deep": {"a": {"b": [[1], {"c": {}}]}}}`
+	b := `{"o2a": [1], "o2s": "text", "deep": {"a": [{"b": {"c": [[]]}}, "s"]}}`
+	opts := Options{
+		Indent:              "  ",
+		Prefix:              "> ",
+		ExpandChangedValues: true,
+		Normal:              Tag{Begin: "<n>", End: "</n>"},
+		Changed:             Tag{Begin: "<c>", End: "</c>"},
+	}
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	expected := `<n>{</n>
+>   <n>"deep": {</n>
+>     <n>"a": </n><c>{</c>
+>       <c>"b": [</c>
+>         <c>[</c>
+>           <c>1</c>
+>         <c>],</c>
+>         <c>{</c>
+>           <c>"c": {}</c>
+>         <c>}</c>
+>       <c>]</c>
+>     <c>} => [</c>
+>       <c>{</c>
+>         <c>"b": {</c>
+>           <c>"c": [</c>
+>             <c>[]</c>
+>           <c>]</c>
+>         <c>}</c>
+>       <c>},</c>
+>       <c>"s"</c>
+>     <c>]</c>
+>   <n>},</n>
+>   <n>"o2a": </n><c>{</c>
+>     <c>"k": 1</c>
+>   <c>} => [</c>
+>     <c>1</c>
+>   <c>]</c><n>,</n>
+>   <n>"o2s": </n><c>{</c>
+>     <c>"k": [</c>
+>       <c>1,</c>
+>       <c>{</c>
+>         <c>"x": null</c>
+>       <c>}</c>
+>     <c>]</c>
+>   <c>} => "text"</c>
+> <n>}</n>`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+
+	opts.ExpandChangedValues = false
+	_, msg = Compare([]byte(a), []byte(b), &opts)
+	expected = `<n>{</n>
+>   <n>"deep": {</n>
+>     <n>"a": </n><c>{} => []</c>
+>   <n>},</n>
+>   <n>"o2a": </n><c>{} => []</c><n>,</n>
+>   <n>"o2s": </n><c>{} => "text"</c>
+> <n>}</n>`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+}
+
+func TestSymbolOptions(t *testing.T) {
+	a := `{"same": 1, "changed": 1, "other": 3, "removed": [1, 2], "list": [1, 2]}`
+	b := `{"same": 1, "changed": 2, "other": 4, "added": {"k": 1}, "list": [1]}`
+	opts := DefaultSymbolOptions()
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	expected := `{
+    ✚ "added": {
+        ✚ "k": 1
+    ✚ },
+    ✎ "changed": 1 => 2,
+    "list": [
+        ✖ 2
+    ],
+    ✎ "other": 3 => 4,
+    ✖ "removed": [
+        ✖ 1,
+        ✖ 2
+    ✖ ]
+}`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+
+	opts = DefaultASCIISymbolOptions()
+	_, msg = Compare([]byte(a), []byte(b), &opts)
+	if strings.Count(msg, "+ ") != 3 || strings.Count(msg, "- ") != 5 || strings.Count(msg, "~ ") != 2 {
+		t.Errorf("unexpected markers:\n%s", msg)
+	}
+	for _, r := range msg {
+		if r >= 0x80 {
+			t.Fatalf("ASCII preset produced non-ASCII output:\n%s", msg)
+		}
+	}
+
+	// Every marker starts its line, whatever the entry.
+	a = `{"changed": {"n": 1, "t": "s", "l": [1]}, "removed": {"k": 1}, "c": [1, {"k": true}]}`
+	b = `{"changed": {"n": 2, "t": [1], "l": [2, 3]}, "added": [1], "c": [0, {"k": null}]}`
+	for _, opts := range []Options{DefaultSymbolOptions(), DefaultASCIISymbolOptions()} {
+		opts.ExpandChangedValues = true
+		_, msg := Compare([]byte(a), []byte(b), &opts)
+		markers := 0
+		for _, line := range strings.Split(msg, "\n") {
+			line = strings.TrimLeft(line, " ")
+			for _, marker := range []Tag{opts.Added, opts.Removed, opts.Changed} {
+				if n := strings.Count(line, marker.Begin); n > 0 {
+					markers += n
+					if n > 1 || !strings.HasPrefix(line, marker.Begin) {
+						t.Errorf("marker %q not at the start of the line %q in:\n%s", marker.Begin, line, msg)
+					}
+				}
+			}
+		}
+		if markers != 14 {
+			t.Errorf("got %d markers, expected 14 in:\n%s", markers, msg)
+		}
+	}
+
+	// Strings are not split by the marker of an inline highlight.
+	opts = DefaultSymbolOptions()
+	opts.InlineStringDiff = true
+	_, msg = Compare([]byte(`{"s": "user-1234-east", "l": ["x-1"]}`), []byte(`{"s": "user-1234-west", "l": ["x-2"]}`), &opts)
+	expected = `{
+    "l": [
+        ✎ "x-1" => "x-2"
+    ],
+    ✎ "s": "user-1234-east" => "user-1234-west"
+}`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+}
+
+func TestShowLegend(t *testing.T) {
+	a, b := []byte(`{"a": 1}`), []byte(`{"a": 2}`)
+
+	opts := DefaultConsoleOptions()
+	opts.ShowLegend = true
+	opts.Prefix = "> "
+	_, msg := Compare(a, b, &opts)
+	expected := "\033[0;32madded\033[0m \033[0;31mremoved\033[0m \033[0;33mchanged\033[0m\n" +
+		"> ---------------------\n" +
+		"> {\n" +
+		">     \"a\": \033[0;33m1 => 2\033[0m\n" +
+		"> }"
+	if msg != expected {
+		t.Errorf("got:\n%q\nexpected:\n%q", msg, expected)
+	}
+
+	opts = DefaultHTMLOptions()
+	opts.ShowLegend = true
+	_, msg = Compare(a, b, &opts)
+	for tag, name := range map[Tag]string{opts.Added: "added", opts.Removed: "removed", opts.Changed: "changed"} {
+		if !strings.Contains(msg, tag.Begin+name+tag.End) {
+			t.Errorf("legend entry %q not found in:\n%s", name, msg)
+		}
+	}
+
+	if _, msg := Compare(a, a, &opts); msg != "" {
+		t.Errorf("legend must be omitted on full match, got:\n%s", msg)
+	}
+}
+
+func TestAlwaysRender(t *testing.T) {
+	a, b := []byte(`{"b": [1, {}], "a": "x"}`), []byte(`{"a":"x","b":[1,{}]}`)
+	opts := Options{Normal: Tag{Begin: "<", End: ">"}, Indent: " ", AlwaysRender: true}
+	expected := "<{>\n <\"a\": \"x\",>\n <\"b\": [>\n  <1,>\n  <{}>\n <]>\n<}>"
+	for _, variant := range []func(*Options){
+		func(*Options) {},
+		func(o *Options) { o.LazyDecoding = true },
+		func(o *Options) { o.StringAsMapFields = []string{"a"} },
+	} {
+		o := opts
+		variant(&o)
+		if diff, text := Compare(a, b, &o); diff != FullMatch || text != expected {
+			t.Errorf("got %s:\n%q\nexpected:\n%q", diff, text, expected)
+		}
+		// The documents need not be decoded to be found identical.
+		if diff, text := Compare(a, a, &o); diff != FullMatch || text != expected {
+			t.Errorf("got %s against itself:\n%q\nexpected:\n%q", diff, text, expected)
+		}
+	}
+	diff, text, err := CompareReaders(bytes.NewReader(a), bytes.NewReader(b), &opts)
+	if diff != FullMatch || text != expected || err != nil {
+		t.Errorf("CompareReaders got %s, %v:\n%q", diff, err, text)
+	}
+	if diff, text := Compare([]byte(`"x"`), []byte(`"x"`), &opts); diff != FullMatch || text != `<"x">` {
+		t.Errorf("got %s: %q", diff, text)
+	}
+
+	console := DefaultConsoleOptions()
+	if _, text := Compare(a, b, &console); text != "" {
+		t.Errorf("got %q without AlwaysRender", text)
+	}
+	console.AlwaysRender = true
+	if _, text := Compare(a, b, &console); !strings.HasPrefix(text, "{\n") || !strings.Contains(text, `"b": [`) {
+		t.Errorf("got %q", text)
+	}
+
+	// Documents that differ are rendered as without the option.
+	c := []byte(`{"a": "y", "b": [1, {}]}`)
+	plain := opts
+	plain.AlwaysRender = false
+	_, want := Compare(a, c, &plain)
+	if diff, text := Compare(a, c, &opts); diff != NoMatch || text != want {
+		t.Errorf("got %s:\n%q\nexpected:\n%q", diff, text, want)
+	}
+}
+
+func TestShowPositions(t *testing.T) {
+	a := "{\n  \"a\": 1,\n  \"b\": [true]\n}"
+	b := `{"a": 2, "b": [true, false]}`
+	expected := `{
+  "a": 1 => 2,    # a:2:8 b:1:7
+  "b": [
+    false    # b:1:22
+  ]
+}`
+	opts := Options{Indent: "  ", ShowPositions: true}
+	if _, got := Compare([]byte(a), []byte(b), &opts); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+	opts.ShowPaths = true
+	_, got := Compare([]byte(a), []byte(b), &opts)
+	if !strings.Contains(got, "# /a a:2:8 b:1:7") || !strings.Contains(got, "# /b/1 b:1:22") {
+		t.Errorf("unexpected output with paths:\n%s", got)
+	}
+}
+
+func TestTreeGuides(t *testing.T) {
+	a := `{"a": 1, "b": {"c": [1, 2, 3], "d": {"e": 1}}, "f": 1, "g": [1]}`
+	b := `{"a": 2, "b": {"c": [1, 5], "d": {"e": 2}}, "f": 1, "g": [2]}`
+	expected := `{
+├─ "a": 1 => 2,
+├─ "b": {
+│  ├─ "c": [
+│  │  ├─ 2 => 5,
+│  │  └─ 3
+│  │  ],
+│  └─ "d": {
+│     └─ "e": 1 => 2
+│     }
+│  },
+└─ "g": [
+   └─ 1 => 2
+   ]
+}`
+	opts := Options{TreeGuides: true}
+	if _, got := Compare([]byte(a), []byte(b), &opts); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+
+	opts.ExpandChangedValues = true
+	expected = `{
+└─ "a": {
+   └─ "x": 1
+   } => [
+   ├─ 2,
+   └─ 3
+   ]
+}`
+	if _, got := Compare([]byte(`{"a": {"x": 1}}`), []byte(`{"a": [2, 3]}`), &opts); got != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", got, expected)
+	}
+}
+
+func TestEmptyContainerIndentation(t *testing.T) {
+	opts := Options{Indent: "  "}
+	_, msg := Compare([]byte(`{"a": [], "b": {}, "c": {"d": 1}, "e": 1}`), []byte(`{"a": [], "b": {}, "c": {"d": 2}, "e": 2}`), &opts)
+	expected := `{
+  "c": {
+    "d": 1 => 2
+  },
+  "e": 1 => 2
+}`
+	if msg != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", msg, expected)
+	}
+}
+
+// TestSeparators checks that separators are written only between the
+// children rendered, whichever of them differ.
+func TestSeparators(t *testing.T) {
+	cases := []struct {
+		a, b, expected string
+	}{
+		// The last child only.
+		{`[1, 2, 3]`, `[1, 2, 4]`, `[
+  3 => 4
+]`},
+		{`{"a": 1, "b": 2, "c": 3}`, `{"a": 1, "b": 2}`, `{
+  "c": 3
+}`},
+		// The first child only.
+		{`[1, 2, 3]`, `[0, 2, 3]`, `[
+  1 => 0
+]`},
+		{`{"a": 1, "b": 2, "c": 3}`, `{"a": 0, "b": 2, "c": 3}`, `{
+  "a": 1 => 0
+}`},
+		// Alternating children.
+		{`[1, 2, 3, 4, 5]`, `[0, 2, 0, 4, 0]`, `[
+  1 => 0,
+  3 => 0,
+  5 => 0
+]`},
+		{`{"a": 1, "b": 2, "c": 3, "d": 4}`, `{"a": 1, "b": 0, "c": 3, "d": 0}`, `{
+  "b": 2 => 0,
+  "d": 4 => 0
+}`},
+		// Nested containers.
+		{`{"a": [1, {"x": 1, "y": 2}], "b": {"c": [1, 2]}, "d": 1}`, `{"a": [1, {"x": 1, "y": 3}], "b": {"c": [1, 2, 3]}, "d": 1}`, `{
+  "a": [
+    {
+      "y": 2 => 3
+    }
+  ],
+  "b": {
+    "c": [
+      3
+    ]
+  }
+}`},
+		{`[[1, 2], [3, 4], [5, 6]]`, `[[0, 2], [3, 4], [5, 0]]`, `[
+  [
+    1 => 0
+  ],
+  [
+    6 => 0
+  ]
+]`},
+	}
+	for _, c := range cases {
+		for _, parallelism := range []int{0, 4} {
+			opts := Options{Indent: "  ", Parallelism: parallelism}
+			if _, msg := Compare([]byte(c.a), []byte(c.b), &opts); msg != c.expected {
+				t.Errorf("%s, %s: got:\n%s\nexpected:\n%s", c.a, c.b, msg, c.expected)
+			}
+		}
+	}
+}
+
+func TestIndentation(t *testing.T) {
+	for _, preset := range []func() Options{DefaultConsoleOptions, DefaultHTMLOptions, DefaultSymbolOptions, DefaultASCIISymbolOptions} {
+		opts := preset()
+		opts.Prefix = "> "
+		// Three levels per nesting, beyond maxCachedIndent for the deepest.
+		for _, depth := range []int{5, maxCachedIndent/3 + 5} {
+			a, b := deepDocuments(depth)
+			_, msg := Compare(a, b, &opts)
+			lines := strings.Split(msg, "\n")
+			// The prefix starts every line but the first.
+			for _, line := range lines[1:] {
+				if !strings.HasPrefix(line, opts.Prefix) {
+					t.Fatalf("line without prefix: %q", line)
+				}
+			}
+			innermost := lines[len(lines)/2]
+			indent := strings.TrimPrefix(innermost, opts.Prefix)
+			if !strings.HasPrefix(indent, strings.Repeat(opts.Indent, 3*depth)) ||
+				strings.HasPrefix(indent, strings.Repeat(opts.Indent, 3*depth+1)) {
+				t.Errorf("depth %d: innermost line %q is not indented %d times", depth, innermost, 3*depth)
+			}
+		}
+	}
+}
+
+func TestNewlineAllocs(t *testing.T) {
+	opts := DefaultConsoleOptions()
+	ctx := newDiffer(opts).newContext(nil)
+	ctx.tag(new(bytes.Buffer), &ctx.opts.Normal)
+	var buf bytes.Buffer
+	for _, level := range []int{3, maxCachedIndent} {
+		ctx.level = level
+		allocs := testing.AllocsPerRun(100, func() {
+			buf.Reset()
+			ctx.newline(&buf, ",")
+		})
+		if allocs != 0 {
+			t.Errorf("level %d: newline allocates %v times", level, allocs)
+		}
+	}
+}
+
+var update = flag.Bool("update", false, "rewrite testdata/render.json.gz with the current output")
+
+// renderVariants are the options of the documents in
+// testdata/render.json.gz, covering the options that affect how items are
+// written and separated.
+func renderVariants() []Options {
+	variants := []Options{DefaultConsoleOptions(), DefaultHTMLClassOptions(), DefaultSymbolOptions()}
+	for _, set := range []func(*Options){
+		func(o *Options) { o.PreserveKeyOrder, o.PrintTypes = true, true },
+		func(o *Options) { o.ShowPaths, o.ShowPositions, o.ShowSummary = true, true, true },
+		func(o *Options) { o.TreeGuides = true },
+		func(o *Options) { o.TreeGuides, o.ShowPaths = true, true },
+		func(o *Options) { o.MaxOutputBytes = 200 },
+		func(o *Options) { o.InlineStringDiff, o.CollapseAddedRemoved = true, true },
+		func(o *Options) { o.ExpandChangedValues, o.MaxDisplayDepth = true, 2 },
+		func(o *Options) { o.Added.Begin, o.Removed.Begin = "<ins {path}>", "<del {path}>" },
+	} {
+		opts := DefaultConsoleOptions()
+		set(&opts)
+		variants = append(variants, opts)
+	}
+	for i := range variants {
+		variants[i].IgnoreFields = []string{"fuzz1"}
+		variants[i].StringAsMapFields = []string{"stringAsMap"}
+	}
+	return variants
+}
+
+// renderCase is a comparison recorded in testdata/render.json.gz.
+type renderCase struct {
+	Variant int
+	A, B    string
+	Diff    Difference
+	Text    string
+}
+
+// TestRenderCorpus compares the output of random documents to the one
+// recorded by an earlier version of the renderer.
+func TestRenderCorpus(t *testing.T) {
+	variants := renderVariants()
+	if *update {
+		docs := randomDocuments(rand.New(rand.NewSource(1)), 200)
+		for _, c := range cases {
+			docs = append(docs, [2][]byte{[]byte(c.a), []byte(c.b)})
+		}
+		var corpus []renderCase
+		for i := range variants {
+			for _, doc := range docs {
+				diff, text := Compare(doc[0], doc[1], &variants[i])
+				corpus = append(corpus, renderCase{i, string(doc[0]), string(doc[1]), diff, text})
+			}
+		}
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if err := json.NewEncoder(zw).Encode(corpus); err != nil {
+			t.Fatal(err)
+		}
+		zw.Close()
+		if err := ioutil.WriteFile("testdata/render.json.gz", buf.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := ioutil.ReadFile("testdata/render.json.gz")
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var corpus []renderCase
+	if err := json.NewDecoder(zr).Decode(&corpus); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range corpus {
+		diff, text := Compare([]byte(c.A), []byte(c.B), &variants[c.Variant])
+		if diff != c.Diff || text != c.Text {
+			t.Fatalf("variant %d: %s, %s: got %s\n%s\nexpected %s\n%s", c.Variant, c.A, c.B, diff, text, c.Diff, c.Text)
+		}
+	}
+}
+
+func TestNoOutput(t *testing.T) {
+	base := DefaultConsoleOptions()
+	base.IgnoreFields = []string{"fuzz1"}
+	base.FuzzyFields = []string{"fuzz2"}
+	base.StringAsMapFields = []string{"stringAsMap"}
+	base.NullAsEmpty = true
+	variants := []func(*Options){
+		func(*Options) {},
+		func(o *Options) { o.MaxDisplayDepth = 1 },
+		func(o *Options) { o.CollapseAddedRemoved = true },
+		func(o *Options) { o.ExpandChangedValues = true },
+		func(o *Options) { o.ShowPaths, o.ShowSummary, o.ShowLegend = true, true, true },
+		func(o *Options) { o.InlineStringDiff, o.MaxOutputBytes = true, 20 },
+	}
+	all := append([]struct {
+		a      string
+		b      string
+		result Difference
+	}{
+		// The key of a fuzzy member in a removed object does not apply to
+		// the elements following it.
+		{`[{"k": {"fuzz2": 1}}, 5]`, `[{}, 6]`, NoMatch},
+		{`[{"k": {"fuzz2": 1}}, 5]`, `[{}, 5]`, SupersetMatch},
+		{`[{"k": {"fuzz2": 1}}, 5]`, `[{"k": 1}, 6]`, NoMatch},
+	}, cases...)
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; i < 500; i++ {
+		v := randomValue(rnd, 4)
+		var a, b bytes.Buffer
+		shuffledJSON(rnd, &a, v)
+		shuffledJSON(rnd, &b, mutate(rnd, v))
+		all = append(all, struct {
+			a      string
+			b      string
+			result Difference
+		}{a.String(), b.String(), -1})
+	}
+	for i, variant := range variants {
+		opts := base
+		variant(&opts)
+		quiet := opts
+		quiet.NoOutput = true
+		for _, c := range all {
+			expected := CompareDetail([]byte(c.a), []byte(c.b), &opts)
+			got := CompareDetail([]byte(c.a), []byte(c.b), &quiet)
+			if i == 0 && c.result >= 0 && expected.Difference != c.result {
+				t.Errorf("variant %d: %s, %s: Compare returned %s, expected %s", i, c.a, c.b, expected.Difference, c.result)
+			}
+			if got.Text != "" && got.Difference != FirstArgIsInvalidJson &&
+				got.Difference != SecondArgIsInvalidJson && got.Difference != BothArgsAreInvalidJson {
+				t.Errorf("variant %d: %s, %s: rendered %q", i, c.a, c.b, got.Text)
+			}
+			expected.Text, got.Text = "", ""
+			expected.Stats.Duration, got.Stats.Duration = 0, 0
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("variant %d: %s, %s: got %+v, expected %+v", i, c.a, c.b, got, expected)
+			}
+		}
+	}
+}
+
+func BenchmarkCompareOneSided(b *testing.B) {
+	docA, _ := largeObjects(20000)
+	docA = []byte(`{"a": 1, "big": ` + string(docA) + `}`)
+	docB := []byte(`{"a": 2}`)
+	for _, noOutput := range []bool{false, true} {
+		b.Run(fmt.Sprintf("NoOutput=%v", noOutput), func(b *testing.B) {
+			opts := DefaultConsoleOptions()
+			opts.NoOutput = noOutput
+			benchmarkCompareDocuments(b, opts, docA, docB)
+		})
+	}
+}
+
+// largeObjects returns two objects of n members, every tenth of which
+// differs.
+func largeObjects(n int) ([]byte, []byte) {
+	var a, b bytes.Buffer
+	a.WriteString("{")
+	b.WriteString("{")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			a.WriteString(",")
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&a, `"key%d": {"id": %d, "name": "item"}`, i, i)
+		if i%10 == 0 {
+			fmt.Fprintf(&b, `"key%d": {"id": %d, "name": "changed"}`, i, i)
+		} else {
+			fmt.Fprintf(&b, `"key%d": {"id": %d, "name": "item"}`, i, i)
+		}
+	}
+	a.WriteString("}")
+	b.WriteString("}")
+	return a.Bytes(), b.Bytes()
+}
+
+// largeArrays returns two arrays of n numbers, every tenth of which differs.
+func largeArrays(n int) ([]byte, []byte) {
+	var a, b bytes.Buffer
+	a.WriteString("[")
+	b.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			a.WriteString(",")
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&a, "%d", i)
+		if i%10 == 0 {
+			fmt.Fprintf(&b, "%d", -i)
+		} else {
+			fmt.Fprintf(&b, "%d", i)
+		}
+	}
+	a.WriteString("]")
+	b.WriteString("]")
+	return a.Bytes(), b.Bytes()
+}
+
+// shiftedArrays returns two arrays of n numbers, counting from 0 and from
+// 1, which differ at every index.
+func shiftedArrays(n int) ([]byte, []byte) {
+	var a, b bytes.Buffer
+	a.WriteString("[")
+	b.WriteString("[")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			a.WriteString(",")
+			b.WriteString(",")
+		}
+		fmt.Fprintf(&a, "%d", i)
+		fmt.Fprintf(&b, "%d", i+1)
+	}
+	a.WriteString("]")
+	b.WriteString("]")
+	return a.Bytes(), b.Bytes()
+}
+
+// deepDocuments returns two documents nested depth levels deep, differing
+// at the innermost level.
+func deepDocuments(depth int) ([]byte, []byte) {
+	open := strings.Repeat(`{"a": [1, {"b": 2, "c": `, depth)
+	close := strings.Repeat(`}]}`, depth)
+	return []byte(open + "1" + close), []byte(open + "2" + close)
+}
+
+func benchmarkCompareDocuments(b *testing.B, opts Options, docA, docB []byte) {
+	d := newDiffer(opts)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(docA) + len(docB)))
+	for i := 0; i < b.N; i++ {
+		d.Compare(docA, docB)
+	}
+}
+
+// wideDocuments returns two objects of n scalar members nested a few levels
+// deep, every fifth of which differs, which render as many short, indented
+// lines.
+func wideDocuments(n int) ([]byte, []byte) {
+	var a, b bytes.Buffer
+	for i := 0; i < n; i++ {
+		sep := ","
+		if i == 0 {
+			sep = ""
+		}
+		fmt.Fprintf(&a, `%s"m%d": %d`, sep, i, i)
+		if i%5 == 0 {
+			fmt.Fprintf(&b, `%s"m%d": %d`, sep, i, -i)
+		} else {
+			fmt.Fprintf(&b, `%s"m%d": %d`, sep, i, i)
+		}
+	}
+	open, close := `{"x": {"y": [{"z": {`, `}}]}}`
+	return []byte(open + a.String() + close), []byte(open + b.String() + close)
+}
+
+func BenchmarkCompareSmall(b *testing.B) {
+	docA, err := ioutil.ReadFile("testdata/data1.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	docB, err := ioutil.ReadFile("testdata/data2.json")
+	if err != nil {
+		b.Fatal(err)
+	}
+	for _, preset := range []struct {
+		name string
+		opts Options
+	}{
+		{"Console", DefaultConsoleOptions()},
+		{"HTML", DefaultHTMLOptions()},
+		{"Symbol", DefaultSymbolOptions()},
+	} {
+		b.Run(preset.name, func(b *testing.B) {
+			benchmarkCompareDocuments(b, preset.opts, docA, docB)
+		})
+	}
+}
+
+func BenchmarkCompareLarge(b *testing.B) {
+	b.Run("Object", func(b *testing.B) {
+		docA, docB := largeObjects(50000)
+		benchmarkCompareDocuments(b, DefaultConsoleOptions(), docA, docB)
+	})
+	b.Run("Array", func(b *testing.B) {
+		docA, docB := largeArrays(50000)
+		benchmarkCompareDocuments(b, DefaultConsoleOptions(), docA, docB)
+	})
+}
+
+func BenchmarkCompareWide(b *testing.B) {
+	docA, docB := wideDocuments(50000)
+	benchmarkCompareDocuments(b, DefaultConsoleOptions(), docA, docB)
+}
+
+func BenchmarkCompareDeep(b *testing.B) {
+	docA, docB := deepDocuments(200)
+	benchmarkCompareDocuments(b, DefaultConsoleOptions(), docA, docB)
+}
+
+// BenchmarkCompareArrayInsertion compares arrays of 100000 numbers, one
+// inserted at the start of the second.
+func BenchmarkCompareArrayInsertion(b *testing.B) {
+	var a, c bytes.Buffer
+	a.WriteString("[")
+	c.WriteString("[-1")
+	for i := 0; i < 100000; i++ {
+		if i > 0 {
+			a.WriteString(",")
+		}
+		fmt.Fprintf(&a, "%d", i)
+		fmt.Fprintf(&c, ",%d", i)
+	}
+	a.WriteString("]")
+	c.WriteString("]")
+	opts := DefaultConsoleOptions()
+	opts.NoOutput = true
+	benchmarkCompareDocuments(b, opts, a.Bytes(), c.Bytes())
+}
+
+// BenchmarkCompareAddedSubtree compares documents differing in a large
+// object added a few levels deep.
+func BenchmarkCompareAddedSubtree(b *testing.B) {
+	added, _ := largeObjects(20000)
+	docA := []byte(`{"a": {"b": {"c": {}}}}`)
+	docB := []byte(`{"a": {"b": {"c": {"added": ` + string(added) + `}}}}`)
+	benchmarkCompareDocuments(b, DefaultConsoleOptions(), docA, docB)
+}
+
+// BenchmarkCompareValuesScalars compares decoded arrays of mostly equal
+// scalars, where the time goes to dispatching on the type of each value.
+func BenchmarkCompareValuesScalars(b *testing.B) {
+	a := make([]interface{}, 0, 80000)
+	for i := 0; i < 20000; i++ {
+		a = append(a, json.Number(strconv.Itoa(i)), "s"+strconv.Itoa(i), true, nil)
+	}
+	c := append([]interface{}(nil), a...)
+	for i := 2; i < len(c); i += 400 {
+		c[i] = false
+	}
+	d := newDiffer(DefaultConsoleOptions())
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		d.CompareValues(a, c)
+	}
 }
@@ -0,0 +1,173 @@
+// Package jsondiffhttp compares the JSON bodies of HTTP responses, e.g. the
+// responses of an old and a new backend to the same shadowed request.
+package jsondiffhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/nsf/jsondiff"
+)
+
+// DefaultMaxBodyBytes is the size limit of a response body used by a
+// Comparer without MaxBodyBytes.
+const DefaultMaxBodyBytes = 10 << 20
+
+// ContentTypeError is the error of a response whose Content-Type is not JSON.
+type ContentTypeError struct {
+	// Response is 1 for the first and 2 for the second response.
+	Response    int
+	ContentType string
+}
+
+func (e *ContentTypeError) Error() string {
+	return fmt.Sprintf("jsondiffhttp: %s response has Content-Type %q, not JSON", ordinal(e.Response), e.ContentType)
+}
+
+// BodyTooLargeError is the error of a response whose body, after decoding
+// its Content-Encoding, is larger than the limit.
+type BodyTooLargeError struct {
+	// Response is 1 for the first and 2 for the second response.
+	Response int
+	Limit    int64
+}
+
+func (e *BodyTooLargeError) Error() string {
+	return fmt.Sprintf("jsondiffhttp: %s response body is larger than %d bytes", ordinal(e.Response), e.Limit)
+}
+
+func ordinal(response int) string {
+	if response == 1 {
+		return "first"
+	}
+	return "second"
+}
+
+// Comparer compares the JSON bodies of HTTP responses. The zero value is
+// ready to use.
+type Comparer struct {
+	// Options are the options of the comparison. Nil uses
+	// jsondiff.DefaultASCIISymbolOptions.
+	Options *jsondiff.Options
+	// MaxBodyBytes limits the size of each body, after decoding its
+	// Content-Encoding. Zero uses DefaultMaxBodyBytes and a negative value
+	// disables the limit.
+	MaxBodyBytes int64
+}
+
+// CompareResponses compares the bodies of a and b with a Comparer using
+// opts, which may be nil.
+func CompareResponses(a, b *http.Response, opts *jsondiff.Options) (jsondiff.Difference, string, error) {
+	c := Comparer{Options: opts}
+	return c.CompareResponses(a, b)
+}
+
+// CompareResponses compares the bodies of a and b like jsondiff.Compare and
+// returns the Difference and the rendered difference. Status codes and
+// headers are not compared.
+//
+// A response must have a JSON Content-Type, application/json or any type with
+// the +json suffix, or none at all; otherwise a *ContentTypeError is returned
+// without reading the bodies. A body encoded with the gzip Content-Encoding is
+// decompressed. A body larger than the limit makes CompareResponses return a
+// *BodyTooLargeError. A body that is not valid JSON is not an error but
+// classified like by jsondiff.Compare. On every error the Difference is
+// NoMatch.
+//
+// The bodies are read, but replaced with readers returning the same bytes,
+// so that the responses can still be used. The caller remains responsible for
+// closing them.
+func (c *Comparer) CompareResponses(a, b *http.Response) (jsondiff.Difference, string, error) {
+	for i, r := range []*http.Response{a, b} {
+		if ct := r.Header.Get("Content-Type"); !isJSON(ct) {
+			return jsondiff.NoMatch, "", &ContentTypeError{Response: i + 1, ContentType: ct}
+		}
+	}
+	bodyA, err := c.readBody(a, 1)
+	if err != nil {
+		return jsondiff.NoMatch, "", err
+	}
+	bodyB, err := c.readBody(b, 2)
+	if err != nil {
+		return jsondiff.NoMatch, "", err
+	}
+	opts := c.Options
+	if opts == nil {
+		o := jsondiff.DefaultASCIISymbolOptions()
+		opts = &o
+	}
+	diff, text := jsondiff.Compare(bodyA, bodyB, opts)
+	return diff, text, nil
+}
+
+// isJSON reports whether contentType is empty or a JSON media type.
+func isJSON(contentType string) bool {
+	if contentType == "" {
+		return true
+	}
+	mt, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	return mt == "application/json" || strings.HasSuffix(mt, "+json")
+}
+
+// readBody reads the body of r, the response numbered n, and returns it with
+// its Content-Encoding decoded. The body of r is replaced with a reader
+// returning the bytes read followed by the rest of the original body.
+func (c *Comparer) readBody(r *http.Response, n int) ([]byte, error) {
+	limit := c.MaxBodyBytes
+	if limit == 0 {
+		limit = DefaultMaxBodyBytes
+	}
+	if r.Body == nil || r.Body == http.NoBody {
+		return nil, nil
+	}
+	var raw bytes.Buffer
+	body := r.Body
+	defer func() {
+		r.Body = readCloser{io.MultiReader(bytes.NewReader(raw.Bytes()), body), body}
+	}()
+	src := io.Reader(body)
+	if limit > 0 {
+		src = io.LimitReader(body, limit+1)
+	}
+	if _, err := raw.ReadFrom(src); err != nil {
+		return nil, fmt.Errorf("jsondiffhttp: reading %s response body: %w", ordinal(n), err)
+	}
+	data := raw.Bytes()
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		if limit > 0 && int64(len(data)) > limit {
+			// The compressed body alone is too large.
+			return nil, &BodyTooLargeError{Response: n, Limit: limit}
+		}
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("jsondiffhttp: decompressing %s response body: %w", ordinal(n), err)
+		}
+		var decoded bytes.Buffer
+		src = gr
+		if limit > 0 {
+			src = io.LimitReader(gr, limit+1)
+		}
+		if _, err := decoded.ReadFrom(src); err != nil {
+			return nil, fmt.Errorf("jsondiffhttp: decompressing %s response body: %w", ordinal(n), err)
+		}
+		data = decoded.Bytes()
+	}
+	if limit > 0 && int64(len(data)) > limit {
+		return nil, &BodyTooLargeError{Response: n, Limit: limit}
+	}
+	return data, nil
+}
+
+// readCloser reads from Reader and closes Closer.
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
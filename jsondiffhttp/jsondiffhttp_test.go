@@ -0,0 +1,127 @@
+package jsondiffhttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/nsf/jsondiff"
+)
+
+// server returns a test server responding with body and the given headers,
+// as name and value pairs.
+func server(t *testing.T, body string, headers ...string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < len(headers); i += 2 {
+			w.Header().Set(headers[i], headers[i+1])
+		}
+		io.WriteString(w, body)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func get(t *testing.T, srv *httptest.Server) *http.Response {
+	// Transparent decompression would hide the Content-Encoding.
+	client := &http.Client{Transport: &http.Transport{DisableCompression: true}}
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func gzipped(s string) string {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	io.WriteString(zw, s)
+	zw.Close()
+	return buf.String()
+}
+
+func TestCompareResponses(t *testing.T) {
+	cases := []struct {
+		name string
+		a, b *httptest.Server
+		diff jsondiff.Difference
+		text string
+	}{
+		{"equal",
+			server(t, `{"a": 1, "b": [1, 2]}`, "Content-Type", "application/json"),
+			server(t, `{"b": [1, 2], "a": 1}`, "Content-Type", "application/json; charset=utf-8"),
+			jsondiff.FullMatch, ""},
+		{"differing",
+			server(t, `{"a": 1, "b": 2}`, "Content-Type", "application/problem+json"),
+			server(t, `{"a": 2, "b": 2}`, "Content-Type", "application/json"),
+			jsondiff.NoMatch, "{\n    ~ \"a\": 1 => 2\n}"},
+		{"gzip",
+			server(t, gzipped(`{"a": 1}`), "Content-Type", "application/json", "Content-Encoding", "gzip"),
+			server(t, `{"a": 1, "b": true}`, "Content-Type", "application/json"),
+			jsondiff.SubsetMatch, "{\n    + \"b\": true\n}"},
+		{"invalid",
+			server(t, `{"a": 1}`, "Content-Type", "application/json"),
+			server(t, `{"a": `, "Content-Type", "application/json"),
+			jsondiff.SecondArgIsInvalidJson, "second argument is invalid json"},
+	}
+	for _, c := range cases {
+		ra, rb := get(t, c.a), get(t, c.b)
+		diff, text, err := CompareResponses(ra, rb, nil)
+		if err != nil || diff != c.diff || text != c.text {
+			t.Errorf("%s: got %s %q %v, expected %s %q", c.name, diff, text, err, c.diff, c.text)
+		}
+		// The bodies can still be read.
+		for _, r := range []*http.Response{ra, rb} {
+			if body, err := io.ReadAll(r.Body); err != nil || len(body) == 0 {
+				t.Errorf("%s: body was not restored: %q %v", c.name, body, err)
+			}
+		}
+	}
+}
+
+func TestCompareResponsesNotJSON(t *testing.T) {
+	ra := get(t, server(t, `{}`, "Content-Type", "application/json"))
+	rb := get(t, server(t, `<html></html>`, "Content-Type", "text/html; charset=utf-8"))
+	diff, _, err := CompareResponses(ra, rb, nil)
+	var cte *ContentTypeError
+	if diff != jsondiff.NoMatch || !errors.As(err, &cte) || cte.Response != 2 || cte.ContentType != "text/html; charset=utf-8" {
+		t.Errorf("got %s %v", diff, err)
+	}
+	if body, _ := io.ReadAll(rb.Body); string(body) != `<html></html>` {
+		t.Errorf("got body %q", body)
+	}
+}
+
+func TestCompareResponsesTooLarge(t *testing.T) {
+	large := `{"a": "` + strings.Repeat("x", 100) + `"}`
+	cases := []struct {
+		name string
+		b    *httptest.Server
+	}{
+		{"plain", server(t, large, "Content-Type", "application/json")},
+		{"gzip", server(t, gzipped(large), "Content-Type", "application/json", "Content-Encoding", "gzip")},
+	}
+	for _, c := range cases {
+		ra, rb := get(t, server(t, `{}`, "Content-Type", "application/json")), get(t, c.b)
+		cmp := Comparer{MaxBodyBytes: 64}
+		diff, _, err := cmp.CompareResponses(ra, rb)
+		var tle *BodyTooLargeError
+		if diff != jsondiff.NoMatch || !errors.As(err, &tle) || tle.Response != 2 || tle.Limit != 64 {
+			t.Errorf("%s: got %s %v", c.name, diff, err)
+		}
+		body, _ := io.ReadAll(rb.Body)
+		if s := string(body); s != large && s != gzipped(large) {
+			t.Errorf("%s: body was not restored: %q", c.name, body)
+		}
+		cmp.MaxBodyBytes = -1
+		ra, rb = get(t, server(t, `{}`, "Content-Type", "application/json")), get(t, c.b)
+		if diff, _, err := cmp.CompareResponses(ra, rb); err != nil || diff != jsondiff.SubsetMatch {
+			t.Errorf("%s: without a limit got %s %v", c.name, diff, err)
+		}
+	}
+}
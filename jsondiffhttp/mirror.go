@@ -0,0 +1,114 @@
+package jsondiffhttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/nsf/jsondiff"
+)
+
+// Result is the outcome of comparing the responses to a mirrored request.
+type Result struct {
+	// Request is the request sent to the primary host.
+	Request *http.Request
+	// Difference and Text are the results of Comparer.CompareResponses,
+	// from the primary to the secondary response.
+	Difference jsondiff.Difference
+	Text       string
+	// Err is the error sending the request to the secondary host or
+	// comparing the responses. The Difference is then NoMatch.
+	Err error
+}
+
+// Mirror is an http.RoundTripper sending every request to its host and a
+// copy of it to a secondary host, for comparing the JSON responses of both.
+// Only the primary response is returned; the secondary one is read for the
+// comparison and closed.
+//
+// Both requests are sent concurrently and RoundTrip returns once both
+// responses arrived and OnResult returned, so the secondary host delays the
+// primary response. A request body is read into memory to be sent twice.
+type Mirror struct {
+	// Transport sends the requests. Nil uses http.DefaultTransport.
+	Transport http.RoundTripper
+	// Secondary is the URL whose scheme and host replace those of the
+	// requests sent to the secondary host.
+	Secondary *url.URL
+	// Comparer compares the responses.
+	Comparer Comparer
+	// OnResult is called with the result of every mirrored request whose
+	// primary request succeeded.
+	OnResult func(Result)
+}
+
+type roundTrip struct {
+	resp *http.Response
+	err  error
+}
+
+// RoundTrip implements http.RoundTripper.
+func (m *Mirror) RoundTrip(req *http.Request) (*http.Response, error) {
+	transport := m.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
+	}
+	primary, mirrored, err := m.requests(req)
+	if err != nil {
+		return nil, err
+	}
+	secondary := make(chan roundTrip, 1)
+	go func() {
+		resp, err := transport.RoundTrip(mirrored)
+		secondary <- roundTrip{resp, err}
+	}()
+	resp, err := transport.RoundTrip(primary)
+	second := <-secondary
+	if second.resp != nil {
+		defer second.resp.Body.Close()
+	}
+	if err != nil {
+		return nil, err
+	}
+	result := Result{Request: req, Difference: jsondiff.NoMatch, Err: second.err}
+	if second.err == nil {
+		result.Difference, result.Text, result.Err = m.Comparer.CompareResponses(resp, second.resp)
+	}
+	if m.OnResult != nil {
+		m.OnResult(result)
+	}
+	return resp, nil
+}
+
+// requests returns the requests for the primary and the secondary host. The
+// body of req is read into memory, unless it can be obtained again with
+// GetBody, in which case req itself is sent to the primary host.
+func (m *Mirror) requests(req *http.Request) (primary, mirrored *http.Request, err error) {
+	primary = req
+	mirrored = req.Clone(req.Context())
+	mirrored.URL.Scheme, mirrored.URL.Host = m.Secondary.Scheme, m.Secondary.Host
+	mirrored.Host = ""
+	if req.Body == nil || req.Body == http.NoBody {
+		return primary, mirrored, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			req.Body.Close()
+			return nil, nil, fmt.Errorf("jsondiffhttp: copying request body: %w", err)
+		}
+		mirrored.Body = body
+		return primary, mirrored, nil
+	}
+	data, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, nil, fmt.Errorf("jsondiffhttp: reading request body: %w", err)
+	}
+	primary = req.Clone(req.Context())
+	primary.Body = io.NopCloser(bytes.NewReader(data))
+	mirrored.Body = io.NopCloser(bytes.NewReader(data))
+	return primary, mirrored, nil
+}
@@ -0,0 +1,64 @@
+package jsondiffhttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/nsf/jsondiff"
+)
+
+// echo returns a test server responding with a JSON object holding version,
+// the request path and the request body.
+func echo(t *testing.T, version string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"version": "`+version+`", "path": "`+r.URL.Path+`", "body": "`+string(body)+`"}`)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestMirror(t *testing.T) {
+	primary, secondary := echo(t, "1"), echo(t, "2")
+	u, _ := url.Parse(secondary.URL)
+	var results []Result
+	client := &http.Client{Transport: &Mirror{Secondary: u, OnResult: func(r Result) { results = append(results, r) }}}
+
+	resp, err := client.Post(primary.URL+"/items", "text/plain", io.NopCloser(strings.NewReader("x")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if expected := `{"version": "1", "path": "/items", "body": "x"}`; string(body) != expected {
+		t.Errorf("got primary response %s, expected %s", body, expected)
+	}
+	if len(results) != 1 {
+		t.Fatalf("got %d results", len(results))
+	}
+	r := results[0]
+	if r.Err != nil || r.Difference != jsondiff.NoMatch || r.Text != "{\n    ~ \"version\": \"1\" => \"2\"\n}" || r.Request.URL.Path != "/items" {
+		t.Errorf("got %+v", r)
+	}
+}
+
+func TestMirrorSecondaryDown(t *testing.T) {
+	primary, secondary := echo(t, "1"), echo(t, "2")
+	u, _ := url.Parse(secondary.URL)
+	secondary.Close()
+	var results []Result
+	client := &http.Client{Transport: &Mirror{Secondary: u, OnResult: func(r Result) { results = append(results, r) }}}
+	resp, err := client.Get(primary.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if len(results) != 1 || results[0].Err == nil || results[0].Difference != jsondiff.NoMatch {
+		t.Errorf("got %+v", results)
+	}
+}
@@ -0,0 +1,72 @@
+package jsondifftest
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nsf/jsondiff"
+)
+
+// Update makes CompareGolden write the actual document to golden files that
+// are missing or differ, instead of failing. It defaults to whether the
+// JSONDIFF_UPDATE environment variable is set, and tests can set it from a
+// flag of their own:
+//
+//	var update = flag.Bool("update", false, "update golden files")
+//
+//	func TestMain(m *testing.M) {
+//		flag.Parse()
+//		jsondifftest.Update = *update
+//		os.Exit(m.Run())
+//	}
+var Update = os.Getenv("JSONDIFF_UPDATE") != ""
+
+// goldenIndent is the indentation of golden files written by CompareGolden.
+const goldenIndent = "  "
+
+// CompareGolden compares actual with the JSON document in the file
+// goldenPath like AssertMatches, and returns whether they match. A missing
+// golden file is an error. With Update set, a missing or different golden
+// file is instead written with actual, pretty-printed by jsondiff.Canonical,
+// and logged. actual must be valid JSON to be written.
+func CompareGolden(t testing.TB, goldenPath string, actual []byte, opts *jsondiff.Options) bool {
+	t.Helper()
+	golden, err := os.ReadFile(goldenPath)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		if Update {
+			return writeGolden(t, goldenPath, actual, "created")
+		}
+		t.Errorf("golden file %s does not exist, set jsondifftest.Update to create it", goldenPath)
+		return false
+	case err != nil:
+		t.Errorf("%v", err)
+		return false
+	}
+	diff, text, err := jsondiff.CompareErr(golden, actual, options(opts))
+	if Update && diff != jsondiff.FullMatch && diff != jsondiff.SecondArgIsInvalidJson && diff != jsondiff.BothArgsAreInvalidJson {
+		return writeGolden(t, goldenPath, actual, "updated")
+	}
+	return check(t, diff, text, err, false)
+}
+
+// writeGolden writes actual to the golden file path.
+func writeGolden(t testing.TB, path string, actual []byte, verb string) bool {
+	t.Helper()
+	data, err := jsondiff.Canonical(actual, goldenIndent)
+	if err == nil {
+		err = os.MkdirAll(filepath.Dir(path), 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(path, data, 0644)
+	}
+	if err != nil {
+		t.Errorf("writing golden file %s: %v", path, err)
+		return false
+	}
+	t.Logf("%s golden file %s", verb, path)
+	return true
+}
@@ -0,0 +1,96 @@
+package jsondifftest
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func setUpdate(t *testing.T, update bool) {
+	old := Update
+	Update = update
+	t.Cleanup(func() { Update = old })
+}
+
+func TestCompareGolden(t *testing.T) {
+	setUpdate(t, false)
+	path := filepath.Join(t.TempDir(), "out.json")
+	if err := os.WriteFile(path, []byte(`{"a": 1, "b": [true]}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	f := &fakeTB{}
+	if !CompareGolden(f, path, []byte(`{"b": [true], "a": 1}`), nil) || len(f.errors) != 0 {
+		t.Errorf("matching document failed: %q", f.errors)
+	}
+	f = &fakeTB{}
+	if CompareGolden(f, path, []byte(`{"a": 2, "b": [true]}`), nil) {
+		t.Error("different document matched")
+	}
+	expected := "JSON documents differ (NoMatch):\n{\n    ~ \"a\": 1 => 2\n}"
+	if len(f.errors) != 1 || f.errors[0] != expected || f.helpers == 0 {
+		t.Errorf("got errors %q and %d Helper calls, expected %q", f.errors, f.helpers, expected)
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"a": 1, "b": [true]}` {
+		t.Errorf("golden file was modified: %q", data)
+	}
+}
+
+func TestCompareGoldenMissing(t *testing.T) {
+	setUpdate(t, false)
+	path := filepath.Join(t.TempDir(), "missing.json")
+	f := &fakeTB{}
+	if CompareGolden(f, path, []byte(`{}`), nil) || len(f.errors) != 1 || !strings.Contains(f.errors[0], "does not exist") {
+		t.Errorf("got errors %q, expected a missing golden file", f.errors)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("golden file was created without Update: %v", err)
+	}
+}
+
+func TestCompareGoldenUpdate(t *testing.T) {
+	setUpdate(t, true)
+	dir := t.TempDir()
+	path := filepath.Join(dir, "nested", "out.json")
+	f := &fakeTB{}
+	if !CompareGolden(f, path, []byte(`{"b": [1, 2], "a": {}}`), nil) || len(f.errors) != 0 {
+		t.Fatalf("creating the golden file failed: %q", f.errors)
+	}
+	expected := "{\n  \"a\": {},\n  \"b\": [\n    1,\n    2\n  ]\n}\n"
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("got golden file %q, expected %q", data, expected)
+	}
+	if len(f.logs) != 1 || !strings.Contains(f.logs[0], "created") {
+		t.Errorf("got logs %q", f.logs)
+	}
+
+	f = &fakeTB{}
+	if !CompareGolden(f, path, []byte(`{"a": {}, "b": [1, 3]}`), nil) || len(f.errors) != 0 {
+		t.Fatalf("updating the golden file failed: %q", f.errors)
+	}
+	expected = "{\n  \"a\": {},\n  \"b\": [\n    1,\n    3\n  ]\n}\n"
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("got golden file %q, expected %q", data, expected)
+	}
+	if len(f.logs) != 1 || !strings.Contains(f.logs[0], "updated") {
+		t.Errorf("got logs %q", f.logs)
+	}
+
+	// A matching document leaves the file alone, an invalid one fails.
+	f = &fakeTB{}
+	if !CompareGolden(f, path, []byte(`{"b": [1, 3], "a": {}}`), nil) || len(f.logs) != 0 {
+		t.Errorf("got logs %q, expected none", f.logs)
+	}
+	f = &fakeTB{}
+	if CompareGolden(f, path, []byte(`{"a": `), nil) || len(f.errors) != 1 {
+		t.Errorf("got errors %q, expected invalid JSON", f.errors)
+	}
+	if data, _ := os.ReadFile(path); string(data) != expected {
+		t.Errorf("golden file was overwritten with invalid JSON: %q", data)
+	}
+	missing := filepath.Join(dir, "invalid.json")
+	f = &fakeTB{}
+	if CompareGolden(f, missing, []byte(`nope`), nil) || len(f.errors) != 1 {
+		t.Errorf("got errors %q, expected invalid JSON", f.errors)
+	}
+}
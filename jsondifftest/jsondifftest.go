@@ -0,0 +1,63 @@
+// Package jsondifftest provides assertions comparing JSON documents in tests,
+// which fail with the rendered difference.
+package jsondifftest
+
+import (
+	"testing"
+
+	"github.com/nsf/jsondiff"
+)
+
+// options returns opts, or plain text options marking differences with ASCII
+// symbols if opts is nil, so that failures read well in CI logs.
+func options(opts *jsondiff.Options) *jsondiff.Options {
+	if opts == nil {
+		o := jsondiff.DefaultASCIISymbolOptions()
+		return &o
+	}
+	return opts
+}
+
+// AssertMatches reports an error on t unless actual is the same JSON
+// document as expected, and returns whether it is. The error holds the
+// rendered difference, or why a document is not valid JSON. A nil opts uses
+// DefaultASCIISymbolOptions.
+func AssertMatches(t testing.TB, expected, actual []byte, opts *jsondiff.Options) bool {
+	t.Helper()
+	diff, text, err := jsondiff.CompareErr(expected, actual, options(opts))
+	return check(t, diff, text, err, false)
+}
+
+// AssertSuperset is like AssertMatches, but also accepts an actual document
+// holding more than expected, e.g. additional object members. The difference
+// is rendered from actual to expected.
+func AssertSuperset(t testing.TB, expected, actual []byte, opts *jsondiff.Options) bool {
+	t.Helper()
+	diff, text, err := jsondiff.CompareErr(actual, expected, options(opts))
+	return check(t, diff, text, err, true)
+}
+
+// AssertMatchesGo is like AssertMatches for the JSON encodings of two Go
+// values, as compared by jsondiff.CompareGo.
+func AssertMatchesGo(t testing.TB, expected, actual interface{}, opts *jsondiff.Options) bool {
+	t.Helper()
+	diff, text, err := jsondiff.CompareGo(expected, actual, options(opts))
+	return check(t, diff, text, err, false)
+}
+
+// check reports an error on t unless diff is FullMatch, or SupersetMatch if
+// superset is set.
+func check(t testing.TB, diff jsondiff.Difference, text string, err error, superset bool) bool {
+	t.Helper()
+	switch {
+	case err != nil:
+		t.Errorf("%v", err)
+	case diff == jsondiff.FullMatch, diff == jsondiff.SupersetMatch && superset:
+		return true
+	case diff.IsInvalidJson():
+		t.Errorf("%s", diff)
+	default:
+		t.Errorf("JSON documents differ (%s):\n%s", diff, text)
+	}
+	return false
+}
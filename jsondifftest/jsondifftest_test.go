@@ -0,0 +1,114 @@
+package jsondifftest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/nsf/jsondiff"
+)
+
+// fakeTB records the calls of the assertions.
+type fakeTB struct {
+	testing.TB
+	helpers int
+	errors  []string
+	logs    []string
+}
+
+func (f *fakeTB) Helper() {
+	f.helpers++
+}
+
+func (f *fakeTB) Errorf(format string, args ...interface{}) {
+	f.errors = append(f.errors, fmt.Sprintf(format, args...))
+}
+
+func (f *fakeTB) Logf(format string, args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprintf(format, args...))
+}
+
+func TestAssertMatches(t *testing.T) {
+	f := &fakeTB{}
+	if !AssertMatches(f, []byte(`{"a": 1, "b": [1]}`), []byte(`{"b": [1], "a": 1}`), nil) {
+		t.Error("equal documents did not match")
+	}
+	if len(f.errors) != 0 || f.helpers == 0 {
+		t.Errorf("got errors %q and %d Helper calls", f.errors, f.helpers)
+	}
+	f = &fakeTB{}
+	if AssertMatches(f, []byte(`{"a": 1, "b": 2}`), []byte(`{"a": 2}`), nil) {
+		t.Error("different documents matched")
+	}
+	expected := "JSON documents differ (NoMatch):\n{\n    ~ \"a\": 1 => 2,\n    - \"b\": 2\n}"
+	if len(f.errors) != 1 || f.errors[0] != expected {
+		t.Errorf("got errors %q, expected %q", f.errors, expected)
+	}
+	if f.helpers == 0 {
+		t.Error("Helper was not called")
+	}
+}
+
+func TestAssertMatchesOptions(t *testing.T) {
+	f := &fakeTB{}
+	opts := jsondiff.Options{IgnoreFields: []string{"id"}}
+	if !AssertMatches(f, []byte(`{"id": 1, "a": 1}`), []byte(`{"id": 2, "a": 1}`), &opts) {
+		t.Errorf("ignored field made the assertion fail: %q", f.errors)
+	}
+}
+
+func TestAssertMatchesInvalid(t *testing.T) {
+	f := &fakeTB{}
+	if AssertMatches(f, []byte(`{}`), []byte(`{"a": `), nil) {
+		t.Error("invalid document matched")
+	}
+	if len(f.errors) != 1 || !strings.Contains(f.errors[0], "second document is invalid JSON") {
+		t.Errorf("got errors %q", f.errors)
+	}
+}
+
+func TestAssertSuperset(t *testing.T) {
+	cases := []struct {
+		expected, actual string
+		ok               bool
+	}{
+		{`{"a": 1}`, `{"a": 1}`, true},
+		{`{"a": 1}`, `{"a": 1, "extra": true}`, true},
+		{`{"a": 1, "b": 2}`, `{"a": 1}`, false},
+		{`{"a": 1}`, `{"a": 2, "extra": true}`, false},
+	}
+	for _, c := range cases {
+		f := &fakeTB{}
+		if ok := AssertSuperset(f, []byte(c.expected), []byte(c.actual), nil); ok != c.ok || (len(f.errors) == 0) != ok {
+			t.Errorf("%s, %s: got %v with errors %q, expected %v", c.expected, c.actual, ok, f.errors, c.ok)
+		}
+		if f.helpers == 0 {
+			t.Error("Helper was not called")
+		}
+	}
+}
+
+type user struct {
+	Name  string   `json:"name"`
+	Email string   `json:"email,omitempty"`
+	Roles []string `json:"roles"`
+}
+
+func TestAssertMatchesGo(t *testing.T) {
+	f := &fakeTB{}
+	if !AssertMatchesGo(f, map[string]interface{}{"name": "x", "roles": []string{"admin"}}, user{Name: "x", Roles: []string{"admin"}}, nil) {
+		t.Errorf("equal values did not match: %q", f.errors)
+	}
+	f = &fakeTB{}
+	if AssertMatchesGo(f, user{Name: "x"}, user{Name: "y"}, nil) {
+		t.Error("different values matched")
+	}
+	expected := "JSON documents differ (NoMatch):\n{\n    ~ \"name\": \"x\" => \"y\"\n}"
+	if len(f.errors) != 1 || f.errors[0] != expected {
+		t.Errorf("got errors %q, expected %q", f.errors, expected)
+	}
+	f = &fakeTB{}
+	if AssertMatchesGo(f, make(chan int), 1, nil) || len(f.errors) != 1 || !strings.Contains(f.errors[0], "marshaling") {
+		t.Errorf("got errors %q, expected a marshaling error", f.errors)
+	}
+}
@@ -0,0 +1,177 @@
+package jsondiff
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// LineResult is the result of comparing one record of two JSON Lines
+// inputs.
+type LineResult struct {
+	// LineA and LineB are the line numbers of the record in the first and
+	// second input, counting from 1, or 0 if the record is only in the
+	// other input.
+	LineA, LineB int
+	Difference   Difference
+	Text         string
+}
+
+// CompareJSONLines compares two inputs in the JSON Lines format, holding one
+// JSON document per line, record by record. Empty lines are skipped. A
+// record only in a, because a has more records than b, is reported as
+// removed with SupersetMatch; a record only in b as added with SubsetMatch.
+// Records that are not valid JSON are reported with the matching invalid
+// JSON Difference. The overall Difference combines those of the records like
+// the differences within a document: NoMatch if any record is NoMatch or
+// invalid, or if records differ in both directions, else SupersetMatch or
+// SubsetMatch, else FullMatch. The returned error is the first
+// error reading a or b. Positions are not tracked for JSON Lines.
+func CompareJSONLines(a, b io.Reader, opts *Options) (Difference, []LineResult, error) {
+	return differOf(opts).CompareJSONLines(a, b)
+}
+
+// CompareJSONLinesByKey is like CompareJSONLines, but pairs the records by
+// the key returned for them by key instead of by position. Records with the
+// same key are paired in the order they appear. The results are in the
+// order of a, followed by the records only in b in the order of b. Invalid
+// records are never paired.
+func CompareJSONLinesByKey(a, b io.Reader, key func(record interface{}) string, opts *Options) (Difference, []LineResult, error) {
+	return differOf(opts).CompareJSONLinesByKey(a, b, key)
+}
+
+// CompareJSONLines is like the package-level CompareJSONLines, using the
+// options of d.
+func (d *Differ) CompareJSONLines(a, b io.Reader) (Difference, []LineResult, error) {
+	return d.compareJSONLines(a, b, nil)
+}
+
+// CompareJSONLinesByKey is like the package-level CompareJSONLinesByKey,
+// using the options of d.
+func (d *Differ) CompareJSONLinesByKey(a, b io.Reader, key func(record interface{}) string) (Difference, []LineResult, error) {
+	return d.compareJSONLines(a, b, key)
+}
+
+// jsonLine is a record of a JSON Lines input.
+type jsonLine struct {
+	line int
+	v    interface{}
+	err  error
+}
+
+func (d *Differ) compareJSONLines(a, b io.Reader, key func(interface{}) string) (Difference, []LineResult, error) {
+	var order keyOrder
+	if d.opts.PreserveKeyOrder {
+		order = make(keyOrder)
+	}
+	la, err := readJSONLines(a, order, &d.opts, "first input")
+	if err != nil {
+		return NoMatch, nil, err
+	}
+	lb, err := readJSONLines(b, order, &d.opts, "second input")
+	if err != nil {
+		return NoMatch, nil, err
+	}
+
+	var results []LineResult
+	overall := FullMatch
+	compare := func(ra, rb *jsonLine) {
+		ctx := d.newContext(nil)
+		ctx.order = order
+		r := LineResult{}
+		var buf bytes.Buffer
+		switch {
+		case rb == nil:
+			r.LineA = ra.line
+			if ra.err != nil {
+				r.Difference, r.Text, _ = invalidJSON(ra.err, nil)
+			} else {
+				ctx.printRemoved(&buf, nil, ra.v)
+				r.Difference, r.Text = SupersetMatch, ctx.finish(&buf)
+			}
+		case ra == nil:
+			r.LineB = rb.line
+			if rb.err != nil {
+				r.Difference, r.Text, _ = invalidJSON(nil, rb.err)
+			} else {
+				ctx.printAdded(&buf, nil, rb.v)
+				r.Difference, r.Text = SubsetMatch, ctx.finish(&buf)
+			}
+		default:
+			r.LineA, r.LineB = ra.line, rb.line
+			if diff, msg, invalid := invalidJSON(ra.err, rb.err); invalid {
+				r.Difference, r.Text = diff, msg
+			} else {
+				r.Difference, r.Text = ctx.compareValues(ra.v, rb.v)
+			}
+		}
+		if r.Difference.IsInvalidJson() {
+			overall = NoMatch
+		} else {
+			overall = combine(overall, r.Difference)
+		}
+		results = append(results, r)
+	}
+
+	if key == nil {
+		for i := 0; i < len(la) || i < len(lb); i++ {
+			var ra, rb *jsonLine
+			if i < len(la) {
+				ra = &la[i]
+			}
+			if i < len(lb) {
+				rb = &lb[i]
+			}
+			compare(ra, rb)
+		}
+		return overall, results, nil
+	}
+
+	byKey := make(map[string][]*jsonLine)
+	for i := range lb {
+		if lb[i].err == nil {
+			k := key(lb[i].v)
+			byKey[k] = append(byKey[k], &lb[i])
+		}
+	}
+	paired := make(map[*jsonLine]bool)
+	for i := range la {
+		var rb *jsonLine
+		if la[i].err == nil {
+			k := key(la[i].v)
+			if queue := byKey[k]; len(queue) > 0 {
+				rb, byKey[k] = queue[0], queue[1:]
+				paired[rb] = true
+			}
+		}
+		compare(&la[i], rb)
+	}
+	for i := range lb {
+		if !paired[&lb[i]] {
+			compare(nil, &lb[i])
+		}
+	}
+	return overall, results, nil
+}
+
+// readJSONLines decodes the records of the JSON Lines input r, which is
+// called name in errors.
+func readJSONLines(r io.Reader, order keyOrder, opts *Options, name string) ([]jsonLine, error) {
+	var lines []jsonLine
+	br := bufio.NewReader(r)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("jsondiff: reading %s at line %d: %w", name, n, err)
+		}
+		if len(bytes.TrimSpace(line)) > 0 {
+			dec := decoder{order: order, noTrailing: opts.DisallowTrailingData, invalidUTF8: opts.InvalidUTF8}
+			v, decErr := dec.decode(line)
+			lines = append(lines, jsonLine{line: n, v: v, err: decErr})
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCompareJSONLines(t *testing.T) {
+	opts := Options{Added: Tag{Begin: "+"}, Removed: Tag{Begin: "-"}}
+	a := "{\"id\": 1, \"v\": 1}\n{\"id\": 2, \"v\": 2}\n\n{\"id\": 3, \"v\": 3}\n"
+	cases := []struct {
+		b        string
+		diff     Difference
+		expected []LineResult
+	}{
+		{a, FullMatch, []LineResult{
+			{LineA: 1, LineB: 1}, {LineA: 2, LineB: 2}, {LineA: 4, LineB: 4},
+		}},
+		{"{\"id\": 1, \"v\": 1}\n{\"id\": 2, \"v\": 5}\n{\"id\": 3, \"v\": 3}", NoMatch, []LineResult{
+			{LineA: 1, LineB: 1}, {LineA: 2, LineB: 2, Difference: NoMatch, Text: "{\n\"v\": 2 => 5\n}"}, {LineA: 4, LineB: 3},
+		}},
+		{"{\"id\": 1, \"v\": 1}\n", SupersetMatch, []LineResult{
+			{LineA: 1, LineB: 1},
+			{LineA: 2, Difference: SupersetMatch, Text: "-{\n-\"id\": 2,\n-\"v\": 2\n-}"},
+			{LineA: 4, Difference: SupersetMatch, Text: "-{\n-\"id\": 3,\n-\"v\": 3\n-}"},
+		}},
+		{a + "[5]\n", SubsetMatch, []LineResult{
+			{LineA: 1, LineB: 1}, {LineA: 2, LineB: 2}, {LineA: 4, LineB: 4},
+			{LineB: 5, Difference: SubsetMatch, Text: "+[\n+5\n+]"},
+		}},
+		{a + "[5]\nnope\n", NoMatch, []LineResult{
+			{LineA: 1, LineB: 1}, {LineA: 2, LineB: 2}, {LineA: 4, LineB: 4},
+			{LineB: 5, Difference: SubsetMatch, Text: "+[\n+5\n+]"},
+			{LineB: 6, Difference: SecondArgIsInvalidJson, Text: "second argument is invalid json"},
+		}},
+	}
+	for _, c := range cases {
+		diff, results, err := CompareJSONLines(strings.NewReader(a), strings.NewReader(c.b), &opts)
+		if err != nil || diff != c.diff || !reflect.DeepEqual(results, c.expected) {
+			t.Errorf("%q: got %s %+v %v, expected %s %+v", c.b, diff, results, err, c.diff, c.expected)
+		}
+	}
+
+	broken := errors.New("broken")
+	_, _, err := CompareJSONLines(strings.NewReader(a), iotest.ErrReader(broken), &opts)
+	if !errors.Is(err, broken) || !strings.Contains(err.Error(), "second input at line 1") {
+		t.Errorf("got %v", err)
+	}
+}
+
+func TestCompareJSONLinesByKey(t *testing.T) {
+	opts := Options{Added: Tag{Begin: "+"}, Removed: Tag{Begin: "-"}}
+	a := "{\"id\": 1, \"v\": 1}\n{\"id\": 2, \"v\": 2}\n{\"id\": 3, \"v\": 3}\n"
+	b := "{\"id\": 3, \"v\": 3}\n{\"id\": 4, \"v\": 4}\n{\"id\": 1, \"v\": 0}\n"
+	id := func(record interface{}) string {
+		if m, ok := record.(map[string]interface{}); ok {
+			return string(m["id"].(json.Number))
+		}
+		return ""
+	}
+	diff, results, err := CompareJSONLinesByKey(strings.NewReader(a), strings.NewReader(b), id, &opts)
+	expected := []LineResult{
+		{LineA: 1, LineB: 3, Difference: NoMatch, Text: "{\n\"v\": 1 => 0\n}"},
+		{LineA: 2, Difference: SupersetMatch, Text: "-{\n-\"id\": 2,\n-\"v\": 2\n-}"},
+		{LineA: 3, LineB: 1},
+		{LineB: 2, Difference: SubsetMatch, Text: "+{\n+\"id\": 4,\n+\"v\": 4\n+}"},
+	}
+	if err != nil || diff != NoMatch || !reflect.DeepEqual(results, expected) {
+		t.Errorf("got %s %+v %v, expected %+v", diff, results, err, expected)
+	}
+}
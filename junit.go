@@ -0,0 +1,112 @@
+package jsondiff
+
+import (
+	"encoding/xml"
+	"io"
+)
+
+// JUnitCase is the result of a single comparison reported by WriteJUnit.
+type JUnitCase struct {
+	Name       string
+	Difference Difference
+	// Text is the rendered difference, as returned by Compare.
+	Text string
+}
+
+type junitTestSuite struct {
+	XMLName  xml.Name        `xml:"testsuite"`
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Errors   int             `xml:"errors,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+	Error     *junitFailure `xml:"error,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the results of one or many comparisons to w as a JUnit
+// XML test suite with the given name. Every case becomes a testcase. NoMatch
+// and SubsetMatch results are reported as failures carrying the rendered
+// difference, invalid JSON as errors, and FullMatch and SupersetMatch results
+// pass. The rendered text should be produced with options without ANSI
+// escape sequences, which are not allowed in XML and are replaced.
+func WriteJUnit(w io.Writer, suite string, cases ...JUnitCase) error {
+	ts := junitTestSuite{Name: suite, Tests: len(cases)}
+	for _, c := range cases {
+		tc := junitTestCase{Name: c.Name, Classname: suite}
+		f := &junitFailure{Message: c.Difference.String(), Type: c.Difference.String(), Text: c.Text}
+		switch c.Difference {
+		case NoMatch, SubsetMatch:
+			tc.Failure = f
+			ts.Failures++
+		case FirstArgIsInvalidJson, SecondArgIsInvalidJson, BothArgsAreInvalidJson:
+			tc.Error = f
+			ts.Errors++
+		}
+		ts.Cases = append(ts.Cases, tc)
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(ts); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// JUnitCasesByKey compares two JSON documents key by key and returns a case
+// for every top-level key, named after the key, for reporting with
+// WriteJUnit. Ignored keys are skipped. If either document is not a JSON
+// object, a single case named "document" holds the result of comparing the
+// whole documents.
+func JUnitCasesByKey(a, b []byte, opts *Options) []JUnitCase {
+	return differOf(opts).JUnitCasesByKey(a, b)
+}
+
+// JUnitCasesByKey is like the package-level JUnitCasesByKey, using the
+// options of d.
+func (d *Differ) JUnitCasesByKey(a, b []byte) []JUnitCase {
+	ctx := d.newContext(nil)
+	av, errA := ctx.decode(a, 0)
+	bv, errB := ctx.decode(b, 1)
+	ma, aok := av.(map[string]interface{})
+	mb, bok := bv.(map[string]interface{})
+	if errA != nil || errB != nil || !aok || !bok {
+		diff, text := d.Compare(a, b)
+		return []JUnitCase{{Name: "document", Difference: diff, Text: text}}
+	}
+	var cases []JUnitCase
+	for _, k := range ctx.mergedKeys(ma, mb) {
+		if _, ignored := ctx.ignoreFields[k]; ignored {
+			continue
+		}
+		sa := make(map[string]interface{})
+		sb := make(map[string]interface{})
+		if v, ok := ma[k]; ok {
+			sa[k] = v
+		}
+		if v, ok := mb[k]; ok {
+			sb[k] = v
+		}
+		kctx := d.newContext(nil)
+		kctx.order = ctx.order
+		kctx.positions = ctx.positions
+		diff, text := kctx.compareValues(sa, sb)
+		cases = append(cases, JUnitCase{Name: k, Difference: diff, Text: text})
+	}
+	return cases
+}
@@ -0,0 +1,79 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+)
+
+func TestWriteJUnit(t *testing.T) {
+	opts := Options{Indent: "  "}
+	var cases []JUnitCase
+	for _, c := range []struct{ name, a, b string }{
+		{"equal", `{"a": 1}`, `{"a": 1}`},
+		{"superset", `{"a": 1, "b": 2}`, `{"a": 1}`},
+		{"nomatch", `{"a": "<x> & y"}`, `{"a": "z"}`},
+		{"invalid", `{`, `{}`},
+	} {
+		diff, text := Compare([]byte(c.a), []byte(c.b), &opts)
+		cases = append(cases, JUnitCase{Name: c.name, Difference: diff, Text: text})
+	}
+	var buf bytes.Buffer
+	if err := WriteJUnit(&buf, "jsondiff", cases...); err != nil {
+		t.Fatal(err)
+	}
+
+	var ts junitTestSuite
+	if err := xml.Unmarshal(buf.Bytes(), &ts); err != nil {
+		t.Fatalf("invalid XML: %s\n%s", err, buf.String())
+	}
+	if ts.Name != "jsondiff" || ts.Tests != 4 || ts.Failures != 1 || ts.Errors != 1 || len(ts.Cases) != 4 {
+		t.Fatalf("unexpected suite %+v", ts)
+	}
+	for i, tc := range ts.Cases {
+		failed := tc.Failure != nil
+		if failed != (i == 2) {
+			t.Errorf("case %s: unexpected failure %+v", tc.Name, tc.Failure)
+		}
+		if errored := tc.Error != nil; errored != (i == 3) {
+			t.Errorf("case %s: unexpected error %+v", tc.Name, tc.Error)
+		}
+	}
+	if text := ts.Cases[2].Failure.Text; text != cases[2].Text || !strings.Contains(text, `"<x> & y"`) {
+		t.Errorf("failure text does not round-trip: %q", text)
+	}
+	if strings.Contains(buf.String(), "<x>") {
+		t.Errorf("diff text is not escaped:\n%s", buf.String())
+	}
+}
+
+func TestJUnitCasesByKey(t *testing.T) {
+	opts := Options{IgnoreFields: []string{"ignored"}}
+	cases := JUnitCasesByKey(
+		[]byte(`{"same": 1, "changed": 1, "removed": 1, "ignored": 1}`),
+		[]byte(`{"same": 1, "changed": 2, "added": 1, "ignored": 2}`),
+		&opts)
+	expected := map[string]Difference{
+		"added":   SubsetMatch,
+		"changed": NoMatch,
+		"removed": SupersetMatch,
+		"same":    FullMatch,
+	}
+	if len(cases) != len(expected) {
+		t.Fatalf("got %d cases, expected %d: %+v", len(cases), len(expected), cases)
+	}
+	for _, c := range cases {
+		if diff, ok := expected[c.Name]; !ok || diff != c.Difference {
+			t.Errorf("case %s: got %s", c.Name, c.Difference)
+		}
+		if c.Difference != FullMatch && !strings.Contains(c.Text, `"`+c.Name+`"`) {
+			t.Errorf("case %s: text does not mention the key: %q", c.Name, c.Text)
+		}
+	}
+
+	cases = JUnitCasesByKey([]byte(`[1]`), []byte(`[2]`), &opts)
+	if len(cases) != 1 || cases[0].Name != "document" || cases[0].Difference != NoMatch {
+		t.Errorf("unexpected cases for arrays: %+v", cases)
+	}
+}
@@ -0,0 +1,127 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+)
+
+// skippedValue stands for the value of a member whose JSON text is the same
+// in both documents, which lazy decoding leaves undecoded.
+type skippedValue struct{}
+
+var skipped interface{} = skippedValue{}
+
+// lazy reports whether the documents are decoded lazily: LazyDecoding is
+// set and nothing needs every value of them decoded.
+func (ctx *context) lazy() bool {
+	opts := ctx.opts
+	return opts.LazyDecoding && !ctx.keepDecoded && !ctx.tracking && ctx.order == nil && !ctx.countLeaves &&
+		!opts.ShowSummary && !opts.DetectDuplicateKeys && !opts.AlwaysRender && ctx.differ.maxDepth >= DefaultMaxDepth &&
+		opts.InvalidUTF8 == InvalidUTF8Replace
+}
+
+// decodeLazily decodes a and b if both are objects, decoding only the
+// members that differ, and reports whether it did. If either document is
+// not an object or not valid JSON, they are left to decode.
+func (ctx *context) decodeLazily(a, b []byte) (av, bv interface{}, ok bool) {
+	ra, okA := ctx.rawObject(a)
+	rb, okB := ctx.rawObject(b)
+	if !okA || !okB {
+		return nil, nil, false
+	}
+	ma, mb, err := ctx.lazyMembers(ra, rb)
+	if err != nil {
+		return nil, nil, false
+	}
+	return ma, mb, true
+}
+
+// rawObject returns the members of the object held in data, undecoded, or
+// false if data holds no valid object.
+func (ctx *context) rawObject(data []byte) (map[string]json.RawMessage, bool) {
+	if ctx.opts.LenientParsing {
+		data = stripLenient(data)
+	}
+	if firstByte(data) != '{' {
+		return nil, false
+	}
+	r := bytes.NewReader(data)
+	d := json.NewDecoder(r)
+	var m map[string]json.RawMessage
+	if err := d.Decode(&m); err != nil {
+		return nil, false
+	}
+	if ctx.opts.DisallowTrailingData && trailingData(d, r) != nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// lazyMembers decodes the objects with the members ra and rb. Members with
+// the same text in both are skipped, members of both that are objects are
+// decoded lazily in turn, and ignored fields are left null.
+func (ctx *context) lazyMembers(ra, rb map[string]json.RawMessage) (ma, mb map[string]interface{}, err error) {
+	ma = make(map[string]interface{}, len(ra))
+	mb = make(map[string]interface{}, len(rb))
+	for k, va := range ra {
+		if _, ignored := ctx.ignoreFields[k]; ignored {
+			ma[k] = nil
+			continue
+		}
+		vb, ok := rb[k]
+		switch {
+		case !ok:
+			ma[k], err = decode(va, nil)
+		case equalIgnoringSpace(va, vb):
+			ma[k], mb[k] = skipped, skipped
+		default:
+			ma[k], mb[k], err = ctx.lazyPair(va, vb)
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	for k, vb := range rb {
+		if _, ignored := ctx.ignoreFields[k]; ignored {
+			mb[k] = nil
+			continue
+		}
+		if _, ok := ra[k]; !ok {
+			if mb[k], err = decode(vb, nil); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+	return ma, mb, nil
+}
+
+// lazyPair decodes the different values a and b of a member, lazily if
+// both are objects.
+func (ctx *context) lazyPair(a, b json.RawMessage) (av, bv interface{}, err error) {
+	if firstByte(a) != '{' || firstByte(b) != '{' {
+		if av, err = decode(a, nil); err != nil {
+			return nil, nil, err
+		}
+		bv, err = decode(b, nil)
+		return av, bv, err
+	}
+	var ra, rb map[string]json.RawMessage
+	if err := json.Unmarshal(a, &ra); err != nil {
+		return nil, nil, err
+	}
+	if err := json.Unmarshal(b, &rb); err != nil {
+		return nil, nil, err
+	}
+	return ctx.lazyMembers(ra, rb)
+}
+
+// firstByte returns the first byte of data that is not JSON whitespace, or
+// 0 if there is none.
+func firstByte(data []byte) byte {
+	for _, c := range data {
+		if !isJSONSpace(c) {
+			return c
+		}
+	}
+	return 0
+}
@@ -0,0 +1,122 @@
+package jsondiff
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+func TestLazyDecoding(t *testing.T) {
+	variants := []func(*Options){
+		func(*Options) {},
+		func(o *Options) { *o = DefaultConsoleOptions() },
+		func(o *Options) { o.IgnoreFields = []string{"fuzz1", "b"} },
+		func(o *Options) { o.PrintTypes = true },
+		func(o *Options) { o.PrintTypes, o.IgnoreFields = true, []string{"fuzz1"} },
+		func(o *Options) { o.PrintTypesMode, o.DetailedTypes = PrintTypesAlways, true },
+		func(o *Options) { o.NullAsEmpty, o.NoOutput = true, true },
+		func(o *Options) { o.ShowPaths, o.CollapseAddedRemoved = true, true },
+		func(o *Options) { o.ShowSummary = true },
+		func(o *Options) { o.MaxDisplayDepth, o.Parallelism = 2, 3 },
+		func(o *Options) { o.DisallowTrailingData, o.LenientParsing = true, true },
+		func(o *Options) { o.FuzzyFields, o.StringAsMapFields = []string{"fuzz2"}, []string{"k1", "a"} },
+	}
+	docs := randomDocuments(rand.New(rand.NewSource(1)), 500)
+	for _, c := range cases {
+		docs = append(docs, [2][]byte{[]byte(c.a), []byte(c.b)})
+	}
+	for _, c := range [][2]string{
+		{`{"a": {"b": [1, 2]}, "c": 1}`, `{"c": 2, "a": {"b": [1,2]}}`},
+		{`{"a": {"b": "\u0041"}}`, `{"a": {"b": "A"}}`},
+		{`{"a": 1} x`, `{"a": 2}`},
+		{`{"a": 1, /* c */ "b": [2]}`, `{"a": 1, "b": [2, ]}`},
+		{`{"a": {"b": 1}}`, `{"a": {"b": 1}, "a": {"b": 2}}`},
+		{`{"a": 1}`, `{"a": 1`},
+	} {
+		docs = append(docs, [2][]byte{[]byte(c[0]), []byte(c[1])})
+	}
+	for i, variant := range variants {
+		var opts Options
+		variant(&opts)
+		lazy := opts
+		lazy.LazyDecoding = true
+		for _, doc := range docs {
+			expected := CompareDetail(doc[0], doc[1], &opts)
+			got := CompareDetail(doc[0], doc[1], &lazy)
+			if got.Difference != expected.Difference || got.Text != expected.Text ||
+				!reflect.DeepEqual(got.Entries, expected.Entries) || got.FirstMismatchPath != expected.FirstMismatchPath {
+				t.Fatalf("variant %d: %s, %s:\ngot      %+v\nexpected %+v", i, doc[0], doc[1], got, expected)
+			}
+			_, _, errExpected := CompareErr(doc[0], doc[1], &opts)
+			_, _, errGot := CompareErr(doc[0], doc[1], &lazy)
+			if fmt.Sprint(errGot) != fmt.Sprint(errExpected) {
+				t.Fatalf("variant %d: %s, %s: got error %v, expected %v", i, doc[0], doc[1], errGot, errExpected)
+			}
+		}
+	}
+}
+
+func TestLazyDecodingSkips(t *testing.T) {
+	a := []byte(`{"a": {"b": [1, 2, {"c": 3}]}, "d": 1, "e": {"f": 1, "g": [4]}, "x": [5]}`)
+	b := []byte(`{"a": {"b": [1,2,{"c":3}]}, "d": 2, "e": {"f": 2, "g": [4]}, "x": [6]}`)
+	for _, c := range []struct {
+		opts  Options
+		nodes int
+	}{
+		// The root, a, d, e, f, g, x and its element.
+		{Options{LazyDecoding: true}, 8},
+		{Options{LazyDecoding: true, IgnoreFields: []string{"x"}}, 6},
+		// Every value.
+		{Options{}, 14},
+		{Options{LazyDecoding: true, PreserveKeyOrder: true}, 14},
+		{Options{LazyDecoding: true, FuzzyFields: []string{"z"}}, 8},
+	} {
+		if nodes := CompareDetail(a, b, &c.opts).Stats.Nodes; nodes != c.nodes {
+			t.Errorf("lazy %v, ignored %v, fuzzy %v: compared %d nodes, expected %d",
+				c.opts.LazyDecoding, c.opts.IgnoreFields, c.opts.FuzzyFields, nodes, c.nodes)
+		}
+	}
+
+	opts := Options{LazyDecoding: true}
+	r := CompareDecoded(a, b, &opts)
+	if v, err := decode(a, nil); err != nil || !reflect.DeepEqual(r.A, v) {
+		t.Errorf("CompareDecoded returned %v, expected %v", r.A, v)
+	}
+}
+
+// sparseDocuments returns two objects of n members holding records, which
+// differ in one member out of 50.
+func sparseDocuments(n int) ([]byte, []byte) {
+	var a, b bytes.Buffer
+	a.WriteString("{")
+	b.WriteString("{")
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			a.WriteString(",")
+			b.WriteString(",")
+		}
+		record := `{"id": %d, "name": "item", "tags": ["a", "b", "c"], "limits": {"cpu": 4, "mem": %d}}`
+		fmt.Fprintf(&a, `"key%d": `+record, i, i, 8)
+		if i%50 == 0 {
+			fmt.Fprintf(&b, `"key%d": `+record, i, i, 16)
+		} else {
+			fmt.Fprintf(&b, `"key%d": `+record, i, i, 8)
+		}
+	}
+	a.WriteString("}")
+	b.WriteString("}")
+	return a.Bytes(), b.Bytes()
+}
+
+func BenchmarkCompareLazy(b *testing.B) {
+	docA, docB := sparseDocuments(10000)
+	for _, lazy := range []bool{false, true} {
+		b.Run(fmt.Sprintf("LazyDecoding=%v", lazy), func(b *testing.B) {
+			opts := DefaultConsoleOptions()
+			opts.LazyDecoding = lazy
+			benchmarkCompareDocuments(b, opts, docA, docB)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package jsondiff
+
+// stripLenient returns data with the comments and trailing commas allowed by
+// LenientParsing replaced by spaces, so that the result is plain JSON with
+// every remaining byte at its original offset. Line breaks inside block
+// comments are kept so that lines stay the same too. An unterminated block
+// comment is left in place for the decoder to report. data is not modified.
+func stripLenient(data []byte) []byte {
+	out := append([]byte(nil), data...)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' && out[i] != '\r' {
+				out[i] = ' '
+			}
+		}
+	}
+	// First pass: comments.
+	for i := 0; i < len(out); i++ {
+		switch {
+		case out[i] == '"':
+			i = skipString(out, i)
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := i
+			for end < len(out) && out[end] != '\n' {
+				end++
+			}
+			blank(i, end)
+			i = end
+		case out[i] == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := indexFrom(out, i+2, "*/")
+			if end < 0 {
+				return out
+			}
+			blank(i, end+2)
+			i = end + 1
+		}
+	}
+	// Second pass: commas followed only by whitespace up to the end of an
+	// object or array.
+	for i := 0; i < len(out); i++ {
+		switch out[i] {
+		case '"':
+			i = skipString(out, i)
+		case ',':
+			j := i + 1
+			for j < len(out) && (out[j] == ' ' || out[j] == '\t' || out[j] == '\n' || out[j] == '\r') {
+				j++
+			}
+			if j < len(out) && (out[j] == '}' || out[j] == ']') {
+				out[i] = ' '
+			}
+		}
+	}
+	return out
+}
+
+// skipString returns the offset of the quote closing the string starting at
+// data[i], or the last offset of data if the string is not closed.
+func skipString(data []byte, i int) int {
+	for i++; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return len(data) - 1
+}
+
+// indexFrom returns the offset of the first sep in data at or after from, or
+// -1.
+func indexFrom(data []byte, from int, sep string) int {
+	for i := from; i+len(sep) <= len(data); i++ {
+		if string(data[i:i+len(sep)]) == sep {
+			return i
+		}
+	}
+	return -1
+}
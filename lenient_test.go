@@ -0,0 +1,68 @@
+package jsondiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestStripLenient(t *testing.T) {
+	cases := []struct{ in, expected string }{
+		{`{"a": 1} // done`, `{"a": 1}        `},
+		{"{\"a\": /* x\ny */ 1}", "{\"a\":     \n     1}"},
+		{`[1, 2, ]`, `[1, 2  ]`},
+		{"{\"a\": 1, // last\n}", "{\"a\": 1         \n}"},
+		{`{"url": "http://x/*y*/", "b": "a,]"}`, `{"url": "http://x/*y*/", "b": "a,]"}`},
+		{`{"q": "\"//", "c": 1}`, `{"q": "\"//", "c": 1}`},
+		{`[1, /* open`, `[1, /* open`},
+		{`[,]`, `[ ]`},
+	}
+	for _, c := range cases {
+		if got := string(stripLenient([]byte(c.in))); got != c.expected {
+			t.Errorf("%q: got %q, expected %q", c.in, got, c.expected)
+		}
+		if len(c.in) != len(c.expected) {
+			t.Fatalf("%q: bad test case", c.in)
+		}
+	}
+}
+
+func TestLenientParsing(t *testing.T) {
+	a := `{
+  // The service name.
+  "name": "web", /* inline */
+  "urls": ["http://example.com/a//b", "/*not a comment*/",],
+  "port": 80,
+}`
+	b := `{"name": "web", "urls": ["http://example.com/a//b", "/*not a comment*/"], "port": 80}`
+	opts := Options{LenientParsing: true}
+	if diff, _ := Compare([]byte(a), []byte(b), &opts); diff != FullMatch {
+		t.Errorf("got %s, expected FullMatch", diff)
+	}
+	if diff, _, err := CompareReaders(strings.NewReader(b), strings.NewReader(a), &opts); err != nil || diff != FullMatch {
+		t.Errorf("readers: got %s and %v, expected FullMatch", diff, err)
+	}
+	if diff, _ := Compare([]byte(a), []byte(b), &Options{}); diff != FirstArgIsInvalidJson {
+		t.Errorf("without LenientParsing: got %s, expected FirstArgIsInvalidJson", diff)
+	}
+	_, entries := CompareEntries([]byte(a), []byte(`{"name": "api"}`), &Options{LenientParsing: true, TrackPositions: true})
+	if len(entries) == 0 || entries[0].Path != "/name" || *entries[0].OldPos != (Position{Offset: 35, Line: 3, Column: 11}) {
+		t.Errorf("got entries %+v, expected the position of name in the original input", entries)
+	}
+}
+
+func TestLenientParsingRejectsJSON5(t *testing.T) {
+	opts := Options{LenientParsing: true}
+	for _, doc := range []string{`{'a': 1}`, `{a: 1}`, `[1, /* open`, `[1,,]`} {
+		_, _, err := CompareErr([]byte(doc), []byte(`{}`), &opts)
+		var ije *InvalidJSONError
+		if !errors.As(err, &ije) {
+			t.Errorf("%s: got %v, expected invalid JSON", doc, err)
+		}
+	}
+	_, _, err := CompareErr([]byte("{\n  // c\n  'a': 1}"), []byte(`{}`), &opts)
+	var ije *InvalidJSONError
+	if !errors.As(err, &ije) || ije.Position.Line != 3 || ije.Position.Column != 3 {
+		t.Errorf("got %v, expected an error at line 3, column 3", err)
+	}
+}
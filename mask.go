@@ -0,0 +1,99 @@
+package jsondiff
+
+import "strconv"
+
+// maskedValue stands for the scalars of masked members in the output: it is
+// rendered without quotes and is the value of entries and conflicts.
+const maskedValue = "***"
+
+// masking reports whether any member is masked by MaskFields.
+func (d *Differ) masking() bool {
+	return d.maskFields != nil || d.maskPaths != nil
+}
+
+// isScalar reports whether v is neither an object nor an array.
+func isScalar(v interface{}) bool {
+	switch v.(type) {
+	case map[string]interface{}, []interface{}:
+		return false
+	}
+	return true
+}
+
+// maskMember sets ctx.masked if the member k, whose key has been pushed to
+// the path, is masked by MaskFields, and returns the previous value of
+// ctx.masked for the caller to restore once done with the member.
+func (ctx *context) maskMember(k string) bool {
+	masked := ctx.masked
+	if masked || !ctx.differ.masking() {
+		return masked
+	}
+	if _, found := ctx.differ.maskFields[k]; found {
+		ctx.masked = true
+	} else if _, found := ctx.differ.maskPaths[ctx.pointer()]; found {
+		ctx.masked = true
+	}
+	return masked
+}
+
+// mask returns v, a value of the document at the current path, as it may be
+// shown: a copy with every scalar inside a masked member replaced with
+// maskedValue, or v itself if no member is masked.
+func (ctx *context) mask(v interface{}) interface{} {
+	if !ctx.differ.masking() {
+		return v
+	}
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv))
+		outer := ctx.curKey
+		for k, e := range vv {
+			ctx.curKey = k
+			ctx.push(k)
+			masked := ctx.maskMember(k)
+			m[k] = ctx.mask(e)
+			ctx.masked = masked
+			ctx.pop()
+		}
+		ctx.curKey = outer
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(vv))
+		for i, e := range vv {
+			ctx.push(strconv.Itoa(i))
+			s[i] = ctx.mask(e)
+			ctx.pop()
+		}
+		return s
+	}
+	if ctx.masked {
+		return maskedValue
+	}
+	if s, ok := v.(string); ok {
+		return ctx.maskEmbedded(s)
+	}
+	return v
+}
+
+// maskEmbedded returns s, a string of the member ctx.curKey, with the
+// members of MaskFields masked in the document it holds if it is a string
+// of StringAsMapFields. The document is then encoded again, compactly and
+// with its keys sorted.
+func (ctx *context) maskEmbedded(s string) string {
+	if !ctx.differ.masking() {
+		return s
+	}
+	if _, isStringAsMap := ctx.stringAsMapFields[ctx.curKey]; !isStringAsMap {
+		return s
+	}
+	nctx := ctx.differ.nested.newContext(ctx)
+	v, err := nctx.decode([]byte(s), 0)
+	if err != nil {
+		return s
+	}
+	data, err := encodeCompact(nctx.mask(v))
+	if err != nil {
+		return s
+	}
+	return string(data)
+}
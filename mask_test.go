@@ -0,0 +1,131 @@
+package jsondiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// maskedDocs are documents whose masked members hold values that must not
+// appear in any output: "ssn" and "token" by name and /card/number by path.
+var maskedDocs = [][2]string{
+	{`{"ssn": "123-45-6789", "name": "a"}`, `{"ssn": "987-65-4321", "name": "b"}`},
+	{`{"ssn": "123-45-6789"}`, `{"ssn": "123-45-6789", "token": {"id": "tok-secret", "n": [4111]}}`},
+	{`{"user": {"token": ["tok-secret", 4111]}}`, `{"user": {"token": ["tok-secret", 1234]}}`},
+	{`{"card": {"number": "4111-1111", "exp": "12/30"}}`, `{"card": {"number": "4111-2222", "exp": "12/31"}}`},
+	{`{"ssn": "123-45-6789"}`, `{"ssn": ["987-65-4321"]}`},
+	{`{"ssn": "x123-45-6789x"}`, `{"ssn": "x987-65-4321x"}`},
+	{`{"doc": "{\"token\": \"tok-secret\"}"}`, `{"doc": "{\"token\": \"tok-other\"}"}`},
+	{`[{"ssn": null}]`, `[{"ssn": 1234}]`},
+}
+
+var maskedSecrets = []string{"6789", "4321", "tok-secret", "tok-other", "4111", "1234", "1111", "2222"}
+
+func TestMaskFields(t *testing.T) {
+	check := func(what, out string) {
+		t.Helper()
+		for _, s := range maskedSecrets {
+			if strings.Contains(out, s) {
+				t.Errorf("%s shows %q:\n%s", what, s, out)
+			}
+		}
+	}
+	variants := []Options{
+		DefaultConsoleOptions(),
+		DefaultHTMLOptions(),
+		{Indent: " ", PrintTypes: true, InlineStringDiff: true, ShowPaths: true},
+		{Indent: " ", ExpandChangedValues: true, AlwaysRender: true, Parallelism: 2},
+		{Indent: " ", CollapseAddedRemoved: true, TreeGuides: true, ShowSummary: true},
+	}
+	for _, docs := range maskedDocs {
+		a, b := []byte(docs[0]), []byte(docs[1])
+		for _, opts := range variants {
+			opts.StringAsMapFields = []string{"doc"}
+			want, _ := Compare(a, b, &opts)
+			opts.MaskFields = []string{"ssn", "token", "/card/number"}
+			var calls []string
+			opts.OnDifference = func(path string, kind DiffKind, oldValue, newValue interface{}) {
+				calls = append(calls, fmt.Sprint(path, kind, oldValue, newValue))
+			}
+			diff, text := Compare(a, b, &opts)
+			if diff != want {
+				t.Errorf("%s, %s: got %s, expected %s", a, b, diff, want)
+			}
+			check("Compare", text)
+			check("OnDifference", strings.Join(calls, "\n"))
+			if diff, text := Compare(a, a, &opts); diff != FullMatch {
+				t.Errorf("%s: got %s against itself", a, diff)
+			} else {
+				check("AlwaysRender", text)
+			}
+		}
+
+		opts := Options{StringAsMapFields: []string{"doc"}, MaskFields: []string{"ssn", "token", "/card/number"}}
+		r := CompareDetail(a, b, &opts)
+		check("CompareDetail", fmt.Sprintf("%+v", r.Entries))
+		check("Paths", strings.Join(r.Paths(false), "\n"))
+		var buf bytes.Buffer
+		WriteNDJSON(&buf, a, b, &opts)
+		WriteSARIF(&buf, a, b, &opts)
+		WriteJUnit(&buf, "suite", JUnitCasesByKey(a, b, &opts)...)
+		check("NDJSON, SARIF and JUnit", buf.String())
+		_, results, _ := CompareJSONLines(bytes.NewReader(a), bytes.NewReader(b), &opts)
+		check("CompareJSONLines", fmt.Sprintf("%+v", results))
+		_, conflicts, _ := Compare3([]byte(`{}`), a, b, &opts)
+		check("Compare3", fmt.Sprintf("%+v", conflicts))
+	}
+}
+
+func TestMaskFieldsRendering(t *testing.T) {
+	opts := Options{
+		Indent:     "  ",
+		Changed:    Tag{Begin: "<c>", End: "</c>"},
+		Added:      Tag{Begin: "<a>", End: "</a>"},
+		MaskFields: []string{"token", "/user/ssn"},
+	}
+	a := `{"user": {"ssn": "1", "name": "x"}, "token": "t1", "ssn": "2"}`
+	b := `{"user": {"ssn": "3", "name": "y"}, "token": {"id": "t2", "n": [null]}, "ssn": "4"}`
+	_, text := Compare([]byte(a), []byte(b), &opts)
+	expected := `{
+  "ssn": <c>"2" => "4"</c>,
+  "token": <c>*** => {}</c>,
+  "user": {
+    "name": <c>"x" => "y"</c>,
+    "ssn": <c>*** => ***</c>
+  }
+}`
+	if text != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", text, expected)
+	}
+
+	_, entries := CompareEntries([]byte(`{"a": 1}`), []byte(`{"a": 1, "token": {"id": "t2", "n": [null]}}`), &opts)
+	if len(entries) != 1 || entries[0].Old != nil || fmt.Sprint(entries[0].New) != "map[id:*** n:[***]]" {
+		t.Errorf("got entries %+v", entries)
+	}
+	_, text = Compare([]byte(`{"a": 1}`), []byte(`{"a": 1, "token": {"id": "t2", "n": [null]}}`), &opts)
+	expected = `{
+  <a>"token": {</a>
+    <a>"id": ***,</a>
+    <a>"n": [</a>
+      <a>***</a>
+    <a>]</a>
+  <a>}</a>
+}`
+	if text != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", text, expected)
+	}
+
+	// The documents of StringAsMapFields are masked where they are shown
+	// as strings.
+	opts.StringAsMapFields = []string{"doc"}
+	opts.AlwaysRender = true
+	doc := []byte(`{"doc": "{\"token\": \"t1\", \"b\": 1}"}`)
+	_, text = Compare(doc, doc, &opts)
+	expected = `{
+  "doc": "{\"b\":1,\"token\":\"***\"}"
+}`
+	if text != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", text, expected)
+	}
+}
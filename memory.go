@@ -0,0 +1,92 @@
+package jsondiff
+
+import (
+	"bytes"
+	"strconv"
+)
+
+// valueMemory is the memory a decoded value is estimated to take on top of
+// the bytes of its text: the interface holding it and its share of the map
+// or slice holding it, or the header of a string.
+const valueMemory = 64
+
+// ComparisonTooLargeError is the error of a comparison stopped because its
+// estimated memory use went over Options.MaxMemoryBytes.
+type ComparisonTooLargeError struct {
+	// Estimate is the estimated memory use that went over the budget.
+	Estimate       int64
+	MaxMemoryBytes int64
+}
+
+func (e *ComparisonTooLargeError) Error() string {
+	return "jsondiff: comparison needs about " + strconv.FormatInt(e.Estimate, 10) +
+		" bytes, more than the budget of " + strconv.FormatInt(e.MaxMemoryBytes, 10)
+}
+
+// charge adds n bytes to the memory ctx is estimated to use and stops the
+// comparison with a *ComparisonTooLargeError if that goes over the budget.
+// It reports whether the comparison may go on.
+func (ctx *context) charge(n int64) bool {
+	ctx.memory += n
+	limit := ctx.opts.MaxMemoryBytes
+	if limit > 0 && ctx.memory > limit {
+		if ctx.err == nil {
+			ctx.err = &ComparisonTooLargeError{Estimate: ctx.memory, MaxMemoryBytes: limit}
+		}
+		return false
+	}
+	return true
+}
+
+// chargeOutput charges the growth of buf, the buffer being rendered into,
+// since it was last charged. Rolled back output is not refunded, since buf
+// keeps its memory.
+func (ctx *context) chargeOutput(buf *bytes.Buffer) {
+	if ctx.opts.MaxMemoryBytes <= 0 {
+		return
+	}
+	if buf != ctx.outBuf {
+		ctx.outBuf, ctx.outLen = buf, 0
+	}
+	if n := buf.Len(); n > ctx.outLen {
+		ctx.charge(int64(n - ctx.outLen))
+		ctx.outLen = n
+	}
+}
+
+// chargeDocuments charges the estimated size of a and b decoded, before
+// they are, and reports whether the comparison may go on.
+func (ctx *context) chargeDocuments(a, b []byte) bool {
+	if ctx.opts.MaxMemoryBytes <= 0 {
+		return true
+	}
+	return ctx.charge(decodedMemory(a) + decodedMemory(b))
+}
+
+// decodedMemory estimates the memory taken by data once decoded: its
+// length plus valueMemory for every value, counted as the brackets opening
+// objects and arrays, the colons following keys and the commas separating
+// values outside of strings.
+func decodedMemory(data []byte) int64 {
+	values := int64(1)
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			switch c {
+			case '\\':
+				i++
+			case '"':
+				inString = false
+			}
+			continue
+		}
+		switch c {
+		case '"':
+			inString = true
+		case '{', '[', ':', ',':
+			values++
+		}
+	}
+	return int64(len(data)) + values*valueMemory
+}
@@ -0,0 +1,106 @@
+package jsondiff
+
+import (
+	gocontext "context"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestDecodedMemory(t *testing.T) {
+	for _, c := range []struct {
+		doc    string
+		values int64
+	}{
+		{`1`, 1},
+		{`"{[,:\""`, 1},
+		{`[]`, 2},
+		{`[1, 2, 3]`, 4},
+		{`{"a": {"b": [1, "x,y"]}}`, 7},
+	} {
+		if got, expected := decodedMemory([]byte(c.doc)), int64(len(c.doc))+c.values*valueMemory; got != expected {
+			t.Errorf("%s: got %d, expected %d", c.doc, got, expected)
+		}
+	}
+	prev := int64(0)
+	for n := 1; n < 200; n++ {
+		a, _ := largeObjects(n)
+		if m := decodedMemory(a); m <= prev {
+			t.Fatalf("%d members: got %d, not more than %d for one member less", n, m, prev)
+		} else {
+			prev = m
+		}
+	}
+}
+
+func TestMaxMemoryBytes(t *testing.T) {
+	a, b := largeObjects(100)
+	documents := decodedMemory(a) + decodedMemory(b)
+	expected, text, _ := CompareErr(a, b, &Options{})
+
+	opts := Options{MaxMemoryBytes: documents - 1}
+	diff, out, err := CompareErr(a, b, &opts)
+	var tooLarge *ComparisonTooLargeError
+	if diff != NoMatch || out != "" || !errors.As(err, &tooLarge) || tooLarge.Estimate != documents {
+		t.Fatalf("below the documents: got %s, %q, %v", diff, out, err)
+	}
+
+	// The output of the comparison is counted too, as it is rendered.
+	passed := false
+	for limit := documents; limit <= documents+2*int64(len(text)); limit += 16 {
+		opts := Options{MaxMemoryBytes: limit}
+		diff, out, err := CompareErr(a, b, &opts)
+		switch {
+		case err == nil:
+			if diff != expected || out != text {
+				t.Fatalf("%d: got %s, %q", limit, diff, out)
+			}
+			passed = true
+		case passed:
+			t.Fatalf("%d: failed with %v after a lower limit passed", limit, err)
+		case !errors.As(err, &tooLarge) || tooLarge.Estimate <= limit || diff != NoMatch || out != "":
+			t.Fatalf("%d: got %s, %q, %v", limit, diff, out, err)
+		}
+	}
+	if !passed {
+		t.Fatal("no limit up to twice the output passed")
+	}
+	opts = Options{MaxMemoryBytes: documents, NoOutput: true}
+	if diff, _, err := CompareErr(a, b, &opts); err != nil || diff != expected {
+		t.Errorf("NoOutput: got %s, %v", diff, err)
+	}
+
+	opts = Options{MaxMemoryBytes: documents}
+	if diff, out := Compare(a, b, &opts); diff != NoMatch || out != "" {
+		t.Errorf("Compare: got %s, %q", diff, out)
+	}
+	if _, _, err := CompareContext(gocontext.Background(), a, b, &opts); !errors.As(err, &tooLarge) {
+		t.Errorf("CompareContext: got %v", err)
+	}
+	opts.Parallelism = 4
+	if _, _, err := CompareErr(a, b, &opts); !errors.As(err, &tooLarge) {
+		t.Errorf("Parallelism: got %v", err)
+	}
+}
+
+func TestMaxMemoryBytesNested(t *testing.T) {
+	inner, _ := largeObjects(50)
+	a := []byte(fmt.Sprintf(`{"s": %q}`, inner))
+	b := []byte(`{"s": "{}"}`)
+	all := decodedMemory(a) + decodedMemory(b) + decodedMemory(inner) + decodedMemory([]byte(`{}`))
+	opts := Options{MaxMemoryBytes: all - 1, StringAsMapFields: []string{"s"}, NoOutput: true}
+	if _, _, err := CompareErr(a, b, &opts); err == nil {
+		t.Error("the documents of a StringAsMapFields field were not counted")
+	}
+	opts.MaxMemoryBytes = all
+	if diff, _, err := CompareErr(a, b, &opts); err != nil || diff != SupersetMatch {
+		t.Errorf("got %s, %v", diff, err)
+	}
+}
+
+func TestMaxMemoryBytesValidate(t *testing.T) {
+	opts := Options{MaxMemoryBytes: -1}
+	if err := opts.Validate(); err == nil {
+		t.Error("negative MaxMemoryBytes accepted")
+	}
+}
@@ -0,0 +1,156 @@
+package jsondiff
+
+import (
+	"sort"
+	"strconv"
+)
+
+// Conflict is a value changed differently by both sides of a three-way
+// merge.
+type Conflict struct {
+	// Path is the JSON Pointer of the value.
+	Path string
+	// Base, Ours and Theirs are the values in the three documents, nil if
+	// the value is null or absent from the document.
+	Base, Ours, Theirs interface{}
+}
+
+type missingValue struct{}
+
+// missing stands for a value absent from a document during a merge.
+var missing = missingValue{}
+
+// Compare3 merges the changes made to the JSON document base in ours and in
+// theirs, and returns the merged document, encoded like by ApplyPatch, and
+// the conflicts in document order. A value changed by only one side takes
+// that change; a value changed the same way by both sides is no conflict.
+// Objects changed by both sides are merged member by member. Arrays changed
+// by both sides are merged element by element if both keep the length of
+// the base array, and are a conflict as a whole otherwise. A conflicting
+// value keeps our version in the merged document. Values are compared like
+// by Compare, so differences in ignored and fuzzy fields are no changes.
+// If a document is not valid JSON, the error is an *InvalidJSONError with
+// Document 1 for base, 2 for ours and 3 for theirs.
+func Compare3(base, ours, theirs []byte, opts *Options) ([]byte, []Conflict, error) {
+	return differOf(opts).Compare3(base, ours, theirs)
+}
+
+// Compare3 is like the package-level Compare3, using the options of d.
+func (d *Differ) Compare3(base, ours, theirs []byte) ([]byte, []Conflict, error) {
+	ctx := d.newContext(nil)
+	ctx.tracking = false
+	var docs [3]interface{}
+	for i, data := range [][]byte{base, ours, theirs} {
+		v, err := ctx.decode(data, 0)
+		if err != nil {
+			return nil, nil, newInvalidJSONError(i+1, data, err)
+		}
+		docs[i] = v
+		if d.opts.HashSubtrees {
+			ctx.hashTrees(v)
+		}
+	}
+	var conflicts []Conflict
+	merged := ctx.merge3(docs[0], docs[1], docs[2], "", &conflicts)
+	data, err := encodeCompact(merged)
+	if err != nil {
+		return nil, nil, err
+	}
+	return data, conflicts, nil
+}
+
+// merge3 returns the merge of the values of the field key, which any of
+// the documents may be missing, and adds the conflicts found to conflicts.
+func (ctx *context) merge3(base, ours, theirs interface{}, key string, conflicts *[]Conflict) interface{} {
+	switch {
+	case ctx.same(ours, theirs, key):
+		return ours
+	case ctx.same(base, ours, key):
+		return theirs
+	case ctx.same(base, theirs, key):
+		return ours
+	}
+	if b, o, t, ok := maps3(base, ours, theirs); ok {
+		merged := make(map[string]interface{}, len(o))
+		for _, k := range unionKeys(b, o, t) {
+			ctx.push(k)
+			masked := ctx.maskMember(k)
+			v := ctx.merge3(member(b, k), member(o, k), member(t, k), k, conflicts)
+			ctx.masked = masked
+			ctx.pop()
+			if v != missing {
+				merged[k] = v
+			}
+		}
+		return merged
+	}
+	if b, o, t, ok := arrays3(base, ours, theirs); ok && len(o) == len(b) && len(t) == len(b) {
+		merged := make([]interface{}, len(b))
+		for i := range b {
+			ctx.push(strconv.Itoa(i))
+			merged[i] = ctx.merge3(b[i], o[i], t[i], key, conflicts)
+			ctx.pop()
+		}
+		return merged
+	}
+	ctx.curKey = key
+	*conflicts = append(*conflicts, Conflict{Path: ctx.localPointer(), Base: ctx.present(base),
+		Ours: ctx.present(ours), Theirs: ctx.present(theirs)})
+	return ours
+}
+
+// same reports whether a and b, either of which may be missing, are equal.
+func (ctx *context) same(a, b interface{}, key string) bool {
+	if a == missing || b == missing {
+		return a == b || ctx.differ.missingMatches(key)
+	}
+	return ctx.equal(a, b, key)
+}
+
+// maps3 returns a, b and c as objects, if they all are.
+func maps3(a, b, c interface{}) (ma, mb, mc map[string]interface{}, ok bool) {
+	ma, okA := a.(map[string]interface{})
+	mb, okB := b.(map[string]interface{})
+	mc, okC := c.(map[string]interface{})
+	return ma, mb, mc, okA && okB && okC
+}
+
+// arrays3 returns a, b and c as arrays, if they all are.
+func arrays3(a, b, c interface{}) (sa, sb, sc []interface{}, ok bool) {
+	sa, okA := a.([]interface{})
+	sb, okB := b.([]interface{})
+	sc, okC := c.([]interface{})
+	return sa, sb, sc, okA && okB && okC
+}
+
+// member returns the member k of m, or missing.
+func member(m map[string]interface{}, k string) interface{} {
+	if v, ok := m[k]; ok {
+		return v
+	}
+	return missing
+}
+
+// present returns v, masked like by mask, or nil if it is missing.
+func (ctx *context) present(v interface{}) interface{} {
+	if v == missing {
+		return nil
+	}
+	return ctx.mask(v)
+}
+
+// unionKeys returns the keys of all maps, sorted.
+func unionKeys(maps ...map[string]interface{}) []string {
+	seen := make(map[string]struct{})
+	var keys []string
+	for _, m := range maps {
+		for k := range m {
+			if _, ok := seen[k]; !ok {
+				seen[k] = struct{}{}
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,74 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCompare3(t *testing.T) {
+	base := `{"name": "svc", "port": 80, "tags": ["a", "b"], "limits": {"cpu": 1, "mem": 2}, "old": true}`
+	cases := []struct {
+		name          string
+		ours, theirs  string
+		merged        string
+		conflictPaths []string
+	}{
+		{"unchanged", base, base, `{"limits":{"cpu":1,"mem":2},"name":"svc","old":true,"port":80,"tags":["a","b"]}`, nil},
+		{"disjoint edits",
+			`{"name": "svc", "port": 8080, "tags": ["a", "b"], "limits": {"cpu": 2, "mem": 2}, "old": true}`,
+			`{"name": "svc2", "port": 80, "tags": ["a", "b"], "limits": {"cpu": 1, "mem": 4}, "new": 1}`,
+			`{"limits":{"cpu":2,"mem":4},"name":"svc2","new":1,"port":8080,"tags":["a","b"]}`, nil},
+		{"same change on both sides",
+			`{"name": "svc", "port": 81, "tags": ["a", "c"], "limits": {"cpu": 1, "mem": 2}}`,
+			`{"name": "svc", "port": 81, "tags": ["a", "c"], "limits": {"cpu": 1, "mem": 2}}`,
+			`{"limits":{"cpu":1,"mem":2},"name":"svc","port":81,"tags":["a","c"]}`, nil},
+		{"array elements",
+			`{"name": "svc", "port": 80, "tags": ["x", "b"], "limits": {"cpu": 1, "mem": 2}, "old": true}`,
+			`{"name": "svc", "port": 80, "tags": ["a", "y"], "limits": {"cpu": 1, "mem": 2}, "old": true}`,
+			`{"limits":{"cpu":1,"mem":2},"name":"svc","old":true,"port":80,"tags":["x","y"]}`, nil},
+		{"conflicts",
+			`{"name": "svc", "port": 81, "tags": ["a", "b", "c"], "limits": {"cpu": 1, "mem": 3}, "old": false}`,
+			`{"name": "svc", "port": 82, "tags": ["a"], "limits": {"cpu": 1, "mem": 4}}`,
+			`{"limits":{"cpu":1,"mem":3},"name":"svc","old":false,"port":81,"tags":["a","b","c"]}`,
+			[]string{"/limits/mem", "/old", "/port", "/tags"}},
+		{"added on both sides", base[:len(base)-1] + `, "x": 1}`, base[:len(base)-1] + `, "x": 2}`,
+			`{"limits":{"cpu":1,"mem":2},"name":"svc","old":true,"port":80,"tags":["a","b"],"x":1}`, []string{"/x"}},
+	}
+	for _, c := range cases {
+		merged, conflicts, err := Compare3([]byte(base), []byte(c.ours), []byte(c.theirs), &Options{})
+		if err != nil {
+			t.Fatalf("%s: %v", c.name, err)
+		}
+		var paths []string
+		for _, conflict := range conflicts {
+			paths = append(paths, conflict.Path)
+		}
+		if string(merged) != c.merged || !reflect.DeepEqual(paths, c.conflictPaths) {
+			t.Errorf("%s: got %s with conflicts %q, expected %s with %q", c.name, merged, paths, c.merged, c.conflictPaths)
+		}
+	}
+}
+
+func TestCompare3ConflictValues(t *testing.T) {
+	_, conflicts, err := Compare3([]byte(`{"a": {"b": 1}}`), []byte(`{"a": {"b": 2}}`), []byte(`{"a": {}}`), &Options{})
+	expected := []Conflict{{Path: "/a/b", Base: json.Number("1"), Ours: json.Number("2"), Theirs: nil}}
+	if err != nil || !reflect.DeepEqual(conflicts, expected) {
+		t.Errorf("got %+v %v, expected %+v", conflicts, err, expected)
+	}
+}
+
+func TestCompare3Options(t *testing.T) {
+	opts := Options{IgnoreFields: []string{"rev"}}
+	merged, conflicts, err := Compare3([]byte(`{"rev": 1, "a": 1}`), []byte(`{"rev": 2, "a": 2}`), []byte(`{"rev": 3, "a": 1}`), &opts)
+	if err != nil || len(conflicts) != 0 || string(merged) != `{"a":2,"rev":2}` {
+		t.Errorf("got %s %+v %v", merged, conflicts, err)
+	}
+	_, _, err = Compare3([]byte(`{}`), []byte(`{}`), []byte(`{`), &opts)
+	var ije *InvalidJSONError
+	if !errors.As(err, &ije) || ije.Document != 3 || !strings.Contains(err.Error(), "third document") {
+		t.Errorf("got %v", err)
+	}
+}
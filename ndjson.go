@@ -0,0 +1,54 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"io"
+)
+
+type ndjsonRecord struct {
+	Path       string          `json:"path"`
+	Kind       string          `json:"kind"`
+	Old        json.RawMessage `json:"old,omitempty"`
+	New        json.RawMessage `json:"new,omitempty"`
+	Difference string          `json:"difference,omitempty"`
+}
+
+// WriteNDJSON compares two JSON documents and writes every difference to w as
+// a JSON object on its own line:
+//
+//	{"path":"/a/b","kind":"changed","old":1,"new":2}
+//
+// Added entries have no "old" member and removed entries no "new" member. The
+// last line is a summary record holding the overall result:
+//
+//	{"path":"","kind":"summary","difference":"NoMatch"}
+//
+// The returned error is the first error returned by w.
+func WriteNDJSON(w io.Writer, a, b []byte, opts *Options) (Difference, error) {
+	return differOf(opts).WriteNDJSON(w, a, b)
+}
+
+// WriteNDJSON is like the package-level WriteNDJSON, using the options of d.
+func (d *Differ) WriteNDJSON(w io.Writer, a, b []byte) (Difference, error) {
+	diff, entries := d.CompareEntries(a, b)
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(false)
+	for _, e := range entries {
+		r := ndjsonRecord{Path: e.Path, Kind: e.Kind.String()}
+		var err error
+		if e.Kind != KindAdded {
+			if r.Old, err = json.Marshal(e.Old); err != nil {
+				return diff, err
+			}
+		}
+		if e.Kind != KindRemoved {
+			if r.New, err = json.Marshal(e.New); err != nil {
+				return diff, err
+			}
+		}
+		if err := enc.Encode(r); err != nil {
+			return diff, err
+		}
+	}
+	return diff, enc.Encode(ndjsonRecord{Kind: "summary", Difference: diff.String()})
+}
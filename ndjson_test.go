@@ -0,0 +1,88 @@
+package jsondiff
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestWriteNDJSON(t *testing.T) {
+	a := `{"a": {"b": 1, "gone": [1, 2]}, "list": [1, 2], "s": "x"}`
+	b := `{"a": {"b": 2, "new": null}, "list": [1, 2, {"k": "v"}], "s": "x"}`
+	var buf bytes.Buffer
+	diff, err := WriteNDJSON(&buf, []byte(a), []byte(b), &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != NoMatch {
+		t.Errorf("got %s, expected %s", diff, NoMatch)
+	}
+
+	type record struct {
+		Path       string
+		Kind       string
+		Old        interface{}
+		New        interface{}
+		Difference string
+		members    []string
+	}
+	var records []record
+	s := bufio.NewScanner(&buf)
+	for s.Scan() {
+		var r record
+		if err := json.Unmarshal(s.Bytes(), &r); err != nil {
+			t.Fatalf("invalid record %q: %s", s.Text(), err)
+		}
+		var m map[string]interface{}
+		json.Unmarshal(s.Bytes(), &m)
+		for k := range m {
+			r.members = append(r.members, k)
+		}
+		records = append(records, r)
+	}
+
+	expected := []record{
+		{Path: "/a/b", Kind: "changed", Old: 1.0, New: 2.0},
+		{Path: "/a/gone", Kind: "removed", Old: []interface{}{1.0, 2.0}},
+		{Path: "/a/new", Kind: "added", New: nil},
+		{Path: "/list/2", Kind: "added", New: map[string]interface{}{"k": "v"}},
+		{Path: "", Kind: "summary", Difference: "NoMatch"},
+	}
+	if len(records) != len(expected) {
+		t.Fatalf("got %d records, expected %d:\n%s", len(records), len(expected), buf.String())
+	}
+	for i, r := range records {
+		members := r.members
+		r.members = nil
+		if !reflect.DeepEqual(r, expected[i]) {
+			t.Errorf("record %d: got %#v, expected %#v", i, r, expected[i])
+		}
+		has := make(map[string]bool)
+		for _, m := range members {
+			has[m] = true
+		}
+		if has["old"] != (r.Kind == "changed" || r.Kind == "removed") {
+			t.Errorf("record %d: unexpected members %q", i, members)
+		}
+		if has["new"] != (r.Kind == "changed" || r.Kind == "added") {
+			t.Errorf("record %d: unexpected members %q", i, members)
+		}
+	}
+}
+
+func TestWriteNDJSONInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	diff, err := WriteNDJSON(&buf, []byte(`{`), []byte(`{}`), &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != FirstArgIsInvalidJson {
+		t.Errorf("got %s", diff)
+	}
+	expected := `{"path":"","kind":"summary","difference":"FirstArgIsInvalidJson"}` + "\n"
+	if buf.String() != expected {
+		t.Errorf("got %q, expected %q", buf.String(), expected)
+	}
+}
@@ -0,0 +1,364 @@
+package jsondiff
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// An Option changes a setting of Options. Options are applied in order, so
+// when two of them conflict the last one wins.
+type Option func(*Options)
+
+// New returns a Differ using the zero Options changed by options, or an error
+// if the resulting Options are not valid.
+func New(options ...Option) (*Differ, error) {
+	var opts Options
+	for _, o := range options {
+		o(&opts)
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	return newDiffer(opts), nil
+}
+
+// WithOptions replaces all settings with base, e.g. one of the Default
+// presets. It is usually the first option.
+func WithOptions(base Options) Option {
+	return func(opts *Options) {
+		*opts = base
+	}
+}
+
+// WithTags sets the tags of added, removed and changed values.
+func WithTags(added, removed, changed Tag) Option {
+	return func(opts *Options) {
+		opts.Added, opts.Removed, opts.Changed = added, removed, changed
+	}
+}
+
+// WithNormal sets the tag of unchanged values.
+func WithNormal(normal Tag) Option {
+	return func(opts *Options) {
+		opts.Normal = normal
+	}
+}
+
+// WithIndent sets the string written once per nesting level.
+func WithIndent(indent string) Option {
+	return func(opts *Options) {
+		opts.Indent = indent
+	}
+}
+
+// WithPrefix sets the string written at the start of every line.
+func WithPrefix(prefix string) Option {
+	return func(opts *Options) {
+		opts.Prefix = prefix
+	}
+}
+
+// WithIgnoreFields adds fields to IgnoreFields, removing them from
+// FuzzyFields and StringAsMapFields.
+func WithIgnoreFields(fields ...string) Option {
+	return func(opts *Options) {
+		opts.FuzzyFields = without(opts.FuzzyFields, fields)
+		opts.StringAsMapFields = without(opts.StringAsMapFields, fields)
+		opts.IgnoreFields = with(opts.IgnoreFields, fields)
+	}
+}
+
+// WithFuzzyFields adds fields to FuzzyFields, removing them from
+// IgnoreFields and StringAsMapFields.
+func WithFuzzyFields(fields ...string) Option {
+	return func(opts *Options) {
+		opts.IgnoreFields = without(opts.IgnoreFields, fields)
+		opts.StringAsMapFields = without(opts.StringAsMapFields, fields)
+		opts.FuzzyFields = with(opts.FuzzyFields, fields)
+	}
+}
+
+// WithStringAsMapFields adds fields to StringAsMapFields, removing them from
+// IgnoreFields and FuzzyFields.
+func WithStringAsMapFields(fields ...string) Option {
+	return func(opts *Options) {
+		opts.IgnoreFields = without(opts.IgnoreFields, fields)
+		opts.FuzzyFields = without(opts.FuzzyFields, fields)
+		opts.StringAsMapFields = with(opts.StringAsMapFields, fields)
+	}
+}
+
+// WithNullAsEmpty sets NullAsEmpty.
+func WithNullAsEmpty() Option {
+	return func(opts *Options) {
+		opts.NullAsEmpty = true
+	}
+}
+
+// WithPrintTypes sets PrintTypesMode.
+func WithPrintTypes(mode PrintTypesMode) Option {
+	return func(opts *Options) {
+		opts.PrintTypesMode = mode
+	}
+}
+
+// WithMaxOutputBytes sets MaxOutputBytes.
+func WithMaxOutputBytes(n int) Option {
+	return func(opts *Options) {
+		opts.MaxOutputBytes = n
+	}
+}
+
+// Clone returns a deep copy of opts: slices and maps are copied, so that
+// changing them in either Options does not affect the other. Functions such
+// as OnDifference are shared.
+func (opts Options) Clone() Options {
+	out := opts
+	v := reflect.ValueOf(&out).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		v.Field(i).Set(cloneValue(v.Field(i)))
+	}
+	return out
+}
+
+// cloneValue returns a copy of a slice or map, or v itself for other kinds.
+func cloneValue(v reflect.Value) reflect.Value {
+	switch {
+	case v.Kind() == reflect.Slice && !v.IsNil():
+		c := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		reflect.Copy(c, v)
+		return c
+	case v.Kind() == reflect.Map && !v.IsNil():
+		c := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			c.SetMapIndex(iter.Key(), iter.Value())
+		}
+		return c
+	}
+	return v
+}
+
+// SliceMerge selects how Merge combines slices and maps set in both of its
+// Options.
+type SliceMerge int
+
+const (
+	// ReplaceSlices uses the slices and maps of the override.
+	ReplaceSlices SliceMerge = iota
+	// AppendSlices appends the elements of override slices missing from
+	// the base slices, and adds the entries of override maps to the base
+	// maps, replacing the values of keys present in both.
+	AppendSlices
+)
+
+// Merge returns base with every field that is not the zero value in override
+// replaced by the override, e.g. to layer per-endpoint IgnoreFields on top of
+// shared tags and indentation. Tags count as set if either of their strings
+// is. Slices and maps set in override are combined with those of base as
+// selected by slices. A field can only be turned on by override, not reset
+// to its zero value, such as false. The result shares no slices or maps with
+// base or override; it may need Validate, e.g. if a field ends up in both
+// IgnoreFields and FuzzyFields.
+func Merge(base, override Options, slices SliceMerge) Options {
+	out := base.Clone()
+	v := reflect.ValueOf(&out).Elem()
+	ov := reflect.ValueOf(override)
+	for i := 0; i < v.NumField(); i++ {
+		f, o := v.Field(i), ov.Field(i)
+		if o.IsZero() {
+			continue
+		}
+		switch {
+		case slices == AppendSlices && o.Kind() == reflect.Slice:
+			for j := 0; j < o.Len(); j++ {
+				if !containsValue(f, o.Index(j)) {
+					f.Set(reflect.Append(f, o.Index(j)))
+				}
+			}
+		case slices == AppendSlices && o.Kind() == reflect.Map && !f.IsNil():
+			iter := o.MapRange()
+			for iter.Next() {
+				f.SetMapIndex(iter.Key(), iter.Value())
+			}
+		default:
+			f.Set(cloneValue(o))
+		}
+	}
+	return out
+}
+
+// containsValue reports whether the slice s holds an element equal to e.
+func containsValue(s, e reflect.Value) bool {
+	for i := 0; i < s.Len(); i++ {
+		if s.Index(i).Interface() == e.Interface() {
+			return true
+		}
+	}
+	return false
+}
+
+// with returns a new slice holding the fields of s followed by those of add
+// that are not in s yet. s itself is never modified, as it may be shared with
+// other Options.
+func with(s, add []string) []string {
+	set := sliceToSet(s)
+	out := append([]string(nil), s...)
+	for _, f := range add {
+		if _, ok := set[f]; !ok {
+			set[f] = struct{}{}
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// without returns a new slice holding the fields of s not in remove.
+func without(s, remove []string) []string {
+	set := sliceToSet(remove)
+	var out []string
+	for _, f := range s {
+		if _, ok := set[f]; !ok {
+			out = append(out, f)
+		}
+	}
+	return out
+}
+
+// Validate reports every setting of opts that cannot work, joined with
+// errors.Join: negative limits, an unknown PrintTypesMode, InvalidUTF8 mode,
+// SARIF level or Severity, negative, infinite or NaN Weights, PrintTypes
+// with PrintTypesNever, a field listed in more than one of IgnoreFields,
+// FuzzyFields and StringAsMapFields, unknown placeholders in tags, tags with
+// unbalanced markup when EscapeHTML is set, and a PathComment spanning
+// lines.
+func (opts *Options) Validate() error {
+	var errs []error
+	for _, limit := range []struct {
+		name string
+		n    int64
+	}{
+		{"MaxOutputBytes", int64(opts.MaxOutputBytes)},
+		{"MaxDifferences", int64(opts.MaxDifferences)},
+		{"MaxDisplayDepth", int64(opts.MaxDisplayDepth)},
+		{"InlineStringDiffMaxLen", int64(opts.InlineStringDiffMaxLen)},
+		{"Parallelism", int64(opts.Parallelism)},
+		{"MaxDepth", int64(opts.MaxDepth)},
+		{"MaxMemoryBytes", opts.MaxMemoryBytes},
+	} {
+		if limit.n < 0 {
+			errs = append(errs, fmt.Errorf("jsondiff: negative %s %d", limit.name, limit.n))
+		}
+	}
+
+	if opts.PrintTypesMode < PrintTypesAuto || opts.PrintTypesMode > PrintTypesNever {
+		errs = append(errs, fmt.Errorf("jsondiff: unknown PrintTypesMode %d", opts.PrintTypesMode))
+	}
+	if opts.InvalidUTF8 < InvalidUTF8Replace || opts.InvalidUTF8 > InvalidUTF8Pass {
+		errs = append(errs, fmt.Errorf("jsondiff: unknown InvalidUTF8 mode %d", opts.InvalidUTF8))
+	}
+	if opts.PrintTypes && opts.PrintTypesMode == PrintTypesNever {
+		errs = append(errs, errors.New("jsondiff: PrintTypes is set but PrintTypesMode is PrintTypesNever"))
+	}
+
+	for _, kind := range []DiffKind{KindAdded, KindRemoved, KindChanged} {
+		switch level := opts.SARIFLevels[kind]; level {
+		case "", "none", "note", "warning", "error":
+		default:
+			errs = append(errs, fmt.Errorf("jsondiff: unknown SARIF level %q for %s", level, kind))
+		}
+	}
+	var graded []string
+	for f, s := range opts.Severities {
+		if s != SeverityFail && s != SeverityWarn {
+			graded = append(graded, f)
+		}
+	}
+	sort.Strings(graded)
+	for _, f := range graded {
+		errs = append(errs, fmt.Errorf("jsondiff: unknown %s for %q", opts.Severities[f], f))
+	}
+	var weighted []string
+	for f, w := range opts.Weights {
+		if !(w >= 0) || math.IsInf(w, 1) {
+			weighted = append(weighted, f)
+		}
+	}
+	sort.Strings(weighted)
+	for _, f := range weighted {
+		errs = append(errs, fmt.Errorf("jsondiff: invalid weight %v for %q", opts.Weights[f], f))
+	}
+
+	lists := []struct {
+		name   string
+		fields []string
+	}{
+		{"IgnoreFields", opts.IgnoreFields},
+		{"FuzzyFields", opts.FuzzyFields},
+		{"StringAsMapFields", opts.StringAsMapFields},
+	}
+	seen := make(map[string]string)
+	for _, list := range lists {
+		for _, f := range list.fields {
+			if name, ok := seen[f]; ok && name != list.name {
+				errs = append(errs, fmt.Errorf("jsondiff: field %q is in both %s and %s", f, name, list.name))
+			}
+			seen[f] = list.name
+		}
+	}
+
+	for _, tag := range []struct {
+		name string
+		tag  *Tag
+	}{
+		{"Normal", &opts.Normal},
+		{"Added", &opts.Added},
+		{"Removed", &opts.Removed},
+		{"Changed", &opts.Changed},
+		{"Warning", &opts.Warning},
+	} {
+		for _, s := range []string{tag.tag.Begin, tag.tag.End} {
+			for _, m := range placeholderPattern.FindAllString(s, -1) {
+				if !isPlaceholder(m) {
+					errs = append(errs, fmt.Errorf("jsondiff: unknown placeholder %s in the %s tag", m, tag.name))
+				}
+			}
+		}
+		if opts.EscapeHTML && !balancedMarkup(tag.tag.Begin+tag.tag.End) {
+			errs = append(errs, fmt.Errorf("jsondiff: unbalanced markup in the %s tag", tag.name))
+		}
+	}
+
+	if strings.ContainsAny(opts.PathComment, "\r\n") {
+		errs = append(errs, errors.New("jsondiff: PathComment contains a line break"))
+	}
+	return errors.Join(errs...)
+}
+
+var placeholderPattern = regexp.MustCompile(`\{[a-z]+\}`)
+
+// balancedMarkup reports whether every '<' in s is closed by a '>' before the
+// next '<'.
+func balancedMarkup(s string) bool {
+	open := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '<':
+			if open {
+				return false
+			}
+			open = true
+		case '>':
+			if !open {
+				return false
+			}
+			open = false
+		}
+	}
+	return !open
+}
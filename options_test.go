@@ -0,0 +1,296 @@
+package jsondiff
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNewMatchesOptions(t *testing.T) {
+	base := DefaultConsoleOptions()
+	d, err := New(
+		WithOptions(base),
+		WithIgnoreFields("id", "etag"),
+		WithFuzzyFields("updated"),
+		WithStringAsMapFields("doc"),
+		WithNullAsEmpty(),
+		WithIndent("  "),
+		WithPrefix("> "),
+		WithTags(Tag{Begin: "+"}, Tag{Begin: "-"}, Tag{Begin: "~"}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := base
+	opts.IgnoreFields = []string{"id", "etag"}
+	opts.FuzzyFields = []string{"updated"}
+	opts.StringAsMapFields = []string{"doc"}
+	opts.NullAsEmpty = true
+	opts.Indent = "  "
+	opts.Prefix = "> "
+	opts.Added, opts.Removed, opts.Changed = Tag{Begin: "+"}, Tag{Begin: "-"}, Tag{Begin: "~"}
+
+	a := `{"id": 1, "etag": "x", "updated": 1, "doc": "{\"x\": 1}", "list": null, "n": 1}`
+	b := `{"id": 2, "etag": "y", "updated": 2, "doc": "{\"x\": 2}", "list": [], "n": 2, "new": true}`
+	diff1, text1 := d.Compare([]byte(a), []byte(b))
+	diff2, text2 := Compare([]byte(a), []byte(b), &opts)
+	if diff1 != diff2 || text1 != text2 {
+		t.Errorf("got %s:\n%s\nexpected %s:\n%s", diff1, text1, diff2, text2)
+	}
+}
+
+func TestOptionsLastWins(t *testing.T) {
+	shared := make([]string, 1, 4)
+	shared[0] = "a"
+	var opts Options
+	for _, o := range []Option{
+		WithOptions(Options{IgnoreFields: shared}),
+		WithIgnoreFields("b", "a"),
+		WithFuzzyFields("b"),
+		WithIndent("\t"),
+		WithIndent("  "),
+	} {
+		o(&opts)
+	}
+	if !reflect.DeepEqual(opts.IgnoreFields, []string{"a"}) || !reflect.DeepEqual(opts.FuzzyFields, []string{"b"}) {
+		t.Errorf("got ignored %q and fuzzy %q", opts.IgnoreFields, opts.FuzzyFields)
+	}
+	if opts.Indent != "  " {
+		t.Errorf("got indent %q", opts.Indent)
+	}
+	if shared[:2][1] != "" {
+		t.Errorf("shared slice was modified: %q", shared[:2])
+	}
+	if err := opts.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	cases := []struct {
+		opts Options
+		err  string
+	}{
+		{Options{MaxOutputBytes: -1}, "negative MaxOutputBytes"},
+		{Options{MaxDisplayDepth: -2}, "negative MaxDisplayDepth"},
+		{Options{PrintTypesMode: 7}, "unknown PrintTypesMode"},
+		{Options{SARIFLevels: map[DiffKind]string{KindAdded: "fatal"}}, `unknown SARIF level "fatal" for added`},
+		{Options{IgnoreFields: []string{"x"}, FuzzyFields: []string{"y", "x"}}, `"x" is in both IgnoreFields and FuzzyFields`},
+		{Options{PrintTypes: true, PrintTypesMode: PrintTypesNever}, "PrintTypes is set but PrintTypesMode is PrintTypesNever"},
+		{Options{Added: Tag{Begin: "<b title={paht}>"}}, "unknown placeholder {paht} in the Added tag"},
+		{Options{EscapeHTML: true, Changed: Tag{Begin: `<span class="x"`, End: "</span>"}}, "unbalanced markup in the Changed tag"},
+		{Options{PathComment: "\n# "}, "PathComment contains a line break"},
+	}
+	for _, c := range cases {
+		err := c.opts.Validate()
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("got %v, expected %q", err, c.err)
+		}
+	}
+	if _, err := New(WithMaxOutputBytes(-5)); err == nil {
+		t.Error("New accepted invalid options")
+	}
+	opts := DefaultHTMLOptions()
+	if err := opts.Validate(); err != nil {
+		t.Error(err)
+	}
+
+	opts = Options{MaxOutputBytes: -1, InlineStringDiffMaxLen: -1, PrintTypesMode: -1}
+	err := opts.Validate()
+	if err == nil || strings.Count(err.Error(), "\n") != 2 {
+		t.Errorf("got %v, expected three problems", err)
+	}
+	if d, err := NewDiffer(opts); d != nil || err == nil {
+		t.Error("NewDiffer accepted invalid options")
+	}
+}
+
+func TestValidateComplexOptions(t *testing.T) {
+	opts := DefaultHTMLClassOptions()
+	opts.Added.Begin = `<span class="jsondiff-added" data-path="{path}" title="{kind} {type}">`
+	opts.IgnoreFields = []string{"etag"}
+	opts.FuzzyFields = []string{"updated"}
+	opts.StringAsMapFields = []string{"payload"}
+	opts.MaxOutputBytes = 1 << 20
+	opts.MaxDisplayDepth = 4
+	opts.PrintTypesMode = PrintTypesOnMismatch
+	opts.ShowPaths = true
+	opts.SARIFLevels = map[DiffKind]string{KindAdded: "note", KindChanged: "warning"}
+	for _, o := range []Options{opts, DefaultConsoleOptions(), DefaultSymbolOptions(), {}} {
+		if err := o.Validate(); err != nil {
+			t.Error(err)
+		}
+	}
+	if _, err := NewDiffer(opts); err != nil {
+		t.Error(err)
+	}
+}
+
+// filledOptions returns Options with every field set to a value that is not
+// the zero value, derived from seed, so that tests cover fields added later.
+func filledOptions(t *testing.T, seed string) Options {
+	var opts Options
+	v := reflect.ValueOf(&opts).Elem()
+	for i := 0; i < v.NumField(); i++ {
+		if !fill(v.Field(i), seed+v.Type().Field(i).Name) {
+			t.Fatalf("cannot fill field %s of kind %s", v.Type().Field(i).Name, v.Field(i).Kind())
+		}
+	}
+	return opts
+}
+
+func fill(v reflect.Value, seed string) bool {
+	switch v.Kind() {
+	case reflect.Bool:
+		v.SetBool(true)
+	case reflect.Int, reflect.Int64:
+		v.SetInt(int64(len(seed)))
+	case reflect.Float64:
+		v.SetFloat(float64(len(seed)))
+	case reflect.String:
+		v.SetString(seed)
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			if !fill(v.Field(i), seed+v.Type().Field(i).Name) {
+				return false
+			}
+		}
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if !fill(v.Index(i), seed+strconv.Itoa(i)) {
+				return false
+			}
+		}
+	case reflect.Slice:
+		e := reflect.New(v.Type().Elem()).Elem()
+		if !fill(e, seed) {
+			return false
+		}
+		v.Set(reflect.Append(reflect.MakeSlice(v.Type(), 0, 1), e))
+	case reflect.Map:
+		k, e := reflect.New(v.Type().Key()).Elem(), reflect.New(v.Type().Elem()).Elem()
+		if !fill(k, seed) || !fill(e, seed) {
+			return false
+		}
+		v.Set(reflect.MakeMap(v.Type()))
+		v.SetMapIndex(k, e)
+	case reflect.Func:
+		v.Set(reflect.MakeFunc(v.Type(), func([]reflect.Value) []reflect.Value { return nil }))
+	default:
+		return false
+	}
+	return true
+}
+
+// sharesMemory reports whether any slice or map field of a and b is the
+// same.
+func sharesMemory(a, b Options) string {
+	va, vb := reflect.ValueOf(a), reflect.ValueOf(b)
+	for i := 0; i < va.NumField(); i++ {
+		fa, fb := va.Field(i), vb.Field(i)
+		if (fa.Kind() == reflect.Slice || fa.Kind() == reflect.Map) && !fa.IsNil() && fa.Pointer() == fb.Pointer() {
+			return va.Type().Field(i).Name
+		}
+	}
+	return ""
+}
+
+func TestOptionsClone(t *testing.T) {
+	opts := filledOptions(t, "a")
+	clone := opts.Clone()
+	if name := sharesMemory(opts, clone); name != "" {
+		t.Errorf("%s is shared by the clone", name)
+	}
+	v, c := reflect.ValueOf(opts), reflect.ValueOf(clone)
+	for i := 0; i < v.NumField(); i++ {
+		if v.Field(i).Kind() == reflect.Func {
+			if v.Field(i).Pointer() != c.Field(i).Pointer() {
+				t.Errorf("%s differs", v.Type().Field(i).Name)
+			}
+		} else if !reflect.DeepEqual(v.Field(i).Interface(), c.Field(i).Interface()) {
+			t.Errorf("%s differs", v.Type().Field(i).Name)
+		}
+	}
+	clone.IgnoreFields[0] = "changed"
+	clone.SARIFLevels[KindAdded] = "changed"
+	if opts.IgnoreFields[0] == "changed" || opts.SARIFLevels[KindAdded] == "changed" {
+		t.Error("changing the clone changed the original")
+	}
+	if (Options{}).Clone().IgnoreFields != nil {
+		t.Error("a nil slice was cloned as an empty one")
+	}
+}
+
+func TestMerge(t *testing.T) {
+	base := DefaultConsoleOptions()
+	base.NullAsEmpty = true
+	base.IgnoreFields = []string{"id"}
+	base.SARIFLevels = map[DiffKind]string{KindAdded: "note", KindRemoved: "warning"}
+	override := Options{
+		Indent:       "  ",
+		Changed:      Tag{Begin: "~"},
+		IgnoreFields: []string{"etag", "id"},
+		SARIFLevels:  map[DiffKind]string{KindAdded: "error"},
+	}
+
+	got := Merge(base, override, ReplaceSlices)
+	expected := DefaultConsoleOptions()
+	expected.NullAsEmpty = true
+	expected.Indent = "  "
+	expected.Changed = Tag{Begin: "~"}
+	expected.IgnoreFields = []string{"etag", "id"}
+	expected.SARIFLevels = map[DiffKind]string{KindAdded: "error"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("ReplaceSlices: got %+v, expected %+v", got, expected)
+	}
+
+	got = Merge(base, override, AppendSlices)
+	expected.IgnoreFields = []string{"id", "etag"}
+	expected.SARIFLevels = map[DiffKind]string{KindAdded: "error", KindRemoved: "warning"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("AppendSlices: got %+v, expected %+v", got, expected)
+	}
+	if base.IgnoreFields[0] != "id" || len(base.IgnoreFields) != 1 || base.SARIFLevels[KindAdded] != "note" {
+		t.Errorf("base was modified: %+v", base)
+	}
+}
+
+func TestMergeEveryField(t *testing.T) {
+	base, override := filledOptions(t, "base"), filledOptions(t, "override")
+	for _, mode := range []SliceMerge{ReplaceSlices, AppendSlices} {
+		got := Merge(base, override, mode)
+		if name := sharesMemory(got, base); name != "" {
+			t.Errorf("%s is shared with base", name)
+		}
+		if name := sharesMemory(got, override); name != "" {
+			t.Errorf("%s is shared with override", name)
+		}
+		v, o := reflect.ValueOf(got), reflect.ValueOf(override)
+		for i := 0; i < v.NumField(); i++ {
+			name := v.Type().Field(i).Name
+			switch f := v.Field(i); f.Kind() {
+			case reflect.Func:
+				if f.Pointer() != o.Field(i).Pointer() {
+					t.Errorf("%s was not overridden", name)
+				}
+			case reflect.Slice:
+				if n := f.Len(); mode == AppendSlices && n != 2 || mode == ReplaceSlices && n != 1 {
+					t.Errorf("%s has %d elements", name, n)
+				}
+			case reflect.Map:
+				if n := f.Len(); mode == AppendSlices && n != 2 || mode == ReplaceSlices && n != 1 {
+					t.Errorf("%s has %d entries", name, n)
+				}
+			default:
+				if !reflect.DeepEqual(f.Interface(), o.Field(i).Interface()) {
+					t.Errorf("%s was not overridden", name)
+				}
+			}
+		}
+		// Nothing set in the override keeps base as it is.
+		if got := Merge(base, Options{}, mode); sharesMemory(got, base) != "" || !reflect.DeepEqual(got.IgnoreFields, base.IgnoreFields) {
+			t.Errorf("merging zero Options changed base")
+		}
+	}
+}
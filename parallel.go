@@ -0,0 +1,177 @@
+package jsondiff
+
+import (
+	"bytes"
+	"sync"
+)
+
+// renderedMember is a member of an object rendered by a worker of
+// printMembersParallel, with the marks and guides recorded in its buffer.
+// The zero renderedMember is one that matches and is not written.
+type renderedMember struct {
+	diff   Difference
+	buf    *bytes.Buffer
+	line   lineState
+	marks  []outputMark
+	guides []guideMark
+}
+
+// parallel reports whether the members with the given keys of the object
+// being compared are compared by several goroutines. Only the root object
+// of the outermost document is, and only when the order the members are
+// compared in can't be observed: differences are neither streamed nor
+// reported to OnDifference nor limited by MaxDifferences, the output is not
+// limited by MaxOutputBytes, and tags have no placeholders, whose expansion
+// depends on the members written before.
+func (ctx *context) parallel(keys []string) bool {
+	return ctx.opts.Parallelism > 1 && len(keys) > 1 && len(ctx.path) == 0 && ctx.basePath == "" &&
+		ctx.emit == nil && ctx.opts.OnDifference == nil && ctx.opts.MaxDifferences == 0 &&
+		ctx.limit == 0 && !ctx.templates
+}
+
+// printMembersParallel is printMembers with the keys split into runs
+// compared by up to opts.Parallelism workers. Each worker renders the
+// members of its run that differ into buffers of their own, which are then
+// appended to buf in key order like printMembers does.
+func (ctx *context) printMembersParallel(buf *bytes.Buffer, keys []string, ma, mb map[string]interface{}) Difference {
+	compared := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if _, found := ctx.ignoreFields[k]; !found {
+			compared = append(compared, k)
+		}
+	}
+	n := ctx.opts.Parallelism
+	if n > len(compared) {
+		n = len(compared)
+	}
+	members := make([]renderedMember, len(compared))
+	workers := make([]*context, n)
+	memory := ctx.memory
+	var wg sync.WaitGroup
+	for i := range workers {
+		w := ctx.fork()
+		workers[i] = w
+		lo, hi := i*len(compared)/n, (i+1)*len(compared)/n
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			w.renderMembers(compared[lo:hi], ma, mb, members[lo:hi])
+		}()
+	}
+	wg.Wait()
+
+	mDiff := FullMatch
+	isFirstKey := true
+	for _, m := range members {
+		if m.diff == FullMatch {
+			continue
+		}
+		ctx.marks = append(ctx.marks, m.marks...)
+		ctx.guides = append(ctx.guides, m.guides...)
+		ctx.appendItem(buf, m.buf, m.line, isFirstKey)
+		ctx.chargeOutput(buf)
+		isFirstKey = false
+		mDiff = combine(mDiff, m.diff)
+		putItemBuf(m.buf)
+	}
+	for _, w := range workers {
+		ctx.absorb(w)
+		ctx.charge(w.memory - memory)
+	}
+	return mDiff
+}
+
+// renderMembers renders the members of ma and mb with the given keys and
+// stores those that differ in out, which is indexed like keys. Each member
+// is rendered into a buffer of its own, as the first item of its object. The
+// buffer starts with the Begin of the tag open before the member, which the
+// member may take back like printMembers would.
+func (ctx *context) renderMembers(keys []string, ma, mb map[string]interface{}, out []renderedMember) {
+	itemBuf := getItemBuf()
+	for i, k := range keys {
+		if ctx.err != nil {
+			break
+		}
+		if itemBuf.Len() == 0 {
+			ctx.reopenTag(itemBuf)
+		}
+		start := ctx.beginItem(itemBuf, true)
+		itemDiff := ctx.printMember(itemBuf, k, ma, mb)
+		ctx.chargeOutput(itemBuf)
+		if itemDiff == FullMatch {
+			ctx.rollback(itemBuf, start)
+			continue
+		}
+		out[i] = renderedMember{diff: itemDiff, buf: itemBuf, line: ctx.lineState, marks: ctx.marks, guides: ctx.guides}
+		ctx.lineState = start.line
+		ctx.marks, ctx.guides = nil, nil
+		itemBuf = getItemBuf()
+	}
+	putItemBuf(itemBuf)
+}
+
+// appendItem appends a member rendered by renderMembers, which differs, to
+// buf, preceded by a separator unless it is the first one, and continues on
+// the line the member ends on. The Begin the member starts with stands for
+// the one written to buf before it, which is taken back.
+func (ctx *context) appendItem(buf, itemBuf *bytes.Buffer, itemLine lineState, first bool) {
+	if !first {
+		ctx.newline(buf, ",")
+	}
+	ctx.closeTag(buf)
+	off := buf.Len()
+	ctx.commit(buf, itemBuf)
+	ctx.lineState = itemLine
+	if ctx.opened == itemBuf {
+		ctx.opened, ctx.openedAt = buf, off+ctx.openedAt
+	}
+	ctx.tag(buf, &ctx.opts.Normal)
+}
+
+// fork returns a context for comparing members of the object ctx is at on
+// another goroutine. It shares the configuration and the decoded documents
+// with ctx, but nothing it changes.
+func (ctx *context) fork() *context {
+	return &context{
+		lineState:         ctx.lineState,
+		opts:              ctx.opts,
+		differ:            ctx.differ,
+		level:             ctx.level,
+		curKey:            ctx.curKey,
+		fuzzyFields:       ctx.fuzzyFields,
+		ignoreFields:      ctx.ignoreFields,
+		stringAsMapFields: ctx.stringAsMapFields,
+		order:             ctx.order,
+		basePath:          ctx.basePath,
+		path:              append([]string(nil), ctx.path...),
+		collect:           ctx.collect,
+		templates:         ctx.templates,
+		tracking:          ctx.tracking,
+		masked:            ctx.masked,
+		warn:              ctx.warn,
+		positions:         ctx.positions,
+		outer:             ctx.outer,
+		countLeaves:       ctx.countLeaves,
+		cancelErr:         ctx.cancelErr,
+		memory:            ctx.memory,
+	}
+}
+
+// absorb adds what the forked context w found to ctx, as if ctx had
+// compared the members w did. Workers are absorbed in key order.
+func (ctx *context) absorb(w *context) {
+	ctx.stats.merge(w.stats, 0)
+	ctx.summary.add(w.summary)
+	ctx.leafCounts.add(w.leafCounts)
+	ctx.entries = append(ctx.entries, w.entries...)
+	ctx.duplicates = append(ctx.duplicates, w.duplicates...)
+	ctx.warnings += w.warnings
+	if !ctx.mismatched && w.mismatched {
+		ctx.mismatched = true
+		ctx.firstPath = w.firstPath
+	}
+	if ctx.err == nil {
+		ctx.err = w.err
+	}
+	ctx.result(w.diff)
+}
@@ -0,0 +1,98 @@
+package jsondiff
+
+import (
+	"bytes"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+// randomDocuments returns n pairs of objects with up to 8 random members,
+// the second of each a mutated copy of the first.
+func randomDocuments(rnd *rand.Rand, n int) [][2][]byte {
+	docs := make([][2][]byte, n)
+	for i := range docs {
+		v := make(map[string]interface{})
+		for j := rnd.Intn(9); j > 0; j-- {
+			v["k"+strconv.Itoa(rnd.Intn(8))] = randomValue(rnd, 3)
+		}
+		v["fuzz1"] = randomValue(rnd, 1)
+		var a, b bytes.Buffer
+		shuffledJSON(rnd, &a, v)
+		shuffledJSON(rnd, &b, mutate(rnd, v))
+		docs[i] = [2][]byte{a.Bytes(), b.Bytes()}
+	}
+	return docs
+}
+
+func TestParallelism(t *testing.T) {
+	base := DefaultConsoleOptions()
+	base.IgnoreFields = []string{"fuzz1"}
+	base.FuzzyFields = []string{"fuzz2"}
+	base.StringAsMapFields = []string{"stringAsMap"}
+	base.NullAsEmpty = true
+	variants := []func(*Options){
+		func(*Options) {},
+		func(o *Options) { o.PreserveKeyOrder = true },
+		func(o *Options) { o.ShowPaths, o.ShowSummary, o.ShowPositions = true, true, true },
+		func(o *Options) { o.TreeGuides = true },
+		func(o *Options) { o.MaxOutputBytes = 300 },
+		func(o *Options) { o.InlineStringDiff, o.CollapseAddedRemoved = true, true },
+		func(o *Options) { o.NoOutput = true },
+		func(o *Options) { o.Normal = Tag{Begin: "<n>", End: "</n>"} },
+		func(o *Options) { o.Normal, o.TreeGuides, o.MaxOutputBytes = Tag{Begin: "<n>", End: "</n>"}, true, 300 },
+	}
+	docs := randomDocuments(rand.New(rand.NewSource(1)), 300)
+	for _, c := range cases {
+		docs = append(docs, [2][]byte{[]byte(c.a), []byte(c.b)})
+	}
+	wideA, wideB := largeObjects(2000)
+	docs = append(docs, [2][]byte{wideA, wideB})
+	for i, variant := range variants {
+		opts := base
+		variant(&opts)
+		for _, workers := range []int{2, 3, 16} {
+			parallel := opts
+			parallel.Parallelism = workers
+			for _, doc := range docs {
+				expected := CompareDetail(doc[0], doc[1], &opts)
+				got := CompareDetail(doc[0], doc[1], &parallel)
+				expected.Stats.Duration, got.Stats.Duration = 0, 0
+				if !reflect.DeepEqual(got, expected) {
+					t.Fatalf("variant %d, %d workers: %s, %s:\ngot      %+v\nexpected %+v", i, workers, doc[0], doc[1], got, expected)
+				}
+			}
+		}
+	}
+}
+
+func TestParallelismRelationship(t *testing.T) {
+	a, b := largeObjects(100)
+	opts := Options{}
+	parallel := Options{Parallelism: 4}
+	rel, counts, err := Relationship(a, b, &opts)
+	prel, pcounts, perr := Relationship(a, b, &parallel)
+	if rel != prel || counts != pcounts || err != perr {
+		t.Errorf("got %v, %+v, %v, expected %v, %+v, %v", prel, pcounts, perr, rel, counts, err)
+	}
+}
+
+func TestParallelismValidate(t *testing.T) {
+	opts := Options{Parallelism: -1}
+	if err := opts.Validate(); err == nil {
+		t.Error("negative Parallelism accepted")
+	}
+}
+
+func BenchmarkCompareParallel(b *testing.B) {
+	docA, docB := largeObjects(50000)
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("Parallelism=%d", workers), func(b *testing.B) {
+			opts := DefaultConsoleOptions()
+			opts.Parallelism = workers
+			benchmarkCompareDocuments(b, opts, docA, docB)
+		})
+	}
+}
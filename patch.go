@@ -0,0 +1,321 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// PatchError reports why an operation of a JSON Patch could not be applied.
+type PatchError struct {
+	// Index is the position of the operation in the patch, from 0, or -1
+	// if the patch itself is malformed.
+	Index int
+	Op    string
+	Path  string
+	Msg   string
+}
+
+func (e *PatchError) Error() string {
+	if e.Index < 0 {
+		return "jsondiff: patch: " + e.Msg
+	}
+	return fmt.Sprintf("jsondiff: patch operation %d (%s %q): %s", e.Index, e.Op, e.Path, e.Msg)
+}
+
+// ApplyPatch applies the JSON Patch (RFC 6902) patch to the JSON document doc
+// and returns the patched document, compact, with object keys sorted and
+// numbers written as they appear in the input. The add, remove, replace and
+// test operations are supported; "-" as the last token of an add path
+// appends to an array. Values are compared by test as RFC 6902 requires:
+// numbers by value, so that 1 equals 1.0 and 1e0, and objects whatever the
+// order of their members. If doc or patch is not valid JSON, the error is an
+// *InvalidJSONError for document 1 or 2 respectively; if an operation is
+// malformed or cannot be applied, it is a *PatchError and nothing is
+// returned.
+func ApplyPatch(doc, patch []byte) ([]byte, error) {
+	v, err := decode(doc, nil)
+	if err != nil {
+		return nil, newInvalidJSONError(1, doc, err)
+	}
+	p, err := decode(patch, nil)
+	if err != nil {
+		return nil, newInvalidJSONError(2, patch, err)
+	}
+	ops, ok := p.([]interface{})
+	if !ok {
+		return nil, &PatchError{Index: -1, Msg: "patch is not an array"}
+	}
+	for i, o := range ops {
+		var perr *PatchError
+		if v, perr = applyOp(v, o); perr != nil {
+			perr.Index = i
+			return nil, perr
+		}
+	}
+	return encodeCompact(v)
+}
+
+// encodeCompact encodes the decoded document v as compact JSON with object
+// keys sorted, without escaping HTML characters.
+func encodeCompact(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// VerifyPatch applies patch to a and compares the result with b using opts.
+// FullMatch means the patch turns a into b; otherwise the Difference and
+// the rendered text describe what the patch got wrong, from the patched
+// document to b. The error is that of ApplyPatch, or an *InvalidJSONError
+// for document 2 if b is not valid JSON.
+func VerifyPatch(a, b, patch []byte, opts *Options) (Difference, string, error) {
+	patched, err := ApplyPatch(a, patch)
+	if err != nil {
+		return NoMatch, "", err
+	}
+	return CompareErr(patched, b, opts)
+}
+
+// applyOp applies the operation o to doc and returns the changed document.
+func applyOp(doc, o interface{}) (interface{}, *PatchError) {
+	op, ok := o.(map[string]interface{})
+	if !ok {
+		return nil, &PatchError{Msg: "operation is not an object"}
+	}
+	name, _ := op["op"].(string)
+	path, ok := op["path"].(string)
+	if !ok {
+		return nil, &PatchError{Op: name, Msg: "missing path"}
+	}
+	fail := func(format string, args ...interface{}) (interface{}, *PatchError) {
+		return nil, &PatchError{Op: name, Path: path, Msg: fmt.Sprintf(format, args...)}
+	}
+	tokens, err := parsePointer(path)
+	if err != nil {
+		return fail("%v", err)
+	}
+	value, hasValue := op["value"]
+	switch name {
+	case "add", "replace", "test":
+		if !hasValue {
+			return fail("missing value")
+		}
+	case "remove":
+	default:
+		return fail("unsupported operation")
+	}
+	if len(tokens) == 0 {
+		// The whole document.
+		switch name {
+		case "add", "replace":
+			return value, nil
+		case "test":
+			if !patchEqual(doc, value) {
+				return fail("value differs")
+			}
+			return doc, nil
+		}
+		return fail("cannot remove the whole document")
+	}
+	parent, err := resolvePointer(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return fail("%v", err)
+	}
+	last := tokens[len(tokens)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		old, exists := container[last]
+		switch {
+		case name == "add":
+			container[last] = value
+		case !exists:
+			return fail("member %q not found", last)
+		case name == "remove":
+			delete(container, last)
+		case name == "replace":
+			container[last] = value
+		case !patchEqual(old, value):
+			return fail("value differs")
+		}
+		return doc, nil
+	case []interface{}:
+		n := len(container)
+		if name == "add" {
+			n++
+		}
+		i, err := arrayIndex(last, n, name == "add")
+		if err != nil {
+			return fail("%v", err)
+		}
+		var changed []interface{}
+		switch name {
+		case "add":
+			changed = append(container[:i:i], append([]interface{}{value}, container[i:]...)...)
+		case "remove":
+			changed = append(container[:i:i], container[i+1:]...)
+		case "replace":
+			container[i] = value
+			return doc, nil
+		default:
+			if !patchEqual(container[i], value) {
+				return fail("value differs")
+			}
+			return doc, nil
+		}
+		// The array itself changed and has to be replaced in its parent.
+		return setPointer(doc, tokens[:len(tokens)-1], changed), nil
+	}
+	return fail("parent is not an object or array")
+}
+
+// parsePointer splits the JSON Pointer p into its unescaped reference
+// tokens.
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, fmt.Errorf("pointer %q does not start with /", p)
+	}
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		for j := 0; j < len(t); j++ {
+			if t[j] == '~' && (j+1 == len(t) || (t[j+1] != '0' && t[j+1] != '1')) {
+				return nil, fmt.Errorf("invalid escape in pointer %q", p)
+			}
+		}
+		tokens[i] = strings.Replace(strings.Replace(t, "~1", "/", -1), "~0", "~", -1)
+	}
+	return tokens, nil
+}
+
+// arrayIndex returns the array index token t refers to in an array of n
+// elements. "-" refers to the end of the array, which is only allowed if
+// dash is set.
+func arrayIndex(t string, n int, dash bool) (int, error) {
+	if t == "-" && dash {
+		return n - 1, nil
+	}
+	if t == "" || (len(t) > 1 && t[0] == '0') || strings.TrimLeft(t, "0123456789") != "" {
+		return 0, fmt.Errorf("invalid array index %q", t)
+	}
+	i, err := strconv.Atoi(t)
+	if err != nil || i >= n {
+		return 0, fmt.Errorf("array index %s out of range", t)
+	}
+	return i, nil
+}
+
+// resolvePointer returns the value tokens refer to in doc.
+func resolvePointer(doc interface{}, tokens []string) (interface{}, error) {
+	v := doc
+	for _, t := range tokens {
+		switch vv := v.(type) {
+		case map[string]interface{}:
+			e, ok := vv[t]
+			if !ok {
+				return nil, fmt.Errorf("member %q not found", t)
+			}
+			v = e
+		case []interface{}:
+			i, err := arrayIndex(t, len(vv), false)
+			if err != nil {
+				return nil, err
+			}
+			v = vv[i]
+		default:
+			return nil, fmt.Errorf("cannot descend into a scalar at %q", t)
+		}
+	}
+	return v, nil
+}
+
+// setPointer replaces the value tokens refer to in doc, which must exist,
+// with v and returns the changed document.
+func setPointer(doc interface{}, tokens []string, v interface{}) interface{} {
+	if len(tokens) == 0 {
+		return v
+	}
+	parent, _ := resolvePointer(doc, tokens[:len(tokens)-1])
+	last := tokens[len(tokens)-1]
+	switch p := parent.(type) {
+	case map[string]interface{}:
+		p[last] = v
+	case []interface{}:
+		i, _ := arrayIndex(last, len(p), false)
+		p[i] = v
+	}
+	return doc
+}
+
+// patchEqual reports whether the values a and b are equal for the test
+// operation.
+func patchEqual(a, b interface{}) bool {
+	switch aa := a.(type) {
+	case json.Number:
+		bb, ok := b.(json.Number)
+		return ok && normalNumber(aa) == normalNumber(bb)
+	case []interface{}:
+		bb, ok := b.([]interface{})
+		if !ok || len(aa) != len(bb) {
+			return false
+		}
+		for i := range aa {
+			if !patchEqual(aa[i], bb[i]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bb, ok := b.(map[string]interface{})
+		if !ok || len(aa) != len(bb) {
+			return false
+		}
+		for k, va := range aa {
+			if vb, found := bb[k]; !found || !patchEqual(va, vb) {
+				return false
+			}
+		}
+		return true
+	}
+	// Strings, booleans and null.
+	return a == b
+}
+
+// normalNumber returns the JSON number n written as its significant digits
+// and a decimal exponent, "-125e-2" for -1.250, so that numbers are equal
+// in value only if their normal forms are. Zero, of either sign, is "0".
+// The exponent is kept apart from the digits, so that numbers like
+// 1e999999999 are cheap to normalize.
+func normalNumber(n json.Number) string {
+	s := string(n)
+	neg := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	exp := new(big.Int)
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp.SetString(strings.TrimPrefix(s[i+1:], "+"), 10)
+		s = s[:i]
+	}
+	if i := strings.IndexByte(s, '.'); i >= 0 {
+		exp.Sub(exp, big.NewInt(int64(len(s)-i-1)))
+		s = s[:i] + s[i+1:]
+	}
+	s = strings.TrimLeft(s, "0")
+	trimmed := strings.TrimRight(s, "0")
+	if trimmed == "" {
+		return "0"
+	}
+	exp.Add(exp, big.NewInt(int64(len(s)-len(trimmed))))
+	if neg {
+		trimmed = "-" + trimmed
+	}
+	return trimmed + "e" + exp.String()
+}
@@ -0,0 +1,158 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"testing"
+)
+
+// patchFromEntries returns a JSON Patch turning a into b, made of the
+// entries of their comparison: removals from the last one, so that array
+// indexes stay valid, then replacements and additions in document order.
+func patchFromEntries(t *testing.T, a, b []byte) []byte {
+	_, entries := CompareEntries(a, b, &Options{})
+	ops := []map[string]interface{}{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		if entries[i].Kind == KindRemoved {
+			ops = append(ops, map[string]interface{}{"op": "remove", "path": entries[i].Path})
+		}
+	}
+	for _, e := range entries {
+		switch e.Kind {
+		case KindChanged:
+			ops = append(ops, map[string]interface{}{"op": "replace", "path": e.Path, "value": e.New})
+		case KindAdded:
+			ops = append(ops, map[string]interface{}{"op": "add", "path": e.Path, "value": e.New})
+		}
+	}
+	patch, err := json.Marshal(ops)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return patch
+}
+
+func TestPatchRoundTrip(t *testing.T) {
+	fixtures := [][2]string{
+		{`{"a": 1, "b": [1, 2, 3], "c": {"d": null}}`, `{"a": 2, "b": [1], "c": {"d": null, "e": [true]}, "f": "x"}`},
+		{`[1, 2]`, `[1, 2, {"x": 1}, [3]]`},
+		{`{"a/b": {"c~d": 1}}`, `{"a/b": {"c~d": 2, "": 0}}`},
+		{`{"n": 12345678901234567890}`, `{"n": 1.50}`},
+		{`{"a": 1}`, `[1]`},
+	}
+	for _, c := range cases {
+		fixtures = append(fixtures, [2]string{c.a, c.b})
+	}
+	data1, err := ioutil.ReadFile("testdata/data1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := ioutil.ReadFile("testdata/data2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fixtures = append(fixtures, [2]string{string(data1), string(data2)}, [2]string{string(data2), string(data1)})
+	for _, f := range fixtures {
+		a, b := []byte(f[0]), []byte(f[1])
+		patch := patchFromEntries(t, a, b)
+		diff, text, err := VerifyPatch(a, b, patch, &Options{})
+		if err != nil || diff != FullMatch {
+			t.Errorf("%s -> %s with %s: got %s %q %v", a, b, patch, diff, text, err)
+		}
+	}
+}
+
+func TestApplyPatch(t *testing.T) {
+	doc := `{"a": [1, 2], "o": {"k": "<v>"}}`
+	cases := []struct {
+		patch, expected string
+	}{
+		{`[]`, `{"a":[1,2],"o":{"k":"<v>"}}`},
+		{`[{"op": "add", "path": "/a/-", "value": 3}, {"op": "add", "path": "/a/-", "value": 4}]`, `{"a":[1,2,3,4],"o":{"k":"<v>"}}`},
+		{`[{"op": "add", "path": "/a/0", "value": 0}, {"op": "remove", "path": "/a/2"}]`, `{"a":[0,1],"o":{"k":"<v>"}}`},
+		{`[{"op": "add", "path": "/a/2", "value": [5]}, {"op": "add", "path": "/a/2/-", "value": 6}]`, `{"a":[1,2,[5,6]],"o":{"k":"<v>"}}`},
+		{`[{"op": "test", "path": "/o", "value": {"k": "<v>"}}, {"op": "replace", "path": "/o/k", "value": null}]`, `{"a":[1,2],"o":{"k":null}}`},
+		{`[{"op": "add", "path": "/o", "value": 1.0}, {"op": "remove", "path": "/a"}]`, `{"o":1.0}`},
+		{`[{"op": "replace", "path": "", "value": [1]}, {"op": "test", "path": "", "value": [1]}]`, `[1]`},
+		// Numbers are tested by value.
+		{`[{"op": "add", "path": "/n", "value": 1.0}, {"op": "test", "path": "/n", "value": 1}, {"op": "remove", "path": "/n"}]`,
+			`{"a":[1,2],"o":{"k":"<v>"}}`},
+		{`[{"op": "test", "path": "/a", "value": [1e0, 0.2E1]}, {"op": "test", "path": "/a/1", "value": 200e-2}]`,
+			`{"a":[1,2],"o":{"k":"<v>"}}`},
+	}
+	for _, c := range cases {
+		got, err := ApplyPatch([]byte(doc), []byte(c.patch))
+		if err != nil || string(got) != c.expected {
+			t.Errorf("%s: got %s %v, expected %s", c.patch, got, err, c.expected)
+		}
+	}
+}
+
+func TestApplyPatchErrors(t *testing.T) {
+	doc := `{"a": [1, 2], "o": {"k": "v"}, "s": 1}`
+	cases := []struct {
+		patch, err string
+	}{
+		{`{}`, "patch: patch is not an array"},
+		{`[1]`, "operation is not an object"},
+		{`[{"op": "add", "value": 1}]`, "missing path"},
+		{`[{"op": "add", "path": "a", "value": 1}]`, `pointer "a" does not start with /`},
+		{`[{"op": "add", "path": "/o/x~2", "value": 1}]`, `invalid escape in pointer "/o/x~2"`},
+		{`[{"op": "add", "path": "/o/x~", "value": 1}]`, `invalid escape in pointer "/o/x~"`},
+		{`[{"op": "remove", "path": "/a/01"}]`, `invalid array index "01"`},
+		{`[{"op": "remove", "path": "/a/-"}]`, `invalid array index "-"`},
+		{`[{"op": "replace", "path": "/a/2", "value": 1}]`, "array index 2 out of range"},
+		{`[{"op": "add", "path": "/a/3", "value": 1}]`, "array index 3 out of range"},
+		{`[{"op": "remove", "path": "/x/y"}]`, `member "x" not found`},
+		{`[{"op": "remove", "path": "/o/x"}]`, `member "x" not found`},
+		{`[{"op": "add", "path": "/s/x", "value": 1}]`, "parent is not an object or array"},
+		{`[{"op": "add", "path": "/s/x/y", "value": 1}]`, `cannot descend into a scalar at "x"`},
+		{`[{"op": "replace", "path": "/s"}]`, "missing value"},
+		{`[{"op": "test", "path": "/a", "value": [1]}]`, "value differs"},
+		{`[{"op": "test", "path": "/s", "value": 10}]`, "value differs"},
+		{`[{"op": "test", "path": "/s", "value": 1e1}]`, "value differs"},
+		{`[{"op": "test", "path": "/s", "value": 0.1}]`, "value differs"},
+		{`[{"op": "test", "path": "/s", "value": "1"}]`, "value differs"},
+		{`[{"op": "move", "from": "/s", "path": "/t"}]`, "unsupported operation"},
+		{`[{"op": "remove", "path": ""}]`, "cannot remove the whole document"},
+		{`[{"op": "add", "path": "/t", "value": 1}, {"op": "test", "path": "/t", "value": 2}]`, `patch operation 1 (test "/t"): value differs`},
+	}
+	for _, c := range cases {
+		got, err := ApplyPatch([]byte(doc), []byte(c.patch))
+		var pe *PatchError
+		if got != nil || !errors.As(err, &pe) || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("%s: got %s %v, expected %q", c.patch, got, err, c.err)
+		}
+	}
+	var ije *InvalidJSONError
+	if _, err := ApplyPatch([]byte(doc), []byte(`[{`)); !errors.As(err, &ije) || ije.Document != 2 {
+		t.Errorf("got %v for an invalid patch", err)
+	}
+	if _, err := ApplyPatch([]byte(`{`), []byte(`[]`)); !errors.As(err, &ije) || ije.Document != 1 {
+		t.Errorf("got %v for an invalid document", err)
+	}
+}
+
+func TestVerifyPatchResidual(t *testing.T) {
+	a, b := []byte(`{"a": 1, "b": [1, 2]}`), []byte(`{"a": 2, "b": [1, 2, 3]}`)
+	patch := []byte(`[{"op": "replace", "path": "/a", "value": 2}]`)
+	diff, text, err := VerifyPatch(a, b, patch, &Options{Added: Tag{Begin: "+"}})
+	if err != nil || diff != SubsetMatch || text != "{\n\"b\": [\n+3\n]\n}" {
+		t.Errorf("got %s %q %v", diff, text, err)
+	}
+}
+
+func TestNormalNumber(t *testing.T) {
+	cases := map[string]string{
+		"1": "1e0", "1.0": "1e0", "1e0": "1e0", "10E-1": "1e0", "0.001e+3": "1e0",
+		"-1.250": "-125e-2", "1500": "15e2", "0": "0", "-0.0e5": "0",
+		"1e999999999999999999999": "1e999999999999999999999",
+	}
+	for n, expected := range cases {
+		if got := normalNumber(json.Number(n)); got != expected {
+			t.Errorf("%s: got %s, expected %s", n, got, expected)
+		}
+	}
+}
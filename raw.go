@@ -0,0 +1,43 @@
+package jsondiff
+
+import "encoding/json"
+
+var rawNull = []byte("null")
+
+// CompareRaw is like Compare for documents held in json.RawMessage values,
+// e.g. fields extracted from larger envelopes. A nil message is invalid JSON
+// unless NilRawMessageAsNull is set, which compares it as null. An empty
+// message that is not nil is always invalid JSON.
+func CompareRaw(a, b json.RawMessage, opts *Options) (Difference, string) {
+	return differOf(opts).CompareRaw(a, b)
+}
+
+// CompareRaw is like the package-level CompareRaw, using the options of d.
+func (d *Differ) CompareRaw(a, b json.RawMessage) (Difference, string) {
+	return d.Compare(d.raw(a), d.raw(b))
+}
+
+// CompareRawBatch compares the two messages of every pair like CompareRaw
+// and returns the results in the order of pairs, as returned by
+// CompareDetail. The options are prepared once for the whole batch.
+func CompareRawBatch(pairs [][2]json.RawMessage, opts *Options) []Result {
+	return differOf(opts).CompareRawBatch(pairs)
+}
+
+// CompareRawBatch is like the package-level CompareRawBatch, using the
+// options of d.
+func (d *Differ) CompareRawBatch(pairs [][2]json.RawMessage) []Result {
+	results := make([]Result, len(pairs))
+	for i, p := range pairs {
+		results[i] = d.CompareDetail(d.raw(p[0]), d.raw(p[1]))
+	}
+	return results
+}
+
+// raw returns the document held in m.
+func (d *Differ) raw(m json.RawMessage) []byte {
+	if m == nil && d.opts.NilRawMessageAsNull {
+		return rawNull
+	}
+	return m
+}
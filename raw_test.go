@@ -0,0 +1,60 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCompareRaw(t *testing.T) {
+	opts := Options{Indent: "  "}
+	nullOpts := Options{Indent: "  ", NilRawMessageAsNull: true}
+	cases := []struct {
+		a, b json.RawMessage
+		opts *Options
+		diff Difference
+	}{
+		{json.RawMessage(`{"a": 1}`), json.RawMessage(`{"a": 1}`), &opts, FullMatch},
+		{json.RawMessage(`{"a": 1, "b": 2}`), json.RawMessage(`{"a": 1}`), &opts, SupersetMatch},
+		{json.RawMessage(`[1]`), json.RawMessage(`[2]`), &opts, NoMatch},
+		{nil, json.RawMessage(`null`), &opts, FirstArgIsInvalidJson},
+		{nil, nil, &opts, BothArgsAreInvalidJson},
+		{nil, json.RawMessage(`null`), &nullOpts, FullMatch},
+		{json.RawMessage(`1`), nil, &nullOpts, NoMatch},
+		{json.RawMessage{}, json.RawMessage(`null`), &nullOpts, FirstArgIsInvalidJson},
+		{json.RawMessage(`null`), json.RawMessage{}, &opts, SecondArgIsInvalidJson},
+	}
+	for _, c := range cases {
+		diff, text := CompareRaw(c.a, c.b, c.opts)
+		if diff != c.diff {
+			t.Errorf("%q, %q: got %s, expected %s", c.a, c.b, diff, c.diff)
+		}
+		if diff == NoMatch && text == "" {
+			t.Errorf("%q, %q: no output", c.a, c.b)
+		}
+	}
+}
+
+func TestCompareRawBatch(t *testing.T) {
+	pairs := [][2]json.RawMessage{
+		{json.RawMessage(`{"a": 1}`), json.RawMessage(`{"a": 1}`)},
+		{json.RawMessage(`{"a": 1}`), json.RawMessage(`{"a": 2}`)},
+		{json.RawMessage(`{"a": 1, "b": 2}`), json.RawMessage(`{"b": 2}`)},
+		{nil, json.RawMessage(`null`)},
+		{json.RawMessage(`{"a": `), json.RawMessage(`{}`)},
+	}
+	opts := Options{Indent: "  "}
+	results := CompareRawBatch(pairs, &opts)
+	expected := []Difference{FullMatch, NoMatch, SupersetMatch, FirstArgIsInvalidJson, FirstArgIsInvalidJson}
+	if len(results) != len(expected) {
+		t.Fatalf("got %d results, expected %d", len(results), len(expected))
+	}
+	for i, r := range results {
+		diff, text := CompareRaw(pairs[i][0], pairs[i][1], &opts)
+		if r.Difference != expected[i] || r.Difference != diff || r.Text != text {
+			t.Errorf("pair %d: got %s %q, expected %s %q", i, r.Difference, r.Text, expected[i], text)
+		}
+	}
+	if path := results[1].FirstMismatchPath; path != "/a" {
+		t.Errorf("got first mismatch path %q, expected /a", path)
+	}
+}
@@ -0,0 +1,127 @@
+package jsondiff
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+)
+
+// CompareReaders is like Compare, but decodes the documents directly from a
+// and b. Only the first JSON value of each reader is compared and anything
+// following it is not read, like Compare ignores data after the first value.
+// An error reading a or b is returned as the error instead of classifying
+// the document as invalid JSON; the Difference is then NoMatch. With
+// TrackPositions, ShowPositions, LenientParsing or DetectDuplicateKeys, both
+// inputs are read into memory first.
+func CompareReaders(a, b io.Reader, opts *Options) (Difference, string, error) {
+	return differOf(opts).CompareReaders(a, b)
+}
+
+// CompareReaders is like the package-level CompareReaders, using the options
+// of d.
+func (d *Differ) CompareReaders(a, b io.Reader) (Difference, string, error) {
+	return d.newContext(nil).compareReaders(a, b, "first document", "second document")
+}
+
+// compareReaders compares the documents read from a and b. Read errors are
+// reported with the names of the documents.
+func (ctx *context) compareReaders(a, b io.Reader, nameA, nameB string) (Difference, string, error) {
+	ra, rb := &errReader{r: a}, &errReader{r: b}
+	av, errA := ctx.decodeReader(ra, 0)
+	if err := readError(ra, errA); err != nil {
+		return NoMatch, "", fmt.Errorf("jsondiff: reading %s: %w", nameA, err)
+	}
+	bv, errB := ctx.decodeReader(rb, 1)
+	if err := readError(rb, errB); err != nil {
+		return NoMatch, "", fmt.Errorf("jsondiff: reading %s: %w", nameB, err)
+	}
+	if diff, msg, invalid := invalidJSON(errA, errB); invalid {
+		return diff, msg, nil
+	}
+	diff, text := ctx.compareValues(av, bv)
+	return diff, text, nil
+}
+
+// CompareFiles is like CompareReaders for the contents of the named files.
+// Errors opening or reading a file name the file.
+func CompareFiles(pathA, pathB string, opts *Options) (Difference, string, error) {
+	return differOf(opts).CompareFiles(pathA, pathB)
+}
+
+// CompareFiles is like the package-level CompareFiles, using the options of
+// d.
+func (d *Differ) CompareFiles(pathA, pathB string) (Difference, string, error) {
+	fa, err := os.Open(pathA)
+	if err != nil {
+		return NoMatch, "", err
+	}
+	defer fa.Close()
+	fb, err := os.Open(pathB)
+	if err != nil {
+		return NoMatch, "", err
+	}
+	defer fb.Close()
+	return d.newContext(nil).compareReaders(fa, fb, pathA, pathB)
+}
+
+// readError returns the error reading r or decompressing its contents, given
+// the error decoding them.
+func readError(r *errReader, decodeErr error) error {
+	if r.err != nil {
+		return r.err
+	}
+	var de *DecompressError
+	if errors.As(decodeErr, &de) {
+		return decodeErr
+	}
+	return nil
+}
+
+// decodeReader is like decode for a document read from r, decompressed first
+// if AutoDecompress is set.
+func (ctx *context) decodeReader(r io.Reader, i int) (interface{}, error) {
+	if !ctx.opts.AutoDecompress {
+		return ctx.decodeStream(r, i)
+	}
+	r, err := decompress(r)
+	if err != nil {
+		return nil, err
+	}
+	v, err := ctx.decodeStream(r, i)
+	if gr, ok := r.(*gzipReader); ok && err == nil {
+		// The checksum of a gzip stream is only verified at its end.
+		_, err = io.Copy(ioutil.Discard, gr)
+	}
+	return v, err
+}
+
+// decodeStream is like decode for a document read from r.
+func (ctx *context) decodeStream(r io.Reader, i int) (interface{}, error) {
+	if ctx.tracking || ctx.opts.LenientParsing || ctx.opts.DetectDuplicateKeys || ctx.opts.InvalidUTF8 != InvalidUTF8Replace ||
+		ctx.opts.EmptyInputAsNull {
+		data, err := ioutil.ReadAll(r)
+		if err != nil {
+			return nil, err
+		}
+		return ctx.decode(data, i)
+	}
+	dec := decoder{order: ctx.order, noTrailing: ctx.opts.DisallowTrailingData}
+	return dec.decodeFrom(&bomReader{r: r})
+}
+
+// errReader records the first error other than io.EOF returned by r, so that
+// read errors can be told apart from syntax errors of the decoder.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (er *errReader) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if err != nil && err != io.EOF && er.err == nil {
+		er.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,79 @@
+package jsondiff
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCompareReaders(t *testing.T) {
+	opts := Options{Indent: "  "}
+	cases := []struct{ a, b string }{
+		{`{"a": 1, "b": [1, 2]}`, `{"a": 2, "b": [1, 3]}`},
+		{`{"a": 1}`, `{"a": 1}`},
+		{`{"a": 1} trailing`, `{"a": 1}`},
+		{`{"a": `, `{"a": 1}`},
+		{``, `nope`},
+	}
+	for _, c := range cases {
+		diff, text, err := CompareReaders(bytes.NewReader([]byte(c.a)), strings.NewReader(c.b), &opts)
+		expDiff, expected := Compare([]byte(c.a), []byte(c.b), &opts)
+		if err != nil || diff != expDiff || text != expected {
+			t.Errorf("%q, %q: got %s %q %v, expected %s %q", c.a, c.b, diff, text, err, expDiff, expected)
+		}
+	}
+}
+
+func TestCompareReadersReadError(t *testing.T) {
+	broken := errors.New("connection reset")
+	a := io.MultiReader(strings.NewReader(`{"a": [1, 2`), iotest.ErrReader(broken))
+	for _, opts := range []Options{{}, {PreserveKeyOrder: true}, {TrackPositions: true}} {
+		a := io.MultiReader(strings.NewReader(`{"a": [1, 2`), iotest.ErrReader(broken))
+		_, _, err := CompareReaders(strings.NewReader(`{}`), a, &opts)
+		if !errors.Is(err, broken) || !strings.Contains(err.Error(), "second document") {
+			t.Errorf("got %v, expected read error", err)
+		}
+	}
+	if _, _, err := CompareReaders(a, strings.NewReader(`{}`), &Options{}); !errors.Is(err, broken) {
+		t.Errorf("got %v, expected read error", err)
+	}
+}
+
+func TestCompareReadersChunked(t *testing.T) {
+	a, b := bigDocument(2000, 1), bigDocument(2000, 13)
+	for _, opts := range []Options{{}, {PreserveKeyOrder: true}, {ShowPositions: true}} {
+		diff, text, err := CompareReaders(iotest.OneByteReader(bytes.NewReader(a)), iotest.HalfReader(bytes.NewReader(b)), &opts)
+		expDiff, expected := Compare(a, b, &opts)
+		if err != nil || diff != expDiff || text != expected {
+			t.Errorf("got %s and %v, expected %s", diff, err, expDiff)
+		}
+	}
+}
+
+func TestCompareFiles(t *testing.T) {
+	opts := Options{Indent: "  "}
+	if _, _, err := CompareFiles("testdata/missing.json", "testdata/data2.json", &opts); !errors.Is(err, os.ErrNotExist) || !strings.Contains(err.Error(), "missing.json") {
+		t.Errorf("got %v for a missing file", err)
+	}
+	if _, _, err := CompareFiles("testdata/data1.json", "testdata", &opts); err == nil || !strings.Contains(err.Error(), "reading testdata:") {
+		t.Errorf("got %v for an unreadable file", err)
+	}
+	a, err := ioutil.ReadFile("testdata/data1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := ioutil.ReadFile("testdata/data2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	diff, text, err := CompareFiles("testdata/data1.json", "testdata/data2.json", &opts)
+	expDiff, expected := Compare(a, b, &opts)
+	if err != nil || diff != expDiff || text != expected {
+		t.Errorf("got %s %q %v, expected %s %q", diff, text, err, expDiff, expected)
+	}
+}
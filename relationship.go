@@ -0,0 +1,112 @@
+package jsondiff
+
+// Relation classifies how two JSON documents relate to each other.
+type Relation int
+
+const (
+	// EqualDocuments means the documents are equal, like FullMatch.
+	EqualDocuments Relation = iota
+	// FirstSupersetOfSecond means the first document is a superset of the
+	// second one, like SupersetMatch.
+	FirstSupersetOfSecond
+	// FirstSubsetOfSecond means the first document is a subset of the
+	// second one, like SubsetMatch.
+	FirstSubsetOfSecond
+	// Overlapping means the documents differ in both directions, but share
+	// at least one equal leaf.
+	Overlapping
+	// Disjoint means the documents differ in both directions and share no
+	// equal leaf.
+	Disjoint
+)
+
+func (r Relation) String() string {
+	switch r {
+	case EqualDocuments:
+		return "EqualDocuments"
+	case FirstSupersetOfSecond:
+		return "FirstSupersetOfSecond"
+	case FirstSubsetOfSecond:
+		return "FirstSubsetOfSecond"
+	case Overlapping:
+		return "Overlapping"
+	case Disjoint:
+		return "Disjoint"
+	}
+	return "Invalid"
+}
+
+// LeafCounts counts the leaves of two documents by where they are found.
+// Leaves are scalars, null and empty objects and arrays, like for
+// Similarity.
+type LeafCounts struct {
+	// Common is the number of leaves equal in both documents. Fuzzy fields
+	// count as equal.
+	Common int
+	// OnlyA and OnlyB are the numbers of leaves of the first and second
+	// document without an equal counterpart. A changed value counts its
+	// leaves in both.
+	OnlyA, OnlyB int
+}
+
+func (c *LeafCounts) add(other LeafCounts) {
+	c.Common += other.Common
+	c.OnlyA += other.OnlyA
+	c.OnlyB += other.OnlyB
+}
+
+// Relationship compares a and b like Compare and classifies how they relate.
+// Documents which are neither equal nor one a superset of the other are
+// Overlapping if they share any equal leaf, and Disjoint otherwise, so that
+// {"a": 1} and {"a": 2} are Disjoint even though they share a key. The leaf
+// counts are collected during the same comparison. An error is returned if
+// either document is not valid JSON, like by CompareErr.
+func Relationship(a, b []byte, opts *Options) (Relation, LeafCounts, error) {
+	return differOf(opts).Relationship(a, b)
+}
+
+// Relationship is like the package-level Relationship, using the options of
+// d.
+func (d *Differ) Relationship(a, b []byte) (Relation, LeafCounts, error) {
+	ctx := d.newContext(nil)
+	ctx.countLeaves = true
+	diff, _, err := ctx.compareErr(a, b)
+	if err == nil {
+		err = ctx.err
+	}
+	if err != nil {
+		return Disjoint, LeafCounts{}, err
+	}
+	switch {
+	case diff == FullMatch:
+		return EqualDocuments, ctx.leafCounts, nil
+	case diff == SupersetMatch:
+		return FirstSupersetOfSecond, ctx.leafCounts, nil
+	case diff == SubsetMatch:
+		return FirstSubsetOfSecond, ctx.leafCounts, nil
+	case ctx.leafCounts.Common > 0:
+		return Overlapping, ctx.leafCounts, nil
+	}
+	return Disjoint, ctx.leafCounts, nil
+}
+
+// countCommon counts the leaves of v as equal in both documents, if leaves
+// are counted.
+func (ctx *context) countCommon(v interface{}) {
+	if ctx.countLeaves {
+		ctx.leafCounts.Common += ctx.leaves(v)
+	}
+}
+
+// countOnly counts the leaves of v as found only in the document i, if
+// leaves are counted.
+func (ctx *context) countOnly(i int, v interface{}) {
+	if !ctx.countLeaves {
+		return
+	}
+	if i == 0 {
+		ctx.leafCounts.OnlyA += ctx.leaves(v)
+	} else {
+		ctx.leafCounts.OnlyB += ctx.leaves(v)
+	}
+}
@@ -0,0 +1,40 @@
+package jsondiff
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestRelationship(t *testing.T) {
+	opts := Options{IgnoreFields: []string{"id"}, FuzzyFields: []string{"ts"}, StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b   string
+		rel    Relation
+		counts LeafCounts
+	}{
+		{`{"a": 1, "b": [1, 2], "c": {}}`, `{"c": {}, "b": [1, 2], "a": 1}`, EqualDocuments, LeafCounts{Common: 4}},
+		{`{"a": 1, "b": {"x": [1, 2]}}`, `{"a": 1}`, FirstSupersetOfSecond, LeafCounts{Common: 1, OnlyA: 2}},
+		{`[1]`, `[1, {"x": 1, "y": null}]`, FirstSubsetOfSecond, LeafCounts{Common: 1, OnlyB: 2}},
+		{`{"a": 1, "b": 2}`, `{"a": 1, "c": 3}`, Overlapping, LeafCounts{Common: 1, OnlyA: 1, OnlyB: 1}},
+		{`{"a": 1, "b": [1]}`, `{"a": 1, "b": [2, 3]}`, Overlapping, LeafCounts{Common: 1, OnlyA: 1, OnlyB: 2}},
+		{`{"a": 1}`, `{"a": 2}`, Disjoint, LeafCounts{OnlyA: 1, OnlyB: 1}},
+		{`{"a": 1}`, `{"b": 1}`, Disjoint, LeafCounts{OnlyA: 1, OnlyB: 1}},
+		{`{"a": {"x": 1}}`, `{"a": [1, 2]}`, Disjoint, LeafCounts{OnlyA: 1, OnlyB: 2}},
+		{`{"id": 1, "ts": 5, "a": 1}`, `{"id": 2, "ts": 6, "b": 1}`, Overlapping, LeafCounts{Common: 1, OnlyA: 1, OnlyB: 1}},
+		{`{"doc": "{\"p\": 1, \"q\": 1}"}`, `{"doc": "{\"p\": 1, \"q\": 2}"}`, Overlapping, LeafCounts{Common: 1, OnlyA: 1, OnlyB: 1}},
+	}
+	for _, c := range cases {
+		rel, counts, err := Relationship([]byte(c.a), []byte(c.b), &opts)
+		if err != nil || rel != c.rel || counts != c.counts {
+			t.Errorf("%s, %s: got %s %+v %v, expected %s %+v", c.a, c.b, rel, counts, err, c.rel, c.counts)
+		}
+	}
+}
+
+func TestRelationshipInvalid(t *testing.T) {
+	_, _, err := Relationship([]byte(`{}`), []byte(`{"a": `), &Options{})
+	var ije *InvalidJSONError
+	if !errors.As(err, &ije) || ije.Document != 2 {
+		t.Errorf("got %v, expected an error for the second document", err)
+	}
+}
@@ -0,0 +1,144 @@
+package jsondiff
+
+import (
+	"strconv"
+	"time"
+)
+
+// Result is the detailed outcome of comparing two JSON documents.
+type Result struct {
+	Difference Difference
+	// Text is the rendered difference, as returned by Compare.
+	Text string
+	// Entries lists the differences in document order, as returned by
+	// CompareEntries.
+	Entries []DiffEntry
+	// Warnings lists the entries of Entries with SeverityWarn, which the
+	// Difference leaves out.
+	Warnings []DiffEntry
+	// FirstMismatchPath is the path of the first difference in document
+	// order, in the format of DiffEntry.Path, warnings left out. It is empty
+	// on FullMatch and for invalid documents, but also if the documents
+	// differ at the root, which the Difference tells apart.
+	FirstMismatchPath string
+	// Stats are the counters collected during the comparison.
+	Stats Stats
+	// DuplicateKeys lists the keys repeated in the same object of either
+	// document if DetectDuplicateKeys is set: those of the first and then
+	// the second document in document order, followed by those of
+	// StringAsMapFields documents. An invalid document is searched up to
+	// its syntax error.
+	DuplicateKeys []DuplicateKey
+	// A and B are the decoded documents, as compared, only set by
+	// CompareDecoded. They are nil for an invalid document.
+	A, B interface{}
+}
+
+// CompareDetail compares two JSON documents like Compare and returns
+// everything known about their differences.
+func CompareDetail(a, b []byte, opts *Options) Result {
+	return differOf(opts).CompareDetail(a, b)
+}
+
+// CompareDetail is like the package-level CompareDetail, using the options
+// of d.
+func (d *Differ) CompareDetail(a, b []byte) Result {
+	start := time.Now()
+	ctx := d.newContext(nil)
+	ctx.collect = true
+	diff, text := ctx.compare(a, b)
+	return ctx.detail(diff, text, start)
+}
+
+// detail returns the Result of the comparison made by ctx, started at start.
+func (ctx *context) detail(diff Difference, text string, start time.Time) Result {
+	stats := ctx.stats
+	stats.Added, stats.Removed = ctx.summary.added, ctx.summary.removed
+	stats.Changed, stats.Unchanged = ctx.summary.changed, ctx.summary.unchanged
+	stats.Duration = time.Since(start)
+	var warnings []DiffEntry
+	for _, e := range ctx.entries {
+		if e.Severity == SeverityWarn {
+			warnings = append(warnings, e)
+		}
+	}
+	return Result{Difference: diff, Text: text, Entries: ctx.entries, Warnings: warnings, FirstMismatchPath: ctx.firstPath,
+		Stats: stats, DuplicateKeys: ctx.duplicates}
+}
+
+// Paths returns the paths of all differences in document order, without
+// duplicates. An added or removed object or array is listed as the paths of
+// all its leaves, the scalars and empty objects and arrays in it, unless
+// collapseSubtrees is set, which lists it as its own path only.
+func (r *Result) Paths(collapseSubtrees bool) []string {
+	var paths []string
+	seen := make(map[string]struct{})
+	add := func(path string) {
+		if _, ok := seen[path]; !ok {
+			seen[path] = struct{}{}
+			paths = append(paths, path)
+		}
+	}
+	for _, e := range r.Entries {
+		switch {
+		case e.Kind == KindChanged || collapseSubtrees:
+			add(e.Path)
+		case e.Kind == KindAdded:
+			leafPaths(e.Path, e.New, add)
+		default:
+			leafPaths(e.Path, e.Old, add)
+		}
+	}
+	return paths
+}
+
+// leafPaths calls fn with the path of every leaf of v, where v is at path.
+func leafPaths(path string, v interface{}, fn func(string)) {
+	switch vv := v.(type) {
+	case map[string]interface{}:
+		if len(vv) > 0 {
+			for _, k := range sortedKeys(vv) {
+				leafPaths(path+"/"+escapePointerToken(k), vv[k], fn)
+			}
+			return
+		}
+	case []interface{}:
+		if len(vv) > 0 {
+			for i, e := range vv {
+				leafPaths(path+"/"+strconv.Itoa(i), e, fn)
+			}
+			return
+		}
+	}
+	fn(path)
+}
+
+// Stats are counters collected while comparing two documents.
+type Stats struct {
+	// Nodes is the number of pairs of values compared, including the
+	// values of StringAsMapFields documents.
+	Nodes int
+	// Objects and Arrays are the numbers of pairs of objects and arrays
+	// compared.
+	Objects, Arrays int
+	// MaxDepth is the deepest nesting level compared, 0 for the root.
+	// Values in StringAsMapFields documents are one level deeper than the
+	// field holding them.
+	MaxDepth int
+	// Added, Removed and Changed are the numbers of differences of each
+	// kind, and Unchanged the number of equal scalars, as in the summary
+	// of ShowSummary.
+	Added, Removed, Changed, Unchanged int
+	// Duration is the time the comparison took, decoding included.
+	Duration time.Duration
+}
+
+// merge adds the counters of a comparison of documents embedded at depth.
+func (s *Stats) merge(other Stats, depth int) {
+	s.Nodes += other.Nodes
+	s.Objects += other.Objects
+	s.Arrays += other.Arrays
+	if d := depth + other.MaxDepth; d > s.MaxDepth {
+		s.MaxDepth = d
+	}
+}
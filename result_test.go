@@ -0,0 +1,114 @@
+package jsondiff
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFirstMismatchPath(t *testing.T) {
+	opts := Options{Indent: "  ", StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b     string
+		diff     Difference
+		expected string
+	}{
+		{`1`, `2`, NoMatch, ""},
+		{`{"a": 1}`, `{"a": 1}`, FullMatch, ""},
+		{`{"a": {"b": 1, "c": {"d": true}}, "z": 1}`, `{"a": {"b": 1, "c": {"d": false}}, "z": 2}`, NoMatch, "/a/c/d"},
+		{`{"list": [1, {"x": 1}, 3]}`, `{"list": [1, {"x": 2}]}`, NoMatch, "/list/1/x"},
+		{`{"list": [1, 2]}`, `{"list": [1]}`, SupersetMatch, "/list/1"},
+		{`{"doc": "{\"p\": 1}", "z": 1}`, `{"doc": "{\"p\": 2}", "z": 2}`, NoMatch, "/doc#/p"},
+		{`{`, `{}`, FirstArgIsInvalidJson, ""},
+	}
+	for _, c := range cases {
+		r := CompareDetail([]byte(c.a), []byte(c.b), &opts)
+		if r.Difference != c.diff || r.FirstMismatchPath != c.expected {
+			t.Errorf("%s, %s: got %s at %q, expected %s at %q", c.a, c.b, r.Difference, r.FirstMismatchPath, c.diff, c.expected)
+		}
+		diff, text := Compare([]byte(c.a), []byte(c.b), &opts)
+		if r.Text != text || r.Difference != diff {
+			t.Errorf("%s, %s: result differs from Compare", c.a, c.b)
+		}
+	}
+}
+
+func TestResultPaths(t *testing.T) {
+	opts := Options{IgnoreFields: []string{"etag"}, FuzzyFields: []string{"ts"}}
+	a := `{"etag": 1, "ts": 1, "user": {"name": "x", "roles": ["a", "b"]}, "items": [{"id": 1}, {"id": 2}], "old": {"k": [1]}}`
+	b := `{"etag": 2, "ts": 2, "user": {"name": "y", "roles": ["a"], "meta": {"a/b": 1, "c": {}}}, "items": [{"id": 1}, {"id": 3}, {"id": 4}]}`
+	r := CompareDetail([]byte(a), []byte(b), &opts)
+	expected := []string{"/items/1/id", "/items/2/id", "/old/k/0", "/user/meta/a~1b", "/user/meta/c", "/user/name", "/user/roles/1"}
+	if got := r.Paths(false); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+	expected = []string{"/items/1/id", "/items/2", "/old", "/user/meta", "/user/name", "/user/roles/1"}
+	if got := r.Paths(true); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestResultStats(t *testing.T) {
+	opts := Options{IgnoreFields: []string{"etag"}, StringAsMapFields: []string{"doc"}}
+	a := `{"etag": 1, "name": "x", "list": [1, 2, {"k": 1}], "doc": "{\"p\": {\"q\": 1}}", "old": 1}`
+	b := `{"etag": 2, "name": "x", "list": [1, 3, {"k": 1}, 4], "doc": "{\"p\": {\"q\": 2}}"}`
+	r := CompareDetail([]byte(a), []byte(b), &opts)
+	got := r.Stats
+	if got.Duration <= 0 {
+		t.Errorf("no duration measured")
+	}
+	got.Duration = 0
+	// The root, name, list, its three common elements, k, doc, and p, q in
+	// the embedded document plus its root.
+	expected := Stats{Nodes: 11, Objects: 4, Arrays: 1, MaxDepth: 3, Added: 1, Removed: 1, Changed: 2, Unchanged: 3}
+	if got != expected {
+		t.Errorf("got %+v, expected %+v", got, expected)
+	}
+}
+
+func BenchmarkCompareDetail(b *testing.B) {
+	x, y := bigDocument(2000, 1), bigDocument(2000, 97)
+	opts := Options{Indent: "  "}
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		CompareDetail(x, y, &opts)
+	}
+}
+
+func TestResultDuplicateKeys(t *testing.T) {
+	opts := Options{DetectDuplicateKeys: true, StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b     string
+		diff     Difference
+		expected []DuplicateKey
+	}{
+		{`{"a": 1, "a": 2}`, `{"a": 2}`, FullMatch, []DuplicateKey{
+			{Document: 1, Path: "/a", Position: Position{Offset: 9, Line: 1, Column: 10}},
+		}},
+		{`{"x": [{"b": 1}]}`, "{\"x\": [{\"b\": 1,\n  \"b\": 1}],\n \"x\": []}", SupersetMatch, []DuplicateKey{
+			{Document: 2, Path: "/x/0/b", Position: Position{Offset: 18, Line: 2, Column: 3}},
+			{Document: 2, Path: "/x", Position: Position{Offset: 29, Line: 3, Column: 2}},
+		}},
+		{`{"a": {"k": 1, "k": 1}}`, `{"a": {"k": 1, "k": 1, "k": 1}, "d": 1, "d": 1}`, SubsetMatch, []DuplicateKey{
+			{Document: 1, Path: "/a/k", Position: Position{Offset: 15, Line: 1, Column: 16}},
+			{Document: 2, Path: "/a/k", Position: Position{Offset: 15, Line: 1, Column: 16}},
+			{Document: 2, Path: "/a/k", Position: Position{Offset: 23, Line: 1, Column: 24}},
+			{Document: 2, Path: "/d", Position: Position{Offset: 40, Line: 1, Column: 41}},
+		}},
+		{`{"doc": "{\"p\": 1}"}`, `{"doc": "{\"p\": 1, \"p\": 2}"}`, NoMatch, []DuplicateKey{
+			{Document: 2, Path: "/doc#/p", Position: Position{Offset: 9, Line: 1, Column: 10}},
+		}},
+		{`{"a": 1, "a": 2, `, `{}`, FirstArgIsInvalidJson, []DuplicateKey{
+			{Document: 1, Path: "/a", Position: Position{Offset: 9, Line: 1, Column: 10}},
+		}},
+		{`{"a": 1}`, `{"a": 1}`, FullMatch, nil},
+	}
+	for _, c := range cases {
+		r := CompareDetail([]byte(c.a), []byte(c.b), &opts)
+		if r.Difference != c.diff || !reflect.DeepEqual(r.DuplicateKeys, c.expected) {
+			t.Errorf("%s, %s: got %s %+v, expected %s %+v", c.a, c.b, r.Difference, r.DuplicateKeys, c.diff, c.expected)
+		}
+	}
+	if r := CompareDetail([]byte(`{"a": 1, "a": 2}`), []byte(`{}`), &Options{}); r.DuplicateKeys != nil {
+		t.Errorf("got duplicates %+v without DetectDuplicateKeys", r.DuplicateKeys)
+	}
+}
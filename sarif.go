@@ -0,0 +1,145 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+)
+
+// SARIF 2.1.0 object model, limited to the parts written by WriteSARIF.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID               string       `json:"id"`
+	ShortDescription sarifMessage `json:"shortDescription"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations,omitempty"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifLogicalLocation struct {
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+const sarifInvalidJSONRule = "invalid-json"
+
+var sarifRules = []sarifRule{
+	{ID: KindAdded.String(), ShortDescription: sarifMessage{"Value is present only in the second document."}},
+	{ID: KindRemoved.String(), ShortDescription: sarifMessage{"Value is present only in the first document."}},
+	{ID: KindChanged.String(), ShortDescription: sarifMessage{"Value differs between the documents."}},
+	{ID: sarifInvalidJSONRule, ShortDescription: sarifMessage{"Document is not valid JSON."}},
+}
+
+// WriteSARIF compares two JSON documents and writes the differences to w as a
+// SARIF 2.1.0 log, which CI systems can show as annotations. Every difference
+// becomes a result whose rule is its kind ("added", "removed" or "changed")
+// and whose logical location is its JSON Pointer. Invalid documents produce a
+// single "invalid-json" result. Result levels are taken from
+// Options.SARIFLevels and default to "error".
+func WriteSARIF(w io.Writer, a, b []byte, opts *Options) (Difference, error) {
+	return differOf(opts).WriteSARIF(w, a, b)
+}
+
+// WriteSARIF is like the package-level WriteSARIF, using the options of d.
+func (d *Differ) WriteSARIF(w io.Writer, a, b []byte) (Difference, error) {
+	diff, entries := d.CompareEntries(a, b)
+	results := make([]sarifResult, 0, len(entries))
+	switch diff {
+	case FirstArgIsInvalidJson, SecondArgIsInvalidJson, BothArgsAreInvalidJson:
+		results = append(results, sarifResult{
+			RuleID:  sarifInvalidJSONRule,
+			Level:   "error",
+			Message: sarifMessage{diff.String()},
+		})
+	}
+	for _, e := range entries {
+		text, err := entryMessage(e)
+		if err != nil {
+			return diff, err
+		}
+		level := d.opts.SARIFLevels[e.Kind]
+		if level == "" {
+			level = "error"
+		}
+		results = append(results, sarifResult{
+			RuleID:  e.Kind.String(),
+			Level:   level,
+			Message: sarifMessage{text},
+			Locations: []sarifLocation{{
+				LogicalLocations: []sarifLogicalLocation{{FullyQualifiedName: e.Path, Kind: "member"}},
+			}},
+		})
+	}
+	log := sarifLog{
+		Version: "2.1.0",
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "jsondiff",
+				InformationURI: "https://github.com/nsf/jsondiff",
+				Rules:          sarifRules,
+			}},
+			Results: results,
+		}},
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return diff, enc.Encode(log)
+}
+
+// entryMessage describes e in a single line, with values encoded as JSON and
+// the path escaped like in rendered output.
+func entryMessage(e DiffEntry) (string, error) {
+	path := "(root)"
+	if e.Path != "" {
+		var buf bytes.Buffer
+		writeEscaped(&buf, e.Path, 0)
+		path = buf.String()
+	}
+	switch e.Kind {
+	case KindAdded:
+		v, err := json.Marshal(e.New)
+		return path + " added: " + string(v), err
+	case KindRemoved:
+		v, err := json.Marshal(e.Old)
+		return path + " removed: " + string(v), err
+	}
+	old, err := json.Marshal(e.Old)
+	if err != nil {
+		return "", err
+	}
+	v, err := json.Marshal(e.New)
+	return path + " changed: " + string(old) + " => " + string(v), err
+}
@@ -0,0 +1,73 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestWriteSARIF(t *testing.T) {
+	a := `{"a": {"b": 1}, "gone": true, "x": "<&>"}`
+	b := `{"a": {"b": 2}, "new": [1], "x": "<&>"}`
+	opts := Options{SARIFLevels: map[DiffKind]string{KindAdded: "warning"}}
+	var buf bytes.Buffer
+	diff, err := WriteSARIF(&buf, []byte(a), []byte(b), &opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if diff != NoMatch {
+		t.Errorf("got %s, expected %s", diff, NoMatch)
+	}
+
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatalf("invalid SARIF: %s\n%s", err, buf.String())
+	}
+	if log.Version != "2.1.0" || len(log.Runs) != 1 || log.Runs[0].Tool.Driver.Name == "" {
+		t.Fatalf("invalid SARIF envelope:\n%s", buf.String())
+	}
+	rules := make(map[string]bool)
+	for _, r := range log.Runs[0].Tool.Driver.Rules {
+		rules[r.ID] = true
+	}
+	expected := []struct {
+		rule, level, path, text string
+	}{
+		{"changed", "error", "/a/b", "/a/b changed: 1 => 2"},
+		{"removed", "error", "/gone", "/gone removed: true"},
+		{"added", "warning", "/new", "/new added: [1]"},
+	}
+	results := log.Runs[0].Results
+	if len(results) != len(expected) {
+		t.Fatalf("got %d results, expected %d:\n%s", len(results), len(expected), buf.String())
+	}
+	for i, r := range results {
+		e := expected[i]
+		if !rules[r.RuleID] {
+			t.Errorf("result %d: rule %q is not defined", i, r.RuleID)
+		}
+		if r.RuleID != e.rule || r.Level != e.level || r.Message.Text != e.text {
+			t.Errorf("result %d: got %+v, expected %+v", i, r, e)
+		}
+		if len(r.Locations) != 1 || len(r.Locations[0].LogicalLocations) != 1 ||
+			r.Locations[0].LogicalLocations[0].FullyQualifiedName != e.path {
+			t.Errorf("result %d: unexpected locations %+v", i, r.Locations)
+		}
+	}
+}
+
+func TestWriteSARIFInvalid(t *testing.T) {
+	var buf bytes.Buffer
+	diff, err := WriteSARIF(&buf, []byte(`{}`), []byte(`nope`), &Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var log sarifLog
+	if err := json.Unmarshal(buf.Bytes(), &log); err != nil {
+		t.Fatal(err)
+	}
+	results := log.Runs[0].Results
+	if diff != SecondArgIsInvalidJson || len(results) != 1 || results[0].RuleID != sarifInvalidJSONRule {
+		t.Errorf("got %s with results %+v", diff, results)
+	}
+}
@@ -0,0 +1,69 @@
+package jsondiff
+
+import "strconv"
+
+// Severity is how much a difference matters, set per member by
+// Options.Severities.
+type Severity int
+
+const (
+	// SeverityFail makes differences count in the Difference. It is the
+	// severity of members not listed in Severities.
+	SeverityFail Severity = iota
+	// SeverityWarn makes differences warnings: they are rendered, with the
+	// Warning tag, and listed, but the Difference is computed as if the
+	// values matched.
+	SeverityWarn
+)
+
+func (s Severity) String() string {
+	switch s {
+	case SeverityFail:
+		return "fail"
+	case SeverityWarn:
+		return "warn"
+	}
+	return "Severity(" + strconv.Itoa(int(s)) + ")"
+}
+
+// grading reports whether any member has a severity set by Severities.
+func (d *Differ) grading() bool {
+	return d.severityFields != nil || d.severityPaths != nil
+}
+
+// gradeMember sets ctx.warn if the member k, whose key has been pushed to
+// the path, has SeverityWarn, and clears it if it has SeverityFail. Members
+// not in Severities keep the severity of their parent. A JSON Pointer takes
+// precedence over a key naming the same member. gradeMember returns the
+// previous value of ctx.warn for the caller to restore once done with the
+// member.
+func (ctx *context) gradeMember(k string) bool {
+	warn := ctx.warn
+	if !ctx.differ.grading() {
+		return warn
+	}
+	if s, found := ctx.differ.severityPaths[ctx.pointer()]; found {
+		ctx.warn = s == SeverityWarn
+	} else if s, found := ctx.differ.severityFields[k]; found {
+		ctx.warn = s == SeverityWarn
+	}
+	return warn
+}
+
+// severity returns the severity of the value being compared.
+func (ctx *context) severity() Severity {
+	if ctx.warn {
+		return SeverityWarn
+	}
+	return SeverityFail
+}
+
+// entryTag returns the tag to render a difference of the value being
+// compared with: tag, one of the Added, Removed and Changed tags, or the
+// Warning tag for a warning if it is set.
+func (ctx *context) entryTag(tag *Tag) *Tag {
+	if ctx.warn && ctx.opts.Warning != (Tag{}) {
+		return &ctx.opts.Warning
+	}
+	return tag
+}
@@ -0,0 +1,129 @@
+package jsondiff
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestSeverities(t *testing.T) {
+	severities := map[string]Severity{"description": SeverityWarn, "meta": SeverityWarn, "/meta/amount": SeverityFail}
+	cases := []struct {
+		a, b     string
+		diff     Difference
+		warnings []string
+	}{
+		// Only warnings.
+		{`{"description": "a", "amount": 1}`, `{"description": "b", "amount": 1}`, FullMatch,
+			[]string{"/description"}},
+		{`{"meta": {"x": 1}}`, `{"meta": {"y": [1]}, "description": "b"}`, FullMatch,
+			[]string{"/description", "/meta/x", "/meta/y"}},
+		{`{"items": [{"description": "a"}]}`, `{"items": [{"description": "b"}]}`, FullMatch,
+			[]string{"/items/0/description"}},
+		// Warnings and failures.
+		{`{"description": "a", "amount": 1}`, `{"description": "b", "amount": 2}`, NoMatch,
+			[]string{"/description"}},
+		{`{"meta": {"x": 1, "amount": 1}}`, `{"meta": {"x": 2}}`, SupersetMatch,
+			[]string{"/meta/x"}},
+		{`{"description": "a"}`, `{"description": "b", "amount": 2}`, SubsetMatch,
+			[]string{"/description"}},
+		// Failures only.
+		{`{"meta": {"amount": 1}}`, `{"meta": {"amount": 2}}`, NoMatch, nil},
+		{`{"description": "a"}`, `{"description": "a"}`, FullMatch, nil},
+	}
+	for _, c := range cases {
+		a, b := []byte(c.a), []byte(c.b)
+		for _, opts := range []Options{{}, {Parallelism: 4}, {LazyDecoding: true}, {NoOutput: true}} {
+			opts.Severities = severities
+			r := CompareDetail(a, b, &opts)
+			var warnings []string
+			for _, e := range r.Warnings {
+				warnings = append(warnings, e.Path)
+			}
+			if r.Difference != c.diff || fmt.Sprint(warnings) != fmt.Sprint(c.warnings) {
+				t.Errorf("%s, %s: got %s with warnings %v, expected %s with %v",
+					c.a, c.b, r.Difference, warnings, c.diff, c.warnings)
+			}
+			warned := make(map[string]bool)
+			for _, path := range c.warnings {
+				warned[path] = true
+			}
+			for _, e := range r.Entries {
+				if warned[e.Path] != (e.Severity == SeverityWarn) {
+					t.Errorf("%s, %s: got severity %s for %s", c.a, c.b, e.Severity, e.Path)
+				}
+			}
+			if (r.FirstMismatchPath != "") != (c.diff != FullMatch) {
+				t.Errorf("%s, %s: got first mismatch %q", c.a, c.b, r.FirstMismatchPath)
+			}
+			if diff, text := Compare(a, b, &opts); diff != c.diff || (text == "") != (len(c.warnings) == 0 && diff == FullMatch || opts.NoOutput) {
+				t.Errorf("%s, %s: Compare got %s and %q", c.a, c.b, diff, text)
+			}
+			if Equal(a, b, &opts) != (c.diff == FullMatch) {
+				t.Errorf("%s, %s: Equal disagrees with %s", c.a, c.b, c.diff)
+			}
+		}
+	}
+
+	// Ignored fields are not compared whatever their severity.
+	opts := Options{IgnoreFields: []string{"description"}, Severities: severities}
+	if r := CompareDetail([]byte(`{"description": "a"}`), []byte(`{"description": "b"}`), &opts); len(r.Entries) != 0 || r.Text != "" {
+		t.Errorf("got entries %+v and %q for an ignored field", r.Entries, r.Text)
+	}
+
+	if err := (&Options{Severities: map[string]Severity{"a": 2}}).Validate(); err == nil {
+		t.Error("unknown Severity accepted")
+	}
+	if _, err := CompareStream(bytes.NewReader([]byte(`{}`)), bytes.NewReader([]byte(`{}`)), &Options{Severities: severities}); err == nil {
+		t.Error("CompareStream accepted Severities")
+	}
+}
+
+func TestSeveritiesRendering(t *testing.T) {
+	opts := Options{
+		Indent:            "  ",
+		Added:             Tag{Begin: "<a>", End: "</a>"},
+		Changed:           Tag{Begin: "<c>", End: "</c>"},
+		Warning:           Tag{Begin: "<w>", End: "</w>"},
+		StringAsMapFields: []string{"doc"},
+		Severities:        map[string]Severity{"note": SeverityWarn, "/doc": SeverityWarn},
+	}
+	a := `{"note": "x", "id": 1, "doc": "{\"n\": 1}"}`
+	b := `{"note": "y", "id": 2, "doc": "{\"n\": 2}", "extra": {"note": [1]}}`
+	diff, text := Compare([]byte(a), []byte(b), &opts)
+	expected := `{
+  "doc": {
+    "n": <w>1 => 2</w>
+  },
+  <a>"extra": {</a>
+    <a>"note": [</a>
+      <a>1</a>
+    <a>]</a>
+  <a>}</a>,
+  "id": <c>1 => 2</c>,
+  "note": <w>"x" => "y"</w>
+}`
+	if diff != NoMatch || text != expected {
+		t.Errorf("got %s and:\n%s\nexpected:\n%s", diff, text, expected)
+	}
+
+	// Without a Warning tag, warnings are rendered with the tag of their
+	// kind.
+	opts.Warning = Tag{}
+	opts.ShowLegend = true
+	diff, text = Compare([]byte(`{"note": "x"}`), []byte(`{"note": "y"}`), &opts)
+	expected = "<a>added</a> removed <c>changed</c>\n" +
+		"---------------------\n" +
+		"{\n" +
+		"  \"note\": <c>\"x\" => \"y\"</c>\n" +
+		"}"
+	if diff != FullMatch || text != expected {
+		t.Errorf("got %s and:\n%s\nexpected:\n%s", diff, text, expected)
+	}
+	opts.Warning = Tag{Begin: "<w>", End: "</w>"}
+	_, text = Compare([]byte(`{"note": "x"}`), []byte(`{"note": "y"}`), &opts)
+	if legend := "<a>added</a> removed <c>changed</c> <w>warning</w>\n-----------------------------\n"; !strings.HasPrefix(text, legend) {
+		t.Errorf("got:\n%s\nexpected the legend:\n%s", text, legend)
+	}
+}
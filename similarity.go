@@ -0,0 +1,237 @@
+package jsondiff
+
+import "strconv"
+
+// Similarity returns how similar a and b are, from 0 for documents sharing
+// no value to 1 for documents Compare reports as FullMatch. The score is the
+// number of matching leaf values divided by the number of leaf values
+// compared:
+//
+//	similarity = matching leaves / compared leaves
+//
+// Leaves are scalars, null and empty objects and arrays. Values present in
+// both documents are compared like by Compare, array elements by index. A
+// value present in only one document, or whose type differs between them,
+// counts as mismatching leaves, as many as the larger side has. Ignored
+// fields count for nothing, fuzzy fields match all their leaves and
+// StringAsMapFields documents contribute their own leaves. If nothing is
+// compared, e.g. because every field is ignored, the score is 1. An error is
+// returned if either document is not valid JSON, like by CompareErr.
+//
+// With Options.Weights, leaves count for their weight rather than 1:
+//
+//	similarity = weight of matching leaves / weight of compared leaves
+//
+// A leaf weighs as much as the innermost member holding it that Weights
+// lists, 1 if there is none. A value present in only one document, or of
+// different types in them, counts as the mismatching leaves of the side
+// weighing more, so added and removed subtrees weigh as much as all their
+// leaves. Members of weight 0 count for nothing, like ignored fields.
+func Similarity(a, b []byte, opts *Options) (float64, error) {
+	return differOf(opts).Similarity(a, b)
+}
+
+// Similarity is like the package-level Similarity, using the options of d.
+func (d *Differ) Similarity(a, b []byte) (float64, error) {
+	ctx := &context{opts: &d.opts, differ: d,
+		fuzzyFields: d.fuzzyFields, ignoreFields: d.ignoreFields, stringAsMapFields: d.stringAsMapFields}
+	av, errA := ctx.decode(a, 0)
+	bv, errB := ctx.decode(b, 1)
+	if err := decodeErrors(a, b, errA, errB); err != nil {
+		return 0, err
+	}
+	score := ctx.score(av, bv)
+	if ctx.err != nil {
+		return 0, ctx.err
+	}
+	return score, nil
+}
+
+// score returns the similarity of the documents a and b.
+func (ctx *context) score(a, b interface{}) float64 {
+	ctx.weight = 1
+	matched, total := ctx.similarity(a, b, "")
+	if total == 0 {
+		return 1
+	}
+	return matched / total
+}
+
+// similarity returns the weight of the matching leaves and the weight of
+// the leaves compared between a and b, the values of the field key. It
+// stops with ctx.err set at a value nested deeper than MaxDepth.
+func (ctx *context) similarity(a, b interface{}, key string) (matched, total float64) {
+	if ctx.canceled() {
+		return 0, 0
+	}
+	_, isFuzzy := ctx.fuzzyFields[key]
+	w := ctx.weight
+	mismatch := func() (float64, float64) {
+		n := ctx.weighLeaves(a)
+		if m := ctx.weighLeaves(b); m > n {
+			n = m
+		}
+		return 0, n
+	}
+	if a == nil || b == nil {
+		if isFuzzy || (a == nil && b == nil) || (ctx.opts.NullAsEmpty && ctx.isZeroLen(a, b)) {
+			return w, w
+		}
+		return mismatch()
+	}
+	if !sameKind(a, b) {
+		return mismatch()
+	}
+	if isFuzzy {
+		_, n := mismatch()
+		return n, n
+	}
+	switch aa := a.(type) {
+	case []interface{}:
+		bb := b.([]interface{})
+		for i := 0; i < len(aa) || i < len(bb); i++ {
+			ctx.push(strconv.Itoa(i))
+			switch {
+			case i < len(aa) && i < len(bb):
+				m, t := ctx.similarity(aa[i], bb[i], key)
+				matched, total = matched+m, total+t
+			case i < len(aa):
+				total += ctx.weighLeaves(aa[i])
+			default:
+				total += ctx.weighLeaves(bb[i])
+			}
+			ctx.pop()
+		}
+		if total == 0 {
+			// Both empty, or holding only members of weight 0.
+			return w, w
+		}
+		return matched, total
+	case map[string]interface{}:
+		bb := b.(map[string]interface{})
+		for k, va := range aa {
+			if _, ignored := ctx.ignoreFields[k]; ignored {
+				continue
+			}
+			ctx.push(k)
+			ctx.weighMember(k)
+			if vb, ok := bb[k]; ok {
+				m, t := ctx.similarity(va, vb, k)
+				matched, total = matched+m, total+t
+			} else if !ctx.differ.missingMatches(k) {
+				total += ctx.weighLeaves(va)
+			}
+			ctx.weight = w
+			ctx.pop()
+		}
+		for k, vb := range bb {
+			if _, ignored := ctx.ignoreFields[k]; ignored {
+				continue
+			}
+			if _, ok := aa[k]; !ok && !ctx.differ.missingMatches(k) {
+				ctx.push(k)
+				ctx.weighMember(k)
+				total += ctx.weighLeaves(vb)
+				ctx.weight = w
+				ctx.pop()
+			}
+		}
+		if total == 0 {
+			// Both empty, or holding only ignored fields and members of
+			// weight 0.
+			return w, w
+		}
+		return matched, total
+	case string:
+		bb, ok := b.(string)
+		if !ok {
+			return 0, 1
+		}
+		if aa == bb {
+			return w, w
+		}
+		if _, isStringAsMap := ctx.stringAsMapFields[key]; isStringAsMap {
+			nctx := ctx.differ.nested.newContext(nil)
+			na, errA := nctx.decode([]byte(aa), 0)
+			nb, errB := nctx.decode([]byte(bb), 1)
+			if errA == nil && errB == nil {
+				basePath, path := ctx.basePath, ctx.path
+				ctx.basePath, ctx.path = ctx.pointer()+"#", nil
+				matched, total = ctx.similarity(na, nb, "")
+				ctx.basePath, ctx.path = basePath, path
+				return matched, total
+			}
+		}
+		return 0, w
+	}
+	if ctx.equal(a, b, key) {
+		return w, w
+	}
+	return 0, w
+}
+
+// leaves returns the number of leaves of v, not counting ignored fields.
+func (ctx *context) leaves(v interface{}) int {
+	n := 0
+	switch vv := v.(type) {
+	case []interface{}:
+		for _, e := range vv {
+			n += ctx.leaves(e)
+		}
+	case map[string]interface{}:
+		for k, e := range vv {
+			if _, ignored := ctx.ignoreFields[k]; !ignored {
+				n += ctx.leaves(e)
+			}
+		}
+	}
+	if n == 0 {
+		return 1
+	}
+	return n
+}
+
+// weighLeaves returns the weight of the leaves of v, the value at the
+// current path, not counting ignored fields. An object or array without
+// leaves of non-zero weight weighs like a leaf, as leaves counts it as one.
+func (ctx *context) weighLeaves(v interface{}) float64 {
+	var n float64
+	w := ctx.weight
+	switch vv := v.(type) {
+	case []interface{}:
+		for i, e := range vv {
+			ctx.push(strconv.Itoa(i))
+			n += ctx.weighLeaves(e)
+			ctx.pop()
+		}
+	case map[string]interface{}:
+		for k, e := range vv {
+			if _, ignored := ctx.ignoreFields[k]; !ignored {
+				ctx.push(k)
+				ctx.weighMember(k)
+				n += ctx.weighLeaves(e)
+				ctx.weight = w
+				ctx.pop()
+			}
+		}
+	}
+	if n == 0 {
+		return w
+	}
+	return n
+}
+
+// weighMember sets ctx.weight to the weight Weights gives the member k,
+// whose key has been pushed to the path, if any. A JSON Pointer takes
+// precedence over a key naming the same member. Callers restore ctx.weight
+// once done with the member.
+func (ctx *context) weighMember(k string) {
+	if ctx.differ.weightFields == nil && ctx.differ.weightPaths == nil {
+		return
+	}
+	if w, found := ctx.differ.weightPaths[ctx.pointer()]; found {
+		ctx.weight = w
+	} else if w, found := ctx.differ.weightFields[k]; found {
+		ctx.weight = w
+	}
+}
@@ -0,0 +1,114 @@
+package jsondiff
+
+import (
+	"errors"
+	"math"
+	"testing"
+)
+
+func TestSimilarity(t *testing.T) {
+	opts := Options{IgnoreFields: []string{"id"}, FuzzyFields: []string{"ts"}, StringAsMapFields: []string{"doc"}}
+	cases := []struct {
+		a, b     string
+		expected float64
+	}{
+		{`{"a": 1, "b": [1, 2], "c": {"d": null}}`, `{"c": {"d": null}, "b": [1, 2], "a": 1}`, 1},
+		{`{"a": 1, "b": 2}`, `{"c": 1, "d": 2}`, 0},
+		{`{"a": 1, "b": 2}`, `{"a": 1, "b": 3}`, 0.5},
+		{`{"a": 1, "b": 2, "id": 1}`, `{"a": 1, "b": 3, "id": 2}`, 0.5},
+		{`{"a": 1, "ts": 5}`, `{"a": 2, "ts": 6}`, 0.5},
+		{`{"a": 1}`, `{"a": 1, "n": {"x": [1, 2, 3]}}`, 0.25},
+		{`[1, 2, 3, 4]`, `[1, 2]`, 0.5},
+		{`{"a": "x"}`, `{"a": {"p": 1, "q": 2}}`, 0},
+		{`{"doc": "{\"x\": 1, \"y\": 2}"}`, `{"doc": "{\"x\": 1, \"y\": 3}"}`, 0.5},
+		{`{"id": 1}`, `{"id": 2}`, 1},
+		{`[]`, `[]`, 1},
+		{`1`, `"1"`, 0},
+	}
+	for _, c := range cases {
+		got, err := Similarity([]byte(c.a), []byte(c.b), &opts)
+		if err != nil {
+			t.Errorf("%s, %s: %v", c.a, c.b, err)
+			continue
+		}
+		if math.Abs(got-c.expected) > 1e-9 {
+			t.Errorf("%s, %s: got %v, expected %v", c.a, c.b, got, c.expected)
+		}
+	}
+	if _, err := Similarity([]byte(`{`), []byte(`{}`), &opts); err == nil {
+		t.Error("no error for invalid JSON")
+	}
+
+	// Values nested deeper than MaxDepth stop the comparison like in
+	// CompareErr.
+	var depthErr *DepthError
+	for _, b := range []string{`[[[[2]]]]`, `[[[[1]]]]`} {
+		if _, err := Similarity([]byte(`[[[[1]]]]`), []byte(b), &Options{MaxDepth: 2}); !errors.As(err, &depthErr) {
+			t.Errorf("%s: got %v, expected a *DepthError", b, err)
+		}
+		if _, _, err := CompareErr([]byte(`[[[[1]]]]`), []byte(b), &Options{MaxDepth: 2}); !errors.As(err, &depthErr) {
+			t.Errorf("%s: CompareErr got %v", b, err)
+		}
+	}
+}
+
+func TestSimilarityWeights(t *testing.T) {
+	cases := []struct {
+		a, b     string
+		weights  map[string]float64
+		expected float64
+	}{
+		{`{"name": "a", "notes": "x"}`, `{"name": "a", "notes": "y"}`, map[string]float64{"notes": 0}, 1},
+		{`{"a": 1, "b": 2, "c": 3}`, `{"a": 1, "b": 0, "c": 0}`, nil, 1.0 / 3},
+		{`{"a": 1, "b": 2, "c": 3}`, `{"a": 1, "b": 0, "c": 0}`, map[string]float64{"a": 2}, 2.0 / 4},
+		// Subtrees weigh every leaf beneath them, unless overridden deeper.
+		{`{"meta": {"x": 1, "y": 2}, "id": 1}`, `{"meta": {"x": 1, "y": 3}, "id": 2}`,
+			map[string]float64{"meta": 2}, 2.0 / 5},
+		{`{"meta": {"x": 1, "y": 2}, "id": 1}`, `{"meta": {"x": 1, "y": 3}, "id": 2}`,
+			map[string]float64{"meta": 2, "/meta/y": 0}, 2.0 / 3},
+		{`{"meta": {"x": 1, "y": 2}}`, `{"meta": {"x": 1, "y": 3}}`,
+			map[string]float64{"/meta/y": 3, "y": 0}, 1.0 / 4},
+		// Added and removed subtrees weigh as much as their leaves.
+		{`{"a": 1}`, `{"a": 1, "n": {"x": [1, 2]}}`, map[string]float64{"n": 3}, 1.0 / 7},
+		{`{"a": 1, "n": [1, {"x": 1}]}`, `{"a": 1}`, map[string]float64{"x": 0.5}, 1.0 / 2.5},
+		{`{"a": 1, "n": {"x": 1}}`, `{"a": 1}`, map[string]float64{"x": 0}, 1.0 / 2},
+		// Inside StringAsMapFields documents.
+		{`{"doc": "{\"x\": 1, \"y\": 2}"}`, `{"doc": "{\"x\": 1, \"y\": 3}"}`, map[string]float64{"/doc#/y": 3}, 1.0 / 4},
+		{`{"doc": "{\"x\": 1, \"y\": 2}"}`, `{"doc": "{\"x\": 1, \"y\": 3}"}`, map[string]float64{"doc": 0}, 1},
+	}
+	for _, c := range cases {
+		opts := Options{StringAsMapFields: []string{"doc"}, Weights: c.weights}
+		got, err := Similarity([]byte(c.a), []byte(c.b), &opts)
+		if err != nil {
+			t.Errorf("%s, %s: %v", c.a, c.b, err)
+			continue
+		}
+		if math.Abs(got-c.expected) > 1e-9 {
+			t.Errorf("%s, %s with %v: got %v, expected %v", c.a, c.b, c.weights, got, c.expected)
+		}
+	}
+
+	// The weights decide which candidate CompareAny picks.
+	a := []byte(`{"name": "x", "tax_id": 1, "notes": "n", "city": "c"}`)
+	candidates := [][]byte{
+		[]byte(`{"name": "y", "tax_id": 2, "notes": "n", "city": "c"}`),
+		[]byte(`{"name": "x", "tax_id": 1, "notes": "m", "city": "d"}`),
+	}
+	for _, c := range []struct {
+		weights map[string]float64
+		best    int
+	}{
+		{nil, 0},
+		{map[string]float64{"name": 10, "tax_id": 10}, 1},
+	} {
+		if best, _, _ := CompareAny(a, candidates, &Options{Weights: c.weights}); best != c.best {
+			t.Errorf("%v: got candidate %d, expected %d", c.weights, best, c.best)
+		}
+	}
+
+	for _, w := range []float64{-1, math.NaN(), math.Inf(1)} {
+		if err := (&Options{Weights: map[string]float64{"a": w}}).Validate(); err == nil {
+			t.Errorf("weight %v accepted", w)
+		}
+	}
+}
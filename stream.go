@@ -0,0 +1,195 @@
+package jsondiff
+
+import (
+	"errors"
+	"io"
+	"sort"
+	"time"
+)
+
+// StreamCompare compares two JSON documents received in chunks, without
+// buffering them. The chunks of the first document are written with WriteA
+// and those of the second one with WriteB, in any interleaving, and the
+// documents are decoded as the chunks arrive. Close compares them. With
+// TrackPositions, ShowPositions, LenientParsing or DetectDuplicateKeys, the
+// documents are buffered until Close, like by CompareReaders.
+//
+// A StreamCompare must not be used from several goroutines at once.
+type StreamCompare struct {
+	ctx    *context
+	start  time.Time
+	sides  [2]*streamSide
+	closed bool
+	result Result
+	err    error
+}
+
+// streamSide decodes one of the documents of a StreamCompare.
+type streamSide struct {
+	r *chunkReader
+	// waiting is set while the decoder waits for the next chunk, finished
+	// once it returned.
+	waiting, finished bool
+	// size is the number of bytes written and newlines the offsets of the
+	// line breaks among them, to tell the position of a syntax error.
+	size     int
+	newlines []int
+	v        interface{}
+	err      error
+}
+
+// NewStreamCompare returns a StreamCompare comparing two documents with the
+// given options.
+func NewStreamCompare(opts *Options) *StreamCompare {
+	return differOf(opts).NewStreamCompare()
+}
+
+// NewStreamCompare is like the package-level NewStreamCompare, using the
+// options of d.
+func (d *Differ) NewStreamCompare() *StreamCompare {
+	s := &StreamCompare{ctx: d.newContext(nil), start: time.Now()}
+	s.ctx.collect = true
+	for i := range s.sides {
+		side := &streamSide{r: &chunkReader{
+			need: make(chan struct{}),
+			data: make(chan []byte),
+			done: make(chan struct{}),
+		}}
+		s.sides[i] = side
+		go func(i int) {
+			defer close(side.r.done)
+			side.v, side.err = s.ctx.decodeStream(side.r, i)
+		}(i)
+	}
+	return s
+}
+
+// ErrStreamClosed is returned by writes to a closed StreamCompare.
+var ErrStreamClosed = errors.New("jsondiff: write to a closed StreamCompare")
+
+// WriteA appends p to the first document. The chunk is consumed when WriteA
+// returns, so p may be reused.
+func (s *StreamCompare) WriteA(p []byte) (int, error) {
+	return s.write(0, p)
+}
+
+// WriteB appends p to the second document, like WriteA.
+func (s *StreamCompare) WriteB(p []byte) (int, error) {
+	return s.write(1, p)
+}
+
+func (s *StreamCompare) write(i int, p []byte) (int, error) {
+	if s.closed {
+		return 0, ErrStreamClosed
+	}
+	side := s.sides[i]
+	for j, c := range p {
+		if c == '\n' {
+			side.newlines = append(side.newlines, side.size+j)
+		}
+	}
+	side.size += len(p)
+	// Data following the value, or an error, is not read by the decoder.
+	if len(p) > 0 && side.idle() {
+		side.r.data <- p
+		side.waiting = false
+		side.idle()
+	}
+	return len(p), nil
+}
+
+// Close ends both documents and compares them like CompareDetail. If either
+// document is not valid JSON, including a truncated one, the error is an
+// *InvalidJSONError, or both of them joined with errors.Join, like by
+// CompareErr. Close must be called to release the decoders, even if the
+// result is not needed. Calling Close again returns the same result.
+func (s *StreamCompare) Close() (Result, error) {
+	if s.closed {
+		return s.result, s.err
+	}
+	s.closed = true
+	// The first document is decoded to its end before the second one, which
+	// keeps the order of its duplicate keys.
+	for _, side := range s.sides {
+		if side.idle() {
+			close(side.r.data)
+		}
+		<-side.r.done
+	}
+	a, b := s.sides[0], s.sides[1]
+	if diff, msg, invalid := invalidJSON(a.err, b.err); invalid {
+		var errs []error
+		for i, side := range s.sides {
+			if side.err != nil {
+				errs = append(errs, &InvalidJSONError{Document: i + 1, Position: side.position(errorOffset(side.err, side.size)), Err: side.err})
+			}
+		}
+		s.result, s.err = s.ctx.detail(diff, msg, s.start), errors.Join(errs...)
+	} else {
+		diff, text := s.ctx.compareValues(a.v, b.v)
+		s.result = s.ctx.detail(diff, text, s.start)
+	}
+	return s.result, s.err
+}
+
+// idle waits until the decoder waits for the next chunk or has finished and
+// reports whether it waits.
+func (side *streamSide) idle() bool {
+	if !side.waiting && !side.finished {
+		select {
+		case <-side.r.need:
+			side.waiting = true
+		case <-side.r.done:
+			side.finished = true
+		}
+	}
+	return side.waiting
+}
+
+// position returns the position of the byte at off.
+func (side *streamSide) position(off int) Position {
+	if off > side.size {
+		off = side.size
+	}
+	if off < 0 {
+		off = 0
+	}
+	line := sort.SearchInts(side.newlines, off)
+	p := Position{Offset: off, Line: line + 1, Column: off + 1}
+	if line > 0 {
+		p.Column = off - side.newlines[line-1]
+	}
+	return p
+}
+
+// chunkReader passes the chunks written to a StreamCompare to its decoder.
+// The decoder only runs between asking for a chunk and asking for the next
+// one, so that the decoders of both documents never run at the same time.
+type chunkReader struct {
+	// need is sent on when the decoder waits for the next chunk, which is
+	// sent on data. data is closed at the end of the document and done once
+	// the decoder returned.
+	need chan struct{}
+	data chan []byte
+	done chan struct{}
+	buf  []byte
+	eof  bool
+}
+
+func (r *chunkReader) Read(p []byte) (int, error) {
+	for len(r.buf) == 0 {
+		if r.eof {
+			return 0, io.EOF
+		}
+		r.need <- struct{}{}
+		chunk, ok := <-r.data
+		if !ok {
+			r.eof = true
+			return 0, io.EOF
+		}
+		r.buf = chunk
+	}
+	n := copy(p, r.buf)
+	r.buf = r.buf[n:]
+	return n, nil
+}
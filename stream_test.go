@@ -0,0 +1,98 @@
+package jsondiff
+
+import (
+	"math/rand"
+	"reflect"
+	"testing"
+)
+
+// streamCompare writes a and b to a StreamCompare in chunks of the sizes
+// returned by size, alternating between them, and closes it.
+func streamCompare(opts *Options, a, b []byte, size func() int) (Result, error) {
+	s := NewStreamCompare(opts)
+	for len(a) > 0 || len(b) > 0 {
+		n := size()
+		if n > len(a) {
+			n = len(a)
+		}
+		s.WriteA(a[:n])
+		a = a[n:]
+		n = size()
+		if n > len(b) {
+			n = len(b)
+		}
+		s.WriteB(b[:n])
+		b = b[n:]
+	}
+	return s.Close()
+}
+
+func TestStreamCompare(t *testing.T) {
+	opts := DefaultConsoleOptions()
+	opts.IgnoreFields = []string{"fuzz1"}
+	opts.FuzzyFields = []string{"fuzz2"}
+	opts.StringAsMapFields = []string{"stringAsMap"}
+	opts.NullAsEmpty = true
+	all := append([]struct {
+		a      string
+		b      string
+		result Difference
+	}{
+		{"{\n  \"a\": [1,\n  2,\n", `{"a": [1, 2]}`, FirstArgIsInvalidJson},
+		{`{"a": 1}`, "{\n  \"a\": 1,\n  ]\n}", SecondArgIsInvalidJson},
+		{``, `{"a": 1} trailing`, FirstArgIsInvalidJson},
+		{`[1, 2] [3]`, `[1, 2]`, FullMatch},
+		{`{"a": "\u00e9\n"}`, "{\"a\": \"\u00e9\\n\", \"b\": [true, false, null]}", SubsetMatch},
+	}, cases...)
+	rnd := rand.New(rand.NewSource(1))
+	sizes := map[string]func() int{
+		"one byte": func() int { return 1 },
+		"random":   func() int { return rnd.Intn(8) },
+		"whole":    func() int { return 1 << 20 },
+	}
+	for _, c := range all {
+		a, b := []byte(c.a), []byte(c.b)
+		diff, text, err := CompareErr(a, b, &opts)
+		if diff != c.result {
+			t.Fatalf("%s, %s: Compare returned %s, expected %s", c.a, c.b, diff, c.result)
+		}
+		for name, size := range sizes {
+			r, serr := streamCompare(&opts, a, b, size)
+			if r.Difference != diff || r.Text != text {
+				t.Errorf("%s: %s, %s: got %s:\n%s\nexpected %s:\n%s", name, c.a, c.b, r.Difference, r.Text, diff, text)
+			}
+			if !reflect.DeepEqual(serr, err) {
+				t.Errorf("%s: %s, %s: got error %v, expected %v", name, c.a, c.b, serr, err)
+			}
+		}
+	}
+}
+
+func TestStreamCompareBuffered(t *testing.T) {
+	opts := Options{ShowPositions: true, DetectDuplicateKeys: true, LenientParsing: true}
+	a := []byte("{\n  \"a\": 1, \"a\": 1,\n  // comment\n  \"b\": [1, 2,],\n}")
+	b := []byte("{\"b\": [1, 3], \"a\": 1, \"c\": {\"d\": 1, \"d\": 2}}")
+	expected := CompareDetail(a, b, &opts)
+	r, err := streamCompare(&opts, a, b, func() int { return 3 })
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Stats.Duration, expected.Stats.Duration = 0, 0
+	if !reflect.DeepEqual(r, expected) {
+		t.Errorf("got %+v, expected %+v", r, expected)
+	}
+}
+
+func TestStreamCompareClosed(t *testing.T) {
+	s := NewStreamCompare(&Options{})
+	s.WriteA([]byte(`[1]`))
+	s.WriteB([]byte(`[1`))
+	r1, err1 := s.Close()
+	r2, err2 := s.Close()
+	if r1.Difference != SecondArgIsInvalidJson || err1 == nil || r2.Difference != r1.Difference || err2 != err1 {
+		t.Errorf("got %s %v, then %s %v", r1.Difference, err1, r2.Difference, err2)
+	}
+	if _, err := s.WriteA([]byte(`x`)); err != ErrStreamClosed {
+		t.Errorf("got %v, expected ErrStreamClosed", err)
+	}
+}
@@ -0,0 +1,186 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"html"
+	"strings"
+)
+
+// hasTemplates reports whether any of the tags contains a placeholder.
+func (opts *Options) hasTemplates() bool {
+	for _, tag := range []*Tag{&opts.Normal, &opts.Added, &opts.Removed, &opts.Changed, &opts.Warning} {
+		if strings.IndexByte(tag.Begin, '{') >= 0 || strings.IndexByte(tag.End, '{') >= 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// isPlaceholder reports whether s, braces included, is a placeholder
+// supported in tags.
+func isPlaceholder(s string) bool {
+	switch s {
+	case "{path}", "{key}", "{type}", "{kind}":
+		return true
+	}
+	return false
+}
+
+// templatePart is a literal run of a tag, or one of its placeholders with
+// the braces left out.
+type templatePart struct {
+	text        string
+	placeholder bool
+}
+
+// template is a tag string split at its placeholders.
+type template []templatePart
+
+// compiledTag holds the templates of the Begin and End strings of a tag.
+type compiledTag [2]template
+
+// parseTemplate splits s at its placeholders. Braces that do not enclose a
+// supported placeholder are literal text.
+func parseTemplate(s string) template {
+	var t template
+	lit := 0
+	for i := 0; i < len(s); i++ {
+		if s[i] != '{' {
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			break
+		}
+		if !isPlaceholder(s[i : i+end+1]) {
+			continue
+		}
+		if lit < i {
+			t = append(t, templatePart{text: s[lit:i]})
+		}
+		t = append(t, templatePart{text: s[i+1 : i+end], placeholder: true})
+		i += end
+		lit = i + 1
+	}
+	if lit < len(s) {
+		t = append(t, templatePart{text: s[lit:]})
+	}
+	return t
+}
+
+// compileTags parses the tags of opts in the order of tagIndex.
+func compileTags(opts *Options) [5]compiledTag {
+	var c [5]compiledTag
+	for i, tag := range []*Tag{&opts.Normal, &opts.Added, &opts.Removed, &opts.Changed, &opts.Warning} {
+		c[i] = compiledTag{parseTemplate(tag.Begin), parseTemplate(tag.End)}
+	}
+	return c
+}
+
+// tagKinds are the values of {kind} for the tags in the order of tagIndex.
+var tagKinds = [5]string{"unchanged", KindAdded.String(), KindRemoved.String(), KindChanged.String(), "warning"}
+
+// tagIndex returns the index of tag, one of the tags of ctx.opts, in the
+// array compileTags returns.
+func (ctx *context) tagIndex(tag *Tag) int {
+	switch tag {
+	case &ctx.opts.Added:
+		return 1
+	case &ctx.opts.Removed:
+		return 2
+	case &ctx.opts.Changed:
+		return 3
+	case &ctx.opts.Warning:
+		return 4
+	}
+	return 0
+}
+
+// tagAt returns the tag of ctx.opts at index i, as returned by tagIndex.
+func (ctx *context) tagAt(i int) *Tag {
+	return [...]*Tag{&ctx.opts.Normal, &ctx.opts.Added, &ctx.opts.Removed, &ctx.opts.Changed, &ctx.opts.Warning}[i]
+}
+
+// carryLine returns line, the state of a line rendered by from, with the
+// tags of from replaced by those of ctx, so that ctx continues the line.
+// Tags with expanded placeholders are kept, since only their End is used.
+func (ctx *context) carryLine(from *context, line lineState) lineState {
+	if line.lastTag == nil {
+		return line
+	}
+	tag := ctx.tagAt(from.tagIndex(line.lastTag))
+	if line.openTag == line.lastTag {
+		line.openTag = tag
+	}
+	line.lastTag = tag
+	return line
+}
+
+// expandTag returns tag with its placeholders replaced by the values of the
+// entry being rendered. The tags are parsed once by the Differ, so only the
+// values of the placeholders they contain are computed.
+func (ctx *context) expandTag(tag *Tag) *Tag {
+	i := ctx.tagIndex(tag)
+	c := &ctx.differ.tags[i]
+	return &Tag{Begin: ctx.expandTemplate(c[0], i), End: ctx.expandTemplate(c[1], i)}
+}
+
+// expandTemplate returns t filled in for the entry being rendered with the
+// tag at index i.
+func (ctx *context) expandTemplate(t template, i int) string {
+	if len(t) == 1 && !t[0].placeholder {
+		return t[0].text
+	}
+	var b strings.Builder
+	for _, part := range t {
+		if !part.placeholder {
+			b.WriteString(part.text)
+			continue
+		}
+		switch part.text {
+		case "path":
+			b.WriteString(ctx.escapeTemplateValue(ctx.pointer()))
+		case "key":
+			if len(ctx.path) > 0 {
+				b.WriteString(ctx.escapeTemplateValue(ctx.path[len(ctx.path)-1]))
+			}
+		case "type":
+			if i != 0 {
+				b.WriteString(typeName(ctx.entryValue))
+			}
+		case "kind":
+			b.WriteString(tagKinds[i])
+		}
+	}
+	return b.String()
+}
+
+// escapeTemplateValue makes s safe for use in text and attribute values for
+// HTML output, and escapes it like rendered strings otherwise. Invalid UTF-8
+// is replaced for HTML output.
+func (ctx *context) escapeTemplateValue(s string) string {
+	if ctx.opts.EscapeHTML {
+		return html.EscapeString(strings.ToValidUTF8(s, "\uFFFD"))
+	}
+	var buf bytes.Buffer
+	writeEscaped(&buf, s, ctx.opts.escapeMode())
+	return buf.String()
+}
+
+// typeName returns the name of the JSON type of v.
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case bool:
+		return "boolean"
+	case json.Number:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "null"
+}
@@ -0,0 +1,122 @@
+package jsondiff
+
+import (
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTagTemplates(t *testing.T) {
+	opts := DefaultHTMLClassOptions()
+	opts.Changed.Begin = `<span class="jsondiff-{kind}" data-path="{path}" data-key="{key}" data-type="{type}">`
+	opts.Added.Begin = `<span class="jsondiff-{kind}" data-path="{path}" data-type="{type}">`
+	a := `{"items": [{"price": 3}, {"price": 1}], "a\"b": 1}`
+	b := `{"items": [{"price": 3}, {"price": "2"}, {"n": 1}], "a\"b": 2}`
+	_, msg := Compare([]byte(a), []byte(b), &opts)
+	for _, s := range []string{
+		`<span class="jsondiff-changed" data-path="/items/1/price" data-key="price" data-type="string">1 => "2"</span>`,
+		`<span class="jsondiff-changed" data-path="/a&#34;b" data-key="a&#34;b" data-type="number">1 => 2</span>`,
+		`<span class="jsondiff-added" data-path="/items/2" data-type="object">{`,
+	} {
+		if !strings.Contains(msg, s) {
+			t.Errorf("%s not found in:\n%s", s, msg)
+		}
+	}
+	if strings.Count(msg, `data-path="/items/2"`) != 3 {
+		t.Errorf("continuation lines must repeat the entry's tag:\n%s", msg)
+	}
+}
+
+func TestHasTemplates(t *testing.T) {
+	opts := DefaultHTMLOptions()
+	if opts.hasTemplates() {
+		t.Errorf("HTML preset has no templates")
+	}
+	opts.Normal.End = "{kind}"
+	if !opts.hasTemplates() {
+		t.Errorf("template in Normal.End not detected")
+	}
+}
+
+func TestParseTemplate(t *testing.T) {
+	for _, c := range []struct {
+		s        string
+		expected template
+	}{
+		{``, nil},
+		{`<b>`, template{{text: `<b>`}}},
+		{`{kind}`, template{{text: "kind", placeholder: true}}},
+		{`a{x}{path}{{key}}`, template{{text: "a{x}"}, {text: "path", placeholder: true}, {text: "{"}, {text: "key", placeholder: true}, {text: "}"}}},
+		{`{type`, template{{text: "{type"}}},
+	} {
+		if got := parseTemplate(c.s); !reflect.DeepEqual(got, c.expected) {
+			t.Errorf("%q: got %+v, expected %+v", c.s, got, c.expected)
+		}
+	}
+}
+
+func TestTagTemplatesPerDiffer(t *testing.T) {
+	opts := DefaultHTMLClassOptions()
+	opts.Changed.Begin = `<span data-path="{path}">`
+	opts.StringAsMapFields = []string{"s"}
+	other := DefaultHTMLClassOptions()
+	other.Changed.Begin = `<span data-kind="{kind}">`
+	d1, err := NewDiffer(opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d2, err := NewDiffer(other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if &d1.nested.tags[3][0][0] != &d1.tags[3][0][0] {
+		t.Errorf("nested Differ parsed the tags again")
+	}
+	a := []byte(`{"a": 1, "s": "{\"b\": 1}"}`)
+	b := []byte(`{"a": 2, "s": "{\"b\": 2}"}`)
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		d, expected, unexpected := d1, `data-path="/s#/b"`, `data-kind`
+		if i%2 == 1 {
+			d, expected, unexpected = d2, `data-kind="changed"`, `data-path`
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, msg := d.Compare(a, b)
+				if !strings.Contains(msg, expected) || strings.Contains(msg, unexpected) {
+					t.Errorf("templates of another Differ used:\n%s", msg)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func BenchmarkCompareTemplates(b *testing.B) {
+	opts := DefaultHTMLClassOptions()
+	opts.Added.Begin = `<span class="jsondiff-{kind}" data-path="{path}">`
+	opts.Removed.Begin = `<span class="jsondiff-{kind}" data-path="{path}">`
+	opts.Changed.Begin = `<span class="jsondiff-{kind}" data-path="{path}" data-type="{type}">`
+	docA, docB := largeObjects(1000)
+	b.Run("Cold", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			Compare(docA, docB, &opts)
+		}
+	})
+	b.Run("Warm", func(b *testing.B) {
+		d, err := NewDiffer(opts)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			d.Compare(docA, docB)
+		}
+	})
+}
@@ -0,0 +1,507 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// CompareStream compares the documents read from a and b like CompareReaders,
+// but only classifies how they differ, without rendering the difference. The
+// documents are walked token by token in lockstep and never decoded as a
+// whole, so that memory stays small for documents of any size: only the
+// values of object members whose keys appear at different positions in both
+// documents are decoded and held until the member of the other document is
+// found, along with the keys of the objects being compared.
+//
+// The Difference is the same Compare returns, with IgnoreFields, FuzzyFields,
+// StringAsMapFields, NullAsEmpty, DisallowTrailingData and AutoDecompress
+// applied. Options only affecting the rendered output are ignored.
+// LenientParsing, DetectDuplicateKeys, EmptyInputAsNull, OnDifference,
+// Severities and an InvalidUTF8 mode other than InvalidUTF8Replace are not
+// supported and make CompareStream return an error, as does a key repeated
+// within an object, whose last value Compare would use. An error reading a
+// or b is returned like by CompareReaders. On every error the Difference is
+// NoMatch.
+func CompareStream(a, b io.Reader, opts *Options) (Difference, error) {
+	return differOf(opts).CompareStream(a, b)
+}
+
+// CompareStream is like the package-level CompareStream, using the options of
+// d.
+func (d *Differ) CompareStream(a, b io.Reader) (Difference, error) {
+	switch {
+	case d.opts.LenientParsing:
+		return NoMatch, errors.New("jsondiff: CompareStream does not support LenientParsing")
+	case d.opts.DetectDuplicateKeys:
+		return NoMatch, errors.New("jsondiff: CompareStream does not support DetectDuplicateKeys")
+	case d.opts.EmptyInputAsNull:
+		return NoMatch, errors.New("jsondiff: CompareStream does not support EmptyInputAsNull")
+	case d.opts.OnDifference != nil:
+		return NoMatch, errors.New("jsondiff: CompareStream does not support OnDifference")
+	case d.grading():
+		return NoMatch, errors.New("jsondiff: CompareStream does not support Severities")
+	case d.opts.InvalidUTF8 != InvalidUTF8Replace:
+		return NoMatch, errors.New("jsondiff: CompareStream only supports InvalidUTF8Replace")
+	}
+	c := tokenComparer{differ: d}
+	names := [2]string{"first", "second"}
+	for i, r := range [2]io.Reader{a, b} {
+		doc := &tokenDoc{r: &errReader{r: r}}
+		c.docs[i] = doc
+		src := io.Reader(doc.r)
+		if d.opts.AutoDecompress {
+			var err error
+			if src, err = decompress(src); err != nil {
+				return NoMatch, fmt.Errorf("jsondiff: reading %s document: %w", names[i], err)
+			}
+		}
+		doc.src = &bomReader{r: src}
+		doc.dec = json.NewDecoder(doc.src)
+		doc.dec.UseNumber()
+	}
+	diff := c.run()
+	for i, doc := range c.docs {
+		if err := readError(doc.r, doc.err); err != nil {
+			return NoMatch, fmt.Errorf("jsondiff: reading %s document: %w", names[i], err)
+		}
+	}
+	if c.err != nil {
+		return NoMatch, c.err
+	}
+	if invalid, _, ok := invalidJSON(c.docs[0].err, c.docs[1].err); ok {
+		return invalid, nil
+	}
+	return diff, nil
+}
+
+// tokenComparer compares two documents token by token for CompareStream.
+type tokenComparer struct {
+	differ *Differ
+	docs   [2]*tokenDoc
+	// err is the error ending the comparison other than one of reading or
+	// decoding a document, which is recorded in the document.
+	err error
+}
+
+// tokenAbort is raised as a panic to end the comparison once err of the
+// comparer or one of the documents is set, and recovered by run.
+type tokenAbort struct{}
+
+// run compares the documents and finishes reading them. If either document
+// turns out not to be valid JSON, the other one is still read to its end, so
+// that both are classified like by Compare.
+func (c *tokenComparer) run() (diff Difference) {
+	aborted := func() (aborted bool) {
+		defer func() {
+			if r := recover(); r != nil {
+				if _, ok := r.(tokenAbort); !ok {
+					panic(r)
+				}
+				aborted = true
+			}
+		}()
+		diff = c.compare(c.docs[0], c.docs[1], "")
+		return false
+	}()
+	if aborted {
+		if c.err != nil || c.docs[0].r.err != nil || c.docs[1].r.err != nil {
+			return NoMatch
+		}
+		for _, doc := range c.docs {
+			if doc.err == nil {
+				doc.drain()
+			}
+		}
+	}
+	for _, doc := range c.docs {
+		if doc.err == nil {
+			doc.finish(c.differ.opts.DisallowTrailingData)
+		}
+	}
+	return diff
+}
+
+// tokenSource is a document being walked by a tokenComparer: a document being
+// read or a value held in memory.
+type tokenSource interface {
+	// token returns the next token.
+	token() json.Token
+	// more reports whether the current array or object has another
+	// element.
+	more() bool
+	// value returns the next value, decoded.
+	value() interface{}
+	// skip skips the next value.
+	skip()
+	// close skips the rest of the array or object whose opening delimiter
+	// was just returned by token.
+	close()
+}
+
+// compare compares the next values of a and b, the values of the field key,
+// like printDiff.
+func (c *tokenComparer) compare(a, b tokenSource, key string) Difference {
+	ta, tb := a.token(), b.token()
+	da, aIsDelim := ta.(json.Delim)
+	db, bIsDelim := tb.(json.Delim)
+	_, isFuzzy := c.differ.fuzzyFields[key]
+	if ta == nil || tb == nil {
+		diff := NoMatch
+		switch {
+		case isFuzzy || (ta == nil && tb == nil):
+			diff = FullMatch
+		case c.differ.opts.NullAsEmpty && aIsDelim && !a.more():
+			a.token()
+			return FullMatch
+		case c.differ.opts.NullAsEmpty && bIsDelim && !b.more():
+			b.token()
+			return FullMatch
+		}
+		if aIsDelim {
+			a.close()
+		}
+		if bIsDelim {
+			b.close()
+		}
+		return diff
+	}
+	if aIsDelim || bIsDelim {
+		diff := NoMatch
+		if aIsDelim && bIsDelim && da == db {
+			switch {
+			case isFuzzy:
+				diff = FullMatch
+			case da == '[':
+				return c.compareArrays(a, b, key)
+			default:
+				return c.compareObjects(a, b)
+			}
+		}
+		if aIsDelim {
+			a.close()
+		}
+		if bIsDelim {
+			b.close()
+		}
+		return diff
+	}
+	if !sameKind(ta, tb) {
+		return NoMatch
+	}
+	if isFuzzy {
+		return FullMatch
+	}
+	switch aa := ta.(type) {
+	case bool:
+		if aa != tb.(bool) {
+			return NoMatch
+		}
+	case json.Number:
+		if bb, ok := tb.(json.Number); !ok || aa != bb {
+			return NoMatch
+		}
+	case string:
+		bb, ok := tb.(string)
+		if !ok {
+			return NoMatch
+		}
+		if aa == bb {
+			return FullMatch
+		}
+		if _, isStringAsMap := c.differ.stringAsMapFields[key]; !isStringAsMap {
+			return NoMatch
+		}
+		diff, _ := c.differ.nested.newContext(nil).compare([]byte(aa), []byte(bb))
+		if diff.IsInvalidJson() {
+			return NoMatch
+		}
+		return diff
+	}
+	return FullMatch
+}
+
+// compareArrays compares the elements of the arrays just opened in a and b.
+func (c *tokenComparer) compareArrays(a, b tokenSource, key string) Difference {
+	diff := FullMatch
+	for a.more() && b.more() {
+		diff = combine(diff, c.compare(a, b, key))
+	}
+	for a.more() {
+		a.skip()
+		diff = combine(diff, SupersetMatch)
+	}
+	for b.more() {
+		b.skip()
+		diff = combine(diff, SubsetMatch)
+	}
+	a.token()
+	b.token()
+	return diff
+}
+
+// compareObjects compares the members of the objects just opened in a and b.
+// Members with the same key at the same position are compared as they are
+// read; any other member is decoded and held until the member with its key
+// is found in the other object, or the objects end.
+func (c *tokenComparer) compareObjects(a, b tokenSource) Difference {
+	diff := FullMatch
+	seen := [2]map[string]struct{}{{}, {}}
+	pending := [2]map[string]interface{}{{}, {}}
+	for {
+		moreA, moreB := a.more(), b.more()
+		if !moreA && !moreB {
+			break
+		}
+		var ka, kb string
+		if moreA {
+			ka = c.key(a, seen[0], 0)
+		}
+		if moreB {
+			kb = c.key(b, seen[1], 1)
+		}
+		if moreA && moreB && ka == kb {
+			if _, ignored := c.differ.ignoreFields[ka]; ignored {
+				a.skip()
+				b.skip()
+			} else {
+				diff = combine(diff, c.compare(a, b, ka))
+			}
+			continue
+		}
+		if moreA {
+			diff = combine(diff, c.member(a, ka, 0, pending))
+		}
+		if moreB {
+			diff = combine(diff, c.member(b, kb, 1, pending))
+		}
+	}
+	a.token()
+	b.token()
+	for _, held := range pending {
+		for k := range held {
+			if c.differ.missingMatches(k) {
+				delete(held, k)
+			}
+		}
+	}
+	if len(pending[0]) > 0 {
+		diff = combine(diff, SupersetMatch)
+	}
+	if len(pending[1]) > 0 {
+		diff = combine(diff, SubsetMatch)
+	}
+	return diff
+}
+
+// key reads the key of the next member of the object of document i being
+// compared, where the keys already read are seen.
+func (c *tokenComparer) key(s tokenSource, seen map[string]struct{}, i int) string {
+	k := s.token().(string)
+	if _, ok := seen[k]; ok {
+		doc := "first"
+		if i == 1 {
+			doc = "second"
+		}
+		c.err = fmt.Errorf("jsondiff: CompareStream does not support the repeated key %q in the %s document", k, doc)
+		panic(tokenAbort{})
+	}
+	seen[k] = struct{}{}
+	return k
+}
+
+// member handles the member with key k of the object of document i being
+// compared, whose key does not match that of the member read from the other
+// document. It is compared with the held member of the other document if
+// there is one, and held itself otherwise.
+func (c *tokenComparer) member(s tokenSource, k string, i int, pending [2]map[string]interface{}) Difference {
+	if _, ignored := c.differ.ignoreFields[k]; ignored {
+		s.skip()
+		return FullMatch
+	}
+	other := pending[1-i]
+	v, ok := other[k]
+	if !ok {
+		pending[i][k] = s.value()
+		return FullMatch
+	}
+	delete(other, k)
+	if i == 0 {
+		return c.compare(s, newTreeSource(v), k)
+	}
+	return c.compare(newTreeSource(v), s, k)
+}
+
+// tokenDoc is a document read by CompareStream.
+type tokenDoc struct {
+	r   *errReader
+	src io.Reader
+	dec *json.Decoder
+	// depth is the number of arrays and objects opened and not yet closed,
+	// and started is set once the first token is read.
+	depth   int
+	started bool
+	err     error
+}
+
+func (doc *tokenDoc) fail(err error) {
+	doc.err = err
+	panic(tokenAbort{})
+}
+
+func (doc *tokenDoc) token() json.Token {
+	t, err := doc.dec.Token()
+	if err != nil {
+		doc.fail(err)
+	}
+	doc.started = true
+	switch t {
+	case json.Delim('['), json.Delim('{'):
+		doc.depth++
+	case json.Delim(']'), json.Delim('}'):
+		doc.depth--
+	}
+	return t
+}
+
+func (doc *tokenDoc) more() bool {
+	return doc.dec.More()
+}
+
+func (doc *tokenDoc) value() interface{} {
+	var v interface{}
+	if err := doc.dec.Decode(&v); err != nil {
+		doc.fail(err)
+	}
+	return v
+}
+
+func (doc *tokenDoc) skip() {
+	if _, ok := doc.token().(json.Delim); ok {
+		doc.close()
+	}
+}
+
+func (doc *tokenDoc) close() {
+	for depth := doc.depth - 1; doc.depth > depth; {
+		doc.token()
+	}
+}
+
+// drain reads the rest of the document after the comparison ended early, to
+// find out whether it is valid JSON.
+func (doc *tokenDoc) drain() {
+	defer func() {
+		if r := recover(); r != nil {
+			if _, ok := r.(tokenAbort); !ok {
+				panic(r)
+			}
+		}
+	}()
+	if !doc.started {
+		doc.token()
+	}
+	for doc.depth > 0 {
+		doc.token()
+	}
+}
+
+// finish checks what follows the value of the document, like decodeReader.
+func (doc *tokenDoc) finish(noTrailing bool) {
+	if noTrailing {
+		doc.err = trailingData(doc.dec, doc.src)
+	}
+	if gr, ok := doc.src.(*gzipReader); ok && doc.err == nil {
+		// The checksum of a gzip stream is only verified at its end.
+		_, doc.err = io.Copy(ioutil.Discard, gr)
+	}
+}
+
+// treeSource walks a decoded value like a tokenDoc.
+type treeSource struct {
+	root  interface{}
+	stack []treeFrame
+}
+
+// treeFrame is an array or object being walked by a treeSource. The keys of
+// an object are walked in no particular order; value is set once the key at
+// i was returned.
+type treeFrame struct {
+	array  []interface{}
+	object map[string]interface{}
+	keys   []string
+	i      int
+	value  bool
+}
+
+func newTreeSource(v interface{}) *treeSource {
+	return &treeSource{root: v}
+}
+
+// next returns the next value and moves past it.
+func (s *treeSource) next() interface{} {
+	if len(s.stack) == 0 {
+		return s.root
+	}
+	f := &s.stack[len(s.stack)-1]
+	if f.array != nil {
+		f.i++
+		return f.array[f.i-1]
+	}
+	f.value = false
+	f.i++
+	return f.object[f.keys[f.i-1]]
+}
+
+func (s *treeSource) token() json.Token {
+	if n := len(s.stack); n > 0 {
+		f := &s.stack[n-1]
+		switch {
+		case f.array != nil && f.i == len(f.array):
+			s.stack = s.stack[:n-1]
+			return json.Delim(']')
+		case f.object != nil && f.i == len(f.keys):
+			s.stack = s.stack[:n-1]
+			return json.Delim('}')
+		case f.object != nil && !f.value:
+			f.value = true
+			return f.keys[f.i]
+		}
+	}
+	switch v := s.next().(type) {
+	case []interface{}:
+		s.stack = append(s.stack, treeFrame{array: v})
+		return json.Delim('[')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		s.stack = append(s.stack, treeFrame{object: v, keys: keys})
+		return json.Delim('{')
+	default:
+		return v
+	}
+}
+
+func (s *treeSource) more() bool {
+	f := &s.stack[len(s.stack)-1]
+	if f.array != nil {
+		return f.i < len(f.array)
+	}
+	return f.i < len(f.keys)
+}
+
+func (s *treeSource) value() interface{} {
+	return s.next()
+}
+
+func (s *treeSource) skip() {
+	s.next()
+}
+
+func (s *treeSource) close() {
+	s.stack = s.stack[:len(s.stack)-1]
+}
@@ -0,0 +1,277 @@
+package jsondiff
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"runtime"
+	"strconv"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestCompareStreamCorpus(t *testing.T) {
+	opts := DefaultConsoleOptions()
+	opts.IgnoreFields = []string{"fuzz1"}
+	opts.FuzzyFields = []string{"fuzz2"}
+	opts.StringAsMapFields = []string{"stringAsMap"}
+	opts.NullAsEmpty = true
+	data1, err := ioutil.ReadFile("testdata/data1.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data2, err := ioutil.ReadFile("testdata/data2.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	all := append([]struct {
+		a      string
+		b      string
+		result Difference
+	}{
+		{`{"a": 1, "b": 2, "c": 3}`, `{"c": 3, "b": 2, "a": 1}`, FullMatch},
+		{`{"a": 1, "b": 2, "c": 3}`, `{"c": 3, "a": 1}`, SupersetMatch},
+		{`{"x": 0, "a": [1, {"k": 2}], "b": 2}`, `{"a": [1, {"k": 2}, 3], "b": 2, "y": 0}`, NoMatch},
+		{`{"a": null, "b": {}}`, `{"a": [], "b": null}`, FullMatch},
+		{`{"a": null}`, `{"a": [1]}`, NoMatch},
+		{`{"fuzz2": [1]}`, `{"fuzz2": null}`, FullMatch},
+		{`{"fuzz2": [1]}`, `{"fuzz2": {}}`, NoMatch},
+		{`{"fuzz2": 1}`, `{"fuzz2": "x"}`, FullMatch},
+		{`{"fuzz1": {"a": 1}, "a": 1}`, `{"a": 1, "fuzz1": [2]}`, FullMatch},
+		{`{"stringAsMap": "{\"a\": 1, \"b\": 2}"}`, `{"stringAsMap": "{\"b\": 2, \"a\": 1}"}`, FullMatch},
+		{`{"stringAsMap": "{\"a\": 1, \"b\": 2}"}`, `{"stringAsMap": "{\"a\": 1}"}`, SupersetMatch},
+		{"[1, 2", `[1, 2]`, FirstArgIsInvalidJson},
+		{`{"a": [1, 2]}`, `{"a": [1, x]}`, SecondArgIsInvalidJson},
+		{`{"a": {"b": 1} `, `[`, BothArgsAreInvalidJson},
+		{``, `[1]`, FirstArgIsInvalidJson},
+		{`[1] garbage`, `[1]`, FullMatch},
+		{string(data1), string(data2), NoMatch},
+	}, cases...)
+	for _, c := range all {
+		expected, _, err := CompareReaders(strings.NewReader(c.a), strings.NewReader(c.b), &opts)
+		if err != nil || expected != c.result {
+			t.Fatalf("%s, %s: CompareReaders returned %s, %v, expected %s", c.a, c.b, expected, err, c.result)
+		}
+		for _, oneByte := range []bool{false, true} {
+			var a, b io.Reader = strings.NewReader(c.a), strings.NewReader(c.b)
+			if oneByte {
+				a, b = iotest.OneByteReader(a), iotest.OneByteReader(b)
+			}
+			diff, err := CompareStream(a, b, &opts)
+			if err != nil || diff != expected {
+				t.Errorf("%s, %s: got %s, %v, expected %s", c.a, c.b, diff, err, expected)
+			}
+		}
+	}
+}
+
+// randomValue returns a random JSON value nested up to depth levels deep,
+// drawing keys and scalars from small sets so that documents overlap.
+func randomValue(rnd *rand.Rand, depth int) interface{} {
+	n := 5
+	if depth == 0 {
+		n = 3
+	}
+	switch rnd.Intn(n) {
+	case 0:
+		return nil
+	case 1:
+		return json.Number(strconv.Itoa(rnd.Intn(3)))
+	case 2:
+		return []string{"x", "y", "fuzz2"}[rnd.Intn(3)]
+	case 3:
+		s := make([]interface{}, rnd.Intn(4))
+		for i := range s {
+			s[i] = randomValue(rnd, depth-1)
+		}
+		return s
+	}
+	m := make(map[string]interface{})
+	for i := rnd.Intn(5); i > 0; i-- {
+		m[[]string{"a", "b", "c", "d", "fuzz1", "fuzz2"}[rnd.Intn(6)]] = randomValue(rnd, depth-1)
+	}
+	return m
+}
+
+// mutate returns v with random changes.
+func mutate(rnd *rand.Rand, v interface{}) interface{} {
+	if rnd.Intn(6) == 0 {
+		return randomValue(rnd, 2)
+	}
+	switch vv := v.(type) {
+	case []interface{}:
+		s := make([]interface{}, 0, len(vv)+1)
+		for _, e := range vv {
+			if rnd.Intn(8) != 0 {
+				s = append(s, mutate(rnd, e))
+			}
+		}
+		if rnd.Intn(8) == 0 {
+			s = append(s, randomValue(rnd, 1))
+		}
+		return s
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(vv)+1)
+		for k, e := range vv {
+			if rnd.Intn(8) != 0 {
+				m[k] = mutate(rnd, e)
+			}
+		}
+		if rnd.Intn(8) == 0 {
+			m["e"] = randomValue(rnd, 1)
+		}
+		return m
+	}
+	return v
+}
+
+// shuffledJSON encodes v with the members of every object in random order.
+func shuffledJSON(rnd *rand.Rand, buf *bytes.Buffer, v interface{}) {
+	switch vv := v.(type) {
+	case []interface{}:
+		buf.WriteString("[")
+		for i, e := range vv {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			shuffledJSON(rnd, buf, e)
+		}
+		buf.WriteString("]")
+	case map[string]interface{}:
+		keys := make([]string, 0, len(vv))
+		for k := range vv {
+			keys = append(keys, k)
+		}
+		rnd.Shuffle(len(keys), func(i, j int) { keys[i], keys[j] = keys[j], keys[i] })
+		buf.WriteString("{")
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteString(",")
+			}
+			buf.WriteString(strconv.Quote(k) + ":")
+			shuffledJSON(rnd, buf, vv[k])
+		}
+		buf.WriteString("}")
+	default:
+		data, _ := json.Marshal(vv)
+		buf.Write(data)
+	}
+}
+
+func TestCompareStreamRandom(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, opts := range []Options{
+		{IgnoreFields: []string{"fuzz1"}},
+		{IgnoreFields: []string{"fuzz1"}, NullAsEmpty: true},
+		{FuzzyFields: []string{"fuzz2"}},
+	} {
+		for i := 0; i < 2000; i++ {
+			v := randomValue(rnd, 4)
+			var a, b bytes.Buffer
+			shuffledJSON(rnd, &a, v)
+			shuffledJSON(rnd, &b, mutate(rnd, v))
+			diff, err := CompareStream(bytes.NewReader(a.Bytes()), bytes.NewReader(b.Bytes()), &opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if equal := Equal(a.Bytes(), b.Bytes(), &opts); (diff == FullMatch) != equal {
+				t.Fatalf("%+v: %s, %s: got %s, Equal returned %v", opts, a.String(), b.String(), diff, equal)
+			}
+			if expected, _ := Compare(a.Bytes(), b.Bytes(), &opts); diff != expected {
+				t.Fatalf("%+v: %s, %s: got %s, expected %s", opts, a.String(), b.String(), diff, expected)
+			}
+		}
+	}
+}
+
+func TestCompareStreamOptions(t *testing.T) {
+	opts := Options{DisallowTrailingData: true}
+	diff, err := CompareStream(strings.NewReader(`[1] x`), strings.NewReader(`[1]`), &opts)
+	if err != nil || diff != FirstArgIsInvalidJson {
+		t.Errorf("trailing data: got %s, %v", diff, err)
+	}
+
+	var gz bytes.Buffer
+	zw := gzip.NewWriter(&gz)
+	zw.Write([]byte(`{"a": [1, 2]}`))
+	zw.Close()
+	opts = Options{AutoDecompress: true}
+	diff, err = CompareStream(&gz, strings.NewReader(`{"a": [1]}`), &opts)
+	if err != nil || diff != SupersetMatch {
+		t.Errorf("gzip: got %s, %v", diff, err)
+	}
+
+	for _, opts := range []Options{
+		{LenientParsing: true},
+		{DetectDuplicateKeys: true},
+		{OnDifference: func(string, DiffKind, interface{}, interface{}) {}},
+	} {
+		if diff, err := CompareStream(strings.NewReader(`1`), strings.NewReader(`1`), &opts); err == nil || diff != NoMatch {
+			t.Errorf("%+v: got %s, %v, expected an error", opts, diff, err)
+		}
+	}
+}
+
+func TestCompareStreamErrors(t *testing.T) {
+	opts := Options{}
+	diff, err := CompareStream(strings.NewReader(`{"a": 1, "b": 2, "a": 3}`), strings.NewReader(`{"a": 3, "b": 2}`), &opts)
+	if err == nil || !strings.Contains(err.Error(), `"a"`) || diff != NoMatch {
+		t.Errorf("duplicate key: got %s, %v", diff, err)
+	}
+
+	readErr := errors.New("broken")
+	diff, err = CompareStream(strings.NewReader(`[1, 2]`), io.MultiReader(strings.NewReader(`[1, `), iotest.ErrReader(readErr)), &opts)
+	if !errors.Is(err, readErr) || !strings.Contains(err.Error(), "second document") || diff != NoMatch {
+		t.Errorf("read error: got %s, %v", diff, err)
+	}
+}
+
+// liveHeapReader reads from r and records the size of the live heap once at
+// least at bytes are read, while the comparison reading it is under way.
+type liveHeapReader struct {
+	r    io.Reader
+	n    int
+	at   int
+	live uint64
+}
+
+func (l *liveHeapReader) Read(p []byte) (int, error) {
+	n, err := l.r.Read(p)
+	l.n += n
+	if l.live == 0 && l.n >= l.at {
+		var stats runtime.MemStats
+		runtime.GC()
+		runtime.ReadMemStats(&stats)
+		l.live = stats.HeapAlloc
+	}
+	return n, err
+}
+
+// benchmarkLiveHeap runs compare on the documents of largeObjects and
+// reports the live heap with nine tenths of the second document read.
+func benchmarkLiveHeap(b *testing.B, compare func(a, b io.Reader)) {
+	docA, docB := largeObjects(100000)
+	var live uint64
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		r := &liveHeapReader{r: bytes.NewReader(docB), at: len(docB) * 9 / 10}
+		compare(bytes.NewReader(docA), r)
+		live += r.live
+	}
+	b.ReportMetric(float64(live)/float64(b.N), "live-B/op")
+}
+
+func BenchmarkCompareStreamLarge(b *testing.B) {
+	opts := Options{}
+	benchmarkLiveHeap(b, func(x, y io.Reader) { CompareStream(x, y, &opts) })
+}
+
+func BenchmarkCompareReadersLarge(b *testing.B) {
+	opts := Options{}
+	benchmarkLiveHeap(b, func(x, y io.Reader) { CompareReaders(x, y, &opts) })
+}
@@ -0,0 +1,117 @@
+package jsondiff
+
+import "bytes"
+
+// guideMark records the start of a line indented with tree guides. The
+// guides are written once the whole output is rendered, because whether a
+// child is the last one of its container is only known by then.
+type guideMark struct {
+	buf   *bytes.Buffer
+	off   int
+	level int
+	// start is set if the line starts a child rather than continuing one,
+	// as the closing bracket of a container does.
+	start bool
+	// dropped is set for a line left out past MaxOutputBytes, which gets no
+	// guides but tells whether the lines before it are last children.
+	dropped bool
+}
+
+// dropGuide records the guide mark of a line left out past MaxOutputBytes
+// if it tells something new about the lines rendered before it: whether
+// the children enclosing them have a next sibling. Only a line at a lower
+// level than the lines dropped before it, or starting a child at the same
+// level, does, so at most two lines per level are recorded.
+func (ctx *context) dropGuide(buf *bytes.Buffer, start bool) {
+	for i := len(ctx.guides) - 1; i >= 0 && ctx.guides[i].dropped; i-- {
+		g := ctx.guides[i]
+		if g.level < ctx.level || (g.level == ctx.level && (g.start || !start)) {
+			return
+		}
+	}
+	ctx.guides = append(ctx.guides, guideMark{buf: buf, off: buf.Len(), level: ctx.level, start: start, dropped: true})
+}
+
+// writeGuides inserts the tree guides into buf, which holds the whole
+// rendered output, and moves the output marks past the inserted guides.
+func (ctx *context) writeGuides(buf *bytes.Buffer) {
+	var lines []guideMark
+	for _, g := range ctx.guides {
+		if g.buf == buf {
+			lines = append(lines, g)
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	// open[k] is the index of the line starting the child that encloses the
+	// current line at level k.
+	var open []int
+	enter := func(i int) {
+		level := lines[i].level
+		for len(open) > level {
+			open = open[:len(open)-1]
+		}
+		if lines[i].start {
+			for len(open) < level {
+				open = append(open, -1)
+			}
+			open[level-1] = i
+		}
+	}
+	last := make([]bool, len(lines))
+	for i := range lines {
+		for k := len(open); k > lines[i].level || (k == lines[i].level && lines[i].start); k-- {
+			if j := open[k-1]; j >= 0 {
+				last[j] = k > lines[i].level
+			}
+		}
+		enter(i)
+	}
+	for _, j := range open {
+		if j >= 0 {
+			last[j] = true
+		}
+	}
+
+	open = open[:0]
+	var out bytes.Buffer
+	src := buf.Bytes()
+	prev, inserted, m := 0, 0, 0
+	for i, g := range lines {
+		out.Write(src[prev:g.off])
+		prev = g.off
+		for ; m < len(ctx.marks) && ctx.marks[m].off < g.off; m++ {
+			ctx.marks[m].off += inserted
+		}
+		enter(i)
+		if g.dropped {
+			continue
+		}
+		n := out.Len()
+		for k := 1; k <= g.level; k++ {
+			j := -1
+			if k <= len(open) {
+				j = open[k-1]
+			}
+			isLast := j < 0 || last[j]
+			switch {
+			case k == g.level && g.start && isLast:
+				out.WriteString("└─ ")
+			case k == g.level && g.start:
+				out.WriteString("├─ ")
+			case isLast:
+				out.WriteString("   ")
+			default:
+				out.WriteString("│  ")
+			}
+		}
+		inserted += out.Len() - n
+	}
+	out.Write(src[prev:])
+	for ; m < len(ctx.marks); m++ {
+		ctx.marks[m].off += inserted
+	}
+	*buf = out
+}
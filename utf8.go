@@ -0,0 +1,192 @@
+package jsondiff
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// InvalidUTF8Mode selects how strings of JSON documents holding bytes that
+// are not valid UTF-8 are decoded. It applies the same way to both
+// documents, to keys and to values. Rendered output never holds invalid
+// UTF-8 whatever the mode: invalid bytes are written as \xXX escapes.
+type InvalidUTF8Mode int
+
+const (
+	// InvalidUTF8Replace decodes every invalid byte as U+FFFD, the
+	// replacement character, like encoding/json does. The replacement is
+	// made before the comparison, which is lossy: strings differing only in
+	// their invalid bytes, such as "v\xfe" and "v\xfd", are equal, and so
+	// are an invalid byte and a U+FFFD written in the document. Use
+	// InvalidUTF8Pass to compare the bytes as they are. InvalidUTF8Replace
+	// is the only mode CompareStream supports.
+	InvalidUTF8Replace InvalidUTF8Mode = iota
+	// InvalidUTF8Reject makes documents holding invalid UTF-8 invalid JSON.
+	// The error is an *InvalidUTF8Error.
+	InvalidUTF8Reject
+	// InvalidUTF8Pass keeps invalid bytes in the decoded strings, which
+	// are then equal only if their bytes are. The invalid bytes are only
+	// escaped in the rendered output.
+	InvalidUTF8Pass
+)
+
+// InvalidUTF8Error is the error of a document holding invalid UTF-8, with
+// InvalidUTF8Reject.
+type InvalidUTF8Error struct {
+	// Offset is the byte offset of the first invalid byte.
+	Offset int
+}
+
+func (e *InvalidUTF8Error) Error() string {
+	return "invalid UTF-8 at offset " + strconv.Itoa(e.Offset)
+}
+
+// invalidUTF8Offset returns the offset of the first byte of data that is not
+// valid UTF-8, or -1 if there is none.
+func invalidUTF8Offset(data []byte) int {
+	for i := 0; i < len(data); {
+		if data[i] < utf8.RuneSelf {
+			i++
+			continue
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return -1
+}
+
+// unquoteRaw returns the string literal starting at data[off], which the
+// decoder has found to be valid JSON, with its escapes decoded and the bytes
+// that are not valid UTF-8 kept as they are.
+func unquoteRaw(data []byte, off int) string {
+	var b []byte
+	for i := off + 1; i < len(data); i++ {
+		c := data[i]
+		switch {
+		case c == '"':
+			return string(b)
+		case c != '\\':
+			b = append(b, c)
+			continue
+		}
+		i++
+		switch c := data[i]; c {
+		case 'b':
+			b = append(b, '\b')
+		case 'f':
+			b = append(b, '\f')
+		case 'n':
+			b = append(b, '\n')
+		case 'r':
+			b = append(b, '\r')
+		case 't':
+			b = append(b, '\t')
+		case 'u':
+			r := hexRune(data[i+1 : i+5])
+			i += 4
+			if utf16.IsSurrogate(r) {
+				r2 := utf8.RuneError
+				if i+6 < len(data) && data[i+1] == '\\' && data[i+2] == 'u' {
+					r2 = hexRune(data[i+3 : i+7])
+				}
+				if r = utf16.DecodeRune(r, r2); r != utf8.RuneError {
+					i += 6
+				}
+			}
+			b = utf8.AppendRune(b, r)
+		default:
+			// '"', '\\' and '/'.
+			b = append(b, c)
+		}
+	}
+	return string(b)
+}
+
+// hexRune returns the rune written as the four hexadecimal digits h.
+func hexRune(h []byte) rune {
+	n, _ := strconv.ParseUint(string(h), 16, 16)
+	return rune(n)
+}
+
+// EncodingError is the error of a document starting with the byte order
+// mark of an encoding other than UTF-8, which JSON documents must be
+// encoded in.
+type EncodingError struct {
+	// Encoding is the name of the encoding, such as "UTF-16LE".
+	Encoding string
+}
+
+func (e *EncodingError) Error() string {
+	return "document is encoded in " + e.Encoding + ", not UTF-8"
+}
+
+// utf8BOM is the byte order mark some tools write at the start of UTF-8
+// documents. It is read as whitespace, which keeps the offsets of the
+// document unchanged.
+const utf8BOM = "\xef\xbb\xbf"
+
+// byteOrderMarks are the byte order marks of the encodings that documents
+// are rejected in. Those of UTF-32 come first, since that of UTF-32LE starts
+// with that of UTF-16LE.
+var byteOrderMarks = []struct {
+	mark, encoding string
+}{
+	{"\xff\xfe\x00\x00", "UTF-32LE"},
+	{"\x00\x00\xfe\xff", "UTF-32BE"},
+	{"\xff\xfe", "UTF-16LE"},
+	{"\xfe\xff", "UTF-16BE"},
+}
+
+// checkBOM returns data with a leading UTF-8 byte order mark replaced by
+// spaces, in a copy, and an *EncodingError if it starts with the byte order
+// mark of another encoding.
+func checkBOM(data []byte) ([]byte, error) {
+	if bytes.HasPrefix(data, []byte(utf8BOM)) {
+		blank := make([]byte, len(data))
+		copy(blank, "   ")
+		copy(blank[len(utf8BOM):], data[len(utf8BOM):])
+		return blank, nil
+	}
+	for _, bom := range byteOrderMarks {
+		if bytes.HasPrefix(data, []byte(bom.mark)) {
+			return data, &EncodingError{Encoding: bom.encoding}
+		}
+	}
+	return data, nil
+}
+
+// bomReader reads the document read from r with its byte order mark handled
+// like checkBOM does: a UTF-8 one is read as spaces, and another one makes
+// Read fail with an *EncodingError.
+type bomReader struct {
+	r       io.Reader
+	head    []byte
+	checked bool
+	err     error
+}
+
+func (br *bomReader) Read(p []byte) (int, error) {
+	if !br.checked {
+		br.checked = true
+		var buf [4]byte
+		n, err := io.ReadFull(br.r, buf[:])
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		br.head, br.err = checkBOM(buf[:n])
+	}
+	if br.err != nil {
+		return 0, br.err
+	}
+	if len(br.head) > 0 {
+		n := copy(p, br.head)
+		br.head = br.head[n:]
+		return n, nil
+	}
+	return br.r.Read(p)
+}
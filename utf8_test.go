@@ -0,0 +1,195 @@
+package jsondiff
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+func TestInvalidUTF8(t *testing.T) {
+	cases := []struct {
+		a, b string
+		// The verdicts with InvalidUTF8Replace, InvalidUTF8Reject and
+		// InvalidUTF8Pass.
+		diffs [3]Difference
+	}{
+		// Values.
+		{"{\"a\": \"\xff\"}", "{\"a\": \"\xfe\"}",
+			[3]Difference{FullMatch, BothArgsAreInvalidJson, NoMatch}},
+		{"{\"a\": \"x\xffy\"}", "{\"a\": \"x\xffy\"}",
+			[3]Difference{FullMatch, BothArgsAreInvalidJson, FullMatch}},
+		{"{\"a\": \"\xff\"}", `{"a": "\ufffd"}`,
+			[3]Difference{FullMatch, FirstArgIsInvalidJson, NoMatch}},
+		{"{\"a\": \"v\xfe\"}", "{\"a\": \"v\xfd\"}",
+			[3]Difference{FullMatch, BothArgsAreInvalidJson, NoMatch}},
+		{"{\"a\": \"\xff\"}", "{\"a\": \"\ufffd\"}",
+			[3]Difference{FullMatch, FirstArgIsInvalidJson, NoMatch}},
+		{"{\"a\": \"\xff\"}", `{"a": "x"}`,
+			[3]Difference{NoMatch, FirstArgIsInvalidJson, NoMatch}},
+		{"[\"\\n\xff\\u00e9\\ud83d\\ude00\"]", "[\"\\n\xff\u00e9\U0001F600\"]",
+			[3]Difference{FullMatch, BothArgsAreInvalidJson, FullMatch}},
+		// Keys.
+		{"{\"\xff\": 1}", "{\"\xfe\": 1}",
+			[3]Difference{FullMatch, BothArgsAreInvalidJson, NoMatch}},
+		{"{\"k\xff\": 1, \"b\": [1]}", "{\"k\xff\": 1, \"b\": [2]}",
+			[3]Difference{NoMatch, BothArgsAreInvalidJson, NoMatch}},
+		{"{\"k\xff\": 1}", `{"k": 1}`,
+			[3]Difference{NoMatch, FirstArgIsInvalidJson, NoMatch}},
+		// Valid UTF-8 is not affected.
+		{`{"é": "\ud83d\ude00"}`, `{"é": "😀"}`,
+			[3]Difference{FullMatch, FullMatch, FullMatch}},
+	}
+	modes := []InvalidUTF8Mode{InvalidUTF8Replace, InvalidUTF8Reject, InvalidUTF8Pass}
+	for _, c := range cases {
+		for i, mode := range modes {
+			for _, base := range []Options{DefaultConsoleOptions(), {PreserveKeyOrder: true}} {
+				opts := base
+				opts.InvalidUTF8 = mode
+				diff, text := Compare([]byte(c.a), []byte(c.b), &opts)
+				if diff != c.diffs[i] {
+					t.Errorf("mode %d: %q, %q: got %s, expected %s", mode, c.a, c.b, diff, c.diffs[i])
+				}
+				if !utf8.ValidString(text) {
+					t.Errorf("mode %d: %q, %q: invalid UTF-8 in %q", mode, c.a, c.b, text)
+				}
+				// Both documents are treated alike.
+				swapped, _ := Compare([]byte(c.b), []byte(c.a), &opts)
+				if expected := swapDifference(c.diffs[i]); swapped != expected {
+					t.Errorf("mode %d: %q, %q: got %s swapped, expected %s", mode, c.a, c.b, swapped, expected)
+				}
+				same, _ := Compare([]byte(c.a), []byte(c.a), &opts)
+				if expected := c.diffs[i]; expected == BothArgsAreInvalidJson || expected == FirstArgIsInvalidJson {
+					if same != BothArgsAreInvalidJson {
+						t.Errorf("mode %d: %q: got %s against itself", mode, c.a, same)
+					}
+				} else if same != FullMatch {
+					t.Errorf("mode %d: %q: got %s against itself", mode, c.a, same)
+				}
+			}
+		}
+	}
+}
+
+// swapDifference returns the Difference of documents compared the other way
+// around.
+func swapDifference(diff Difference) Difference {
+	switch diff {
+	case FirstArgIsInvalidJson:
+		return SecondArgIsInvalidJson
+	case SecondArgIsInvalidJson:
+		return FirstArgIsInvalidJson
+	case SupersetMatch:
+		return SubsetMatch
+	case SubsetMatch:
+		return SupersetMatch
+	}
+	return diff
+}
+
+func TestInvalidUTF8Pass(t *testing.T) {
+	opts := Options{
+		Added:       Tag{Begin: "+", End: "+"},
+		Removed:     Tag{Begin: "-", End: "-"},
+		Changed:     Tag{Begin: "~", End: "~"},
+		Indent:      " ",
+		InvalidUTF8: InvalidUTF8Pass,
+	}
+	_, text := Compare([]byte("{\"a\": \"\xff\", \"\xfe\": 1}"), []byte("{\"a\": \"\xfe\"}"), &opts)
+	expected := "{\n \"a\": ~\"\\xff\" => \"\\xfe\"~,\n -\"\\xfe\": 1-\n}"
+	if text != expected {
+		t.Errorf("got:\n%s\nexpected:\n%s", text, expected)
+	}
+}
+
+func TestInvalidUTF8Reject(t *testing.T) {
+	opts := Options{InvalidUTF8: InvalidUTF8Reject}
+	_, _, err := CompareErr([]byte("{\"a\": \"x\xff\"}"), []byte(`{}`), &opts)
+	var invalid *InvalidJSONError
+	var bad *InvalidUTF8Error
+	if !errors.As(err, &invalid) || invalid.Document != 1 || invalid.Position != (Position{8, 1, 9}) ||
+		!errors.As(err, &bad) || bad.Offset != 8 {
+		t.Errorf("got %#v", err)
+	}
+
+	// Syntax errors are reported first, and ignored trailing data is not
+	// checked.
+	_, _, err = CompareErr([]byte("[\"\xff\" 1]"), []byte(`[]`), &opts)
+	if errors.As(err, &bad) {
+		t.Errorf("got %v, expected a syntax error", err)
+	}
+	if diff, _ := Compare([]byte("[] \xff"), []byte(`[]`), &opts); diff != FullMatch {
+		t.Errorf("got %s with invalid UTF-8 after the value", diff)
+	}
+
+	for _, mode := range []InvalidUTF8Mode{InvalidUTF8Reject, InvalidUTF8Pass} {
+		diff, _, err := CompareReaders(strings.NewReader("[\"\xff\"]"), strings.NewReader("[\"\xfe\"]"),
+			&Options{InvalidUTF8: mode})
+		if expected := [...]Difference{InvalidUTF8Reject: BothArgsAreInvalidJson, InvalidUTF8Pass: NoMatch}[mode]; diff != expected {
+			t.Errorf("mode %d: CompareReaders got %s and %v, expected %s", mode, diff, err, expected)
+		}
+		if _, err := CompareStream(strings.NewReader("[]"), strings.NewReader("[]"), &Options{InvalidUTF8: mode}); err == nil {
+			t.Errorf("mode %d: CompareStream accepted", mode)
+		}
+	}
+	if err := (&Options{InvalidUTF8: 3}).Validate(); err == nil {
+		t.Error("unknown InvalidUTF8 mode accepted")
+	}
+}
+
+// utf16LE encodes s in UTF-16LE with a byte order mark.
+func utf16LE(s string) []byte {
+	b := []byte{0xff, 0xfe}
+	for _, u := range utf16.Encode([]rune(s)) {
+		b = append(b, byte(u), byte(u>>8))
+	}
+	return b
+}
+
+func TestByteOrderMark(t *testing.T) {
+	bom := "\xef\xbb\xbf"
+	cases := []struct {
+		a, b string
+		diff Difference
+	}{
+		{bom + `{"a": 1}`, `{"a": 1}`, FullMatch},
+		{`{"a": 1}`, bom + `{"a": 1}`, FullMatch},
+		{bom + `{"a": 1}`, bom + "\n{\"a\": 2}", NoMatch},
+		{bom + `[1, 2]`, `[1]`, SupersetMatch},
+		{bom, `null`, FirstArgIsInvalidJson},
+		{string(utf16LE(`{"a": 1}`)), `{"a": 1}`, FirstArgIsInvalidJson},
+		{`{"a": 1}`, "\xfe\xff\x00{\x00}", SecondArgIsInvalidJson},
+		{"\xff\xfe\x00\x00{", "\x00\x00\xfe\xff{", BothArgsAreInvalidJson},
+	}
+	for _, c := range cases {
+		for _, opts := range []Options{{}, {TrackPositions: true}, {LazyDecoding: true}} {
+			if diff, _ := Compare([]byte(c.a), []byte(c.b), &opts); diff != c.diff {
+				t.Errorf("%q, %q: got %s, expected %s", c.a, c.b, diff, c.diff)
+			}
+		}
+		if diff, _, err := CompareReaders(strings.NewReader(c.a), strings.NewReader(c.b), &Options{}); diff != c.diff || err != nil {
+			t.Errorf("%q, %q: CompareReaders got %s, %v, expected %s", c.a, c.b, diff, err, c.diff)
+		}
+		if diff, err := CompareStream(strings.NewReader(c.a), strings.NewReader(c.b), &Options{}); diff != c.diff || err != nil {
+			t.Errorf("%q, %q: CompareStream got %s, %v, expected %s", c.a, c.b, diff, err, c.diff)
+		}
+	}
+
+	// The byte order mark counts in offsets.
+	_, _, err := CompareErr([]byte(bom+`{"a": 1,}`), []byte(`{}`), &Options{})
+	var invalid *InvalidJSONError
+	if !errors.As(err, &invalid) || invalid.Position != (Position{11, 1, 12}) {
+		t.Errorf("got %v, expected a syntax error at offset 11", err)
+	}
+	_, entries := CompareEntries([]byte(bom+`{"a": 1}`), []byte(`{"a": 2}`), &Options{TrackPositions: true})
+	if len(entries) != 1 || *entries[0].OldPos != (Position{9, 1, 10}) || *entries[0].NewPos != (Position{6, 1, 7}) {
+		t.Errorf("got entries %+v", entries)
+	}
+
+	_, _, err = CompareErr(utf16LE(`{"a": 1}`), []byte(`{}`), &Options{})
+	var encoding *EncodingError
+	if !errors.As(err, &encoding) || encoding.Encoding != "UTF-16LE" {
+		t.Errorf("got %v, expected UTF-16LE to be named", err)
+	}
+}
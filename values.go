@@ -0,0 +1,168 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+)
+
+// CompareValues is like Compare for documents that are already decoded. The
+// values are expected to be what encoding/json decodes into an interface{}
+// with UseNumber: map[string]interface{}, []interface{}, string, bool, nil
+// and json.Number. Go numbers of any other type, e.g. float64 from decoding
+// without UseNumber, are converted to the json.Number encoding/json would
+// write for them, so float64(2) equals json.Number("2"); NaN and infinities
+// become "NaN", "+Inf" and "-Inf". Values of any other type are marshaled
+// with encoding/json and decoded again, and values that cannot be marshaled
+// are compared as strings in the %v format. a and b are never modified.
+func CompareValues(a, b interface{}, opts *Options) (Difference, string) {
+	return differOf(opts).CompareValues(a, b)
+}
+
+// CompareValues is like the package-level CompareValues, using the options
+// of d.
+func (d *Differ) CompareValues(a, b interface{}) (Difference, string) {
+	av, _ := normalize(a)
+	bv, _ := normalize(b)
+	return d.newContext(nil).compareValues(av, bv)
+}
+
+// CompareGo compares the JSON encodings of two Go values, as produced by
+// json.Marshal with its struct tags, omitempty and custom marshalers. An error
+// marshaling either value is returned, with a NoMatch Difference.
+func CompareGo(a, b interface{}, opts *Options) (Difference, string, error) {
+	return differOf(opts).CompareGo(a, b)
+}
+
+// CompareGo is like the package-level CompareGo, using the options of d.
+func (d *Differ) CompareGo(a, b interface{}) (Difference, string, error) {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return NoMatch, "", fmt.Errorf("jsondiff: marshaling first value: %w", err)
+	}
+	jb, err := json.Marshal(b)
+	if err != nil {
+		return NoMatch, "", fmt.Errorf("jsondiff: marshaling second value: %w", err)
+	}
+	diff, text := d.Compare(ja, jb)
+	return diff, text, nil
+}
+
+// CompareGoToJSON is like CompareGo for a Go value and a JSON document, e.g.
+// an expected literal. An error marshaling a is returned, while an invalid
+// document b is reported as SecondArgIsInvalidJson like by Compare.
+func CompareGoToJSON(a interface{}, b []byte, opts *Options) (Difference, string, error) {
+	return differOf(opts).CompareGoToJSON(a, b)
+}
+
+// CompareGoToJSON is like the package-level CompareGoToJSON, using the
+// options of d.
+func (d *Differ) CompareGoToJSON(a interface{}, b []byte) (Difference, string, error) {
+	ja, err := json.Marshal(a)
+	if err != nil {
+		return NoMatch, "", fmt.Errorf("jsondiff: marshaling first value: %w", err)
+	}
+	diff, text := d.Compare(ja, b)
+	return diff, text, nil
+}
+
+// normalize converts v to the types produced by decode and reports whether
+// anything was converted. Maps and slices are copied only if one of their
+// elements is converted.
+func normalize(v interface{}) (interface{}, bool) {
+	switch vv := v.(type) {
+	case nil, bool, string, json.Number:
+		return v, false
+	case map[string]interface{}:
+		var m map[string]interface{}
+		for k, e := range vv {
+			if n, changed := normalize(e); changed {
+				if m == nil {
+					m = make(map[string]interface{}, len(vv))
+					for k, e := range vv {
+						m[k] = e
+					}
+				}
+				m[k] = n
+			}
+		}
+		if m == nil {
+			return v, false
+		}
+		return m, true
+	case []interface{}:
+		var s []interface{}
+		for i, e := range vv {
+			if n, changed := normalize(e); changed {
+				if s == nil {
+					s = append([]interface{}(nil), vv...)
+				}
+				s[i] = n
+			}
+		}
+		if s == nil {
+			return v, false
+		}
+		return s, true
+	case float64:
+		return floatNumber(vv, 64), true
+	case float32:
+		return floatNumber(float64(vv), 32), true
+	case int:
+		return json.Number(strconv.FormatInt(int64(vv), 10)), true
+	case int8:
+		return json.Number(strconv.FormatInt(int64(vv), 10)), true
+	case int16:
+		return json.Number(strconv.FormatInt(int64(vv), 10)), true
+	case int32:
+		return json.Number(strconv.FormatInt(int64(vv), 10)), true
+	case int64:
+		return json.Number(strconv.FormatInt(vv, 10)), true
+	case uint:
+		return json.Number(strconv.FormatUint(uint64(vv), 10)), true
+	case uint8:
+		return json.Number(strconv.FormatUint(uint64(vv), 10)), true
+	case uint16:
+		return json.Number(strconv.FormatUint(uint64(vv), 10)), true
+	case uint32:
+		return json.Number(strconv.FormatUint(uint64(vv), 10)), true
+	case uint64:
+		return json.Number(strconv.FormatUint(vv, 10)), true
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v), true
+	}
+	n, err := decode(data, nil)
+	if err != nil {
+		return fmt.Sprintf("%v", v), true
+	}
+	return n, true
+}
+
+// floatNumber formats f like encoding/json does.
+func floatNumber(f float64, bits int) json.Number {
+	if math.IsNaN(f) {
+		return "NaN"
+	}
+	if math.IsInf(f, 0) {
+		return json.Number(strconv.FormatFloat(f, 'g', -1, bits))
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) || bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+	b := strconv.AppendFloat(nil, f, format, -1, bits)
+	if format == 'e' {
+		// Shorten e-09 to e-9.
+		n := len(b)
+		if n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return json.Number(b)
+}
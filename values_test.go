@@ -0,0 +1,191 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestCompareValues(t *testing.T) {
+	opts := Options{Indent: "  ", IgnoreFields: []string{"id"}}
+	var a, b interface{}
+	if err := json.Unmarshal([]byte(`{"id": 1, "n": 2, "f": 0.5, "list": [1, 2.5, "x"], "big": 1e21}`), &a); err != nil {
+		t.Fatal(err)
+	}
+	b = map[string]interface{}{
+		"id":   json.Number("7"),
+		"n":    2,
+		"f":    float32(0.5),
+		"list": []interface{}{uint8(1), json.Number("2.5"), "y"},
+		"big":  json.Number("1e+21"),
+	}
+	expected := `{
+  "list": [
+    "x" => "y"
+  ]
+}`
+	diff, text := CompareValues(a, b, &opts)
+	if diff != NoMatch || text != expected {
+		t.Errorf("got %s:\n%s\nexpected:\n%s", diff, text, expected)
+	}
+	if _, ok := a.(map[string]interface{})["n"].(float64); !ok {
+		t.Error("the input was modified")
+	}
+
+	type point struct {
+		X int `json:"x"`
+	}
+	diff, text = CompareValues(map[string]interface{}{"p": point{1}, "s": []string{"a"}},
+		map[string]interface{}{"p": map[string]interface{}{"x": 2.0}, "s": []interface{}{"a"}}, &opts)
+	expected = `{
+  "p": {
+    "x": 1 => 2
+  }
+}`
+	if diff != NoMatch || text != expected {
+		t.Errorf("got %s:\n%s\nexpected:\n%s", diff, text, expected)
+	}
+}
+
+func TestNormalizeNumbers(t *testing.T) {
+	cases := []struct {
+		in       interface{}
+		expected json.Number
+	}{
+		{float64(3), "3"},
+		{-0.25, "-0.25"},
+		{1e21, "1e+21"},
+		{1e-7, "1e-7"},
+		{float32(0.1), "0.1"},
+		{math.NaN(), "NaN"},
+		{math.Inf(-1), "-Inf"},
+		{int64(-9007199254740993), "-9007199254740993"},
+		{uint64(18446744073709551615), "18446744073709551615"},
+	}
+	for _, c := range cases {
+		got, changed := normalize(c.in)
+		if !changed || got != c.expected {
+			t.Errorf("%v: got %#v, expected %q", c.in, got, c.expected)
+		}
+	}
+	m := map[string]interface{}{"a": []interface{}{json.Number("1")}}
+	if got, changed := normalize(m); changed || reflect.ValueOf(got).Pointer() != reflect.ValueOf(m).Pointer() {
+		t.Error("a decoded tree was copied")
+	}
+}
+
+func TestCompareValuesUnusualTypes(t *testing.T) {
+	opts := Options{FuzzyFields: []string{"fuzzy"}}
+	cases := []struct {
+		a, b     interface{}
+		expected Difference
+	}{
+		{float64(1), int(1), FullMatch},
+		{float64(1), int(2), NoMatch},
+		{json.Number("1"), float64(1), FullMatch},
+		{float64(1), "1", NoMatch},
+		{true, int(1), NoMatch},
+		{[]interface{}{float64(0.5), int(3)}, []interface{}{json.Number("0.5"), uint8(3)}, FullMatch},
+		{map[string]interface{}{"fuzzy": float64(1)}, map[string]interface{}{"fuzzy": "x"}, FullMatch},
+		{map[string]interface{}{"fuzzy": float64(1)}, map[string]interface{}{"fuzzy": false}, NoMatch},
+	}
+	for _, c := range cases {
+		if diff, text := CompareValues(c.a, c.b, &opts); diff != c.expected {
+			t.Errorf("%#v, %#v: got %s:\n%s\nexpected %s", c.a, c.b, diff, text, c.expected)
+		}
+	}
+}
+
+func TestPrintDiffOtherTypes(t *testing.T) {
+	// Values of types the decoder does not produce only reach printDiff
+	// through internal callers; they match if they are deeply equal.
+	cases := []struct {
+		a, b     interface{}
+		expected Difference
+	}{
+		{float64(1), float64(1), FullMatch},
+		{float64(1), float64(2), NoMatch},
+		{float64(1), int(1), NoMatch},
+		{[]int{1}, []int{1}, FullMatch},
+		{[]int{1}, []interface{}{json.Number("1")}, NoMatch},
+	}
+	d := newDiffer(Options{})
+	for _, c := range cases {
+		ctx := d.newContext(nil)
+		diff, _ := ctx.compareValues(c.a, c.b)
+		if diff != c.expected {
+			t.Errorf("%#v, %#v: got %s, expected %s", c.a, c.b, diff, c.expected)
+		}
+		if eq := ctx.equal(c.a, c.b, ""); eq != (c.expected == FullMatch) {
+			t.Errorf("%#v, %#v: equal returned %v", c.a, c.b, eq)
+		}
+	}
+}
+
+type goBase struct {
+	ID      int    `json:"id"`
+	Comment string `json:"comment,omitempty"`
+}
+
+type goCelsius float64
+
+func (c goCelsius) MarshalJSON() ([]byte, error) {
+	return []byte(`"` + strconv.FormatFloat(float64(c), 'f', 1, 64) + `C"`), nil
+}
+
+type goItem struct {
+	goBase
+	Name  string    `json:"name"`
+	Temp  goCelsius `json:"temp"`
+	Tags  []string  `json:"tags,omitempty"`
+	Extra string    `json:"-"`
+}
+
+func TestCompareGo(t *testing.T) {
+	opts := Options{Indent: "  "}
+	a := goItem{goBase: goBase{ID: 1}, Name: "x", Temp: 21.5, Extra: "ignored"}
+	b := goItem{goBase: goBase{ID: 1, Comment: "new"}, Name: "x", Temp: 22, Tags: []string{"t"}}
+	diff, text, err := CompareGo(a, b, &opts)
+	expected := "{\n  \"comment\": \"new\",\n  \"tags\": [\n    \"t\"\n  ],\n  \"temp\": \"21.5C\" => \"22.0C\"\n}"
+	if err != nil || diff != NoMatch || text != expected {
+		t.Errorf("got %s %q %v, expected NoMatch %q", diff, text, err, expected)
+	}
+	if diff, _, err := CompareGo(b, a, &opts); err != nil || diff != NoMatch {
+		t.Errorf("got %s and %v, expected NoMatch", diff, err)
+	}
+	a.Extra = "different"
+	if diff, _, err := CompareGo(a, goItem{goBase: goBase{ID: 1}, Name: "x", Temp: 21.5}, &opts); err != nil || diff != FullMatch {
+		t.Errorf("got %s and %v, expected FullMatch", diff, err)
+	}
+	if _, _, err := CompareGo(a, make(chan int), &opts); err == nil || !strings.Contains(err.Error(), "second value") {
+		t.Errorf("got %v, expected a marshaling error", err)
+	}
+	if _, _, err := CompareGo(math.NaN(), 1, &opts); err == nil || !strings.Contains(err.Error(), "first value") {
+		t.Errorf("got %v, expected a marshaling error", err)
+	}
+}
+
+func TestCompareGoToJSON(t *testing.T) {
+	opts := Options{Indent: "  "}
+	item := goItem{goBase: goBase{ID: 1}, Name: "x", Temp: 21.5}
+	cases := []struct {
+		b    string
+		diff Difference
+	}{
+		{`{"id": 1, "name": "x", "temp": "21.5C"}`, FullMatch},
+		{`{"id": 1, "name": "x"}`, SupersetMatch},
+		{`{"id": 1, "name": "x", "temp": "21.5C", "tags": []}`, SubsetMatch},
+		{`{"id": `, SecondArgIsInvalidJson},
+	}
+	for _, c := range cases {
+		if diff, _, err := CompareGoToJSON(item, []byte(c.b), &opts); err != nil || diff != c.diff {
+			t.Errorf("%s: got %s and %v, expected %s", c.b, diff, err, c.diff)
+		}
+	}
+	if _, _, err := CompareGoToJSON(func() {}, []byte(`{}`), &opts); err == nil {
+		t.Error("expected a marshaling error")
+	}
+}
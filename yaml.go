@@ -0,0 +1,855 @@
+package jsondiff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"math"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// YAMLError reports why a document is not valid YAML or uses a YAML feature
+// CompareYAML does not support.
+type YAMLError struct {
+	// Document is 1 for the first and 2 for the second document.
+	Document int
+	// Position is where parsing failed.
+	Position Position
+	Msg      string
+}
+
+func (e *YAMLError) Error() string {
+	doc := "first"
+	if e.Document == 2 {
+		doc = "second"
+	}
+	return "jsondiff: " + doc + " document is invalid YAML at line " + strconv.Itoa(e.Position.Line) +
+		", column " + strconv.Itoa(e.Position.Column) + ": " + e.Msg
+}
+
+// CompareYAML is like Compare for two YAML documents. The documents are
+// parsed into the values Compare decodes from JSON and the output is
+// rendered as JSON. Plain scalars are resolved with the YAML 1.2 core
+// schema: null, booleans, integers and floats become JSON values, with
+// octal and hexadecimal integers converted to decimal and .inf and .nan to
+// "+Inf", "-Inf" and "NaN" numbers. Mapping keys are always strings as
+// written, so 1: and "1": are the same key, and keys that are collections
+// are rejected, as are duplicate keys. Aliases are expanded and merge keys
+// (<<) are applied. Each input must hold a single document, an error is
+// returned for a second one. Tags, directives and complex (?) keys are not
+// supported.
+//
+// If either document cannot be parsed, the Difference reports it like
+// Compare and the error is a *YAMLError, or both of them joined with
+// errors.Join.
+func CompareYAML(a, b []byte, opts *Options) (Difference, string, error) {
+	return differOf(opts).CompareYAML(a, b)
+}
+
+// CompareYAML is like the package-level CompareYAML, using the options of d.
+func (d *Differ) CompareYAML(a, b []byte) (Difference, string, error) {
+	ctx := d.newContext(nil)
+	av, errA := ctx.decodeYAML(a, 1)
+	bv, errB := ctx.decodeYAML(b, 2)
+	if diff, msg, invalid := invalidJSON(errA, errB); invalid {
+		var errs []error
+		for _, err := range []error{errA, errB} {
+			if err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return diff, msg, errors.Join(errs...)
+	}
+	diff, text := ctx.compareValues(av, bv)
+	return diff, text, ctx.limitError()
+}
+
+// decodeYAML parses the YAML document doc (1 or 2) held in data, recording
+// key order for ctx.
+func (ctx *context) decodeYAML(data []byte, doc int) (v interface{}, err error) {
+	p := yamlParser{data: data, order: ctx.order, anchors: make(map[string]interface{}), maxDepth: ctx.differ.maxDepth}
+	defer func() {
+		if r := recover(); r != nil {
+			e, ok := r.(*YAMLError)
+			if !ok {
+				panic(r)
+			}
+			e.Document = doc
+			err = e
+		}
+	}()
+	return p.parseDocument(), nil
+}
+
+// yamlParser parses the subset of YAML supported by CompareYAML. Errors are
+// raised as a panic with a *YAMLError, recovered by decodeYAML.
+type yamlParser struct {
+	data    []byte
+	pos     int
+	order   keyOrder
+	anchors map[string]interface{}
+	// depth is the number of collections being parsed, at most maxDepth.
+	depth    int
+	maxDepth int
+	// value is the offset of the last mapping value starting on the line
+	// of its key, or 0. No block mapping may start on that line.
+	value int
+}
+
+// enter counts a collection starting at p.pos, failing if it is nested too
+// deeply. Each call is paired with one of leave once the collection ends.
+func (p *yamlParser) enter() {
+	p.depth++
+	if p.depth > p.maxDepth {
+		p.fail(p.pos, "collections nested deeper than "+strconv.Itoa(p.maxDepth)+" levels")
+	}
+}
+
+func (p *yamlParser) leave() {
+	p.depth--
+}
+
+func (p *yamlParser) fail(off int, msg string) {
+	panic(&YAMLError{Position: positionAt(p.data, off), Msg: msg})
+}
+
+// at returns the byte at p.pos+i, or 0 past the end of the data.
+func (p *yamlParser) at(i int) byte {
+	if p.pos+i < len(p.data) {
+		return p.data[p.pos+i]
+	}
+	return 0
+}
+
+// col returns the zero-based column of p.pos.
+func (p *yamlParser) col() int {
+	return p.pos - (bytes.LastIndexByte(p.data[:p.pos], '\n') + 1)
+}
+
+// firstOnLine reports whether only whitespace precedes p.pos on its line.
+func (p *yamlParser) firstOnLine() bool {
+	start := bytes.LastIndexByte(p.data[:p.pos], '\n') + 1
+	return len(bytes.TrimLeft(p.data[start:p.pos], " \t")) == 0
+}
+
+func isYAMLBlank(c byte) bool {
+	return c == ' ' || c == '\t'
+}
+
+// isYAMLBreak reports whether c ends a line. 0 stands for the end of the
+// data.
+func isYAMLBreak(c byte) bool {
+	return c == '\n' || c == '\r' || c == 0
+}
+
+func isYAMLSpace(c byte) bool {
+	return isYAMLBlank(c) || isYAMLBreak(c)
+}
+
+// atBreak reports whether p.pos is at the end of a line or of the data.
+func (p *yamlParser) atBreak() bool {
+	return p.pos >= len(p.data) || p.data[p.pos] == '\n' || p.data[p.pos] == '\r'
+}
+
+// skipBreak skips a single line break.
+func (p *yamlParser) skipBreak() {
+	if p.at(0) == '\r' {
+		p.pos++
+	}
+	if p.at(0) == '\n' {
+		p.pos++
+	}
+}
+
+// skipSpace skips blanks and a comment up to the end of the line.
+func (p *yamlParser) skipSpace() {
+	for isYAMLBlank(p.at(0)) {
+		p.pos++
+	}
+	if p.at(0) == '#' && (p.pos == 0 || isYAMLSpace(p.data[p.pos-1])) {
+		for !p.atBreak() {
+			p.pos++
+		}
+	}
+}
+
+// skipBlank skips whitespace, comments and empty lines.
+func (p *yamlParser) skipBlank() {
+	for {
+		p.skipSpace()
+		if p.pos >= len(p.data) || !p.atBreak() {
+			return
+		}
+		p.skipBreak()
+	}
+}
+
+// atMarker reports whether p.pos is at the document marker m ("---" or
+// "...") at the start of a line.
+func (p *yamlParser) atMarker(m string) bool {
+	return p.col() == 0 && bytes.HasPrefix(p.data[p.pos:], []byte(m)) && isYAMLSpace(p.at(len(m)))
+}
+
+func (p *yamlParser) atDocumentEnd() bool {
+	return p.pos >= len(p.data) || p.atMarker("---") || p.atMarker("...")
+}
+
+// atSeqEntry reports whether p.pos is at the "-" of a block sequence entry.
+func (p *yamlParser) atSeqEntry() bool {
+	return p.at(0) == '-' && isYAMLSpace(p.at(1))
+}
+
+func (p *yamlParser) parseDocument() interface{} {
+	p.skipBlank()
+	if p.at(0) == '%' && p.col() == 0 {
+		p.fail(p.pos, "directives are not supported")
+	}
+	if p.atMarker("---") {
+		p.pos += 3
+	}
+	v := p.parseNode(-1)
+	p.skipBlank()
+	if p.atMarker("...") {
+		p.pos += 3
+		p.skipBlank()
+	}
+	switch {
+	case p.atMarker("---"):
+		p.fail(p.pos, "multiple documents are not supported")
+	case p.pos < len(p.data):
+		p.fail(p.pos, "unexpected content")
+	}
+	return v
+}
+
+// parseNode parses a block node indented more than indent, or returns nil if
+// there is none.
+func (p *yamlParser) parseNode(indent int) interface{} {
+	p.skipBlank()
+	if p.atDocumentEnd() || p.col() <= indent {
+		return nil
+	}
+	if p.firstOnLine() && bytes.IndexByte(p.data[p.pos-p.col():p.pos], '\t') >= 0 {
+		p.fail(p.pos, "tabs are not allowed in indentation")
+	}
+	col := p.col()
+	switch c := p.at(0); {
+	case c == '&':
+		name := p.parseName()
+		v := p.parseNode(indent)
+		p.anchors[name] = v
+		return v
+	case c == '*':
+		v := p.parseAlias()
+		p.skipSpace()
+		if p.at(0) == ':' {
+			p.fail(p.pos, "aliases as keys are not supported")
+		}
+		p.expectBreak()
+		return v
+	case c == '-' && isYAMLSpace(p.at(1)):
+		return p.parseBlockSeq(col)
+	case c == '[' || c == '{':
+		v := p.parseFlowNode()
+		p.skipSpace()
+		if p.at(0) == ':' {
+			p.fail(p.pos, "collections as keys are not supported")
+		}
+		p.expectBreak()
+		return v
+	case c == '|' || c == '>':
+		return p.parseBlockScalar(indent)
+	case c == '?' && isYAMLSpace(p.at(1)):
+		p.fail(p.pos, "complex keys are not supported")
+	}
+	start := p.pos
+	s, plain := p.parseScalar(false)
+	for isYAMLBlank(p.at(0)) {
+		p.pos++
+	}
+	if p.at(0) == ':' && isYAMLSpace(p.at(1)) {
+		if p.value > 0 && bytes.IndexByte(p.data[p.value:start], '\n') < 0 {
+			p.fail(p.pos, "mapping values are not allowed here")
+		}
+		return p.parseBlockMap(col, s, plain, start)
+	}
+	p.expectBreak()
+	if plain {
+		return resolveYAMLScalar(s)
+	}
+	return s
+}
+
+// expectBreak fails unless only blanks and a comment are left on the line.
+func (p *yamlParser) expectBreak() {
+	p.skipSpace()
+	if !p.atBreak() {
+		p.fail(p.pos, "unexpected content after a value")
+	}
+}
+
+// parseName parses the name of an anchor or alias after its '&' or '*'.
+func (p *yamlParser) parseName() string {
+	p.pos++
+	start := p.pos
+	for !isYAMLSpace(p.at(0)) && !strings.ContainsRune(",[]{}", rune(p.at(0))) {
+		p.pos++
+	}
+	if p.pos == start {
+		p.fail(start, "missing anchor name")
+	}
+	return string(p.data[start:p.pos])
+}
+
+func (p *yamlParser) parseAlias() interface{} {
+	start := p.pos
+	name := p.parseName()
+	v, ok := p.anchors[name]
+	if !ok {
+		p.fail(start, "unknown anchor "+strconv.Quote(name))
+	}
+	return v
+}
+
+// parseBlockMap parses a block mapping at column col, whose first key has
+// been parsed and is followed by its ':'.
+func (p *yamlParser) parseBlockMap(col int, key string, plain bool, keyStart int) map[string]interface{} {
+	p.enter()
+	defer p.leave()
+	m := make(map[string]interface{})
+	var keys []string
+	var merges []interface{}
+	var mergeStarts []int
+	for {
+		p.pos++ // ':'
+		p.skipSpace()
+		var v interface{}
+		if p.atBreak() {
+			p.skipBlank()
+			if !p.atDocumentEnd() && p.col() == col && p.atSeqEntry() {
+				// A sequence may be indented as much as its key.
+				v = p.parseBlockSeq(col)
+			} else {
+				v = p.parseNode(col)
+			}
+		} else {
+			if p.atSeqEntry() {
+				p.fail(p.pos, "a block sequence must start on a new line")
+			}
+			p.value = p.pos
+			v = p.parseNode(col)
+		}
+		if plain && key == "<<" {
+			merges = append(merges, v)
+			mergeStarts = append(mergeStarts, keyStart)
+		} else {
+			if _, dup := m[key]; dup {
+				p.fail(keyStart, "duplicate key "+strconv.Quote(key))
+			}
+			m[key] = v
+			keys = append(keys, key)
+		}
+		p.skipBlank()
+		if p.atDocumentEnd() || p.col() < col {
+			break
+		}
+		if p.col() > col || !p.firstOnLine() {
+			p.fail(p.pos, "unexpected indentation")
+		}
+		keyStart = p.pos
+		key, plain = p.parseKey()
+	}
+	for i, v := range merges {
+		sources := []interface{}{v}
+		if s, ok := v.([]interface{}); ok {
+			sources = s
+		}
+		for _, src := range sources {
+			sm, ok := src.(map[string]interface{})
+			if !ok {
+				p.fail(mergeStarts[i], "merge value is not a mapping")
+			}
+			for _, k := range p.keysOf(sm) {
+				if _, ok := m[k]; !ok {
+					m[k] = sm[k]
+					keys = append(keys, k)
+				}
+			}
+		}
+	}
+	if p.order != nil {
+		p.order.set(m, keys)
+	}
+	return m
+}
+
+// keysOf returns the keys of m in input order if it is known, or sorted.
+func (p *yamlParser) keysOf(m map[string]interface{}) []string {
+	if p.order != nil {
+		if keys := p.order.keys(m); keys != nil {
+			return keys
+		}
+	}
+	return sortedKeys(m)
+}
+
+// parseKey parses a key of a block mapping up to its ':'.
+func (p *yamlParser) parseKey() (string, bool) {
+	switch c := p.at(0); {
+	case c == '[' || c == '{':
+		p.fail(p.pos, "collections as keys are not supported")
+	case c == '?' && isYAMLSpace(p.at(1)):
+		p.fail(p.pos, "complex keys are not supported")
+	case c == '-' && isYAMLSpace(p.at(1)):
+		p.fail(p.pos, "unexpected sequence entry in a mapping")
+	case c == '&' || c == '*':
+		p.fail(p.pos, "anchors and aliases on keys are not supported")
+	}
+	s, plain := p.parseScalar(false)
+	for isYAMLBlank(p.at(0)) {
+		p.pos++
+	}
+	if p.at(0) != ':' || !isYAMLSpace(p.at(1)) {
+		p.fail(p.pos, "expected ':' after a key")
+	}
+	return s, plain
+}
+
+// parseBlockSeq parses a block sequence whose entries start at column col.
+func (p *yamlParser) parseBlockSeq(col int) []interface{} {
+	p.enter()
+	defer p.leave()
+	s := make([]interface{}, 0)
+	for {
+		p.pos++ // '-'
+		s = append(s, p.parseNode(col))
+		p.skipBlank()
+		if p.atDocumentEnd() || p.col() < col {
+			break
+		}
+		if p.col() > col || !p.firstOnLine() {
+			p.fail(p.pos, "unexpected indentation")
+		}
+		if !p.atSeqEntry() {
+			// The sequence was indented as much as its key and the
+			// next key follows.
+			break
+		}
+	}
+	return s
+}
+
+// parseScalar parses a quoted or plain scalar and reports whether it was
+// plain. In flow context plain scalars end at flow indicators.
+func (p *yamlParser) parseScalar(flow bool) (string, bool) {
+	switch c := p.at(0); c {
+	case '"':
+		return p.parseDoubleQuoted(), false
+	case '\'':
+		return p.parseSingleQuoted(), false
+	case '!':
+		p.fail(p.pos, "tags are not supported")
+	case '@', '`':
+		p.fail(p.pos, "reserved indicator "+string(c))
+	case '|', '>':
+		if flow {
+			p.fail(p.pos, "block scalars are not allowed in flow collections")
+		}
+	}
+	start, end := p.pos, p.pos
+	for !p.atBreak() {
+		c := p.at(0)
+		if c == ':' && (isYAMLSpace(p.at(1)) || flow && strings.IndexByte(",[]{}", p.at(1)) >= 0) {
+			break
+		}
+		if c == '#' && p.pos > start && isYAMLBlank(p.data[p.pos-1]) {
+			break
+		}
+		if flow && strings.IndexByte(",[]{}", c) >= 0 {
+			break
+		}
+		p.pos++
+		if !isYAMLBlank(c) {
+			end = p.pos
+		}
+	}
+	p.pos = end
+	return string(p.data[start:end]), true
+}
+
+// fold handles a line break at p.pos inside the quoted scalar starting at
+// start: trailing blanks are removed from buf, and the break with the leading
+// blanks of the following lines becomes a space, or the empty lines it is
+// followed by become line feeds.
+func (p *yamlParser) fold(buf *bytes.Buffer, start int) {
+	b := bytes.TrimRight(buf.Bytes(), " \t")
+	buf.Truncate(len(b))
+	p.skipBreak()
+	breaks := 0
+	for {
+		for isYAMLBlank(p.at(0)) {
+			p.pos++
+		}
+		if p.pos >= len(p.data) || !p.atBreak() {
+			break
+		}
+		p.skipBreak()
+		breaks++
+	}
+	if p.atDocumentEnd() {
+		p.fail(start, "unterminated string")
+	}
+	if breaks == 0 {
+		buf.WriteByte(' ')
+	}
+	for ; breaks > 0; breaks-- {
+		buf.WriteByte('\n')
+	}
+}
+
+func (p *yamlParser) parseSingleQuoted() string {
+	start := p.pos
+	p.pos++
+	var buf bytes.Buffer
+	for {
+		switch c := p.at(0); {
+		case p.pos >= len(p.data):
+			p.fail(start, "unterminated string")
+		case c == '\'' && p.at(1) == '\'':
+			buf.WriteByte('\'')
+			p.pos += 2
+		case c == '\'':
+			p.pos++
+			return buf.String()
+		case c == '\n' || c == '\r':
+			p.fold(&buf, start)
+		default:
+			buf.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+var yamlEscapes = map[byte]string{
+	'0': "\x00", 'a': "\a", 'b': "\b", 't': "\t", '\t': "\t", 'n': "\n", 'v': "\v",
+	'f': "\f", 'r': "\r", 'e': "\x1b", ' ': " ", '"': "\"", '/': "/", '\\': "\\",
+	'N': "\u0085", '_': "\u00a0", 'L': "\u2028", 'P': "\u2029",
+}
+
+func (p *yamlParser) parseDoubleQuoted() string {
+	start := p.pos
+	p.pos++
+	var buf bytes.Buffer
+	for {
+		switch c := p.at(0); {
+		case p.pos >= len(p.data):
+			p.fail(start, "unterminated string")
+		case c == '"':
+			p.pos++
+			return buf.String()
+		case c == '\n' || c == '\r':
+			p.fold(&buf, start)
+		case c == '\\':
+			p.parseEscape(&buf)
+		default:
+			buf.WriteByte(c)
+			p.pos++
+		}
+	}
+}
+
+// parseEscape parses an escape sequence in a double-quoted scalar.
+func (p *yamlParser) parseEscape(buf *bytes.Buffer) {
+	start := p.pos
+	c := p.at(1)
+	p.pos += 2
+	if s, ok := yamlEscapes[c]; ok {
+		buf.WriteString(s)
+		return
+	}
+	n := 0
+	switch c {
+	case '\n', '\r':
+		// An escaped line break joins the lines without a space.
+		p.pos--
+		p.skipBreak()
+		for isYAMLBlank(p.at(0)) {
+			p.pos++
+		}
+		return
+	case 'x':
+		n = 2
+	case 'u':
+		n = 4
+	case 'U':
+		n = 8
+	default:
+		p.fail(start, "invalid escape sequence")
+	}
+	if p.pos+n > len(p.data) {
+		p.fail(start, "invalid escape sequence")
+	}
+	r, err := strconv.ParseUint(string(p.data[p.pos:p.pos+n]), 16, 32)
+	if err != nil || !utf8.ValidRune(rune(r)) {
+		p.fail(start, "invalid escape sequence")
+	}
+	buf.WriteRune(rune(r))
+	p.pos += n
+}
+
+// parseBlockScalar parses a literal (|) or folded (>) block scalar of a node
+// indented more than indent.
+func (p *yamlParser) parseBlockScalar(indent int) string {
+	folded := p.at(0) == '>'
+	p.pos++
+	chomp := byte(0)
+	explicit := 0
+	for i := 0; i < 2; i++ {
+		switch c := p.at(0); {
+		case (c == '+' || c == '-') && chomp == 0:
+			chomp = c
+			p.pos++
+		case c >= '1' && c <= '9' && explicit == 0:
+			explicit = int(c - '0')
+			p.pos++
+		}
+	}
+	p.expectBreak()
+	p.skipBreak()
+	if indent < 0 {
+		indent = 0
+	}
+	contentIndent := 0
+	if explicit > 0 {
+		contentIndent = indent + explicit
+	}
+	var lines []string
+	finalBreak := false
+	for p.pos < len(p.data) {
+		lineStart := p.pos
+		spaces := 0
+		for p.at(spaces) == ' ' {
+			spaces++
+		}
+		end := lineStart + spaces
+		for end < len(p.data) && p.data[end] != '\n' && p.data[end] != '\r' {
+			end++
+		}
+		if strings.TrimSpace(string(p.data[lineStart+spaces:end])) == "" {
+			// Empty lines belong to the scalar until a less indented
+			// line ends it.
+			lines = append(lines, "")
+			p.pos = end
+			p.skipBreak()
+			continue
+		}
+		if contentIndent == 0 {
+			if spaces <= indent {
+				break
+			}
+			contentIndent = spaces
+		}
+		if spaces < contentIndent || p.col() == 0 && (p.atMarker("---") || p.atMarker("...")) {
+			break
+		}
+		lines = append(lines, string(p.data[lineStart+contentIndent:end]))
+		p.pos = end
+		finalBreak = p.pos < len(p.data)
+		p.skipBreak()
+	}
+	// Trailing empty lines are only kept with the keep chomping
+	// indicator.
+	trailing := 0
+	for len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+		trailing++
+	}
+	var buf bytes.Buffer
+	moreIndented := func(s string) bool {
+		return s != "" && isYAMLBlank(s[0])
+	}
+	for i, line := range lines {
+		if i > 0 {
+			switch {
+			case line == "":
+				buf.WriteByte('\n')
+			case folded && lines[i-1] != "" && !moreIndented(line) && !moreIndented(lines[i-1]):
+				buf.WriteByte(' ')
+			case folded && lines[i-1] == "" && !moreIndented(line) && i >= 2 && !moreIndented(lines[i-2]):
+				// The break before the empty lines is folded away.
+			default:
+				buf.WriteByte('\n')
+			}
+		}
+		buf.WriteString(line)
+	}
+	if len(lines) > 0 && chomp != '-' && finalBreak {
+		buf.WriteByte('\n')
+	}
+	if chomp == '+' {
+		buf.WriteString(strings.Repeat("\n", trailing))
+	}
+	return buf.String()
+}
+
+// parseFlowNode parses a node of a flow collection, or a whole flow
+// collection.
+func (p *yamlParser) parseFlowNode() interface{} {
+	p.skipFlowSpace()
+	switch c := p.at(0); c {
+	case '[':
+		return p.parseFlowSeq()
+	case '{':
+		return p.parseFlowMap()
+	case '&':
+		name := p.parseName()
+		v := p.parseFlowNode()
+		p.anchors[name] = v
+		return v
+	case '*':
+		return p.parseAlias()
+	}
+	s, plain := p.parseScalar(true)
+	if plain {
+		return resolveYAMLScalar(s)
+	}
+	return s
+}
+
+// skipFlowSpace skips whitespace, line breaks and comments in a flow
+// collection.
+func (p *yamlParser) skipFlowSpace() {
+	p.skipBlank()
+	if p.pos >= len(p.data) {
+		p.fail(p.pos, "unterminated flow collection")
+	}
+}
+
+func (p *yamlParser) parseFlowSeq() []interface{} {
+	p.enter()
+	defer p.leave()
+	p.pos++ // '['
+	s := make([]interface{}, 0)
+	for {
+		p.skipFlowSpace()
+		if p.at(0) == ']' {
+			p.pos++
+			return s
+		}
+		s = append(s, p.parseFlowNode())
+		p.skipFlowSpace()
+		switch p.at(0) {
+		case ',':
+			p.pos++
+		case ']':
+		case ':':
+			p.fail(p.pos, "mappings in flow sequences are not supported")
+		default:
+			p.fail(p.pos, "expected ',' or ']'")
+		}
+	}
+}
+
+func (p *yamlParser) parseFlowMap() map[string]interface{} {
+	p.enter()
+	defer p.leave()
+	p.pos++ // '{'
+	m := make(map[string]interface{})
+	var keys []string
+	for {
+		p.skipFlowSpace()
+		if p.at(0) == '}' {
+			p.pos++
+			break
+		}
+		keyStart := p.pos
+		switch c := p.at(0); c {
+		case '[', '{':
+			p.fail(p.pos, "collections as keys are not supported")
+		case '&', '*', '?':
+			p.fail(p.pos, "complex keys are not supported")
+		}
+		key, _ := p.parseScalar(true)
+		p.skipFlowSpace()
+		var v interface{}
+		if p.at(0) == ':' {
+			p.pos++
+			p.skipFlowSpace()
+			if c := p.at(0); c != ',' && c != '}' {
+				v = p.parseFlowNode()
+			}
+		}
+		if _, dup := m[key]; dup {
+			p.fail(keyStart, "duplicate key "+strconv.Quote(key))
+		}
+		m[key] = v
+		keys = append(keys, key)
+		p.skipFlowSpace()
+		switch p.at(0) {
+		case ',':
+			p.pos++
+		case '}':
+		default:
+			p.fail(p.pos, "expected ',' or '}'")
+		}
+	}
+	if p.order != nil {
+		p.order.set(m, keys)
+	}
+	return m
+}
+
+var (
+	yamlInt        = regexp.MustCompile(`^[-+]?[0-9]+$`)
+	yamlOctal      = regexp.MustCompile(`^0o[0-7]+$`)
+	yamlHex        = regexp.MustCompile(`^0x[0-9a-fA-F]+$`)
+	yamlFloat      = regexp.MustCompile(`^[-+]?(\.[0-9]+|[0-9]+(\.[0-9]*)?)([eE][-+]?[0-9]+)?$`)
+	jsonNumberRe   = regexp.MustCompile(`^-?(0|[1-9][0-9]*)(\.[0-9]+)?([eE][-+]?[0-9]+)?$`)
+	yamlInfinity   = map[string]bool{".inf": true, ".Inf": true, ".INF": true}
+	yamlNotANumber = map[string]bool{".nan": true, ".NaN": true, ".NAN": true}
+)
+
+// resolveYAMLScalar returns the value of a plain scalar under the YAML 1.2
+// core schema.
+func resolveYAMLScalar(s string) interface{} {
+	switch s {
+	case "", "~", "null", "Null", "NULL":
+		return nil
+	case "true", "True", "TRUE":
+		return true
+	case "false", "False", "FALSE":
+		return false
+	}
+	base := 0
+	switch {
+	case yamlInt.MatchString(s):
+		base = 10
+	case yamlOctal.MatchString(s):
+		s, base = s[2:], 8
+	case yamlHex.MatchString(s):
+		s, base = s[2:], 16
+	}
+	if base != 0 {
+		n, _ := new(big.Int).SetString(s, base)
+		return json.Number(n.String())
+	}
+	switch {
+	case yamlFloat.MatchString(s):
+		if t := strings.TrimPrefix(s, "+"); jsonNumberRe.MatchString(t) {
+			return json.Number(t)
+		}
+		f, _ := strconv.ParseFloat(s, 64)
+		return floatNumber(f, 64)
+	case yamlNotANumber[s]:
+		return floatNumber(math.NaN(), 64)
+	case yamlInfinity[strings.TrimPrefix(s, "+")]:
+		return floatNumber(math.Inf(1), 64)
+	case s[0] == '-' && yamlInfinity[s[1:]]:
+		return floatNumber(math.Inf(-1), 64)
+	}
+	return s
+}
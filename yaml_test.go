@@ -0,0 +1,160 @@
+package jsondiff
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// yamlValue parses a YAML document for tests.
+func yamlValue(t *testing.T, doc string) interface{} {
+	t.Helper()
+	ctx := newDiffer(Options{}).newContext(nil)
+	v, err := ctx.decodeYAML([]byte(doc), 1)
+	if err != nil {
+		t.Fatalf("%q: %v", doc, err)
+	}
+	return v
+}
+
+func TestDecodeYAMLMatchesJSON(t *testing.T) {
+	cases := []struct{ yaml, json string }{
+		{"a: 1\nb: two\n", `{"a": 1, "b": "two"}`},
+		{"# comment\n---\nname: x # trailing\nlist:\n  - 1\n  - -2.5\n  - true\n  - null\n  - ~\n  -\n",
+			`{"name": "x", "list": [1, -2.5, true, null, null, null]}`},
+		{"spec:\n  containers:\n  - name: app\n    image: nginx:1.25\n    ports:\n    - containerPort: 80\n",
+			`{"spec": {"containers": [{"name": "app", "image": "nginx:1.25", "ports": [{"containerPort": 80}]}]}}`},
+		{"- - a\n  - b\n- c: d\n", `[["a", "b"], {"c": "d"}]`},
+		{"- - a: 1\n    b: 2\n", `[[{"a": 1, "b": 2}]]`},
+		{"c: &x\n  d: 3\n", `{"c": {"d": 3}}`},
+		{`{"a": [1, 2, {"b": null}], "c": "d"}`, `{"a": [1, 2, {"b": null}], "c": "d"}`},
+		{"flow: {a: 1, b: [x, 'y', \"z\"], c}\nempty: []\n", `{"flow": {"a": 1, "b": ["x", "y", "z"], "c": null}, "empty": []}`},
+		{"quoted: 'it''s'\ndouble: \"tab\\tnew\\nline \\u00e9\"\nnum: '1'\n", `{"quoted": "it's", "double": "tab\tnew\nline é", "num": "1"}`},
+		{"folded: 'a\n  b\n\n  c'\n", `{"folded": "a b\nc"}`},
+		{"ints: [0o17, 0x1F, +5, 007, 12345678901234567890]\n", `{"ints": [15, 31, 5, 7, 12345678901234567890]}`},
+		{"floats: [1.5, 1e3, .5, 1., +2.0]\n", `{"floats": [1.5, 1e3, 0.5, 1, 2.0]}`},
+		{"bools: [True, FALSE, yes, no]\n", `{"bools": [true, false, "yes", "no"]}`},
+		{"keys:\n  1: a\n  true: b\n  null: c\n", `{"keys": {"1": "a", "true": "b", "null": "c"}}`},
+		{"literal: |\n  line 1\n   line 2\n\n  line 3\nnext: x\n", `{"literal": "line 1\n line 2\n\nline 3\n", "next": "x"}`},
+		{"folded: >-\n  a\n  b\n\n  c\n", `{"folded": "a b\nc"}`},
+		{"keep: |+\n  a\n\nstrip: |-\n  a\nexplicit: |2\n    indented\n", `{"keep": "a\n\n", "strip": "a", "explicit": "  indented\n"}`},
+		{"scalar\n...\n", `"scalar"`},
+		{"", `null`},
+	}
+	for _, c := range cases {
+		got := yamlValue(t, c.yaml)
+		expected, err := decode([]byte(c.json), nil)
+		if err != nil {
+			t.Fatalf("%s: %v", c.json, err)
+		}
+		if !Equal(mustMarshal(t, got), []byte(c.json), &Options{}) || !reflect.DeepEqual(got, expected) {
+			t.Errorf("%q: got %#v, expected %#v", c.yaml, got, expected)
+		}
+	}
+}
+
+func mustMarshal(t *testing.T, v interface{}) []byte {
+	t.Helper()
+	data, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestDecodeYAMLNumbers(t *testing.T) {
+	got := yamlValue(t, "[.inf, -.Inf, .NaN, 1.]")
+	expected := []interface{}{json.Number("+Inf"), json.Number("-Inf"), json.Number("NaN"), json.Number("1")}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %#v, expected %#v", got, expected)
+	}
+}
+
+func TestDecodeYAMLAnchors(t *testing.T) {
+	doc := `defaults: &defaults
+  image: base
+  replicas: 1
+list: &list [a, b]
+web:
+  <<: *defaults
+  replicas: 3
+worker:
+  <<: [*defaults, {image: other, queue: jobs}]
+copy: *list
+`
+	expected := map[string]interface{}{
+		"defaults": map[string]interface{}{"image": "base", "replicas": json.Number("1")},
+		"list":     []interface{}{"a", "b"},
+		"web":      map[string]interface{}{"image": "base", "replicas": json.Number("3")},
+		"worker":   map[string]interface{}{"image": "base", "replicas": json.Number("1"), "queue": "jobs"},
+		"copy":     []interface{}{"a", "b"},
+	}
+	if got := yamlValue(t, doc); !reflect.DeepEqual(got, expected) {
+		t.Errorf("got %#v, expected %#v", got, expected)
+	}
+}
+
+func TestCompareYAML(t *testing.T) {
+	opts := Options{Indent: "  ", IgnoreFields: []string{"generation"}}
+	a := "kind: Deployment\nmetadata:\n  name: web\n  generation: 4\nspec:\n  replicas: 2\n"
+	b := "kind: Deployment\nmetadata: {name: web, generation: 5}\nspec:\n  replicas: 3\n"
+	diff, text, err := CompareYAML([]byte(a), []byte(b), &opts)
+	expected := "{\n  \"spec\": {\n    \"replicas\": 2 => 3\n  }\n}"
+	if err != nil || diff != NoMatch || text != expected {
+		t.Errorf("got %s %q %v, expected NoMatch %q", diff, text, err, expected)
+	}
+	// YAML and the equivalent JSON compare equal.
+	j := `{"kind": "Deployment", "metadata": {"name": "web", "generation": 1}, "spec": {"replicas": 2}}`
+	if diff, _, err := CompareYAML([]byte(a), []byte(j), &opts); err != nil || diff != FullMatch {
+		t.Errorf("got %s and %v, expected FullMatch", diff, err)
+	}
+	opts.PreserveKeyOrder = true
+	diff, text, _ = CompareYAML([]byte("z: 1\na: 1\n"), []byte("z: 2\na: 2\n"), &opts)
+	if expected := "{\n  \"z\": 1 => 2,\n  \"a\": 1 => 2\n}"; diff != NoMatch || text != expected {
+		t.Errorf("got %s %q, expected NoMatch %q", diff, text, expected)
+	}
+}
+
+func TestCompareYAMLErrors(t *testing.T) {
+	cases := []struct {
+		doc, msg string
+		line     int
+	}{
+		{"a: 1\n---\nb: 2\n", "multiple documents", 2},
+		{"a: 1\na: 2\n", "duplicate key", 2},
+		{"? a\n: b\n", "complex keys", 1},
+		{"[a]: b\n", "collections as keys", 1},
+		{"a: !!str 1\n", "tags", 1},
+		{"a: *missing\n", "unknown anchor", 1},
+		{"a:\n\t- b\n", "tabs", 2},
+		{"a: 'open\n", "unterminated string", 1},
+		{"a: [1, 2\n", "unterminated flow collection", 2},
+		{"a: 1\n  b: 2\n", "unexpected indentation", 2},
+		{"<<: 1\n", "merge value", 1},
+		{"a: \"\\q\"\n", "escape", 1},
+		{"%YAML 1.2\n---\na: 1\n", "directives", 1},
+		{"a: - b\n", "new line", 1},
+		{"a: b: c\n", "mapping values are not allowed", 1},
+		{"a:\n  b: c: d\n", "mapping values are not allowed", 2},
+		{"a: &x b: c\n", "mapping values are not allowed", 1},
+		{"- a: b: c\n", "mapping values are not allowed", 1},
+	}
+	for _, c := range cases {
+		diff, _, err := CompareYAML([]byte(`{}`), []byte(c.doc), &Options{})
+		var ye *YAMLError
+		if diff != SecondArgIsInvalidJson || !errors.As(err, &ye) {
+			t.Errorf("%q: got %s and %v, expected an error", c.doc, diff, err)
+			continue
+		}
+		if ye.Document != 2 || ye.Position.Line != c.line || !strings.Contains(ye.Msg, c.msg) {
+			t.Errorf("%q: got %v, expected %q on line %d", c.doc, err, c.msg, c.line)
+		}
+	}
+	diff, _, err := CompareYAML([]byte("a: [\n"), []byte("a: 'x\n"), &Options{})
+	var ye *YAMLError
+	if diff != BothArgsAreInvalidJson || !errors.As(err, &ye) || !strings.Contains(err.Error(), "second document") {
+		t.Errorf("got %s and %v, expected both documents to be invalid", diff, err)
+	}
+}